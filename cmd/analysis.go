@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/cli/go-gh/pkg/auth"
+	"github.com/cli/go-gh/v2/pkg/repository"
+	"github.com/noamtamir/gh-octoscope/internal/reports"
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Formats the analysis commands, and report list and show, print in with --format
+var (
+	analysisFormats    = []string{reports.FormatTable, reports.FormatMarkdown, reports.FormatJSON}
+	analysisCSVFormats = []string{reports.FormatTable, reports.FormatCSV, reports.FormatMarkdown, reports.FormatJSON}
+	reportInfoFormats  = []string{reports.FormatTable, reports.FormatJSON}
+)
+
+// addFormatFlag adds the --format flag of commands printing an analysis to stdout. It
+// picks the one format the analysis is printed in, while report --output lists the
+// files to write.
+func addFormatFlag(flags *pflag.FlagSet, format *string, formats []string) {
+	last := len(formats) - 1
+	flags.StringVarP(format, "format", "f", reports.FormatTable, "Format printed to stdout: "+strings.Join(formats[:last], ", ")+" or "+formats[last])
+}
+
+// validateFormat ensures format is one of the formats a command prints in
+func validateFormat(format string, formats []string) error {
+	if !slices.Contains(formats, format) {
+		return fmt.Errorf("unsupported --format %q, must be one of: %s", format, strings.Join(formats, ", "))
+	}
+	return nil
+}
+
+// runAnalysis validates the format of an analysis and runs it. Status messages are
+// written to stderr, so the analysis printed to stdout can be piped.
+func runAnalysis(cmd *cobra.Command, format string, formats []string, run func() error) error {
+	if err := validateFormat(format, formats); err != nil {
+		return err
+	}
+	cmd.SilenceUsage = true
+	statusOut = os.Stderr
+	return run()
+}
+
+// currentGitHubCLIConfig returns the current repository and the GitHub CLI's token
+func currentGitHubCLIConfig() (GitHubCLIConfig, error) {
+	repo, err := repository.Current()
+	if err != nil {
+		return GitHubCLIConfig{}, fmt.Errorf("failed to get current repository: %w", err)
+	}
+	host, _ := auth.DefaultHost()
+	token, _ := auth.TokenForHost(host)
+	return GitHubCLIConfig{Token: token, Repo: repo}, nil
+}
+
+// loadOrFetch fetches the jobs of the current repository when fetch is set, saving them
+// for later commands when save is set, and otherwise loads the data saved by the last fetch
+func loadOrFetch(cfg Config, fetch, save bool, logger zerolog.Logger) ([]reports.JobDetails, error) {
+	if !fetch {
+		jobDetails, _, err := loadExistingData(cfg, logger)
+		return jobDetails, err
+	}
+	ghCLIConfig, err := currentGitHubCLIConfig()
+	if err != nil {
+		return nil, err
+	}
+	jobDetails, _, err := fetchAndProcessData(cfg, ghCLIConfig, logger, save)
+	return jobDetails, err
+}
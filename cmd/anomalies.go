@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/noamtamir/gh-octoscope/internal/reports"
+	"github.com/spf13/cobra"
+)
+
+// newAnomaliesCmd creates and returns the anomalies command
+func newAnomaliesCmd() *cobra.Command {
+	var format string
+	var topN int
+	var config reports.AnomalyConfig
+
+	var anomaliesCmd = &cobra.Command{
+		Use:   "anomalies",
+		Short: "Find jobs that ran unusually long and days with unusual spend",
+		Long: `The anomalies command analyzes previously fetched data for outliers.
+
+It builds a duration baseline for every job of every workflow and flags jobs that
+ran much longer than usual, e.g. a hung test. It also compares the spend of every
+day with the previous days and flags days with unusually high spend.
+
+Baselines use the median and the median absolute deviation (MAD), so a few outliers
+don't skew them. Every anomaly is listed with its run URL and the cost attributable
+to it. Run 'gh octoscope fetch' first to fetch the data.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAnalysis(cmd, format, analysisFormats, func() error {
+				return runAnomalies(cfg, config, format, topN)
+			})
+		},
+	}
+
+	addFormatFlag(anomaliesCmd.Flags(), &format, analysisFormats)
+	anomaliesCmd.Flags().IntVar(&topN, "top", reports.DefaultTopN, "Number of anomalies listed per kind")
+	anomaliesCmd.Flags().Float64Var(&config.Threshold, "threshold", reports.DefaultAnomalyThreshold, "Modified z-score above which a job or day is an anomaly")
+	anomaliesCmd.Flags().IntVar(&config.MinSamples, "min-samples", reports.DefaultAnomalyMinSamples, "Number of runs of a job, or previous days, needed to build a baseline")
+	anomaliesCmd.Flags().IntVar(&config.SpendWindow, "window", reports.DefaultAnomalySpendWindow, "Number of previous days in the daily spend baseline")
+
+	return anomaliesCmd
+}
+
+func runAnomalies(cfg Config, config reports.AnomalyConfig, format string, topN int) error {
+	logger := setupLogger()
+
+	jobDetails, _, err := loadExistingData(cfg, logger)
+	if err != nil {
+		return err
+	}
+
+	anomalies := reports.DetectAnomalies(jobDetails, config, cfg.Obfuscate)
+	return reports.NewAnomalyGenerator(os.Stdout, format, topN, logger).Generate(&anomalies)
+}
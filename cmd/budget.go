@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/noamtamir/gh-octoscope/internal/reports"
+	"github.com/spf13/cobra"
+)
+
+// defaultBudgetsPath is where budgets are read from when --budgets is not set
+const defaultBudgetsPath = ".octoscope/budgets.yml"
+
+// Budget states that make budget check fail, selected with --fail-on
+const (
+	failOnBreach = "breach"
+	failOnAtRisk = "at-risk"
+)
+
+// newBudgetCmd creates and returns the budget command
+func newBudgetCmd() *cobra.Command {
+	var budgetCmd = &cobra.Command{
+		Use:   "budget",
+		Short: "Work with GitHub Actions budgets",
+		Long: `Budgets are spending limits per org, repository, workflow or runner type,
+defined in a YAML file (default ` + defaultBudgetsPath + `, see --budgets):
+
+  budgets:
+    - name: Monthly repo budget
+      scope: repo            # org, repo, workflow or runner
+      match: my-org/my-repo  # empty matches everything
+      period: month          # day, week or month
+      amount: 500            # US dollars
+      thresholds: [50, 80, 100]
+
+Budgets that crossed a threshold, are projected to exceed their amount or
+already exceeded it are included in every report.`,
+	}
+
+	budgetCmd.AddCommand(newBudgetCheckCmd())
+
+	return budgetCmd
+}
+
+// newBudgetCheckCmd creates and returns the budget check subcommand
+func newBudgetCheckCmd() *cobra.Command {
+	var fetch bool
+	var format string
+	var failOn string
+
+	var checkCmd = &cobra.Command{
+		Use:   "check",
+		Short: "Check spend against budgets and fail when a budget is breached",
+		Long: `The check command evaluates every budget against the spend of its current
+period, projects the spend to the end of the period at the current run rate, and
+prints the status of every budget.
+
+It exits with a non-zero status when a budget is breached, or with --fail-on at-risk
+also when a budget is projected to be breached, so a scheduled workflow can fail or
+notify. Run 'gh octoscope fetch' first, or pass --fetch.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// A breach is an expected outcome, not a usage error, so usage isn't printed
+			return runAnalysis(cmd, format, analysisFormats, func() error {
+				return runBudgetCheck(cfg, fetch, format, failOn)
+			})
+		},
+	}
+
+	checkCmd.Flags().BoolVar(&fetch, "fetch", false, "Fetch new data before checking instead of using existing data, without saving it")
+	addFormatFlag(checkCmd.Flags(), &format, analysisFormats)
+	checkCmd.Flags().StringVar(&failOn, "fail-on", failOnBreach, "Budget state that fails the check: breach, at-risk")
+
+	return checkCmd
+}
+
+func runBudgetCheck(cfg Config, fetch bool, format, failOn string) error {
+	logger := setupLogger()
+
+	switch failOn {
+	case failOnBreach, failOnAtRisk:
+	default:
+		return fmt.Errorf("unsupported --fail-on %q, must be one of: %s, %s", failOn, failOnBreach, failOnAtRisk)
+	}
+
+	budgets, err := loadBudgets(cfg)
+	if err != nil {
+		return err
+	}
+	if len(budgets) == 0 {
+		return fmt.Errorf("no budgets found, create %s or pass --budgets", defaultBudgetsPath)
+	}
+
+	now := time.Now()
+	// Only fetch what the budgets need, unless --from asks for more. Fetched data isn't
+	// saved, so the data set other commands use isn't replaced by this narrower window.
+	if fetch && cfg.FromDate == "" {
+		cfg.FromDate = earliestBudgetPeriod(budgets, now).Format(time.DateOnly)
+	}
+	jobDetails, err := loadOrFetch(cfg, fetch, false, logger)
+	if err != nil {
+		return err
+	}
+
+	statuses := reports.EvaluateBudgets(jobDetails, budgets, now)
+	if err := reports.NewBudgetGenerator(os.Stdout, format, cfg.NoColor, logger).Generate(statuses); err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, s := range statuses {
+		if s.Breached() || (failOn == failOnAtRisk && s.State == reports.BudgetAtRisk) {
+			failed++
+		}
+	}
+	switch {
+	case failed == 0:
+		return nil
+	case failOn == failOnAtRisk:
+		return fmt.Errorf("%d budget(s) breached or at risk", failed)
+	default:
+		return fmt.Errorf("%d budget(s) breached", failed)
+	}
+}
+
+// loadBudgets reads the budgets file set with --budgets, or the default budgets file
+// when it exists. It returns no budgets when neither is present.
+func loadBudgets(cfg Config) ([]reports.Budget, error) {
+	path := cfg.BudgetsPath
+	if path == "" {
+		path = defaultBudgetsPath
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+	}
+
+	budgets, err := reports.LoadBudgets(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load budgets: %w", err)
+	}
+	return budgets, nil
+}
+
+// earliestBudgetPeriod returns the start of the earliest current period of the budgets
+func earliestBudgetPeriod(budgets []reports.Budget, now time.Time) time.Time {
+	earliest := now
+	for _, s := range reports.EvaluateBudgets(nil, budgets, now) {
+		if s.PeriodStart.Before(earliest) {
+			earliest = s.PeriodStart
+		}
+	}
+	return earliest
+}
@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/noamtamir/gh-octoscope/internal/api"
+	"github.com/noamtamir/gh-octoscope/internal/reports"
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+)
+
+// defaultTeamsPath is where teams are read from when --teams is not set
+const defaultTeamsPath = ".octoscope/teams.yml"
+
+// codeOwnersPaths are the locations GitHub looks for a CODEOWNERS file, in order
+var codeOwnersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// newChargebackCmd creates and returns the chargeback subcommand for the report command
+func newChargebackCmd() *cobra.Command {
+	var fetch bool
+	var format string
+	var teamsPath string
+	var codeOwnersPath string
+	var githubTeams bool
+	var month string
+
+	var chargebackCmd = &cobra.Command{
+		Use:   "chargeback",
+		Short: "Charge GitHub Actions costs back to the teams that own them",
+		Long: `The chargeback command splits the spend of every month by team and writes a
+cost statement per team, with spend no team owns listed as unallocated.
+
+Teams are defined in a YAML file (default ` + defaultTeamsPath + `, see --teams):
+
+  teams:
+    - name: Platform
+      repos: [my-org/infra, my-org/tooling-*]
+      workflows: [.github/workflows/deploy.yml]
+      actors: [alice, bob]
+      github_team: my-org/platform   # CODEOWNERS owner, and members with --github-teams
+  codeowners:
+    "@my-org/web-devs": Web          # CODEOWNERS owner to team
+
+A job is charged to the team that lists its workflow, else the CODEOWNERS owner of
+its workflow file, else the team that lists its repository, else the team of the
+actor who triggered it. CODEOWNERS is read from the working directory, or fetched
+from the repository when there is none locally. Owners that map to no team are
+teams of their own.
+
+Run 'gh octoscope fetch' first, or pass --fetch. Without --from, --fetch fetches
+from the start of --month, or of the current month, so its statement is complete.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAnalysis(cmd, format, analysisCSVFormats, func() error {
+				return runChargeback(cfg, fetch, format, teamsPath, codeOwnersPath, githubTeams, month)
+			})
+		},
+	}
+
+	chargebackCmd.Flags().BoolVar(&fetch, "fetch", false, "Fetch new data before charging back instead of using existing data")
+	addFormatFlag(chargebackCmd.Flags(), &format, analysisCSVFormats)
+	chargebackCmd.Flags().StringVar(&teamsPath, "teams", "", "Teams file (default "+defaultTeamsPath+" when it exists)")
+	chargebackCmd.Flags().StringVar(&codeOwnersPath, "codeowners", "", "CODEOWNERS file (default .github/CODEOWNERS, CODEOWNERS or docs/CODEOWNERS)")
+	chargebackCmd.Flags().BoolVar(&githubTeams, "github-teams", false, "Charge actors to teams they are a member of on GitHub, using github_team")
+	chargebackCmd.Flags().StringVar(&month, "month", "", "Only include one month, e.g. 2025-04")
+
+	return chargebackCmd
+}
+
+func runChargeback(cfg Config, fetch bool, format, teamsPath, codeOwnersPath string, githubTeams bool, month string) error {
+	logger := setupLogger()
+
+	now := time.Now().UTC()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if month != "" {
+		var err error
+		if monthStart, err = time.Parse("2006-01", month); err != nil {
+			return fmt.Errorf("invalid --month %q, must be YYYY-MM", month)
+		}
+	}
+
+	teams, err := loadTeams(teamsPath)
+	if err != nil {
+		return err
+	}
+
+	// The API is only needed to fetch data, CODEOWNERS or team members
+	var client api.Client
+	if ghCLIConfig, err := currentGitHubCLIConfig(); err == nil {
+		client = api.NewClient(ghCLIConfig.Repo, api.Config{PageSize: cfg.PageSize, Logger: logger, Token: ghCLIConfig.Token})
+	} else if fetch || githubTeams {
+		return err
+	}
+
+	codeOwners, err := loadCodeOwners(client, codeOwnersPath, logger)
+	if err != nil {
+		return err
+	}
+
+	var members map[string][]string
+	if githubTeams {
+		members, err = fetchTeamMembers(client, teams)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Fetch whole months from the start of --month, or of the current month, unless --from
+	// asks for more. Data fetched for this window isn't saved, so the data set other
+	// commands use isn't replaced by it.
+	save := true
+	if fetch && cfg.FromDate == "" {
+		cfg.FromDate = monthStart.Format(time.DateOnly)
+		save = false
+	}
+	jobDetails, err := loadOrFetch(cfg, fetch, save, logger)
+	if err != nil {
+		return err
+	}
+
+	statements := reports.BuildChargeback(jobDetails, reports.NewOwnershipResolver(teams, codeOwners, members))
+	if month != "" {
+		var filtered []reports.ChargebackStatement
+		for _, s := range statements {
+			if s.Month == month {
+				filtered = append(filtered, s)
+			}
+		}
+		statements = filtered
+	}
+	return reports.NewChargebackGenerator(os.Stdout, format, logger).Generate(statements)
+}
+
+// loadTeams reads the teams file set with --teams, or the default teams file when
+// it exists. It returns no teams when neither is present.
+func loadTeams(path string) (*reports.TeamFile, error) {
+	if path == "" {
+		path = defaultTeamsPath
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+	}
+
+	teams, err := reports.LoadTeams(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load teams: %w", err)
+	}
+	return teams, nil
+}
+
+// loadCodeOwners reads the CODEOWNERS file set with --codeowners, or the first
+// CODEOWNERS file in the working directory, or fetches it from the repository.
+// A repository without CODEOWNERS has no owners.
+func loadCodeOwners(client api.Client, path string, logger zerolog.Logger) (reports.CodeOwners, error) {
+	if path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CODEOWNERS: %w", err)
+		}
+		return reports.ParseCodeOwners(string(content)), nil
+	}
+
+	for _, p := range codeOwnersPaths {
+		if content, err := os.ReadFile(p); err == nil {
+			logger.Debug().Str("path", p).Msg("Using local CODEOWNERS")
+			return reports.ParseCodeOwners(string(content)), nil
+		}
+	}
+
+	if client == nil {
+		return nil, nil
+	}
+	for _, p := range codeOwnersPaths {
+		content, err := client.GetFileContent(context.Background(), p)
+		if err != nil {
+			logger.Debug().Err(err).Str("path", p).Msg("No CODEOWNERS in repository")
+			continue
+		}
+		logger.Debug().Str("path", p).Msg("Using CODEOWNERS from repository")
+		return reports.ParseCodeOwners(content), nil
+	}
+	return nil, nil
+}
+
+// fetchTeamMembers returns the logins of the members of every github_team in the teams file
+func fetchTeamMembers(client api.Client, teams *reports.TeamFile) (map[string][]string, error) {
+	members := make(map[string][]string)
+	if teams == nil {
+		return members, nil
+	}
+	for _, team := range teams.Teams {
+		if team.GitHubTeam == "" {
+			continue
+		}
+		org, slug, _ := strings.Cut(team.GitHubTeam, "/")
+		users, err := client.ListTeamMembers(context.Background(), org, slug)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list members of %s: %w", team.GitHubTeam, err)
+		}
+		for _, user := range users {
+			members[team.GitHubTeam] = append(members[team.GitHubTeam], user.GetLogin())
+		}
+	}
+	return members, nil
+}
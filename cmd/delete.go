@@ -3,7 +3,6 @@ package cmd
 import (
 	"context"
 	"fmt"
-	"os"
 
 	"github.com/cli/go-gh/pkg/auth"
 	"github.com/noamtamir/gh-octoscope/internal/api"
@@ -25,10 +24,7 @@ func newDeleteCmd() *cobra.Command {
 			token, _ := auth.TokenForHost(host)
 
 			// Get the API URL from environment or default
-			apiURL := os.Getenv("OCTOSCOPE_API_URL")
-			if apiURL == "" {
-				apiURL = "https://octoscope-server-production.up.railway.app"
-			}
+			apiURL := octoscopeAPIURL()
 
 			// Setup logger
 			logger := setupLogger()
@@ -46,6 +42,12 @@ func newDeleteCmd() *cobra.Command {
 				return fmt.Errorf("error deleting report: %w", err)
 			}
 
+			if history, err := reportHistory(); err == nil {
+				if err := history.Forget(reportID); err != nil {
+					logger.Warn().Err(err).Str("report_id", reportID).Msg("Failed to remove the report from the local history")
+				}
+			}
+
 			cmd.Printf("Report %s deleted successfully\n", reportID)
 			return nil
 		},
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/noamtamir/gh-octoscope/internal/reports"
+	"github.com/spf13/cobra"
+)
+
+// windowPattern matches a date window such as 2025-01-01..2025-01-31.
+// Either side may be omitted to leave the window open.
+var windowPattern = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})?\.\.(\d{4}-\d{2}-\d{2})?$`)
+
+// newDiffCmd creates and returns the diff command
+func newDiffCmd() *cobra.Command {
+	var format string
+	var topN int
+
+	var diffCmd = &cobra.Command{
+		Use:   "diff <base> <head>",
+		Short: "Compare GitHub Actions usage between two datasets or date windows",
+		Long: `The diff command compares usage and costs per workflow and per job, e.g. before
+and after a workflow change. It reports changes in run count, mean duration,
+billable minutes and cost, and flags new and removed jobs.
+
+Each of base and head is either a directory with previously fetched data
+(such as a copy of .reports/data), or a date window over the locally fetched
+data in the form YYYY-MM-DD..YYYY-MM-DD. Either side of a window may be
+omitted, e.g. 2025-03-01.. for everything since March 1st.`,
+		Example: `  gh octoscope diff 2025-02-01..2025-02-28 2025-03-01..2025-03-31
+  gh octoscope diff ./before/data ./after/data --format markdown`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAnalysis(cmd, format, analysisFormats, func() error {
+				return runDiff(cfg, args[0], args[1], format, topN)
+			})
+		},
+	}
+
+	addFormatFlag(diffCmd.Flags(), &format, analysisFormats)
+	diffCmd.Flags().IntVar(&topN, "top", reports.DefaultTopN, "Number of rows in the workflows and jobs tables")
+
+	return diffCmd
+}
+
+func runDiff(cfg Config, baseArg, headArg, format string, topN int) error {
+	logger := setupLogger()
+
+	calculator, err := newCalculator(cfg, logger)
+	if err != nil {
+		return err
+	}
+
+	// The local data set is only loaded once, even when comparing two of its windows
+	var localJobs []reports.JobDetails
+	load := func(arg string) ([]reports.JobDetails, error) {
+		m := windowPattern.FindStringSubmatch(arg)
+		if m == nil {
+			jobs, _, err := loadDataDir(arg, calculator, logger)
+			return jobs, err
+		}
+
+		var from, to time.Time
+		var err error
+		if m[1] != "" {
+			if from, err = time.Parse(time.DateOnly, m[1]); err != nil {
+				return nil, fmt.Errorf("invalid date window %q: %w", arg, err)
+			}
+		}
+		if m[2] != "" {
+			if to, err = time.Parse(time.DateOnly, m[2]); err != nil {
+				return nil, fmt.Errorf("invalid date window %q: %w", arg, err)
+			}
+		}
+		if localJobs == nil {
+			if localJobs, _, err = loadDataDir(reportsDirName+"/data", calculator, logger); err != nil {
+				return nil, err
+			}
+		}
+		return reports.JobsBetween(localJobs, from, to), nil
+	}
+
+	base, err := load(baseArg)
+	if err != nil {
+		return fmt.Errorf("failed to load base %s: %w", baseArg, err)
+	}
+	head, err := load(headArg)
+	if err != nil {
+		return fmt.Errorf("failed to load head %s: %w", headArg, err)
+	}
+	if len(base) == 0 && len(head) == 0 {
+		return fmt.Errorf("no jobs found in %s or %s", baseArg, headArg)
+	}
+
+	diff := reports.CompareJobs(strings.TrimSuffix(baseArg, "/"), base, strings.TrimSuffix(headArg, "/"), head, cfg.Obfuscate)
+	return reports.NewDiffGenerator(os.Stdout, format, topN, logger).Generate(&diff)
+}
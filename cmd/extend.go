@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cli/go-gh/pkg/auth"
+	"github.com/spf13/cobra"
+)
+
+// newExtendCmd creates and returns the extend subcommand for the report command
+func newExtendCmd() *cobra.Command {
+	var days int
+
+	var extendCmd = &cobra.Command{
+		Use:   "extend [reportID]",
+		Short: "Extend the expiry of a report on the Octoscope server",
+		Long: `Keep a report generated on the Octoscope server for --days more days, counted
+from its current expiry, or from now when it already expired.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if days <= 0 {
+				return errors.New("--days must be positive")
+			}
+			cmd.SilenceUsage = true
+			reportID := args[0]
+
+			logger := setupLogger()
+			host, _ := auth.DefaultHost()
+			token, _ := auth.TokenForHost(host)
+			client := newOctoscopeClient(token, logger)
+
+			ctx := context.Background()
+			info, err := client.GetReport(ctx, reportID)
+			if err != nil {
+				return fmt.Errorf("error getting report: %w", err)
+			}
+			if info.ExpiresAt == nil {
+				return fmt.Errorf("report %s doesn't expire", reportID)
+			}
+
+			expiresAt := time.Now().UTC()
+			if info.ExpiresAt.After(expiresAt) {
+				expiresAt = *info.ExpiresAt
+			}
+			info, err = client.ExtendReport(ctx, reportID, expiresAt.AddDate(0, 0, days))
+			if err != nil {
+				return fmt.Errorf("error extending report: %w", err)
+			}
+			updateHistory(withReportURL(*info)[0], logger)
+
+			fmt.Fprintln(statusOut, createSuccessMessage(fmt.Sprintf("Report %s now expires on %s", reportID, info.ExpiresAt.UTC().Format("2006-01-02 15:04 MST"))))
+			return nil
+		},
+	}
+
+	extendCmd.Flags().IntVar(&days, "days", 30, "Number of days to keep the report for")
+
+	return extendCmd
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/noamtamir/gh-octoscope/internal/reports"
+	"github.com/spf13/cobra"
+)
+
+// newFlakyCmd creates and returns the flaky command
+func newFlakyCmd() *cobra.Command {
+	var format string
+	var topN int
+	var sortBy string
+
+	var flakyCmd = &cobra.Command{
+		Use:   "flaky",
+		Short: "Find jobs that failed and then passed on a re-run of the same commit",
+		Long: `The flaky command finds jobs that failed in one attempt of a run and succeeded
+in the next attempt. All attempts of a run build the same head SHA, so the job
+passed and failed on the same commit.
+
+Jobs are ranked by flake rate, the share of their runs that flaked, or by the
+cost of the retries that succeeded. Run 'gh octoscope fetch' first to fetch the
+data, including the previous attempts of re-run workflows.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAnalysis(cmd, format, analysisCSVFormats, func() error {
+				return runFlaky(cfg, format, sortBy, topN)
+			})
+		},
+	}
+
+	addFormatFlag(flakyCmd.Flags(), &format, analysisCSVFormats)
+	flakyCmd.Flags().IntVar(&topN, "top", reports.DefaultTopN, "Number of jobs listed in the table and markdown formats")
+	flakyCmd.Flags().StringVar(&sortBy, "sort", string(reports.FlakyByRate), "Rank jobs by flake rate or by retry cost: rate or cost")
+
+	return flakyCmd
+}
+
+func runFlaky(cfg Config, format, sortBy string, topN int) error {
+	logger := setupLogger()
+
+	flakySort, err := reports.ParseFlakySort(sortBy)
+	if err != nil {
+		return err
+	}
+
+	jobDetails, _, err := loadExistingData(cfg, logger)
+	if err != nil {
+		return err
+	}
+
+	flaky := reports.DetectFlaky(jobDetails, flakySort, cfg.Obfuscate)
+	return reports.NewFlakyGenerator(os.Stdout, format, topN, logger).Generate(flaky)
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/noamtamir/gh-octoscope/internal/api"
+	"github.com/noamtamir/gh-octoscope/internal/reports"
+	"github.com/rs/zerolog"
+)
+
+// newOctoscopeClient creates a client of the Octoscope server API authenticated with token
+func newOctoscopeClient(token string, logger zerolog.Logger) api.OctoscopeClient {
+	return api.NewOctoscopeClient(api.OctoscopeConfig{
+		BaseUrl:     octoscopeAPIURL(),
+		Logger:      logger,
+		GitHubToken: token,
+	})
+}
+
+// reportHistory returns the local history of reports generated on the server. It's kept
+// in the user's config directory, so reports of every repository are found again.
+func reportHistory() (*reports.ReportHistory, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the report history: %w", err)
+	}
+	return reports.NewReportHistory(filepath.Join(dir, "gh-octoscope", "history.json")), nil
+}
+
+// reportURL returns the URL a report is viewed at
+func reportURL(reportID string) string {
+	return octoscopeAppURL() + "/report/" + reportID
+}
+
+// withReportURL sets the URL of reports described by the server, which doesn't know
+// the app serving them. Private reports are opened with the view token the server
+// signed for their creator.
+func withReportURL(infos ...reports.ReportInfo) []reports.ReportInfo {
+	for i := range infos {
+		infos[i].URL = reportURL(infos[i].ReportID)
+		if infos[i].ViewToken != "" {
+			infos[i].URL += "?token=" + url.QueryEscape(infos[i].ViewToken)
+		}
+	}
+	return infos
+}
+
+// recordReport records a report of jobs just generated on the server in the local history,
+// as the server describes it when it can, since a refreshed report has more jobs than the
+// ones just uploaded. Failing to record it only warns, the report was generated either way.
+func recordReport(client api.OctoscopeClient, reportID string, jobs []reports.JobDetails, logger zerolog.Logger) {
+	info := reports.NewReportInfo(reportID, jobs, time.Now().UTC())
+	if described, err := client.GetReport(context.Background(), reportID); err == nil {
+		info = *described
+	} else {
+		logger.Debug().Err(err).Str("report_id", reportID).Msg("Server didn't describe the report")
+	}
+	updateHistory(withReportURL(info)[0], logger)
+}
+
+// updateHistory records a report in the local history, warning when it can't. View
+// tokens expire, so the history keeps the plain URL of reports.
+func updateHistory(info reports.ReportInfo, logger zerolog.Logger) {
+	info.URL, info.ViewToken = reportURL(info.ReportID), ""
+	history, err := reportHistory()
+	if err == nil {
+		err = history.Record(info)
+	}
+	if err != nil {
+		logger.Warn().Err(err).Str("report_id", info.ReportID).Msg("Failed to record the report in the local history")
+	}
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cli/go-gh/pkg/auth"
+	"github.com/noamtamir/gh-octoscope/internal/reports"
+	"github.com/spf13/cobra"
+)
+
+// newListCmd creates and returns the list subcommand for the report command
+func newListCmd() *cobra.Command {
+	var format string
+
+	var listCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the reports generated on the Octoscope server",
+		Long: `List the reports generated on the Octoscope server with your token, with their
+repository, the window of their jobs, when they were created and expire, and their URL.
+
+When the server can't list reports, the reports recorded in the local report
+history are listed instead. Every report generated from this machine is recorded
+there.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAnalysis(cmd, format, reportInfoFormats, func() error {
+				logger := setupLogger()
+				host, _ := auth.DefaultHost()
+				token, _ := auth.TokenForHost(host)
+
+				infos, err := newOctoscopeClient(token, logger).ListReports(context.Background())
+				if err != nil {
+					logger.Debug().Err(err).Msg("Failed to list reports on the server")
+					fmt.Fprintln(statusOut, createInfoMessage(fmt.Sprintf("Couldn't list reports on the server, listing the local report history instead: %v", err)))
+					history, err := reportHistory()
+					if err != nil {
+						return err
+					}
+					if infos, err = history.Reports(); err != nil {
+						return err
+					}
+				} else {
+					infos = withReportURL(infos...)
+				}
+
+				return reports.NewReportInfoGenerator(os.Stdout, format, time.Now(), logger).Generate(infos)
+			})
+		},
+	}
+
+	addFormatFlag(listCmd.Flags(), &format, reportInfoFormats)
+
+	return listCmd
+}
+
+// newShowCmd creates and returns the show subcommand for the report command
+func newShowCmd() *cobra.Command {
+	var format string
+
+	var showCmd = &cobra.Command{
+		Use:   "show [reportID]",
+		Short: "Show a report generated on the Octoscope server",
+		Long: `Show the repository, window, expiry, access and URL of a report generated on the
+Octoscope server. When the server can't describe it, the report is looked up in
+the local report history instead.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAnalysis(cmd, format, reportInfoFormats, func() error {
+				reportID := args[0]
+
+				logger := setupLogger()
+				host, _ := auth.DefaultHost()
+				token, _ := auth.TokenForHost(host)
+
+				info, err := newOctoscopeClient(token, logger).GetReport(context.Background(), reportID)
+				if err != nil {
+					history, historyErr := reportHistory()
+					if historyErr != nil {
+						return fmt.Errorf("error getting report: %w", err)
+					}
+					recorded, found, historyErr := history.Find(reportID)
+					if historyErr != nil || !found {
+						return fmt.Errorf("error getting report: %w", err)
+					}
+					fmt.Fprintln(statusOut, createInfoMessage(fmt.Sprintf("Couldn't get the report from the server, showing the local report history instead: %v", err)))
+					info = &recorded
+				} else {
+					info = &withReportURL(*info)[0]
+				}
+
+				return reports.NewReportInfoGenerator(os.Stdout, format, time.Now(), logger).GenerateDetails(*info)
+			})
+		},
+	}
+
+	addFormatFlag(showCmd.Flags(), &format, reportInfoFormats)
+
+	return showCmd
+}
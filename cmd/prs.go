@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/noamtamir/gh-octoscope/internal/reports"
+	"github.com/spf13/cobra"
+)
+
+// newPRsCmd creates and returns the prs command
+func newPRsCmd() *cobra.Command {
+	var format string
+	var topN int
+
+	var prsCmd = &cobra.Command{
+		Use:   "prs",
+		Short: "Show the CI cost of pull requests and their authors",
+		Long: `The prs command rolls the cost of workflow runs up to the pull requests they
+belong to. It lists the most expensive pull requests with their cost per push,
+the cost per author and the median CI cost of a merged pull request.
+
+Runs are resolved to pull requests while fetching, from the pull requests a run
+lists or by looking up its head commit (see --pull-requests). Pushes to the
+default branch are not attributed to pull requests. Run 'gh octoscope fetch'
+first to fetch the data.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAnalysis(cmd, format, analysisFormats, func() error {
+				return runPRs(cfg, format, topN)
+			})
+		},
+	}
+
+	addFormatFlag(prsCmd.Flags(), &format, analysisFormats)
+	prsCmd.Flags().IntVar(&topN, "top", reports.DefaultTopN, "Number of rows in the pull requests and authors tables")
+
+	return prsCmd
+}
+
+func runPRs(cfg Config, format string, topN int) error {
+	logger := setupLogger()
+
+	jobDetails, _, err := loadExistingData(cfg, logger)
+	if err != nil {
+		return err
+	}
+
+	costs := reports.AnalyzePRCosts(jobDetails, cfg.Obfuscate)
+	return reports.NewPRGenerator(os.Stdout, format, topN, logger).Generate(&costs)
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/noamtamir/gh-octoscope/internal/reports"
+	"github.com/spf13/cobra"
+)
+
+// newQueueCmd creates and returns the queue command
+func newQueueCmd() *cobra.Command {
+	var format string
+	var topN int
+
+	var queueCmd = &cobra.Command{
+		Use:   "queue",
+		Short: "Show how long jobs waited for a runner",
+		Long: `The queue command analyzes the time jobs spent queued, from creation until a
+runner picked them up, separately from the time they executed.
+
+It lists queue latency percentiles per runner type, per runner group and per hour
+of the day (UTC), to help size self-hosted runner pools and spot larger runners
+that are starved. Run 'gh octoscope fetch' first to fetch the data.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAnalysis(cmd, format, analysisFormats, func() error {
+				return runQueue(cfg, format, topN)
+			})
+		},
+	}
+
+	addFormatFlag(queueCmd.Flags(), &format, analysisFormats)
+	queueCmd.Flags().IntVar(&topN, "top", reports.DefaultTopN, "Number of rows in the runner type and runner group tables")
+
+	return queueCmd
+}
+
+func runQueue(cfg Config, format string, topN int) error {
+	logger := setupLogger()
+
+	jobDetails, _, err := loadExistingData(cfg, logger)
+	if err != nil {
+		return err
+	}
+
+	latency := reports.AnalyzeQueue(jobDetails, cfg.Obfuscate)
+	return reports.NewQueueGenerator(os.Stdout, format, topN, logger).Generate(&latency)
+}
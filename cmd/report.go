@@ -1,53 +1,176 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/cli/go-gh/pkg/auth"
 	"github.com/cli/go-gh/v2/pkg/repository"
+	"github.com/noamtamir/gh-octoscope/internal/reports"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
+// Report output formats accepted by --output
+const (
+	outputCSV      = "csv"
+	outputHTML     = "html"
+	outputJSON     = reports.FormatJSON
+	outputNDJSON   = reports.FormatNDJSON
+	outputMarkdown = reports.FormatMarkdown
+)
+
+var supportedOutputs = []string{outputCSV, outputHTML, outputJSON, outputNDJSON, outputMarkdown}
+
+// stdoutOutputs are the formats that can be written to stdout with --stdout
+var stdoutOutputs = []string{outputJSON, outputNDJSON, outputMarkdown}
+
+// validateOutputs ensures every requested output format is supported, and that
+// exactly one stdout-capable format is requested when writing to stdout
+func validateOutputs(outputs []string, toStdout bool) error {
+	if toStdout {
+		count := 0
+		for _, o := range outputs {
+			for _, s := range stdoutOutputs {
+				if o == s {
+					count++
+				}
+			}
+		}
+		if count != 1 {
+			return fmt.Errorf("--stdout requires exactly one of these output formats: %s", strings.Join(stdoutOutputs, ", "))
+		}
+	}
+
+	for _, o := range outputs {
+		supported := false
+		for _, s := range supportedOutputs {
+			if o == s {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			return fmt.Errorf("unsupported output format %q, must be one of: %s", o, strings.Join(supportedOutputs, ", "))
+		}
+	}
+	return nil
+}
+
 // newReportCmd creates and returns the report command
 func newReportCmd() *cobra.Command {
-	var fetch bool = true // By default, fetch is true
+	var fetch bool = true  // By default, fetch is true
+	var upload bool = true // By default, the full report is generated on the server
 
 	var reportCmd = &cobra.Command{
 		Use:   "report",
 		Short: "Generate reports based on GitHub Actions usage data",
 		Long: `The report command generates various types of reports based on GitHub Actions usage data.
-It can generate CSV or full reports with different levels of detail.`,
+It can generate CSV, self-contained HTML or full reports with different levels of detail.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// By default, if no subcommand is specified, we'll set the full report flag to true
-			cfg.FullReport = true
-
-			// Execute the root command's logic
-			host, _ := auth.DefaultHost()
-			token, _ := auth.TokenForHost(host)
-			repo, err := repository.Current()
-			if err != nil {
-				return fmt.Errorf("failed to get current repository: %w", err)
+			// unless the user opted out of uploading data to the server. Local reports are
+			// meant to stay offline, so they're only uploaded when --upload is passed.
+			if !cmd.Flags().Changed("upload") && wantsLocalReport() {
+				upload = false
 			}
-
-			ghCLIConfig := GitHubCLIConfig{
-				Token: token,
-				Repo:  repo,
+			cfg.FullReport = upload && !cfg.SummaryOnly
+			if err := validateReportID(cfg.ReportID, cfg.Refresh, cfg.FullReport); err != nil {
+				return err
 			}
 
-			// Run the application with fetchMode determined by the fetch flag
-			return Run(cfg, ghCLIConfig, fetch)
+			return runReport(fetch)
 		},
 	}
 
 	// Add flags specific to the report command
 	reportCmd.Flags().BoolVar(&cfg.CSVReport, "csv", false, "Generate CSV report")
+	addOutputFlags(reportCmd.Flags())
 	reportCmd.Flags().BoolVar(&fetch, "fetch", true, "Whether to fetch new data or use existing data")
+	reportCmd.Flags().BoolVar(&upload, "upload", true, "Whether to upload data to the server to generate a full hosted report, off by default with --csv, --html or --output")
+	reportCmd.Flags().StringVar(&cfg.ReportID, "report-id", "", "Generate the reports with this ID, replacing the data of the hosted report with this ID while keeping its URL")
+	reportCmd.Flags().BoolVar(&cfg.Refresh, "refresh", false, "Merge the jobs into the hosted report with --report-id instead of replacing its data, adding new jobs and updating the ones it has")
 	// Note: obfuscate is now a persistent flag defined in the root command
 
 	// Add subcommands
 	reportCmd.AddCommand(
 		newDeleteCmd(),
+		newUploadCmd(),
+		newListCmd(),
+		newShowCmd(),
+		newExtendCmd(),
+		newShareCmd(),
+		newTrendCmd(),
+		newChargebackCmd(),
 	)
 
 	return reportCmd
 }
+
+// addOutputFlags adds the flags shared by the report command and its report modes
+func addOutputFlags(flags *pflag.FlagSet) {
+	flags.BoolVar(&cfg.HTMLReport, "html", false, "Generate a self-contained offline HTML report")
+	flags.StringSliceVar(&cfg.Outputs, "output", nil, "Report formats to generate (alias --format): "+strings.Join(supportedOutputs, ", "))
+	flags.BoolVar(&cfg.Stdout, "stdout", false, "Write the "+strings.Join(stdoutOutputs, " or ")+" report to stdout instead of a file")
+	flags.BoolVar(&cfg.JobSummary, "job-summary", true, "Append the Markdown report to the job summary when running in GitHub Actions ($GITHUB_STEP_SUMMARY)")
+	flags.StringSliceVar(&cfg.GroupBy, "group-by", nil, "Add a grouped cost breakdown by these dimensions, e.g. workflow,runner")
+	flags.IntVar(&cfg.TopN, "top", reports.DefaultTopN, "Number of rows in top workflows and jobs tables")
+	flags.StringVar(&cfg.ForecastModel, "forecast-model", string(reports.ForecastAuto), "Model of the end-of-month forecast: auto, linear, weekday")
+	flags.BoolVar(&cfg.SummaryOnly, "summary-only", false, "Only print the summary in the terminal, without writing files or uploading to the server")
+
+	// --format is an alias of --output, e.g. report --format ndjson --stdout | jq
+	flags.SetNormalizeFunc(func(f *pflag.FlagSet, name string) pflag.NormalizedName {
+		if name == "format" {
+			name = "output"
+		}
+		return pflag.NormalizedName(name)
+	})
+}
+
+// wantsLocalReport returns whether a local report format was requested
+func wantsLocalReport() bool {
+	return cfg.CSVReport || cfg.HTMLReport || len(cfg.Outputs) > 0
+}
+
+// validateReportID ensures a report ID can be used in file names, and that only full
+// reports with a report ID are refreshed
+func validateReportID(reportID string, refresh, fullReport bool) error {
+	if reportID != "" && !reports.ValidReportID(reportID) {
+		return fmt.Errorf("invalid --report-id %q, must be up to 128 letters, digits, - and _", reportID)
+	}
+	if refresh && reportID == "" {
+		return errors.New("--refresh requires the --report-id of the report to refresh")
+	}
+	if refresh && !fullReport {
+		return errors.New("--refresh requires uploading the full report, it can't be combined with --upload=false or --summary-only")
+	}
+	return nil
+}
+
+// runReport validates the output flags, resolves the current repository and runs the report
+func runReport(fetch bool) error {
+	if err := validateOutputs(cfg.Outputs, cfg.Stdout); err != nil {
+		return err
+	}
+	if cfg.Stdout {
+		// Keep stdout clean for the report so it can be piped
+		statusOut = os.Stderr
+	}
+
+	host, _ := auth.DefaultHost()
+	token, _ := auth.TokenForHost(host)
+	repo, err := repository.Current()
+	if err != nil {
+		return fmt.Errorf("failed to get current repository: %w", err)
+	}
+
+	ghCLIConfig := GitHubCLIConfig{
+		Token: token,
+		Repo:  repo,
+	}
+
+	// Run the application with fetchMode determined by the fetch flag
+	return Run(cfg, ghCLIConfig, fetch)
+}
@@ -3,37 +3,65 @@ package cmd
 import (
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"time"
 
 	"github.com/cli/go-gh/pkg/auth"
 	"github.com/cli/go-gh/v2/pkg/repository"
+	"github.com/fatih/color"
 	"github.com/joho/godotenv"
+	"github.com/noamtamir/gh-octoscope/internal/billing"
+	"github.com/noamtamir/gh-octoscope/internal/reports"
 	"github.com/rs/zerolog"
 	"github.com/spf13/cobra"
 )
 
 // Config holds application configuration
 type Config struct {
-	Debug      bool
-	ProdLogger bool
-	FullReport bool
-	CSVReport  bool
-	FromDate   string
-	PageSize   int
-	Obfuscate  bool
+	Debug         bool
+	ProdLogger    bool
+	FullReport    bool
+	CSVReport     bool
+	HTMLReport    bool
+	Outputs       []string // Additional report formats requested via --output
+	Stdout        bool     // Write the machine-readable report to stdout instead of a file
+	JobSummary    bool     // Append the Markdown report to $GITHUB_STEP_SUMMARY when it's set
+	TopN          int      // Number of rows in "top N" tables
+	NoColor       bool     // Disable colored terminal output
+	SummaryOnly   bool     // Only print the terminal summary, without writing files or uploading
+	GroupBy       []string // Dimensions for an additional grouped breakdown in every report
+	TrendPeriod   string   // Bucket size of the trend report (day, week or month), empty for other reports
+	TrendBasis    string   // Whether trend buckets use the run or the job creation time
+	TrendWindow   int      // Number of previous periods in the trend's trailing average
+	BudgetsPath   string   // Budgets file, empty for the default one
+	ForecastModel string   // Model of the end-of-cycle forecast (auto, linear or weekday)
+	BillOn        string   // Part of a job's lifetime that is billed (total or execution)
+	PullRequests  bool     // Resolve runs to pull requests while fetching
+	Tags          bool     // Read cost tags from custom properties and workflow files while fetching
+	Concurrency   int      // Number of batches uploaded at once by report and sync
+	ReportID      string   // ID of the generated reports, empty for a new one
+	Refresh       bool     // Merge the full report's jobs into the existing report with ReportID
+	FromDate      string
+	PageSize      int
+	Obfuscate     bool
 }
 
 // GitHubCLIConfig holds GitHub CLI configuration
 type GitHubCLIConfig struct {
-	Token string
-	Repo  repository.Repository
+	Token      string
+	Repo       repository.Repository
+	HTTPClient *http.Client // optional, used for GitHub API calls instead of the default client
 }
 
 var (
 	// Config that will be used throughout the application
 	cfg = Config{
-		PageSize: 30,
+		PageSize:     30,
+		TopN:         reports.DefaultTopN,
+		PullRequests: true,
+		Tags:         true,
+		Concurrency:  reports.DefaultUploadConcurrency,
 	}
 
 	// Version information
@@ -52,6 +80,10 @@ It fetches workflow run data from the GitHub API and calculates the cost based
 on the runner types used.`,
 		Version: version,
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if cfg.NoColor {
+				color.NoColor = true
+			}
+
 			// Load environment variables from .env file
 			if err := godotenv.Load(); err != nil {
 				// This is expected in production, so just log in debug mode
@@ -86,6 +118,12 @@ on the runner types used.`,
 	rootCmd.PersistentFlags().StringVar(&cfg.FromDate, "from", "", "Generate report from this date. Format: YYYY-MM-DD")
 	rootCmd.PersistentFlags().IntVar(&cfg.PageSize, "page-size", 30, "Page size for GitHub API requests")
 	rootCmd.PersistentFlags().BoolVar(&cfg.Obfuscate, "obfuscate", false, "Obfuscate sensitive data in reports")
+	rootCmd.PersistentFlags().BoolVar(&cfg.NoColor, "no-color", false, "Disable colored output")
+	rootCmd.PersistentFlags().StringVar(&cfg.BillOn, "bill-on", string(billing.BillTotal), "Bill jobs on their total time including the queue, or on their execution time only: total or execution")
+	rootCmd.PersistentFlags().BoolVar(&cfg.PullRequests, "pull-requests", true, "Resolve runs to pull requests while fetching, looking up commits of runs that don't list one")
+	rootCmd.PersistentFlags().BoolVar(&cfg.Tags, "tags", true, "Read cost tags from repository custom properties and workflow files while fetching")
+	rootCmd.PersistentFlags().IntVar(&cfg.Concurrency, "upload-concurrency", reports.DefaultUploadConcurrency, "Number of batches uploaded at once by report and sync")
+	rootCmd.PersistentFlags().StringVar(&cfg.BudgetsPath, "budgets", "", "Budgets file (default "+defaultBudgetsPath+" when it exists)")
 
 	// Set version template
 	rootCmd.SetVersionTemplate(`Version: {{.Version}}
@@ -100,20 +138,51 @@ Built: ` + date + `
 		newReportCmd(),
 		newFetchCmd(),
 		newSyncCmd(),
+		newDiffCmd(),
+		newAnomaliesCmd(),
+		newWasteCmd(),
+		newFlakyCmd(),
+		newQueueCmd(),
+		newPRsCmd(),
+		newBudgetCmd(),
+		newServeMetricsCmd(),
+		newTracesCmd(),
+		newServerCmd(),
 	)
 
 	return rootCmd
 }
 
+// wantsOutput reports whether the given report format was requested,
+// either through its dedicated flag or through --output
+func (c Config) wantsOutput(format string) bool {
+	switch format {
+	case outputCSV:
+		if c.CSVReport {
+			return true
+		}
+	case outputHTML:
+		if c.HTMLReport {
+			return true
+		}
+	}
+	for _, o := range c.Outputs {
+		if o == format {
+			return true
+		}
+	}
+	return false
+}
+
 // setupLogger creates and configures a logger based on the application configuration
 func setupLogger() zerolog.Logger {
 	var writer io.Writer
 	if cfg.ProdLogger {
 		zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
-		writer = os.Stdout
+		writer = statusOut
 	} else {
 		writer = zerolog.ConsoleWriter{
-			Out:        os.Stdout,
+			Out:        statusOut,
 			TimeFormat: time.RFC3339,
 		}
 	}
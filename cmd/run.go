@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/google/go-github/v62/github"
@@ -22,12 +23,31 @@ const reportsDirName string = ".reports"
 func Run(cfg Config, ghCLIConfig GitHubCLIConfig, fetchMode bool) error {
 	logger := setupLogger()
 
+	// Validate the dimensions before fetching anything
+	groupBy, err := reports.ParseDimensions(cfg.GroupBy)
+	if err != nil {
+		return err
+	}
+	trendConfig, err := parseTrendConfig(cfg)
+	if err != nil {
+		return err
+	}
+	forecastModel, err := reports.ParseForecastModel(cfg.ForecastModel)
+	if err != nil {
+		return err
+	}
+	budgets, err := loadBudgets(cfg)
+	if err != nil {
+		return err
+	}
+
 	var jobDetails []reports.JobDetails
 	var totalCosts reports.TotalCosts
 
 	if fetchMode {
 		var err error
-		jobDetails, totalCosts, err = fetchData(cfg, ghCLIConfig, logger)
+		// Fetched data is saved for future use, unless only a summary was requested
+		jobDetails, totalCosts, err = fetchAndProcessData(cfg, ghCLIConfig, logger, !cfg.SummaryOnly)
 		if err != nil {
 			return err
 		}
@@ -37,32 +57,64 @@ func Run(cfg Config, ghCLIConfig GitHubCLIConfig, fetchMode bool) error {
 		s.Start()
 
 		var err error
-		jobDetails, totalCosts, err = loadExistingData()
+		jobDetails, totalCosts, err = loadExistingData(cfg, logger)
 
 		// Stop spinner and show success or error message
 		s.Stop()
 		if err != nil {
 			return err
 		}
-		fmt.Println(createSuccessMessage("Data loaded successfully."))
+		fmt.Fprintln(statusOut, createSuccessMessage("Data loaded successfully."))
 	}
 
-	if err := os.MkdirAll(reportsDirName, 0755); err != nil {
-		return err
+	reportData := &reports.ReportData{
+		Jobs:          jobDetails,
+		Totals:        totalCosts,
+		ObfuscateData: cfg.Obfuscate,
+		GroupBy:       groupBy,
+	}
+	if trendConfig != nil {
+		trend := reports.BuildTrend(jobDetails, *trendConfig, cfg.Obfuscate)
+		reportData.Trend = &trend
+	}
+	now := time.Now()
+	if len(budgets) > 0 {
+		reportData.Budgets = reports.EvaluateBudgets(jobDetails, budgets, now)
+	}
+	if forecast, ok := reports.BuildForecast(jobDetails, reports.ForecastConfig{Model: forecastModel}, now, cfg.Obfuscate); ok {
+		reportData.Forecast = &forecast
 	}
 
-	// Start spinner for report generation
-	s := createSpinner("Generating reports...")
-	s.Start()
+	if !cfg.SummaryOnly {
+		if err := os.MkdirAll(reportsDirName, 0755); err != nil {
+			return err
+		}
+
+		// Start spinner for report generation
+		s := createSpinner("Generating reports...")
+		s.Start()
 
-	err := generateReports(cfg, ghCLIConfig, jobDetails, totalCosts, logger)
+		err := generateReports(cfg, ghCLIConfig, reportData, logger)
 
-	// Stop spinner and show message
-	s.Stop()
-	if err != nil {
+		// Stop spinner and show message
+		s.Stop()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(statusOut, createSuccessMessage("Report generation completed."))
+	}
+
+	// Print the summary after every report
+	summary := reports.NewTerminalGenerator(statusOut, reports.TerminalConfig{
+		Width:     terminalWidth(),
+		NoColor:   cfg.NoColor,
+		TopN:      cfg.TopN,
+		OwnerName: ghCLIConfig.Repo.Owner,
+		RepoName:  ghCLIConfig.Repo.Name,
+	}, logger)
+	if err := summary.Generate(reportData); err != nil {
 		return err
 	}
-	fmt.Println(createSuccessMessage("Report generation completed."))
 
 	logger.Debug().
 		Str("total_duration", totalCosts.JobDuration.String()).
@@ -82,9 +134,10 @@ func fetchAndProcessData(cfg Config, ghCLIConfig GitHubCLIConfig, logger zerolog
 	// Create new throttled client with appropriate rate limits
 	ghClient := api.NewThrottledClient(ghCLIConfig.Repo, api.ThrottledClientConfig{
 		Config: api.Config{
-			PageSize: cfg.PageSize,
-			Logger:   logger,
-			Token:    ghCLIConfig.Token,
+			PageSize:   cfg.PageSize,
+			Logger:     logger,
+			Token:      ghCLIConfig.Token,
+			HTTPClient: ghCLIConfig.HTTPClient,
 		},
 		MaxConcurrentRequests: 5,               // Concurrent API calls
 		RequestsPerSecond:     5,               // 300 per minute (below GitHub's 5000/hour primary limit)
@@ -93,12 +146,14 @@ func fetchAndProcessData(cfg Config, ghCLIConfig GitHubCLIConfig, logger zerolog
 		RetryBackoff:          time.Second * 1, // Start with 1 second backoff
 	})
 
-	calculator := billing.NewCalculator(nil, logger)
+	calculator, err := newCalculator(cfg, logger)
+	if err != nil {
+		return nil, totalCosts, err
+	}
 	ctx := context.Background()
 
 	fromDate := time.Now().AddDate(0, 0, -7) // default to last 7 days
 	if cfg.FromDate != "" {
-		var err error
 		fromDate, err = time.Parse(time.DateOnly, cfg.FromDate)
 		if err != nil {
 			return nil, totalCosts, err
@@ -120,8 +175,19 @@ func fetchAndProcessData(cfg Config, ghCLIConfig GitHubCLIConfig, logger zerolog
 		s.Stop()
 		return nil, totalCosts, err
 	}
+	if cfg.PullRequests {
+		if err := ghClient.ResolvePullRequests(ctx, runsWithJobs, repoDetails.GetDefaultBranch()); err != nil {
+			s.Stop()
+			return nil, totalCosts, err
+		}
+	}
+	var repoTags map[string]string
+	var workflowTags map[string]map[string]string
+	if cfg.Tags {
+		repoTags, workflowTags = fetchTags(ctx, ghClient, runsWithJobs, logger)
+	}
 	s.Stop()
-	fmt.Println(createSuccessMessage("Data fetching completed!"))
+	fmt.Fprintln(statusOut, createSuccessMessage("Data fetching completed!"))
 
 	// Process the fetched runs and jobs
 	s = createSpinner("Processing data...")
@@ -131,6 +197,8 @@ func fetchAndProcessData(cfg Config, ghCLIConfig GitHubCLIConfig, logger zerolog
 		run := runWithJobs.Run
 		workflow := runWithJobs.Workflow
 
+		processed := len(jobDetails)
+
 		// Process main jobs
 		jobDetails, totalCosts = ProcessJobs(jobDetails, totalCosts, repoDetails, workflow, run, runWithJobs.Jobs, calculator)
 
@@ -138,10 +206,16 @@ func fetchAndProcessData(cfg Config, ghCLIConfig GitHubCLIConfig, logger zerolog
 		for _, attemptJobs := range runWithJobs.AttemptJobs {
 			jobDetails, totalCosts = ProcessJobs(jobDetails, totalCosts, repoDetails, workflow, run, attemptJobs, calculator)
 		}
+
+		tags := reports.MergeTags(repoTags, workflowTags[workflow.GetPath()])
+		for i := processed; i < len(jobDetails); i++ {
+			jobDetails[i].PullRequest = runWithJobs.PullRequest
+			jobDetails[i].Tags = tags
+		}
 	}
 
 	s.Stop()
-	fmt.Println(createSuccessMessage("Successfully processed data!"))
+	fmt.Fprintln(statusOut, createSuccessMessage("Successfully processed data!"))
 
 	// Save the data for future use without fetching again (optional)
 	if saveLocally {
@@ -152,17 +226,42 @@ func fetchAndProcessData(cfg Config, ghCLIConfig GitHubCLIConfig, logger zerolog
 		if err != nil {
 			logger.Warn().Err(err).Msg("Failed to save data for future use")
 		} else {
-			fmt.Println(createSuccessMessage("Data successfully saved for future use!"))
+			fmt.Fprintln(statusOut, createSuccessMessage("Data successfully saved for future use!"))
 		}
 	}
 
 	return jobDetails, totalCosts, nil
 }
 
-// fetchData is a wrapper that calls fetchAndProcessData with saveLocally=true
-// Kept for backward compatibility with the report command
-func fetchData(cfg Config, ghCLIConfig GitHubCLIConfig, logger zerolog.Logger) ([]reports.JobDetails, reports.TotalCosts, error) {
-	return fetchAndProcessData(cfg, ghCLIConfig, logger, true)
+// fetchTags returns the cost tags of the repository, from its custom properties, and of
+// every workflow of the runs, from its file in the default branch keyed by path. Tags
+// that can't be fetched are logged and left out, e.g. custom properties of a repository
+// that isn't owned by an organization.
+func fetchTags(ctx context.Context, client api.Client, runs []api.RunWithJobs, logger zerolog.Logger) (map[string]string, map[string]map[string]string) {
+	var repoTags map[string]string
+	values, err := client.GetCustomPropertyValues(ctx)
+	if err != nil {
+		logger.Debug().Err(err).Msg("Failed to get custom property values")
+	} else {
+		repoTags = reports.RepoTags(values)
+	}
+
+	workflowTags := make(map[string]map[string]string)
+	for _, run := range runs {
+		path := run.Workflow.GetPath()
+		if _, ok := workflowTags[path]; ok || path == "" {
+			continue
+		}
+		content, err := client.GetFileContent(ctx, path)
+		if err != nil {
+			// Failed lookups are cached too, so they are only tried once
+			logger.Debug().Err(err).Str("path", path).Msg("Failed to get workflow file")
+			workflowTags[path] = nil
+			continue
+		}
+		workflowTags[path] = reports.ParseWorkflowTags(content)
+	}
+	return repoTags, workflowTags
 }
 
 // saveData saves the fetched data to disk
@@ -210,20 +309,30 @@ func saveData(jobDetails []reports.JobDetails, totalCosts reports.TotalCosts) er
 	return nil
 }
 
-func loadExistingData() ([]reports.JobDetails, reports.TotalCosts, error) {
+// loadExistingData loads the data saved by the last fetch, billed on the basis set in cfg
+func loadExistingData(cfg Config, logger zerolog.Logger) ([]reports.JobDetails, reports.TotalCosts, error) {
+	calculator, err := newCalculator(cfg, logger)
+	if err != nil {
+		return nil, reports.TotalCosts{}, err
+	}
+	return loadDataDir(reportsDirName+"/data", calculator, logger)
+}
+
+// loadDataDir loads a data set previously saved by saveData from dataDir, recalculating
+// its costs with calculator
+func loadDataDir(dataDir string, calculator *billing.Calculator, logger zerolog.Logger) ([]reports.JobDetails, reports.TotalCosts, error) {
 	var jobDetails []reports.JobDetails
 	var totalCosts reports.TotalCosts
 
 	s := createSpinner("Checking for existing data...")
 	s.Start()
 
-	dataDir := reportsDirName + "/data"
 	if _, err := os.Stat(dataDir); os.IsNotExist(err) {
 		s.Stop()
 		return nil, totalCosts, fmt.Errorf("data directory %s does not exist. Run 'gh octoscope fetch' first", dataDir)
 	}
 	s.Stop()
-	fmt.Println(createInfoMessage("Found existing data directory."))
+	fmt.Fprintln(statusOut, createInfoMessage("Found existing data directory."))
 
 	s = createSpinner("Loading data files...")
 	s.Start()
@@ -268,21 +377,83 @@ func loadExistingData() ([]reports.JobDetails, reports.TotalCosts, error) {
 		return nil, totalCosts, fmt.Errorf("no job data found in %s", dataDir)
 	}
 
-	fmt.Println(createSuccessMessage(fmt.Sprintf("Successfully loaded %d jobs from existing data.", len(jobDetails))))
+	// Costs are recalculated, so saved data is billed on the current basis
+	jobDetails, totalCosts = rebillJobs(jobDetails, calculator, logger)
+
+	fmt.Fprintln(statusOut, createSuccessMessage(fmt.Sprintf("Successfully loaded %d jobs from existing data.", len(jobDetails))))
 	return jobDetails, totalCosts, nil
 }
 
-func generateReports(cfg Config, ghCLIConfig GitHubCLIConfig, jobDetails []reports.JobDetails, totalCosts reports.TotalCosts, logger zerolog.Logger) error {
-	reportData := &reports.ReportData{
-		Jobs:          jobDetails,
-		Totals:        totalCosts,
-		ObfuscateData: cfg.Obfuscate,
+// newCalculator creates a cost calculator with the default prices, billing on the basis set by --bill-on
+func newCalculator(cfg Config, logger zerolog.Logger) (*billing.Calculator, error) {
+	basis, err := billing.ParseBillingBasis(cfg.BillOn)
+	if err != nil {
+		return nil, err
+	}
+	prices := billing.DefaultPriceConfig()
+	prices.Basis = basis
+	return billing.NewCalculator(prices, logger), nil
+}
+
+// rebillJobs recalculates the cost and timings of previously processed jobs. Every job is
+// kept: only its cost and timings are replaced, and jobs that can't be recalculated keep
+// their saved cost.
+func rebillJobs(jobs []reports.JobDetails, calculator *billing.Calculator, logger zerolog.Logger) ([]reports.JobDetails, reports.TotalCosts) {
+	rebilled := make([]reports.JobDetails, len(jobs))
+	var totalCosts reports.TotalCosts
+	for i, job := range jobs {
+		// Jobs without timings can't be recalculated, so they are kept as saved
+		if job.Job != nil && job.Job.CreatedAt != nil && job.Job.CompletedAt != nil {
+			cost, runnerType, err := calculator.CalculateJobCost(job.Job)
+			if err != nil {
+				logger.Warn().Err(err).Int64("job_id", job.Job.GetID()).Msg("Failed to recalculate the cost of a saved job, keeping its saved cost")
+			} else {
+				job.JobDuration = cost.ActualDuration
+				job.RoundedUpJobDuration = cost.BillableDuration
+				job.QueueDuration = cost.QueueDuration
+				job.ExecutionDuration = cost.ExecutionDuration
+				job.PricePerMinuteInUSD = cost.PricePerMinute
+				job.BillableInUSD = cost.TotalBillableUSD
+				job.Runner = string(runnerType)
+			}
+		}
+		rebilled[i] = job
+		totalCosts.JobDuration += job.JobDuration
+		totalCosts.RoundedUpJobDuration += job.RoundedUpJobDuration
+		totalCosts.BillableInUSD += job.BillableInUSD
 	}
+	return rebilled, totalCosts
+}
 
-	// Generate a single reportID to be used for both CSV and full report if needed
-	reportID := uuid.New().String()
+// parseTrendConfig validates the trend options, returning nil when no trend was requested
+func parseTrendConfig(cfg Config) (*reports.TrendConfig, error) {
+	if cfg.TrendPeriod == "" {
+		return nil, nil
+	}
+	period, err := reports.ParseTrendPeriod(cfg.TrendPeriod)
+	if err != nil {
+		return nil, err
+	}
+	basis, err := reports.ParseTrendBasis(cfg.TrendBasis)
+	if err != nil {
+		return nil, err
+	}
+	return &reports.TrendConfig{
+		Period: period,
+		Basis:  basis,
+		Window: cfg.TrendWindow,
+	}, nil
+}
 
-	if cfg.CSVReport {
+func generateReports(cfg Config, ghCLIConfig GitHubCLIConfig, reportData *reports.ReportData, logger zerolog.Logger) error {
+	// Generate a single reportID to be used for both CSV and full report if needed, unless
+	// an existing report is uploaded again to keep its URL
+	reportID := cfg.ReportID
+	if reportID == "" {
+		reportID = uuid.New().String()
+	}
+
+	if cfg.wantsOutput(outputCSV) {
 		// Start spinner for CSV report generation
 		s := createSpinner("Generating CSV reports...")
 		s.Start()
@@ -301,77 +472,186 @@ func generateReports(cfg Config, ghCLIConfig GitHubCLIConfig, jobDetails []repor
 		if err != nil {
 			return err
 		}
-		fmt.Println(createSuccessMessage("CSV reports generated."))
+		fmt.Fprintln(statusOut, createSuccessMessage("CSV reports generated."))
 
-		// Get current working directory to create absolute paths
-		cwd, err := os.Getwd()
+		fmt.Fprintf(statusOut, "\nCSV Report: %s", fileLink(csvGen.GetJobsPath(), logger))
+		fmt.Fprintf(statusOut, "\nCSV Totals: %s", fileLink(csvGen.GetTotalsPath(), logger))
+		if reports.HasSteps(reportData.Jobs) {
+			fmt.Fprintf(statusOut, "\nCSV Steps: %s", fileLink(csvGen.GetStepsPath(), logger))
+		}
+		if len(reportData.GroupBy) > 0 {
+			fmt.Fprintf(statusOut, "\nCSV Grouped: %s", fileLink(csvGen.GetGroupedPath(), logger))
+		}
+		if reportData.Trend != nil {
+			fmt.Fprintf(statusOut, "\nCSV Trend: %s", fileLink(csvGen.GetTrendPath(), logger))
+		}
+		if reportData.Forecast != nil {
+			fmt.Fprintf(statusOut, "\nCSV Forecast: %s", fileLink(csvGen.GetForecastPath(), logger))
+		}
+		if len(reportData.Budgets) > 0 {
+			fmt.Fprintf(statusOut, "\nCSV Budgets: %s", fileLink(csvGen.GetBudgetsPath(), logger))
+		}
+		fmt.Fprint(statusOut, "\n\n")
+	}
+
+	if cfg.wantsOutput(outputHTML) {
+		s := createSpinner("Generating HTML report...")
+		s.Start()
+
+		htmlGen := reports.NewHTMLGeneratorWithFormat(
+			reportsDirName,
+			ghCLIConfig.Repo.Owner,
+			ghCLIConfig.Repo.Name,
+			reportID,
+			logger,
+		)
+		err := htmlGen.Generate(reportData)
+
+		s.Stop()
 		if err != nil {
-			logger.Warn().Err(err).Msg("Failed to get current working directory")
-			cwd = "" // Use relative paths if we can't get the current directory
+			return fmt.Errorf("failed to generate HTML report: %w", err)
 		}
+		fmt.Fprintln(statusOut, createSuccessMessage("HTML report generated."))
 
-		// Convert to absolute paths
-		jobsPath := csvGen.GetJobsPath()
-		totalsPath := csvGen.GetTotalsPath()
+		fmt.Fprintf(statusOut, "\nHTML Report: %s\n\n", fileLink(htmlGen.GetPath(), logger))
+	}
 
-		if cwd != "" && !filepath.IsAbs(jobsPath) {
-			jobsPath = filepath.Join(cwd, jobsPath)
-			totalsPath = filepath.Join(cwd, totalsPath)
+	for _, format := range []string{outputJSON, outputNDJSON} {
+		if !cfg.wantsOutput(format) {
+			continue
 		}
 
-		// Make the file paths clickable using the OSC 8 ANSI escape sequence
-		// Format: \033]8;;file:///path/to/file\033\\file path\033]8;;\033\\
-		jobsPathLink := fmt.Sprintf("\033]8;;file://%s\033\\%s\033]8;;\033\\", jobsPath, jobsPath)
-		totalsPathLink := fmt.Sprintf("\033]8;;file://%s\033\\%s\033]8;;\033\\", totalsPath, totalsPath)
+		if cfg.Stdout {
+			jsonGen := reports.NewJSONGenerator(os.Stdout, format, ghCLIConfig.Repo.Owner, ghCLIConfig.Repo.Name, reportID, logger)
+			if err := jsonGen.Generate(reportData); err != nil {
+				return err
+			}
+			continue
+		}
 
-		fmt.Printf("\nCSV Report: %s", jobsPathLink)
-		fmt.Printf("\nCSV Totals: %s\n\n", totalsPathLink)
-	}
+		s := createSpinner(fmt.Sprintf("Generating %s export...", strings.ToUpper(format)))
+		s.Start()
 
-	if cfg.FullReport {
-		apiBaseUrl := os.Getenv("OCTOSCOPE_API_URL")
-		appBaseUrl := os.Getenv("OCTOSCOPE_APP_URL")
+		jsonGen := reports.NewJSONGeneratorWithFormat(
+			reportsDirName,
+			format,
+			ghCLIConfig.Repo.Owner,
+			ghCLIConfig.Repo.Name,
+			reportID,
+			logger,
+		)
+		err := jsonGen.Generate(reportData)
 
-		if apiBaseUrl == "" {
-			apiBaseUrl = "https://octoscope-server-production.up.railway.app"
+		s.Stop()
+		if err != nil {
+			return err
 		}
-		if appBaseUrl == "" {
-			appBaseUrl = "https://octoscope.netlify.app"
+		fmt.Fprintln(statusOut, createSuccessMessage(fmt.Sprintf("%s export generated.", strings.ToUpper(format))))
+
+		fmt.Fprintf(statusOut, "\n%s Export: %s\n\n", strings.ToUpper(format), fileLink(jsonGen.GetPath(), logger))
+	}
+
+	if cfg.wantsOutput(outputMarkdown) {
+		if err := generateMarkdownReports(cfg, ghCLIConfig, reportData, reportID, logger); err != nil {
+			return err
+		}
+	}
+
+	// GitHub Actions exposes the job summary file through $GITHUB_STEP_SUMMARY
+	if summaryPath := os.Getenv("GITHUB_STEP_SUMMARY"); cfg.JobSummary && summaryPath != "" {
+		if err := appendJobSummary(summaryPath, cfg, ghCLIConfig, reportData, reportID, logger); err != nil {
+			return err
 		}
+	}
 
+	if cfg.FullReport {
 		// Start spinner for server report generation
-		s := createSpinner("Generating full report on server...")
+		message := "Generating full report on server..."
+		if cfg.Refresh {
+			message = "Refreshing full report on server..."
+		}
+		s := createSpinner(message)
 		s.Start()
 
-		osClient := api.NewOctoscopeClient(api.OctoscopeConfig{
-			BaseUrl:     apiBaseUrl,
-			Logger:      logger,
-			GitHubToken: ghCLIConfig.Token,
-		})
-
-		serverGen := reports.NewServerGenerator(osClient, reports.ServerConfig{
-			AppURL:    appBaseUrl,
-			OwnerName: ghCLIConfig.Repo.Owner,
-			RepoName:  ghCLIConfig.Repo.Name,
-			ReportID:  reportID, // Pass the same reportID used for CSV
-		}, logger)
+		// Pass the same reportID used for CSV
+		serverGen := newServerGenerator(ghCLIConfig.Token, ghCLIConfig.Repo.Owner, ghCLIConfig.Repo.Name, reportID,
+			cfg.Refresh, uploadOptions(cfg.Concurrency, s, message), logger)
 
 		err := serverGen.Generate(reportData)
 
 		// Stop spinner
 		s.Stop()
 		if err != nil {
+			printResumeHint(reportID)
 			return fmt.Errorf("failed to generate server report: %w", err)
 		}
-		fmt.Println(createSuccessMessage("Full report generated successfully on server."))
+		if cfg.Refresh {
+			fmt.Fprintln(statusOut, createSuccessMessage(fmt.Sprintf("Full report %s refreshed successfully on server.", reportID)))
+		} else {
+			fmt.Fprintln(statusOut, createSuccessMessage("Full report generated successfully on server."))
+		}
 
 		reportURL := serverGen.GetReportURL()
-		fmt.Printf("\nReport URL: %s\n\n", reportURL)
+		fmt.Fprintf(statusOut, "\nReport URL: %s\n\n", reportURL)
+		recordReport(newOctoscopeClient(ghCLIConfig.Token, logger), reportID, reportData.Jobs, logger)
 	}
 
 	return nil
 }
 
+// generateMarkdownReports writes the Markdown report to stdout or a file
+func generateMarkdownReports(cfg Config, ghCLIConfig GitHubCLIConfig, reportData *reports.ReportData, reportID string, logger zerolog.Logger) error {
+	owner, repo := ghCLIConfig.Repo.Owner, ghCLIConfig.Repo.Name
+
+	if cfg.Stdout {
+		mdGen := reports.NewMarkdownGenerator(os.Stdout, cfg.TopN, owner, repo, reportID, logger)
+		if err := mdGen.Generate(reportData); err != nil {
+			return err
+		}
+	} else {
+		mdGen := reports.NewMarkdownGeneratorWithFormat(reportsDirName, cfg.TopN, owner, repo, reportID, logger)
+		if err := mdGen.Generate(reportData); err != nil {
+			return err
+		}
+		fmt.Fprintln(statusOut, createSuccessMessage("Markdown report generated."))
+		fmt.Fprintf(statusOut, "\nMarkdown Report: %s\n\n", fileLink(mdGen.GetPath(), logger))
+	}
+	return nil
+}
+
+// appendJobSummary appends the Markdown report to the job summary of a GitHub Actions run
+func appendJobSummary(summaryPath string, cfg Config, ghCLIConfig GitHubCLIConfig, reportData *reports.ReportData, reportID string, logger zerolog.Logger) error {
+	summary, err := os.OpenFile(summaryPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open job summary: %w", err)
+	}
+	defer summary.Close()
+
+	mdGen := reports.NewMarkdownGenerator(summary, cfg.TopN, ghCLIConfig.Repo.Owner, ghCLIConfig.Repo.Name, reportID, logger)
+	if err := mdGen.Generate(reportData); err != nil {
+		return fmt.Errorf("failed to write job summary: %w", err)
+	}
+	fmt.Fprintln(statusOut, createSuccessMessage("Markdown report added to the job summary."))
+	return nil
+}
+
+// fileLink converts a report path to an absolute path and makes it clickable
+// in the terminal using the OSC 8 ANSI escape sequence
+func fileLink(path string, logger zerolog.Logger) string {
+	if !filepath.IsAbs(path) {
+		// Get current working directory to create absolute paths
+		cwd, err := os.Getwd()
+		if err != nil {
+			logger.Warn().Err(err).Msg("Failed to get current working directory")
+		} else {
+			path = filepath.Join(cwd, path)
+		}
+	}
+
+	// Format: \033]8;;file:///path/to/file\033\\file path\033]8;;\033\\
+	return fmt.Sprintf("\033]8;;file://%s\033\\%s\033]8;;\033\\", path, path)
+}
+
 // ProcessJobs processes workflow jobs and calculates costs
 // This function is exported for testing purposes
 func ProcessJobs(
@@ -397,6 +677,8 @@ func ProcessJobs(
 			Job:                  job,
 			JobDuration:          cost.ActualDuration,
 			RoundedUpJobDuration: cost.BillableDuration,
+			QueueDuration:        cost.QueueDuration,
+			ExecutionDuration:    cost.ExecutionDuration,
 			PricePerMinuteInUSD:  cost.PricePerMinute,
 			BillableInUSD:        cost.TotalBillableUSD,
 			Runner:               string(runnerType),
@@ -0,0 +1,269 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/cli/go-gh/pkg/auth"
+	"github.com/cli/go-gh/v2/pkg/repository"
+	"github.com/noamtamir/gh-octoscope/internal/reports"
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+)
+
+// metricsOptions are the options of the serve-metrics command
+type metricsOptions struct {
+	addr     string
+	textfile string
+	interval time.Duration
+	lookback time.Duration
+	once     bool
+}
+
+// newServeMetricsCmd creates and returns the serve-metrics command
+func newServeMetricsCmd() *cobra.Command {
+	var opts metricsOptions
+
+	var serveMetricsCmd = &cobra.Command{
+		Use:   "serve-metrics",
+		Short: "Expose GitHub Actions costs as Prometheus metrics",
+		Long: `The serve-metrics command fetches GitHub Actions data every --interval and
+serves the cost, billable minutes, job counts and durations of completed jobs as
+Prometheus metrics on /metrics, labelled by repo, workflow, runner type and
+conclusion, together with the health of the fetches.
+
+The first fetch starts at --from (default 7 days ago). Later fetches only go back
+--lookback before the last successful fetch, and jobs fetched again are counted
+once, so counters only grow while the command runs.
+
+With --textfile, the metrics are written to a file for the node_exporter textfile
+collector instead of being served. Add --once to fetch and write them a single
+time, e.g. from cron. Runs with --once keep the jobs they counted in a state file
+next to the text file (octoscope.prom.state.json for octoscope.prom), so each run
+only fetches from --lookback before the last one and counters keep growing across
+runs. Remove the state file to start counting again.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Keep stdout clean, the metrics go to the endpoint or the text file
+			statusOut = os.Stderr
+			if opts.once && opts.textfile == "" {
+				return errors.New("--once requires --textfile")
+			}
+			if opts.interval <= 0 {
+				return fmt.Errorf("invalid --interval %s, must be greater than 0", opts.interval)
+			}
+			cmd.SilenceUsage = true
+
+			host, _ := auth.DefaultHost()
+			token, _ := auth.TokenForHost(host)
+			repo, err := repository.Current()
+			if err != nil {
+				return fmt.Errorf("failed to get current repository: %w", err)
+			}
+			return runServeMetrics(cfg, GitHubCLIConfig{Token: token, Repo: repo}, opts)
+		},
+	}
+
+	serveMetricsCmd.Flags().StringVar(&opts.addr, "addr", ":9184", "Address to serve /metrics on")
+	serveMetricsCmd.Flags().StringVar(&opts.textfile, "textfile", "", "Write the metrics to this file, e.g. /var/lib/node_exporter/octoscope.prom, instead of serving them")
+	serveMetricsCmd.Flags().DurationVar(&opts.interval, "interval", 15*time.Minute, "Time between fetches")
+	serveMetricsCmd.Flags().DurationVar(&opts.lookback, "lookback", 6*time.Hour, "How far before the last successful fetch later fetches start, to pick up jobs that completed since")
+	serveMetricsCmd.Flags().BoolVar(&opts.once, "once", false, "Fetch once, write the text file and exit, keeping the counted jobs next to it for the next run")
+
+	return serveMetricsCmd
+}
+
+func runServeMetrics(cfg Config, ghCLIConfig GitHubCLIConfig, opts metricsOptions) error {
+	logger := setupLogger()
+
+	observer := newAPIObserver(http.DefaultTransport)
+	ghCLIConfig.HTTPClient = &http.Client{Transport: observer}
+	exporter := &metricsExporter{
+		cfg:         cfg,
+		ghCLIConfig: ghCLIConfig,
+		logger:      logger,
+		lookback:    opts.lookback,
+		observer:    observer,
+		store:       reports.NewMetricsStore(cfg.Obfuscate),
+		health:      reports.FetchHealth{RateLimitRemaining: -1},
+	}
+
+	if opts.once {
+		// Without the jobs of earlier runs, jobs leaving the --from window would
+		// decrease the counters, which Prometheus reads as counter resets
+		statePath := metricsStatePath(opts.textfile)
+		store, health, err := reports.LoadMetricsState(statePath, cfg.Obfuscate)
+		if err != nil {
+			return err
+		}
+		exporter.store, exporter.health = store, health
+		observer.requests.Store(health.APIRequests)
+
+		if err := exporter.fetch(); err != nil {
+			return err
+		}
+		if err := reports.SaveMetricsState(statePath, exporter.store, exporter.health); err != nil {
+			return fmt.Errorf("failed to save metrics state: %w", err)
+		}
+		return exporter.writeTextfile(opts.textfile)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	var server *http.Server
+	if opts.textfile == "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", exporter)
+		server = &http.Server{Addr: opts.addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serverErr <- err
+			}
+		}()
+		fmt.Fprintln(statusOut, createInfoMessage(fmt.Sprintf("Serving metrics on %s/metrics", opts.addr)))
+	}
+
+	ticker := time.NewTicker(opts.interval)
+	defer ticker.Stop()
+	for {
+		if err := exporter.fetch(); err != nil {
+			// A failed fetch is reported in the metrics, the next one may succeed
+			logger.Warn().Err(err).Msg("Failed to fetch data for metrics")
+		}
+		if opts.textfile != "" {
+			if err := exporter.writeTextfile(opts.textfile); err != nil {
+				logger.Warn().Err(err).Str("path", opts.textfile).Msg("Failed to write metrics text file")
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case err := <-serverErr:
+			return fmt.Errorf("failed to serve metrics: %w", err)
+		case <-ctx.Done():
+			if server == nil {
+				return nil
+			}
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			return server.Shutdown(shutdownCtx)
+		}
+	}
+}
+
+// metricsExporter fetches data incrementally and serves it as metrics
+type metricsExporter struct {
+	cfg         Config
+	ghCLIConfig GitHubCLIConfig
+	logger      zerolog.Logger
+	lookback    time.Duration
+	observer    *apiObserver
+
+	mu     sync.Mutex
+	store  *reports.MetricsStore
+	health reports.FetchHealth
+}
+
+// fetch fetches new data, from --lookback before the last successful fetch, into the store
+func (e *metricsExporter) fetch() error {
+	start := time.Now()
+	cfg := e.cfg
+	if !e.health.LastSuccess.IsZero() {
+		cfg.FromDate = e.health.LastSuccess.Add(-e.lookback).UTC().Format(time.DateOnly)
+	}
+	jobDetails, _, err := fetchAndProcessData(cfg, e.ghCLIConfig, e.logger, false)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.health.LastDuration = time.Since(start)
+	e.health.APIRequests = e.observer.requests.Load()
+	e.health.RateLimitRemaining = int(e.observer.remaining.Load())
+	if err != nil {
+		e.health.Failures++
+		return err
+	}
+	e.store.Add(jobDetails)
+	e.health.Successes++
+	e.health.LastSuccess = start
+	e.logger.Debug().Int("jobs", e.store.Jobs()).Msg("Updated metrics")
+	return nil
+}
+
+func (e *metricsExporter) write(w io.Writer) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return reports.WriteMetrics(w, e.store, e.health)
+}
+
+func (e *metricsExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", reports.MetricsContentType)
+	if err := e.write(w); err != nil {
+		e.logger.Debug().Err(err).Msg("Failed to write metrics response")
+	}
+}
+
+// writeTextfile writes the metrics to a temporary file next to path and renames it,
+// so node_exporter never reads a partially written file
+func (e *metricsExporter) writeTextfile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := e.write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// metricsStatePath returns the path of the state kept next to the text file by --once.
+// The node_exporter textfile collector only reads *.prom files, so it's ignored.
+func metricsStatePath(textfile string) string {
+	return textfile + ".state.json"
+}
+
+// apiObserver counts GitHub API requests and records the remaining rate limit
+type apiObserver struct {
+	next      http.RoundTripper
+	requests  atomic.Int64
+	remaining atomic.Int64
+}
+
+func newAPIObserver(next http.RoundTripper) *apiObserver {
+	o := &apiObserver{next: next}
+	o.remaining.Store(-1)
+	return o
+}
+
+func (o *apiObserver) RoundTrip(req *http.Request) (*http.Response, error) {
+	o.requests.Add(1)
+	resp, err := o.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining")); err == nil {
+		o.remaining.Store(int64(remaining))
+	}
+	return resp, nil
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/noamtamir/gh-octoscope/internal/server"
+	"github.com/spf13/cobra"
+)
+
+// Authentication modes of the server command
+const (
+	serverAuthGitHub = "github"
+	serverAuthTokens = "tokens"
+	serverAuthNone   = "none"
+)
+
+// serverOptions are the options of the server command
+type serverOptions struct {
+	addr         string
+	dataDir      string
+	auth         string
+	githubAPIURL string
+	githubOrgs   []string
+	anyAccount   bool
+	tokensFile   string
+	cacheTTL     time.Duration
+	reportDays   int
+}
+
+// newServerCmd creates and returns the server command
+func newServerCmd() *cobra.Command {
+	var opts serverOptions
+
+	var serverCmd = &cobra.Command{
+		Use:   "server",
+		Short: "Run a self-hosted Octoscope API server",
+		Long: `The server command runs an Octoscope API server compatible with the hosted one,
+so the report and sync commands can upload to a server inside your network. Point
+both OCTOSCOPE_API_URL and OCTOSCOPE_APP_URL at it, e.g. http://octoscope.internal:8888.
+
+Uploaded jobs are kept in --data-dir, and reports are served at /report/<report-id>.
+
+Requests are authenticated with the GitHub token the CLI sends (--auth github),
+restricted to members of --github-org, with a fixed set of tokens (--auth tokens),
+or not at all (--auth none). Accepting any GitHub account requires
+--allow-any-github-account, since anyone with a GitHub token could then upload
+and read reports shared by link. Only the creator of a report can
+change or delete it, while anyone with its ID can view it unless it was shared as
+private. Reports expire after --report-days, and can be extended by their creator.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			authenticator, err := newServerAuth(opts)
+			if err != nil {
+				return err
+			}
+			if opts.reportDays < 0 {
+				return errors.New("--report-days must not be negative")
+			}
+			cmd.SilenceUsage = true
+			return runServer(opts, authenticator)
+		},
+	}
+
+	serverCmd.Flags().StringVar(&opts.addr, "addr", ":8888", "Address to serve the API and reports on")
+	serverCmd.Flags().StringVar(&opts.dataDir, "data-dir", ".octoscope-server", "Directory uploaded jobs are kept in")
+	serverCmd.Flags().StringVar(&opts.auth, "auth", serverAuthGitHub, "How requests are authenticated: github, tokens or none")
+	serverCmd.Flags().StringVar(&opts.githubAPIURL, "github-api-url", "https://api.github.com", "GitHub API that validates tokens, e.g. https://github.example.com/api/v3 for GitHub Enterprise Server")
+	serverCmd.Flags().StringArrayVar(&opts.githubOrgs, "github-org", nil, "Only accept members of this GitHub organization, can be repeated")
+	serverCmd.Flags().BoolVar(&opts.anyAccount, "allow-any-github-account", false, "Accept any GitHub account with --auth github and no --github-org")
+	serverCmd.Flags().StringVar(&opts.tokensFile, "tokens-file", "", "File of accepted tokens, one '<name> <token>' pair per line, for --auth tokens")
+	serverCmd.Flags().DurationVar(&opts.cacheTTL, "token-cache-ttl", 10*time.Minute, "How long a GitHub token is trusted before validating it again")
+	serverCmd.Flags().IntVar(&opts.reportDays, "report-days", 30, "Days new reports are kept before they expire, 0 to keep them forever")
+
+	return serverCmd
+}
+
+// newServerAuth creates the authenticator selected in the options
+func newServerAuth(opts serverOptions) (server.Authenticator, error) {
+	switch opts.auth {
+	case serverAuthGitHub:
+		if len(opts.githubOrgs) == 0 && !opts.anyAccount {
+			return nil, errors.New("--auth github accepts any GitHub account unless --github-org is set, pass --allow-any-github-account to accept them")
+		}
+		return server.NewGitHubAuth(server.GitHubAuthConfig{
+			APIURL:   opts.githubAPIURL,
+			Orgs:     opts.githubOrgs,
+			CacheTTL: opts.cacheTTL,
+		}), nil
+	case serverAuthTokens:
+		if opts.tokensFile == "" {
+			return nil, errors.New("--auth tokens requires --tokens-file")
+		}
+		return server.LoadTokens(opts.tokensFile)
+	case serverAuthNone:
+		return server.NoAuth(), nil
+	}
+	return nil, fmt.Errorf("unsupported --auth %q, must be one of: %s, %s, %s", opts.auth, serverAuthGitHub, serverAuthTokens, serverAuthNone)
+}
+
+func runServer(opts serverOptions, authenticator server.Authenticator) error {
+	logger := setupLogger()
+
+	store, err := server.OpenStore(opts.dataDir, time.Duration(opts.reportDays)*24*time.Hour)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	httpServer := &http.Server{
+		Addr:              opts.addr,
+		Handler:           server.New(server.Config{Store: store, Auth: authenticator, Logger: logger}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	serverErr := make(chan error, 1)
+	go func() {
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+	fmt.Fprintln(statusOut, createInfoMessage(fmt.Sprintf("Serving the Octoscope API on %s, with %s authentication", opts.addr, opts.auth)))
+
+	select {
+	case err := <-serverErr:
+		return fmt.Errorf("failed to serve: %w", err)
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return httpServer.Shutdown(shutdownCtx)
+	}
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/cli/go-gh/pkg/auth"
+	"github.com/noamtamir/gh-octoscope/internal/reports"
+	"github.com/spf13/cobra"
+)
+
+// newShareCmd creates and returns the share subcommand for the report command
+func newShareCmd() *cobra.Command {
+	var access string
+
+	var shareCmd = &cobra.Command{
+		Use:   "share [reportID]",
+		Short: "Change who can view a report on the Octoscope server",
+		Long: `Change who can view a report generated on the Octoscope server: anyone with its
+URL (--access link, the default), or only you (--access private).
+
+Private reports are opened in a browser with a signed URL, valid for a day. The URL
+printed by this command and by 'report show' is signed for you.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !slices.Contains(reports.ReportAccesses, access) {
+				return fmt.Errorf("unsupported --access %q, must be one of: %s", access, strings.Join(reports.ReportAccesses, ", "))
+			}
+			cmd.SilenceUsage = true
+			reportID := args[0]
+
+			logger := setupLogger()
+			host, _ := auth.DefaultHost()
+			token, _ := auth.TokenForHost(host)
+
+			info, err := newOctoscopeClient(token, logger).ShareReport(context.Background(), reportID, access)
+			if err != nil {
+				return fmt.Errorf("error sharing report: %w", err)
+			}
+			shared := withReportURL(*info)[0]
+			updateHistory(shared, logger)
+
+			message := fmt.Sprintf("Report %s can be viewed by anyone with its URL.", reportID)
+			if access == reports.AccessPrivate {
+				message = fmt.Sprintf("Report %s can only be viewed by you. Its URL opens it in a browser for a day, get a new one with: gh octoscope report show %s", reportID, reportID)
+			}
+			fmt.Fprintln(statusOut, createSuccessMessage(message))
+			fmt.Fprintf(statusOut, "\nReport URL: %s\n\n", shared.URL)
+			return nil
+		},
+	}
+
+	shareCmd.Flags().StringVar(&access, "access", reports.AccessLink, "Who can view the report: "+strings.Join(reports.ReportAccesses, " or "))
+
+	return shareCmd
+}
@@ -1,16 +1,24 @@
 package cmd
 
 import (
+	"io"
+	"os"
 	"time"
 
 	"github.com/briandowns/spinner"
+	"github.com/cli/go-gh/v2/pkg/term"
 	"github.com/fatih/color"
 )
 
+// statusOut receives spinners, progress messages and logs. It is switched to
+// stderr when a report is written to stdout, so the report can be piped.
+var statusOut io.Writer = os.Stdout
+
 // createSpinner creates a new spinner with the specified message
 func createSpinner(message string) *spinner.Spinner {
 	// Create a new spinner with character set 14 and speed of 100ms
 	s := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
+	s.Writer = statusOut
 	s.Suffix = " " + message
 	s.Color("cyan") // Use cyan color for the spinner
 	return s
@@ -25,3 +33,12 @@ func createSuccessMessage(message string) string {
 func createInfoMessage(message string) string {
 	return color.CyanString("ℹ ") + message
 }
+
+// terminalWidth returns the width of the terminal, or 0 if it cannot be determined
+func terminalWidth() int {
+	width, _, err := term.FromEnv().Size()
+	if err != nil || width <= 0 {
+		return 0
+	}
+	return width
+}
@@ -2,9 +2,9 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
-	"time"
 
 	"github.com/cli/go-gh/pkg/auth"
 	"github.com/cli/go-gh/v2/pkg/repository"
@@ -14,14 +14,46 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// defaultOctoscopeAPIURL is the Octoscope server used when OCTOSCOPE_API_URL isn't set
+const defaultOctoscopeAPIURL = "https://octoscope-server-production.up.railway.app"
+
+// defaultOctoscopeAppURL is the Octoscope app serving reports when OCTOSCOPE_APP_URL isn't set
+const defaultOctoscopeAppURL = "https://octoscope.netlify.app"
+
+// syncOptions are the sinks the sync command uploads to
+type syncOptions struct {
+	octoscope       bool
+	webhook         string
+	webhookTemplate string
+	webhookHeaders  []string
+	s3              string
+	s3Endpoint      string
+	s3Region        string
+	dir             string
+	postgres        string
+	postgresTable   string
+}
+
+// syncSink is a sink with the name it is reported by
+type syncSink struct {
+	name string
+	sink api.Sink
+}
+
 // newSyncCmd creates and returns the sync command
 func newSyncCmd() *cobra.Command {
+	var opts syncOptions
+
 	var syncCmd = &cobra.Command{
 		Use:   "sync",
 		Short: "Sync GitHub Actions data to the server without generating a report",
 		Long: `The sync command fetches GitHub Actions usage data and uploads it to the server.
 Unlike the report command, it does not generate a report_id and does not create shareable reports.
-This is useful for continuously syncing data to the server for analysis.`,
+This is useful for continuously syncing data to the server for analysis.
+
+Instead of the Octoscope server, the data can be synced to a webhook, an S3-compatible
+object store, a local directory or a Postgres table, or to several of them at once.
+The Octoscope server is only used when no other sink is set, or with --octoscope.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// Get GitHub CLI configuration
 			host, _ := auth.DefaultHost()
@@ -37,51 +69,63 @@ This is useful for continuously syncing data to the server for analysis.`,
 			}
 
 			// Run the sync logic
-			return runSync(cfg, ghCLIConfig)
+			return runSync(cfg, ghCLIConfig, opts)
 		},
 	}
 
+	syncCmd.Flags().BoolVar(&opts.octoscope, "octoscope", false, "Sync to the Octoscope server, the default when no other sink is set")
+	syncCmd.Flags().StringVar(&opts.webhook, "webhook", "", "Sync to a webhook, POSTing every batch as JSON to this URL")
+	syncCmd.Flags().StringVar(&opts.webhookTemplate, "webhook-template", "", "Go template file rendering the JSON body of webhook requests")
+	syncCmd.Flags().StringArrayVar(&opts.webhookHeaders, "webhook-header", nil, "Header sent to the webhook as key=value, e.g. for authentication, can be repeated")
+	syncCmd.Flags().StringVar(&opts.s3, "s3", "", "Sync to an S3-compatible object store, as s3://bucket/prefix")
+	syncCmd.Flags().StringVar(&opts.s3Endpoint, "s3-endpoint", "", "Endpoint of an S3-compatible object store, e.g. http://localhost:9000 (default AWS_ENDPOINT_URL_S3 or AWS_ENDPOINT_URL, else AWS)")
+	syncCmd.Flags().StringVar(&opts.s3Region, "s3-region", "", "Region of the object store (default AWS_REGION or AWS_DEFAULT_REGION, else us-east-1)")
+	syncCmd.Flags().StringVar(&opts.dir, "dir", "", "Sync to a local directory, writing every job as an ndjson file")
+	syncCmd.Flags().StringVar(&opts.postgres, "postgres", "", "Sync to Postgres, as postgres://user@host:5432/db")
+	syncCmd.Flags().StringVar(&opts.postgresTable, "postgres-table", api.DefaultPostgresTable, "Postgres table jobs are upserted into, created when missing")
+
 	return syncCmd
 }
 
-func runSync(cfg Config, ghCLIConfig GitHubCLIConfig) error {
+func runSync(cfg Config, ghCLIConfig GitHubCLIConfig, opts syncOptions) error {
 	logger := setupLogger()
 
-	// Fetch and process data from GitHub (without saving locally)
-	jobDetails, totalCosts, err := fetchAndProcessData(cfg, ghCLIConfig, logger, false)
+	// Set up the sinks first, so a misconfigured sink fails before fetching
+	sinks, err := newSyncSinks(opts, ghCLIConfig.Token, logger)
 	if err != nil {
 		return err
 	}
 
-	// Upload to server without report_id
-	s := createSpinner("Syncing data to server...")
-	s.Start()
-
-	apiBaseUrl := os.Getenv("OCTOSCOPE_API_URL")
-	if apiBaseUrl == "" {
-		apiBaseUrl = "https://octoscope-server-production.up.railway.app"
+	// Fetch and process data from GitHub (without saving locally)
+	jobDetails, totalCosts, err := fetchAndProcessData(cfg, ghCLIConfig, logger, false)
+	if err != nil {
+		return err
 	}
 
-	osClient := api.NewOctoscopeClient(api.OctoscopeConfig{
-		BaseUrl:     apiBaseUrl,
-		Logger:      logger,
-		GitHubToken: ghCLIConfig.Token,
-	})
-
 	reportData := &reports.ReportData{
 		Jobs:          jobDetails,
 		Totals:        totalCosts,
 		ObfuscateData: cfg.Obfuscate,
 	}
 
-	err = syncToServer(osClient, reportData, logger)
-	s.Stop()
+	// Sync to every sink even when one fails, and report all failures
+	var errs []error
+	for _, sink := range sinks {
+		message := fmt.Sprintf("Syncing data to %s...", sink.name)
+		s := createSpinner(message)
+		s.Start()
+		err := syncToSink(sink.sink, reportData, uploadOptions(cfg.Concurrency, s, message), logger)
+		s.Stop()
 
-	if err != nil {
-		return fmt.Errorf("failed to sync data to server: %w", err)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to sync data to %s: %w", sink.name, err))
+			continue
+		}
+		fmt.Fprintln(statusOut, createSuccessMessage(fmt.Sprintf("Data synced successfully to %s!", sink.name)))
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
 	}
-
-	fmt.Println(createSuccessMessage("Data synced successfully to server!"))
 
 	logger.Debug().
 		Str("total_duration", totalCosts.JobDuration.String()).
@@ -92,46 +136,109 @@ func runSync(cfg Config, ghCLIConfig GitHubCLIConfig) error {
 	return nil
 }
 
-func syncToServer(client api.OctoscopeClient, data *reports.ReportData, logger zerolog.Logger) error {
-	const batchSize = 25
-	const maxRetries = 3
+// newSyncSinks creates the sinks set in the options, the Octoscope server when none is set
+func newSyncSinks(opts syncOptions, token string, logger zerolog.Logger) ([]syncSink, error) {
+	var sinks []syncSink
 
-	// Split jobs into batches and upload without report_id
-	for i := 0; i < len(data.Jobs); i += batchSize {
-		end := i + batchSize
-		if end > len(data.Jobs) {
-			end = len(data.Jobs)
+	if opts.webhook != "" {
+		headers, err := parseHeaders(opts.webhookHeaders)
+		if err != nil {
+			return nil, err
 		}
-
-		batch := data.Jobs[i:end]
-
-		// Retry logic for each batch
-		var err error
-		for retry := 0; retry < maxRetries; retry++ {
-			// Use empty string for report_id to indicate sync operation
-			err = client.SyncJobs(context.Background(), batch, data.ObfuscateData)
-			if err == nil {
-				break
-			}
-			logger.Warn().
-				Int("retry", retry+1).
-				Int("batch", i/batchSize+1).
-				Err(err).
-				Msg("Failed to upload batch, retrying...")
-
-			if retry < maxRetries-1 {
-				time.Sleep(time.Second * time.Duration(retry+1))
+		var template string
+		if opts.webhookTemplate != "" {
+			content, err := os.ReadFile(opts.webhookTemplate)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read webhook template: %w", err)
 			}
+			template = string(content)
 		}
+		sink, err := api.NewWebhookSink(api.WebhookConfig{
+			URL:      opts.webhook,
+			Headers:  headers,
+			Template: template,
+			Logger:   logger,
+		})
 		if err != nil {
-			return fmt.Errorf("failed to upload batch after %d retries: %w", maxRetries, err)
+			return nil, err
 		}
+		sinks = append(sinks, syncSink{name: "webhook", sink: sink})
+	}
+
+	if opts.s3 != "" {
+		sink, err := api.NewS3Sink(api.S3Config{
+			URL:             opts.s3,
+			Endpoint:        firstNonEmpty(opts.s3Endpoint, os.Getenv("AWS_ENDPOINT_URL_S3"), os.Getenv("AWS_ENDPOINT_URL")),
+			Region:          firstNonEmpty(opts.s3Region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION")),
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+			Logger:          logger,
+		})
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, syncSink{name: opts.s3, sink: sink})
+	}
 
-		logger.Debug().
-			Int("batch", i/batchSize+1).
-			Int("total_batches", (len(data.Jobs)+batchSize-1)/batchSize).
-			Msg("Batch uploaded successfully")
+	if opts.dir != "" {
+		sinks = append(sinks, syncSink{name: opts.dir, sink: api.NewDirSink(api.DirSinkConfig{
+			Dir:    opts.dir,
+			Logger: logger,
+		})})
 	}
 
-	return nil
+	if opts.postgres != "" {
+		sink, err := api.NewPostgresSink(api.PostgresConfig{
+			URL:    opts.postgres,
+			Table:  opts.postgresTable,
+			Logger: logger,
+		})
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, syncSink{name: "Postgres", sink: sink})
+	}
+
+	if opts.octoscope || len(sinks) == 0 {
+		sinks = append(sinks, syncSink{name: "server", sink: api.NewOctoscopeClient(api.OctoscopeConfig{
+			BaseUrl:     octoscopeAPIURL(),
+			Logger:      logger,
+			GitHubToken: token,
+		})})
+	}
+
+	return sinks, nil
+}
+
+func syncToSink(sink api.Sink, data *reports.ReportData, opts reports.UploadOptions, logger zerolog.Logger) error {
+	return reports.UploadInBatches(context.Background(), data.Jobs, func(ctx context.Context, batch []reports.JobDetails) error {
+		return sink.SyncJobs(ctx, batch, data.ObfuscateData)
+	}, opts, logger)
+}
+
+// octoscopeAPIURL returns the URL of the Octoscope server API
+func octoscopeAPIURL() string {
+	if apiURL := os.Getenv("OCTOSCOPE_API_URL"); apiURL != "" {
+		return apiURL
+	}
+	return defaultOctoscopeAPIURL
+}
+
+// octoscopeAppURL returns the URL of the Octoscope app serving reports
+func octoscopeAppURL() string {
+	if appURL := os.Getenv("OCTOSCOPE_APP_URL"); appURL != "" {
+		return appURL
+	}
+	return defaultOctoscopeAppURL
+}
+
+// firstNonEmpty returns the first value that isn't empty
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
 }
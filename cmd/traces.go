@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/noamtamir/gh-octoscope/internal/api"
+	"github.com/noamtamir/gh-octoscope/internal/reports"
+	"github.com/spf13/cobra"
+)
+
+// defaultTraceBatchSize is the number of runs exported per request or file line
+const defaultTraceBatchSize = 50
+
+// tracesOptions are the options of the traces command
+type tracesOptions struct {
+	fetch       bool
+	endpoint    string
+	headers     []string
+	file        string
+	serviceName string
+	batchSize   int
+}
+
+// newTracesCmd creates and returns the traces command
+func newTracesCmd() *cobra.Command {
+	var opts tracesOptions
+
+	var tracesCmd = &cobra.Command{
+		Use:   "traces",
+		Short: "Export workflow runs as OpenTelemetry traces",
+		Long: `The traces command converts workflow runs into OpenTelemetry traces, with a
+span per run, attempt, job and step carrying its cost and billable minutes, and
+exports them to an OTLP/HTTP endpoint or writes them to a file of OTLP/JSON lines,
+e.g. to find critical paths and expensive jobs in Jaeger or Tempo.
+
+Job spans start when the job was created, so they include the time queued for a
+runner, with a "started" event when a runner picked it up. Trace and span IDs are
+derived from the run, job and step IDs, so exporting runs again doesn't duplicate
+them.
+
+The endpoint defaults to OTEL_EXPORTER_OTLP_TRACES_ENDPOINT or
+OTEL_EXPORTER_OTLP_ENDPOINT, and headers to OTEL_EXPORTER_OTLP_HEADERS.
+Run 'gh octoscope fetch' first, or pass --fetch.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Keep stdout clean, the traces may be written to it
+			statusOut = os.Stderr
+			if opts.endpoint == "" {
+				opts.endpoint = otlpEndpointFromEnv()
+			}
+			if len(opts.headers) == 0 && os.Getenv("OTEL_EXPORTER_OTLP_HEADERS") != "" {
+				opts.headers = strings.Split(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"), ",")
+			}
+			if opts.endpoint == "" && opts.file == "" {
+				return errors.New("no destination for the traces, pass --endpoint or --file")
+			}
+			if opts.batchSize <= 0 {
+				return fmt.Errorf("invalid --batch-size %d, must be greater than 0", opts.batchSize)
+			}
+			return runTraces(cfg, opts)
+		},
+	}
+
+	tracesCmd.Flags().BoolVar(&opts.fetch, "fetch", false, "Fetch new data before exporting instead of using existing data")
+	tracesCmd.Flags().StringVar(&opts.endpoint, "endpoint", "", "OTLP/HTTP endpoint, e.g. http://localhost:4318")
+	tracesCmd.Flags().StringArrayVar(&opts.headers, "header", nil, "Header sent to the endpoint as key=value, e.g. for authentication, can be repeated")
+	tracesCmd.Flags().StringVar(&opts.file, "file", "", "Write the traces as OTLP/JSON lines to this file, - for stdout")
+	tracesCmd.Flags().StringVar(&opts.serviceName, "service-name", reports.DefaultTraceServiceName, "service.name of the traces")
+	tracesCmd.Flags().IntVar(&opts.batchSize, "batch-size", defaultTraceBatchSize, "Number of runs per request or file line")
+
+	return tracesCmd
+}
+
+func runTraces(cfg Config, opts tracesOptions) error {
+	logger := setupLogger()
+
+	headers, err := parseHeaders(opts.headers)
+	if err != nil {
+		return err
+	}
+
+	jobDetails, err := loadOrFetch(cfg, opts.fetch, true, logger)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer
+	switch opts.file {
+	case "":
+	case "-":
+		out = os.Stdout
+	default:
+		file, err := os.Create(opts.file)
+		if err != nil {
+			return fmt.Errorf("failed to create traces file: %w", err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	var client api.OTLPClient
+	if opts.endpoint != "" {
+		client = api.NewOTLPClient(api.OTLPConfig{
+			Endpoint: opts.endpoint,
+			Headers:  headers,
+			Logger:   logger,
+		})
+	}
+
+	ctx := context.Background()
+	runs, spans := 0, 0
+	for _, batch := range batchByRun(jobDetails, opts.batchSize) {
+		traces := reports.BuildTraces(batch, opts.serviceName, cfg.Obfuscate)
+		if traces.SpanCount() == 0 {
+			continue
+		}
+		if out != nil {
+			if err := reports.WriteOTLPJSON(out, traces); err != nil {
+				return fmt.Errorf("failed to write traces: %w", err)
+			}
+		}
+		if client != nil {
+			if err := client.ExportTraces(ctx, traces); err != nil {
+				return fmt.Errorf("failed to export traces: %w", err)
+			}
+		}
+		for _, rs := range traces.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				for _, span := range ss.Spans {
+					if span.ParentSpanID == "" {
+						runs++
+					}
+				}
+			}
+		}
+		spans += traces.SpanCount()
+	}
+
+	fmt.Fprintln(statusOut, createSuccessMessage(fmt.Sprintf("Exported %d spans of %d workflow runs", spans, runs)))
+	return nil
+}
+
+// batchByRun splits jobs into batches of at most size runs, keeping the jobs of a run together
+func batchByRun(jobs []reports.JobDetails, size int) [][]reports.JobDetails {
+	byRun := make(map[int64][]reports.JobDetails)
+	var runIDs []int64
+	for _, job := range jobs {
+		runID := job.WorkflowRun.GetID()
+		if _, ok := byRun[runID]; !ok {
+			runIDs = append(runIDs, runID)
+		}
+		byRun[runID] = append(byRun[runID], job)
+	}
+
+	var batches [][]reports.JobDetails
+	for start := 0; start < len(runIDs); start += size {
+		end := min(start+size, len(runIDs))
+		var batch []reports.JobDetails
+		for _, runID := range runIDs[start:end] {
+			batch = append(batch, byRun[runID]...)
+		}
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// otlpEndpointFromEnv returns the traces endpoint set in the standard OpenTelemetry
+// environment variables, empty when none is set
+func otlpEndpointFromEnv() string {
+	if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); endpoint != "" {
+		return endpoint
+	}
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+}
+
+// parseHeaders parses key=value headers of a flag
+func parseHeaders(values []string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, header := range values {
+		key, value, ok := strings.Cut(header, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid header %q, must be key=value", header)
+		}
+		headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
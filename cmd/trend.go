@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"github.com/noamtamir/gh-octoscope/internal/reports"
+	"github.com/spf13/cobra"
+)
+
+// newTrendCmd creates and returns the trend subcommand for the report command
+func newTrendCmd() *cobra.Command {
+	var fetch bool = true
+	var csv bool = true // The trend is written as CSV by default
+	// Trend options are only copied into cfg when the trend command runs,
+	// so their defaults don't turn other reports into trends
+	var period, basis string
+	var window int
+
+	var trendCmd = &cobra.Command{
+		Use:   "trend",
+		Short: "Show how GitHub Actions costs change over time",
+		Long: `The trend command buckets cost and billable minutes by day, week or month.
+Every period is compared with the previous one and with a trailing average, and
+the workflows that drove each change are listed.
+
+The trend is printed in the terminal as sparkline charts, written as CSV, and added
+as a section to any HTML or Markdown output.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg.CSVReport = csv
+			cfg.TrendPeriod = period
+			cfg.TrendBasis = basis
+			cfg.TrendWindow = window
+			// The hosted report does not show trends, so nothing is uploaded
+			cfg.FullReport = false
+
+			return runReport(fetch)
+		},
+	}
+
+	trendCmd.Flags().StringVar(&period, "period", string(reports.DimensionWeek), "Bucket size: day, week or month")
+	trendCmd.Flags().StringVar(&basis, "by", string(reports.TrendByRun), "Bucket jobs by run or job creation time: run, job")
+	trendCmd.Flags().IntVar(&window, "window", reports.DefaultTrendWindow, "Number of previous periods in the trailing average")
+	trendCmd.Flags().BoolVar(&csv, "csv", true, "Generate CSV report, including the trend")
+	addOutputFlags(trendCmd.Flags())
+	trendCmd.Flags().BoolVar(&fetch, "fetch", true, "Whether to fetch new data or use existing data")
+
+	return trendCmd
+}
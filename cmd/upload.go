@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/briandowns/spinner"
+	"github.com/cli/go-gh/pkg/auth"
+	"github.com/google/uuid"
+	"github.com/noamtamir/gh-octoscope/internal/reports"
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+)
+
+// uploadsDirName is where upload manifests are kept, so interrupted uploads can be resumed
+const uploadsDirName = reportsDirName + "/uploads"
+
+// newUploadCmd creates and returns the upload subcommand for the report command
+func newUploadCmd() *cobra.Command {
+	var resume string
+
+	var uploadCmd = &cobra.Command{
+		Use:   "upload",
+		Short: "Upload previously fetched data to the Octoscope server",
+		Long: `Upload previously fetched data as a new full report on the Octoscope server.
+
+Every upload is recorded in .reports/uploads, so an upload that failed part way can be
+resumed with --resume <report-id>, sending only the batches the server didn't receive.
+The server publishes the report once all of its batches arrived.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logger := setupLogger()
+			host, _ := auth.DefaultHost()
+			token, _ := auth.TokenForHost(host)
+
+			jobDetails, totalCosts, err := loadExistingData(cfg, logger)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+
+			reportID := resume
+			if reportID == "" {
+				reportID = uuid.New().String()
+			}
+			message := "Uploading report to server..."
+			s := createSpinner(message)
+			serverGen := newServerGenerator(token, "", "", reportID, false, uploadOptions(cfg.Concurrency, s, message), logger)
+			s.Start()
+			if resume != "" {
+				err = serverGen.Resume(jobDetails)
+			} else {
+				err = serverGen.Generate(&reports.ReportData{
+					Jobs:          jobDetails,
+					Totals:        totalCosts,
+					ObfuscateData: cfg.Obfuscate,
+				})
+			}
+			s.Stop()
+			if err != nil {
+				printResumeHint(reportID)
+				return fmt.Errorf("failed to upload report: %w", err)
+			}
+			fmt.Fprintln(statusOut, createSuccessMessage("Full report uploaded successfully to server."))
+			fmt.Fprintf(statusOut, "\nReport URL: %s\n\n", serverGen.GetReportURL())
+			recordReport(newOctoscopeClient(token, logger), reportID, jobDetails, logger)
+			return nil
+		},
+	}
+
+	uploadCmd.Flags().StringVar(&resume, "resume", "", "Resume the interrupted upload of this report ID, sending only the missing batches")
+
+	return uploadCmd
+}
+
+// uploadOptions uploads concurrency batches at once, counting uploaded batches on the spinner
+func uploadOptions(concurrency int, s *spinner.Spinner, message string) reports.UploadOptions {
+	return reports.UploadOptions{
+		Concurrency: concurrency,
+		Progress: func(done, total int) {
+			s.Lock()
+			s.Suffix = fmt.Sprintf(" %s %d/%d batches", message, done, total)
+			s.Unlock()
+		},
+	}
+}
+
+// newServerGenerator creates the generator of full reports on the Octoscope server,
+// recording uploads in uploadsDirName. With merge, the jobs are merged into the existing
+// report with reportID instead of replacing its jobs.
+func newServerGenerator(token, owner, repo, reportID string, merge bool, opts reports.UploadOptions, logger zerolog.Logger) *reports.ServerGenerator {
+	return reports.NewServerGenerator(newOctoscopeClient(token, logger), reports.ServerConfig{
+		AppURL:      octoscopeAppURL(),
+		OwnerName:   owner,
+		RepoName:    repo,
+		ReportID:    reportID,
+		ManifestDir: uploadsDirName,
+		Merge:       merge,
+		Upload:      opts,
+	}, logger)
+}
+
+// printResumeHint tells how to resume the upload of a report when it's incomplete
+func printResumeHint(reportID string) {
+	manifest, err := reports.LoadUploadManifest(uploadsDirName, reportID)
+	if err != nil || manifest.Committed {
+		return
+	}
+	fmt.Fprintln(statusOut, createInfoMessage(fmt.Sprintf("The upload of report %s is incomplete, resume it with: gh octoscope report upload --resume %s", reportID, reportID)))
+}
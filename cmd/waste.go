@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/noamtamir/gh-octoscope/internal/reports"
+	"github.com/spf13/cobra"
+)
+
+// newWasteCmd creates and returns the waste command
+func newWasteCmd() *cobra.Command {
+	var format string
+	var topN int
+
+	var wasteCmd = &cobra.Command{
+		Use:   "waste",
+		Short: "Show the cost of failed, cancelled and re-run jobs",
+		Long: `The waste command attributes the spend that did not produce a useful result
+to workflows and jobs, as a percentage of their own cost and of the total:
+
+  failed       jobs that failed or timed out, in any attempt
+  cancelled    jobs that were cancelled
+  concurrency  jobs cancelled because a newer run of the same workflow and
+               branch started, as with a concurrency group with cancel-in-progress
+  superseded   jobs of an attempt that was re-run
+
+Run 'gh octoscope fetch' first to fetch the data.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAnalysis(cmd, format, analysisFormats, func() error {
+				return runWaste(cfg, format, topN)
+			})
+		},
+	}
+
+	addFormatFlag(wasteCmd.Flags(), &format, analysisFormats)
+	wasteCmd.Flags().IntVar(&topN, "top", reports.DefaultTopN, "Number of rows in the workflows and jobs tables")
+
+	return wasteCmd
+}
+
+func runWaste(cfg Config, format string, topN int) error {
+	logger := setupLogger()
+
+	jobDetails, _, err := loadExistingData(cfg, logger)
+	if err != nil {
+		return err
+	}
+
+	waste := reports.AnalyzeWaste(jobDetails, cfg.Obfuscate)
+	return reports.NewWasteGenerator(os.Stdout, format, topN, logger).Generate(&waste)
+}
@@ -2,6 +2,8 @@ package api
 
 import (
 	"context"
+	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/cli/go-gh/v2/pkg/repository"
@@ -15,6 +17,11 @@ type Client interface {
 	ListRepositoryRuns(ctx context.Context, from time.Time) (*github.WorkflowRuns, error)
 	ListWorkflowJobs(ctx context.Context, runID int64) (*github.Jobs, error)
 	ListWorkflowJobsAttempt(ctx context.Context, runID, attempt int64) (*github.Jobs, error)
+	ListPullRequestsWithCommit(ctx context.Context, sha string) ([]*github.PullRequest, error)
+	GetPullRequest(ctx context.Context, number int) (*github.PullRequest, error)
+	GetFileContent(ctx context.Context, path string) (string, error)
+	ListTeamMembers(ctx context.Context, org, slug string) ([]*github.User, error)
+	GetCustomPropertyValues(ctx context.Context) ([]*github.CustomPropertyValue, error)
 }
 
 type client struct {
@@ -25,14 +32,15 @@ type client struct {
 }
 
 type Config struct {
-	PageSize int
-	Logger   zerolog.Logger
-	Token    string
+	PageSize   int
+	Logger     zerolog.Logger
+	Token      string
+	HTTPClient *http.Client // optional, e.g. to observe API calls, defaults to http.DefaultClient
 }
 
 func NewClient(repo repository.Repository, cfg Config) Client {
 	return &client{
-		ghClient: github.NewClient(nil).WithAuthToken(cfg.Token),
+		ghClient: github.NewClient(cfg.HTTPClient).WithAuthToken(cfg.Token),
 		repo:     repo,
 		logger:   cfg.Logger,
 		pageSize: cfg.PageSize,
@@ -173,6 +181,89 @@ func (c *client) ListWorkflowJobsAttempt(ctx context.Context, runID, attempt int
 	return allJobs, nil
 }
 
+// ListPullRequestsWithCommit lists the pull requests that contain the commit, open ones first
+func (c *client) ListPullRequestsWithCommit(ctx context.Context, sha string) ([]*github.PullRequest, error) {
+	opt := &github.ListOptions{
+		PerPage: c.pageSize,
+	}
+
+	var allPRs []*github.PullRequest
+	for {
+		prs, resp, err := c.ghClient.PullRequests.ListPullRequestsWithCommit(ctx, c.repo.Owner, c.repo.Name, sha, opt)
+		if err != nil {
+			return nil, err
+		}
+		c.logResponse(resp, prs)
+
+		allPRs = append(allPRs, prs...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	return allPRs, nil
+}
+
+func (c *client) GetPullRequest(ctx context.Context, number int) (*github.PullRequest, error) {
+	pr, resp, err := c.ghClient.PullRequests.Get(ctx, c.repo.Owner, c.repo.Name, number)
+	if err != nil {
+		return nil, err
+	}
+	c.logResponse(resp, pr)
+	return pr, nil
+}
+
+// GetFileContent returns the content of a file in the repository's default branch
+func (c *client) GetFileContent(ctx context.Context, path string) (string, error) {
+	file, _, resp, err := c.ghClient.Repositories.GetContents(ctx, c.repo.Owner, c.repo.Name, path, nil)
+	if err != nil {
+		return "", err
+	}
+	c.logResponse(resp, nil)
+	if file == nil {
+		return "", fmt.Errorf("%s is a directory", path)
+	}
+	return file.GetContent()
+}
+
+func (c *client) ListTeamMembers(ctx context.Context, org, slug string) ([]*github.User, error) {
+	opt := &github.TeamListTeamMembersOptions{
+		ListOptions: github.ListOptions{
+			PerPage: c.pageSize,
+		},
+	}
+
+	var allMembers []*github.User
+	for {
+		members, resp, err := c.ghClient.Teams.ListTeamMembersBySlug(ctx, org, slug, opt)
+		if err != nil {
+			return nil, err
+		}
+		c.logResponse(resp, members)
+
+		allMembers = append(allMembers, members...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	return allMembers, nil
+}
+
+// GetCustomPropertyValues returns the custom property values of the repository
+func (c *client) GetCustomPropertyValues(ctx context.Context) ([]*github.CustomPropertyValue, error) {
+	values, resp, err := c.ghClient.Repositories.GetAllCustomPropertyValues(ctx, c.repo.Owner, c.repo.Name)
+	if err != nil {
+		return nil, err
+	}
+	c.logResponse(resp, values)
+	return values, nil
+}
+
 func (c *client) logResponse(resp *github.Response, body interface{}) {
 	c.logger.Debug().
 		Str("method", resp.Request.Method).
@@ -2,11 +2,17 @@ package api
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
 
 	"github.com/noamtamir/gh-octoscope/internal/reports"
 	"github.com/rs/zerolog"
@@ -15,7 +21,45 @@ import (
 type OctoscopeClient interface {
 	BatchCreate(ctx context.Context, jobs []reports.JobDetails, reportID string, shouldObfuscate bool) error
 	SyncJobs(ctx context.Context, jobs []reports.JobDetails, shouldObfuscate bool) error
+	CommitReport(ctx context.Context, manifest *reports.UploadManifest) error
 	DeleteReport(ctx context.Context, reportID string) error
+	ListReports(ctx context.Context) ([]reports.ReportInfo, error)
+	GetReport(ctx context.Context, reportID string) (*reports.ReportInfo, error)
+	ExtendReport(ctx context.Context, reportID string, expiresAt time.Time) (*reports.ReportInfo, error)
+	ShareReport(ctx context.Context, reportID, access string) (*reports.ReportInfo, error)
+}
+
+// defaultRetryAfter is how long to wait after a 429 response without a Retry-After header
+const defaultRetryAfter = 5 * time.Second
+
+// gzipMinBytes is the size above which payloads are gzipped, smaller ones gain little
+const gzipMinBytes = 1024
+
+// statusError is returned for an unsuccessful response of the Octoscope API
+type statusError struct {
+	StatusCode int
+	Body       []byte
+	retryAfter time.Duration
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("server returned error: status=%d body=%s", e.StatusCode, string(e.Body))
+}
+
+// RetryAfter returns how long the server asked to wait before retrying, zero when it didn't
+func (e *statusError) RetryAfter() time.Duration {
+	return e.retryAfter
+}
+
+// parseRetryAfter parses a Retry-After header, in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
 }
 
 type octoscopeClient struct {
@@ -23,6 +67,7 @@ type octoscopeClient struct {
 	baseUrl     string
 	logger      zerolog.Logger
 	githubToken string
+	plainOnly   atomic.Bool // set once the server rejected a gzipped payload
 }
 
 type OctoscopeConfig struct {
@@ -41,18 +86,62 @@ func NewOctoscopeClient(cfg OctoscopeConfig) OctoscopeClient {
 	}
 }
 
-// doJSONRequest is a helper method for making JSON POST requests with authentication
-func (c *octoscopeClient) doJSONRequest(ctx context.Context, method, endpoint string, payload interface{}) error {
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
+// doJSONRequest is a helper method for making JSON requests with authentication, sending
+// the payload unless it's nil, and decoding the response into result unless it's nil.
+// Payloads above gzipMinBytes are gzipped, unless the server responded 415 Unsupported
+// Media Type to a gzipped payload before, in which case they're sent again uncompressed.
+// Requests with an idempotency key are applied once by the server, however often they're sent.
+func (c *octoscopeClient) doJSONRequest(ctx context.Context, method, endpoint, idempotencyKey string, payload, result interface{}) error {
+	var body []byte
+	if payload != nil {
+		var err error
+		if body, err = json.Marshal(payload); err != nil {
+			return fmt.Errorf("failed to marshal payload: %w", err)
+		}
 	}
 
-	req, err := http.NewRequestWithContext(ctx, method, c.baseUrl+endpoint, bytes.NewBuffer(jsonData))
+	if len(body) <= gzipMinBytes || c.plainOnly.Load() {
+		return c.doRequest(ctx, method, endpoint, idempotencyKey, body, false, result)
+	}
+	err := c.doRequest(ctx, method, endpoint, idempotencyKey, body, true, result)
+	var statusErr *statusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusUnsupportedMediaType {
+		c.logger.Debug().Str("endpoint", endpoint).Msg("Server doesn't accept gzipped payloads, sending them uncompressed")
+		c.plainOnly.Store(true)
+		return c.doRequest(ctx, method, endpoint, idempotencyKey, body, false, result)
+	}
+	return err
+}
+
+// doRequest sends a JSON body, gzipped when compress is set, and decodes the response
+// into result unless it's nil
+func (c *octoscopeClient) doRequest(ctx context.Context, method, endpoint, idempotencyKey string, body []byte, compress bool, result interface{}) error {
+	content := body
+	if compress {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write(body); err != nil {
+			return fmt.Errorf("failed to compress payload: %w", err)
+		}
+		if err := gz.Close(); err != nil {
+			return fmt.Errorf("failed to compress payload: %w", err)
+		}
+		content = buf.Bytes()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseUrl+endpoint, bytes.NewReader(content))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
-	req.Header.Set("Content-Type", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
 
 	// Add GitHub token as Bearer token if available
 	if c.githubToken != "" {
@@ -67,9 +156,21 @@ func (c *octoscopeClient) doJSONRequest(ctx context.Context, method, endpoint st
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("server returned error: status=%d body=%s", resp.StatusCode, string(body))
+		statusErr := &statusError{StatusCode: resp.StatusCode, Body: body}
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+			statusErr.retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+			if statusErr.retryAfter == 0 && resp.StatusCode == http.StatusTooManyRequests {
+				statusErr.retryAfter = defaultRetryAfter
+			}
+		}
+		return statusErr
 	}
 
+	if result != nil {
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -84,7 +185,7 @@ func (c *octoscopeClient) BatchCreate(ctx context.Context, jobs []reports.JobDet
 		Jobs:     flattened,
 	}
 
-	if err := c.doJSONRequest(ctx, "POST", "/report-jobs", payload); err != nil {
+	if err := c.doJSONRequest(ctx, "POST", "/report-jobs", reports.BatchHash(flattened), payload, nil); err != nil {
 		return err
 	}
 
@@ -105,7 +206,7 @@ func (c *octoscopeClient) SyncJobs(ctx context.Context, jobs []reports.JobDetail
 		Jobs: flattened,
 	}
 
-	if err := c.doJSONRequest(ctx, "POST", "/jobs", payload); err != nil {
+	if err := c.doJSONRequest(ctx, "POST", "/jobs", reports.BatchHash(flattened), payload, nil); err != nil {
 		return err
 	}
 
@@ -116,6 +217,48 @@ func (c *octoscopeClient) SyncJobs(ctx context.Context, jobs []reports.JobDetail
 	return nil
 }
 
+// CommitReport completes an upload, so the server publishes the report once it has
+// received every batch of the manifest
+func (c *octoscopeClient) CommitReport(ctx context.Context, manifest *reports.UploadManifest) error {
+	payload := struct {
+		ReportID string   `json:"report_id"`
+		Batches  []string `json:"batches"`
+		JobCount int      `json:"job_count"`
+		Merge    bool     `json:"merge,omitempty"`
+	}{
+		ReportID: manifest.ReportID,
+		Batches:  manifest.Hashes(),
+		JobCount: manifest.JobCount(),
+		Merge:    manifest.Merge,
+	}
+
+	err := c.doJSONRequest(ctx, "POST", "/report-jobs/commit", "", payload, nil)
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusNotFound, http.StatusMethodNotAllowed:
+			return reports.ErrCommitUnsupported
+		case http.StatusConflict:
+			var missing struct {
+				Missing []string `json:"missing"`
+			}
+			if json.Unmarshal(statusErr.Body, &missing) == nil && len(missing.Missing) > 0 {
+				return &reports.MissingBatchesError{Hashes: missing.Missing}
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	c.logger.Debug().
+		Str("report_id", manifest.ReportID).
+		Int("batch_count", len(manifest.Batches)).
+		Msg("Successfully committed report")
+
+	return nil
+}
+
 func (c *octoscopeClient) DeleteReport(ctx context.Context, reportID string) error {
 	req, err := http.NewRequestWithContext(ctx, "DELETE", c.baseUrl+"/report-jobs", nil)
 	if err != nil {
@@ -148,3 +291,45 @@ func (c *octoscopeClient) DeleteReport(ctx context.Context, reportID string) err
 
 	return nil
 }
+
+// ListReports lists the reports created with the client's token
+func (c *octoscopeClient) ListReports(ctx context.Context) ([]reports.ReportInfo, error) {
+	var result struct {
+		Reports []reports.ReportInfo `json:"reports"`
+	}
+	if err := c.doJSONRequest(ctx, "GET", "/reports", "", nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Reports, nil
+}
+
+// GetReport returns a report created with the client's token
+func (c *octoscopeClient) GetReport(ctx context.Context, reportID string) (*reports.ReportInfo, error) {
+	var info reports.ReportInfo
+	if err := c.doJSONRequest(ctx, "GET", "/reports/"+url.PathEscape(reportID), "", nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// ExtendReport keeps a report until expiresAt
+func (c *octoscopeClient) ExtendReport(ctx context.Context, reportID string, expiresAt time.Time) (*reports.ReportInfo, error) {
+	return c.updateReport(ctx, reportID, map[string]any{"expires_at": expiresAt.UTC()})
+}
+
+// ShareReport changes who can view a report, one of reports.ReportAccesses
+func (c *octoscopeClient) ShareReport(ctx context.Context, reportID, access string) (*reports.ReportInfo, error) {
+	return c.updateReport(ctx, reportID, map[string]any{"access": access})
+}
+
+func (c *octoscopeClient) updateReport(ctx context.Context, reportID string, update map[string]any) (*reports.ReportInfo, error) {
+	var info reports.ReportInfo
+	if err := c.doJSONRequest(ctx, "PATCH", "/reports/"+url.PathEscape(reportID), "", update, &info); err != nil {
+		return nil, err
+	}
+	c.logger.Debug().
+		Str("report_id", reportID).
+		Interface("update", update).
+		Msg("Successfully updated report")
+	return &info, nil
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/noamtamir/gh-octoscope/internal/reports"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOctoscopeClientBatchCreate(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		gz, err := gzip.NewReader(r.Body)
+		require.NoError(t, err)
+		body, err := io.ReadAll(gz)
+		require.NoError(t, err)
+		bodies = append(bodies, string(body))
+
+		if len(requests) == 1 {
+			w.Header().Set("Retry-After", "2")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	client := NewOctoscopeClient(OctoscopeConfig{BaseUrl: server.URL, GitHubToken: "token", Logger: zerolog.New(io.Discard)})
+	jobs := sinkTestJobs(10)
+
+	err := client.BatchCreate(context.Background(), jobs, "report-1", false)
+	var throttled interface{ RetryAfter() time.Duration }
+	require.ErrorAs(t, err, &throttled)
+	assert.Equal(t, 2*time.Second, throttled.RetryAfter())
+	require.NoError(t, client.BatchCreate(context.Background(), jobs, "report-1", false))
+
+	// Large bodies are gzipped, and a batch sent again has the same idempotency key
+	require.Len(t, requests, 2)
+	assert.Equal(t, "gzip", requests[0].Header.Get("Content-Encoding"))
+	assert.Equal(t, "Bearer token", requests[0].Header.Get("Authorization"))
+	key := reports.BatchHash(reports.FlattenJobs(jobs, false))
+	assert.Equal(t, key, requests[0].Header.Get("Idempotency-Key"))
+	assert.Equal(t, key, requests[1].Header.Get("Idempotency-Key"))
+
+	var payload struct {
+		ReportID string                   `json:"report_id"`
+		Jobs     []reports.FlatJobDetails `json:"jobs"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(bodies[1]), &payload))
+	assert.Equal(t, "report-1", payload.ReportID)
+	assert.Len(t, payload.Jobs, 10)
+}
+
+func TestOctoscopeClientCompression(t *testing.T) {
+	var encodings []string
+	acceptsGzip := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := r.Header.Get("Content-Encoding")
+		encodings = append(encodings, encoding)
+		if encoding == "gzip" && !acceptsGzip {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		var body io.Reader = r.Body
+		if encoding == "gzip" {
+			gz, err := gzip.NewReader(r.Body)
+			require.NoError(t, err)
+			body = gz
+		}
+		var payload struct {
+			Jobs []reports.FlatJobDetails `json:"jobs"`
+		}
+		require.NoError(t, json.NewDecoder(body).Decode(&payload))
+		assert.NotEmpty(t, payload.Jobs)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	client := NewOctoscopeClient(OctoscopeConfig{BaseUrl: server.URL, Logger: zerolog.New(io.Discard)})
+
+	// Small payloads aren't worth compressing
+	require.NoError(t, client.SyncJobs(context.Background(), sinkTestJobs(1), false))
+	require.NoError(t, client.SyncJobs(context.Background(), sinkTestJobs(10), false))
+	assert.Equal(t, []string{"", "gzip"}, encodings)
+
+	// A server rejecting gzip gets the payload again uncompressed, and every later one too
+	encodings = nil
+	acceptsGzip = false
+	require.NoError(t, client.SyncJobs(context.Background(), sinkTestJobs(10), false))
+	require.NoError(t, client.SyncJobs(context.Background(), sinkTestJobs(10), false))
+	assert.Equal(t, []string{"gzip", "", ""}, encodings)
+}
+
+func TestOctoscopeClientCommitReport(t *testing.T) {
+	status := http.StatusConflict
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/report-jobs/commit", r.URL.Path)
+		w.WriteHeader(status)
+		if status == http.StatusConflict {
+			_, _ = w.Write([]byte(`{"error": "report is missing batches", "missing": ["abc"]}`))
+		}
+	}))
+	defer server.Close()
+
+	client := NewOctoscopeClient(OctoscopeConfig{BaseUrl: server.URL, Logger: zerolog.New(io.Discard)})
+	manifest := reports.NewUploadManifest("report-1", [][]reports.JobDetails{sinkTestJobs(1)}, false)
+
+	var missing *reports.MissingBatchesError
+	require.ErrorAs(t, client.CommitReport(context.Background(), manifest), &missing)
+	assert.Equal(t, []string{"abc"}, missing.Hashes)
+
+	// Servers without upload sessions publish batches as they arrive
+	status = http.StatusNotFound
+	assert.True(t, errors.Is(client.CommitReport(context.Background(), manifest), reports.ErrCommitUnsupported))
+
+	status = http.StatusOK
+	assert.NoError(t, client.CommitReport(context.Background(), manifest))
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, 30*time.Second, parseRetryAfter("30", now))
+	assert.Equal(t, time.Minute, parseRetryAfter("Tue, 01 Apr 2025 12:01:00 GMT", now))
+	assert.Zero(t, parseRetryAfter("", now))
+	assert.Zero(t, parseRetryAfter("Tue, 01 Apr 2025 11:59:00 GMT", now))
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/noamtamir/gh-octoscope/internal/reports"
+	"github.com/rs/zerolog"
+)
+
+// otlpTracesPath is the path of the traces endpoint of an OTLP/HTTP receiver
+const otlpTracesPath = "/v1/traces"
+
+type OTLPClient interface {
+	ExportTraces(ctx context.Context, traces reports.OTLPTraces) error
+}
+
+type otlpClient struct {
+	httpClient *http.Client
+	endpoint   string
+	headers    map[string]string
+	logger     zerolog.Logger
+}
+
+type OTLPConfig struct {
+	// Endpoint is the base URL of an OTLP/HTTP receiver, e.g. http://localhost:4318,
+	// or the full URL of its traces endpoint when it ends with /v1/traces
+	Endpoint string
+	Headers  map[string]string // sent with every request, e.g. for authentication
+	Logger   zerolog.Logger
+}
+
+// NewOTLPClient creates a new client that exports traces to an OTLP/HTTP receiver
+// using the OTLP/JSON encoding
+func NewOTLPClient(cfg OTLPConfig) OTLPClient {
+	endpoint := strings.TrimSuffix(cfg.Endpoint, "/")
+	if !strings.HasSuffix(endpoint, otlpTracesPath) {
+		endpoint += otlpTracesPath
+	}
+	return &otlpClient{
+		httpClient: &http.Client{},
+		endpoint:   endpoint,
+		headers:    cfg.Headers,
+		logger:     cfg.Logger,
+	}
+}
+
+func (c *otlpClient) ExportTraces(ctx context.Context, traces reports.OTLPTraces) error {
+	var body bytes.Buffer
+	if err := reports.WriteOTLPJSON(&body, traces); err != nil {
+		return fmt.Errorf("failed to marshal traces: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, &body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range c.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send traces: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("OTLP receiver returned error: status=%d body=%s", resp.StatusCode, string(respBody))
+	}
+	// A partial success lists the rejected spans in the response body
+	c.logger.Debug().
+		Str("url", c.endpoint).
+		Int("status", resp.StatusCode).
+		Int("spans", traces.SpanCount()).
+		Str("body", string(respBody)).
+		Msg("Exported traces")
+	return nil
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/noamtamir/gh-octoscope/internal/reports"
+	"github.com/rs/zerolog"
+)
+
+// DefaultPostgresTable is the table jobs are upserted into
+const DefaultPostgresTable = "octoscope_jobs"
+
+// postgresTablePattern matches a table name, optionally schema qualified
+var postgresTablePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+type postgresSink struct {
+	db     *sql.DB
+	table  string
+	logger zerolog.Logger
+}
+
+type PostgresConfig struct {
+	// URL is the connection string, e.g. postgres://user@host:5432/db?sslmode=require.
+	// The libpq environment variables, e.g. PGPASSWORD, are honored too.
+	URL    string
+	Table  string // DefaultPostgresTable when empty
+	Logger zerolog.Logger
+}
+
+// NewPostgresSink creates a sink that upserts every job into a Postgres table by job ID,
+// creating the table when it doesn't exist. It connects on the first sync.
+func NewPostgresSink(cfg PostgresConfig) (Sink, error) {
+	dsn, err := url.Parse(cfg.URL)
+	if err != nil || (dsn.Scheme != "postgres" && dsn.Scheme != "postgresql") {
+		return nil, errors.New("invalid Postgres URL, must start with postgres://")
+	}
+	connector, err := pq.NewConnector(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Postgres URL: %w", err)
+	}
+	return newPostgresSink(sql.OpenDB(connector), cfg)
+}
+
+func newPostgresSink(db *sql.DB, cfg PostgresConfig) (*postgresSink, error) {
+	if cfg.Table == "" {
+		cfg.Table = DefaultPostgresTable
+	}
+	if !postgresTablePattern.MatchString(cfg.Table) {
+		return nil, fmt.Errorf("invalid Postgres table %q, must be a name or schema.name", cfg.Table)
+	}
+	return &postgresSink{
+		db:     db,
+		table:  cfg.Table,
+		logger: cfg.Logger,
+	}, nil
+}
+
+// SyncJobs upserts a batch of jobs in a single transaction, so a failed batch is retried
+// as a whole
+func (s *postgresSink) SyncJobs(ctx context.Context, jobs []reports.JobDetails, shouldObfuscate bool) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to connect to Postgres: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The table name was validated, it's the only part of the statements not passed as a parameter
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+  job_id bigint PRIMARY KEY,
+  workflow_run_id bigint,
+  repository text,
+  job_created_at timestamptz,
+  billable_in_usd double precision,
+  data jsonb NOT NULL,
+  synced_at timestamptz NOT NULL DEFAULT now()
+)`, s.table)); err != nil {
+		return fmt.Errorf("failed to create table %s: %w", s.table, err)
+	}
+
+	upsert, err := tx.PrepareContext(ctx, fmt.Sprintf(`INSERT INTO %s (job_id, workflow_run_id, repository, job_created_at, billable_in_usd, data)
+VALUES ($1, $2, $3, $4, $5, $6::jsonb)
+ON CONFLICT (job_id) DO UPDATE SET
+  workflow_run_id = EXCLUDED.workflow_run_id,
+  repository = EXCLUDED.repository,
+  job_created_at = EXCLUDED.job_created_at,
+  billable_in_usd = EXCLUDED.billable_in_usd,
+  data = EXCLUDED.data,
+  synced_at = now()`, s.table))
+	if err != nil {
+		return fmt.Errorf("failed to prepare upsert: %w", err)
+	}
+	defer upsert.Close()
+
+	upserted := 0
+	for i, job := range reports.FlattenJobs(jobs, shouldObfuscate) {
+		if job.JobID == nil {
+			continue
+		}
+		data, err := json.Marshal(job)
+		if err != nil {
+			return fmt.Errorf("failed to encode job: %w", err)
+		}
+		var repository *string
+		if job.OwnerName != nil && job.RepoName != nil {
+			name := *job.OwnerName + "/" + *job.RepoName
+			repository = &name
+		}
+		var createdAt *time.Time
+		if created := jobs[i].Job.GetCreatedAt().Time; !created.IsZero() {
+			created = created.UTC()
+			createdAt = &created
+		}
+		if _, err := upsert.ExecContext(ctx, *job.JobID, job.WorkflowRunID, repository, createdAt, job.BillableInUSD, string(data)); err != nil {
+			return fmt.Errorf("failed to upsert job %d: %w", *job.JobID, err)
+		}
+		upserted++
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit jobs: %w", err)
+	}
+
+	s.logger.Debug().
+		Int("job_count", upserted).
+		Str("table", s.table).
+		Msg("Successfully upserted job batch")
+	return nil
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/noamtamir/gh-octoscope/internal/reports"
+	"github.com/rs/zerolog"
+)
+
+// defaultS3Region is the region used when none is configured
+const defaultS3Region = "us-east-1"
+
+type s3Sink struct {
+	httpClient *http.Client
+	endpoint   string // base URL objects are PUT under, including the bucket
+	prefix     string
+	cfg        S3Config
+	now        func() time.Time
+}
+
+type S3Config struct {
+	// URL is the location of the objects, s3://bucket or s3://bucket/prefix
+	URL string
+	// Endpoint is the base URL of an S3-compatible store, e.g. http://localhost:9000 for
+	// MinIO, addressed path-style. Empty for AWS, addressed virtual-hosted-style.
+	Endpoint        string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string // optional, for temporary credentials
+	Logger          zerolog.Logger
+}
+
+// NewS3Sink creates a sink that stores every job as an ndjson object in an S3-compatible
+// object store, at <prefix>/<day>/job-<job ID>.ndjson
+func NewS3Sink(cfg S3Config) (Sink, error) {
+	location, err := url.Parse(cfg.URL)
+	if err != nil || location.Scheme != "s3" || location.Host == "" {
+		return nil, fmt.Errorf("invalid S3 URL %q, must be s3://bucket or s3://bucket/prefix", cfg.URL)
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, errors.New("missing S3 credentials, set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	if cfg.Region == "" {
+		cfg.Region = defaultS3Region
+	}
+
+	bucket := location.Host
+	endpoint := fmt.Sprintf("https://%s.s3.%s.amazonaws.com", bucket, cfg.Region)
+	if cfg.Endpoint != "" {
+		endpoint = strings.TrimSuffix(cfg.Endpoint, "/") + "/" + s3EscapePath(bucket)
+	}
+
+	return &s3Sink{
+		httpClient: &http.Client{},
+		endpoint:   endpoint,
+		prefix:     strings.Trim(location.Path, "/"),
+		cfg:        cfg,
+		now:        time.Now,
+	}, nil
+}
+
+func (s *s3Sink) SyncJobs(ctx context.Context, jobs []reports.JobDetails, shouldObfuscate bool) error {
+	for i := range jobs {
+		content, err := encodeNDJSON(jobs[i:i+1], shouldObfuscate)
+		if err != nil {
+			return fmt.Errorf("failed to encode jobs: %w", err)
+		}
+		key := jobObjectName(jobs[i])
+		if s.prefix != "" {
+			key = s.prefix + "/" + key
+		}
+		if err := s.putObject(ctx, key, content); err != nil {
+			return err
+		}
+	}
+
+	s.cfg.Logger.Debug().
+		Int("job_count", len(jobs)).
+		Str("prefix", s.prefix).
+		Msg("Successfully stored job batch")
+	return nil
+}
+
+// putObject stores content at key, replacing the object stored there
+func (s *s3Sink) putObject(ctx context.Context, key string, content []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.endpoint+"/"+s3EscapePath(key), bytes.NewReader(content))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	signS3Request(req, content, s.cfg, s.now())
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("object store returned error: status=%d body=%s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// signS3Request signs a request with AWS Signature Version 4, signing the host and every
+// header already set on the request
+func signS3Request(req *http.Request, payload []byte, cfg S3Config, now time.Time) {
+	payloadSum := sha256.Sum256(payload)
+	payloadHash := hex.EncodeToString(payloadSum[:])
+	amzDate := now.UTC().Format("20060102T150405Z")
+	day := amzDate[:8]
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", cfg.SessionToken)
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.Join(strings.Fields(strings.Join(values, ",")), " ")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	requestSum := sha256.Sum256([]byte(canonicalRequest))
+
+	scope := day + "/" + cfg.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(requestSum[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+cfg.SecretAccessKey), day)
+	key = hmacSHA256(key, cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s,SignedHeaders=%s,Signature=%s",
+		cfg.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3EscapePath escapes every byte of a key except unreserved characters and slashes,
+// as the canonical request of a signature expects
+func s3EscapePath(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
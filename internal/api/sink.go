@@ -0,0 +1,112 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/noamtamir/gh-octoscope/internal/reports"
+	"github.com/rs/zerolog"
+)
+
+// Sink is a destination the sync command uploads batches of jobs to.
+// OctoscopeClient is a Sink uploading to the Octoscope server.
+type Sink interface {
+	SyncJobs(ctx context.Context, jobs []reports.JobDetails, shouldObfuscate bool) error
+}
+
+// encodeNDJSON encodes jobs as lines of the ndjson export's job records
+func encodeNDJSON(jobs []reports.JobDetails, shouldObfuscate bool) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, job := range reports.FlattenJobs(jobs, shouldObfuscate) {
+		record := reports.NDJSONJobRecord{
+			SchemaVersion:  reports.ExportSchemaVersion,
+			RecordType:     reports.RecordTypeJob,
+			FlatJobDetails: job,
+		}
+		if err := encoder.Encode(record); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// jobObjectName returns the relative path a job is stored at by the directory and S3
+// sinks, e.g. 2025-04-01/job-101.ndjson. It is partitioned by the day the job was created
+// and named after its ID, so syncing a job again, e.g. in the overlapping window of the
+// next sync, replaces its file rather than duplicating it.
+func jobObjectName(job reports.JobDetails) string {
+	day := "unknown"
+	if job.Job != nil && job.Job.CreatedAt != nil {
+		day = job.Job.CreatedAt.UTC().Format("2006-01-02")
+	} else if job.WorkflowRun != nil && job.WorkflowRun.CreatedAt != nil {
+		day = job.WorkflowRun.CreatedAt.UTC().Format("2006-01-02")
+	}
+	return fmt.Sprintf("%s/job-%d.ndjson", day, job.Job.GetID())
+}
+
+type dirSink struct {
+	dir    string
+	logger zerolog.Logger
+}
+
+type DirSinkConfig struct {
+	Dir    string // created when missing
+	Logger zerolog.Logger
+}
+
+// NewDirSink creates a sink that writes every job as an ndjson file into a local
+// directory, e.g. a directory shipped elsewhere by another agent
+func NewDirSink(cfg DirSinkConfig) Sink {
+	return &dirSink{
+		dir:    cfg.Dir,
+		logger: cfg.Logger,
+	}
+}
+
+func (s *dirSink) SyncJobs(ctx context.Context, jobs []reports.JobDetails, shouldObfuscate bool) error {
+	for i := range jobs {
+		content, err := encodeNDJSON(jobs[i:i+1], shouldObfuscate)
+		if err != nil {
+			return fmt.Errorf("failed to encode jobs: %w", err)
+		}
+		if err := s.writeFile(filepath.Join(s.dir, filepath.FromSlash(jobObjectName(jobs[i]))), content); err != nil {
+			return err
+		}
+	}
+
+	s.logger.Debug().
+		Int("job_count", len(jobs)).
+		Str("dir", s.dir).
+		Msg("Successfully wrote job batch")
+	return nil
+}
+
+// writeFile replaces the file at path with content
+func (s *dirSink) writeFile(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// Write to a temporary file first, so readers of the directory never see a partial job
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".job-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
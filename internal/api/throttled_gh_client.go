@@ -14,6 +14,7 @@ import (
 type ThrottledClient interface {
 	Client
 	FetchRunsWithJobs(ctx context.Context, from time.Time) ([]RunWithJobs, error)
+	ResolvePullRequests(ctx context.Context, runs []RunWithJobs, defaultBranch string) error
 }
 
 // RunWithJobs contains a workflow run with its associated jobs
@@ -22,6 +23,7 @@ type RunWithJobs struct {
 	Jobs        []*github.WorkflowJob
 	Workflow    *github.Workflow
 	AttemptJobs map[int][]*github.WorkflowJob
+	PullRequest *github.PullRequest // set by ResolvePullRequests, nil when the run isn't part of one
 }
 
 // ThrottledClientConfig extends the base Config with concurrency settings
@@ -72,7 +74,7 @@ func NewThrottledClient(repo repository.Repository, cfg ThrottledClientConfig) T
 
 	return &throttledClient{
 		client: client{
-			ghClient: github.NewClient(nil).WithAuthToken(cfg.Token),
+			ghClient: github.NewClient(cfg.HTTPClient).WithAuthToken(cfg.Token),
 			repo:     repo,
 			logger:   cfg.Logger,
 			pageSize: cfg.PageSize,
@@ -328,3 +330,123 @@ func (c *throttledClient) processRun(ctx context.Context, run *github.WorkflowRu
 
 	return result, nil
 }
+
+// ResolvePullRequests sets the pull request of every run that belongs to one. Runs of pull
+// request events list their pull requests, other runs, e.g. of pushes to a pull request's branch
+// or of pull requests from forks, are looked up by their head commit. Pushes to the default
+// branch are left out, so the runs after merging aren't attributed to the merged pull request.
+// Lookups that fail are logged and skipped.
+func (c *throttledClient) ResolvePullRequests(ctx context.Context, runs []RunWithJobs, defaultBranch string) error {
+	prs := make(map[int]*github.PullRequest)
+	numbers := make([]int, len(runs))
+	bySHA := make(map[string]int)               // head SHA to pull request number, 0 when there is none
+	listed := make(map[int]*github.PullRequest) // as listed by the runs, with only the number, head and base
+
+	for i, runWithJobs := range runs {
+		run := runWithJobs.Run
+		if len(run.PullRequests) > 0 {
+			numbers[i] = run.PullRequests[0].GetNumber()
+			listed[numbers[i]] = run.PullRequests[0]
+			continue
+		}
+		if !lookupPullRequest(run, defaultBranch) {
+			continue
+		}
+
+		sha := run.GetHeadSHA()
+		number, ok := bySHA[sha]
+		if !ok {
+			var found []*github.PullRequest
+			err := c.executeWithRateLimit(ctx, func() error {
+				var err error
+				found, err = c.ListPullRequestsWithCommit(ctx, sha)
+				return err
+			})
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				c.logger.Warn().Err(err).Str("sha", sha).Msg("Failed to look up pull requests of commit")
+			}
+			if pr := pullRequestOfCommit(found, sha); pr != nil {
+				number = pr.GetNumber()
+				prs[number] = pr
+			}
+			bySHA[sha] = number
+		}
+		numbers[i] = number
+	}
+
+	for i, number := range numbers {
+		if number == 0 {
+			continue
+		}
+		pr, ok := prs[number]
+		if !ok {
+			err := c.executeWithRateLimit(ctx, func() error {
+				var err error
+				pr, err = c.GetPullRequest(ctx, number)
+				return err
+			})
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				c.logger.Warn().Err(err).Int("number", number).Msg("Failed to get pull request")
+				pr = listed[number]
+			}
+			// Failed lookups are cached too, so they are only tried once
+			prs[number] = pr
+		}
+		runs[i].PullRequest = trimPullRequest(pr)
+	}
+
+	return nil
+}
+
+// lookupPullRequest reports whether a run without pull requests may still belong to one
+func lookupPullRequest(run *github.WorkflowRun, defaultBranch string) bool {
+	if run.GetHeadSHA() == "" {
+		return false
+	}
+	switch run.GetEvent() {
+	case "pull_request", "pull_request_target":
+		return true
+	case "push":
+		return run.GetHeadBranch() != defaultBranch
+	}
+	return false
+}
+
+// trimPullRequest keeps the fields of a pull request that reports use, since it is
+// saved and uploaded with every job of its runs
+func trimPullRequest(pr *github.PullRequest) *github.PullRequest {
+	if pr == nil {
+		return nil
+	}
+	trimmed := &github.PullRequest{
+		ID:       pr.ID,
+		Number:   pr.Number,
+		Title:    pr.Title,
+		State:    pr.State,
+		HTMLURL:  pr.HTMLURL,
+		MergedAt: pr.MergedAt,
+	}
+	if pr.User != nil {
+		trimmed.User = &github.User{Login: pr.User.Login}
+	}
+	return trimmed
+}
+
+// pullRequestOfCommit picks the pull request whose head is the commit, or else the first one
+func pullRequestOfCommit(prs []*github.PullRequest, sha string) *github.PullRequest {
+	for _, pr := range prs {
+		if pr.GetHead().GetSHA() == sha {
+			return pr
+		}
+	}
+	if len(prs) > 0 {
+		return prs[0]
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"text/template"
+
+	"github.com/noamtamir/gh-octoscope/internal/reports"
+	"github.com/rs/zerolog"
+)
+
+// WebhookData is the data a webhook template is executed with, for every batch
+type WebhookData struct {
+	Jobs            []reports.FlatJobDetails
+	JobCount        int
+	BillableInUSD   float64
+	BillableMinutes float64
+}
+
+type webhookSink struct {
+	httpClient *http.Client
+	url        string
+	headers    map[string]string
+	template   *template.Template
+	logger     zerolog.Logger
+}
+
+type WebhookConfig struct {
+	URL     string
+	Headers map[string]string // sent with every request, e.g. for authentication
+	// Template is a text/template rendering the JSON body of a request from WebhookData,
+	// empty to send {"jobs": [...]} like the Octoscope server expects.
+	// The json function encodes a value as JSON, e.g. {{json .Jobs}}.
+	Template string
+	Logger   zerolog.Logger
+}
+
+// NewWebhookSink creates a sink that POSTs every batch as JSON to a URL
+func NewWebhookSink(cfg WebhookConfig) (Sink, error) {
+	sink := &webhookSink{
+		httpClient: &http.Client{},
+		url:        cfg.URL,
+		headers:    cfg.Headers,
+		logger:     cfg.Logger,
+	}
+	if cfg.Template != "" {
+		tmpl, err := template.New("webhook").Funcs(template.FuncMap{
+			"json": func(v any) (string, error) {
+				content, err := json.Marshal(v)
+				return string(content), err
+			},
+		}).Parse(cfg.Template)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse webhook template: %w", err)
+		}
+		sink.template = tmpl
+	}
+	return sink, nil
+}
+
+func (s *webhookSink) SyncJobs(ctx context.Context, jobs []reports.JobDetails, shouldObfuscate bool) error {
+	body, err := s.body(jobs, shouldObfuscate)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range s.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("webhook returned error: status=%d body=%s", resp.StatusCode, string(respBody))
+	}
+
+	s.logger.Debug().
+		Int("job_count", len(jobs)).
+		Int("status", resp.StatusCode).
+		Msg("Successfully sent job batch to webhook")
+	return nil
+}
+
+// body renders the request body of a batch
+func (s *webhookSink) body(jobs []reports.JobDetails, shouldObfuscate bool) ([]byte, error) {
+	flattened := reports.FlattenJobs(jobs, shouldObfuscate)
+	if s.template == nil {
+		body, err := json.Marshal(struct {
+			Jobs []reports.FlatJobDetails `json:"jobs"`
+		}{Jobs: flattened})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal payload: %w", err)
+		}
+		return body, nil
+	}
+
+	data := WebhookData{Jobs: flattened, JobCount: len(jobs)}
+	for _, job := range jobs {
+		data.BillableInUSD += job.BillableInUSD
+		data.BillableMinutes += job.RoundedUpJobDuration.Minutes()
+	}
+	var buf bytes.Buffer
+	if err := s.template.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to execute webhook template: %w", err)
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, fmt.Errorf("webhook template rendered invalid JSON: %s", buf.String())
+	}
+	return buf.Bytes(), nil
+}
@@ -2,6 +2,7 @@ package billing
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/go-github/v62/github"
@@ -60,8 +61,28 @@ const (
 	RunnerSelfHosted RunnerType = "SELF_HOSTED" // Any runner with "self-hosted" label
 )
 
+// BillingBasis selects the part of a job's lifetime that is billed
+type BillingBasis string
+
+const (
+	BillTotal     BillingBasis = "total"     // from creation to completion, including the time queued for a runner
+	BillExecution BillingBasis = "execution" // from start to completion, on the runner only
+)
+
+// ParseBillingBasis validates a billing basis, e.g. from the --bill-on flag
+func ParseBillingBasis(name string) (BillingBasis, error) {
+	switch basis := BillingBasis(strings.ToLower(strings.TrimSpace(name))); basis {
+	case BillTotal, BillExecution:
+		return basis, nil
+	case "":
+		return BillTotal, nil
+	}
+	return "", fmt.Errorf("unsupported billing basis %q, must be one of: total, execution", name)
+}
+
 type PriceConfig struct {
 	Prices map[RunnerType]float64
+	Basis  BillingBasis // defaults to BillTotal
 }
 
 func DefaultPriceConfig() *PriceConfig {
@@ -126,10 +147,27 @@ func NewCalculator(cfg *PriceConfig, logger zerolog.Logger) *Calculator {
 }
 
 type JobCost struct {
-	ActualDuration   time.Duration
-	BillableDuration time.Duration
-	PricePerMinute   float64
-	TotalBillableUSD float64
+	ActualDuration    time.Duration // billed duration before rounding, see BillingBasis
+	BillableDuration  time.Duration
+	QueueDuration     time.Duration // from creation to start, waiting for a runner
+	ExecutionDuration time.Duration // from start to completion
+	PricePerMinute    float64
+	TotalBillableUSD  float64
+}
+
+// JobTimings splits the lifetime of a job into the time it was queued waiting for a runner
+// and the time it executed. Missing or out of order timestamps give zero durations.
+func JobTimings(job *github.WorkflowJob) (queue, execution time.Duration) {
+	if job.StartedAt == nil {
+		return 0, 0
+	}
+	if job.CreatedAt != nil && job.StartedAt.After(job.CreatedAt.Time) {
+		queue = job.StartedAt.Sub(job.CreatedAt.Time)
+	}
+	if job.CompletedAt != nil && job.CompletedAt.After(job.StartedAt.Time) {
+		execution = job.CompletedAt.Sub(job.StartedAt.Time)
+	}
+	return queue, execution
 }
 
 // CalculateJobCost calculates the job cost by first determining the runner type from job labels
@@ -154,7 +192,11 @@ func (c *Calculator) CalculateJobCost(job *github.WorkflowJob) (*JobCost, Runner
 	// Determine runner type based on job labels
 	runnerType := DetermineRunnerTypeFromLabels(job, c.logger)
 
+	queue, execution := JobTimings(job)
 	duration := job.CompletedAt.Sub(job.CreatedAt.Time)
+	if c.priceConfig.Basis == BillExecution && job.StartedAt != nil {
+		duration = execution
+	}
 	rounded := c.roundUpToMinute(duration)
 	pricePerMinute := c.getPricePerMinute(runnerType)
 	billable := c.calculateBillablePrice(pricePerMinute, rounded)
@@ -165,10 +207,12 @@ func (c *Calculator) CalculateJobCost(job *github.WorkflowJob) (*JobCost, Runner
 	}
 
 	return &JobCost{
-		ActualDuration:   duration,
-		BillableDuration: rounded,
-		PricePerMinute:   pricePerMinute,
-		TotalBillableUSD: billable,
+		ActualDuration:    duration,
+		BillableDuration:  rounded,
+		QueueDuration:     queue,
+		ExecutionDuration: execution,
+		PricePerMinute:    pricePerMinute,
+		TotalBillableUSD:  billable,
 	}, runnerType, nil
 }
 
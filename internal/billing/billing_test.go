@@ -8,6 +8,7 @@ import (
 	"github.com/google/go-github/v62/github"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRoundUpToMinute(t *testing.T) {
@@ -217,3 +218,44 @@ func TestCalculateJobCost_SpecialCases(t *testing.T) {
 		})
 	}
 }
+
+func TestCalculateJobCost_BillingBasis(t *testing.T) {
+	created := time.Date(2025, 4, 25, 10, 0, 0, 0, time.UTC)
+	job := &github.WorkflowJob{
+		Conclusion:  github.String("success"),
+		CreatedAt:   &github.Timestamp{Time: created},
+		StartedAt:   &github.Timestamp{Time: created.Add(3 * time.Minute)},
+		CompletedAt: &github.Timestamp{Time: created.Add(8 * time.Minute)},
+		Labels:      []string{"ubuntu-latest"},
+		RunnerID:    github.Int64(1),
+		Steps:       []*github.TaskStep{{}},
+	}
+
+	cost, _, err := NewCalculator(nil, zerolog.New(io.Discard)).CalculateJobCost(job)
+	require.NoError(t, err)
+	assert.Equal(t, 8*time.Minute, cost.ActualDuration)
+	assert.Equal(t, 3*time.Minute, cost.QueueDuration)
+	assert.Equal(t, 5*time.Minute, cost.ExecutionDuration)
+	assert.InDelta(t, 0.064, cost.TotalBillableUSD, 0.0001)
+
+	config := DefaultPriceConfig()
+	config.Basis = BillExecution
+	cost, _, err = NewCalculator(config, zerolog.New(io.Discard)).CalculateJobCost(job)
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Minute, cost.ActualDuration)
+	assert.Equal(t, 3*time.Minute, cost.QueueDuration)
+	assert.InDelta(t, 0.04, cost.TotalBillableUSD, 0.0001)
+}
+
+func TestParseBillingBasis(t *testing.T) {
+	basis, err := ParseBillingBasis(" Execution ")
+	require.NoError(t, err)
+	assert.Equal(t, BillExecution, basis)
+
+	basis, err = ParseBillingBasis("")
+	require.NoError(t, err)
+	assert.Equal(t, BillTotal, basis)
+
+	_, err = ParseBillingBasis("queue")
+	assert.Error(t, err)
+}
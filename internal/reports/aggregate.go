@@ -0,0 +1,279 @@
+package reports
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Dimension is a job attribute that costs can be grouped by
+type Dimension string
+
+const (
+	DimensionRepo       Dimension = "repo"
+	DimensionWorkflow   Dimension = "workflow"
+	DimensionJob        Dimension = "job"
+	DimensionRunner     Dimension = "runner"
+	DimensionBranch     Dimension = "branch"
+	DimensionEvent      Dimension = "event"
+	DimensionActor      Dimension = "actor"
+	DimensionConclusion Dimension = "conclusion"
+	DimensionDay        Dimension = "day"
+	DimensionWeek       Dimension = "week"
+	DimensionMonth      Dimension = "month"
+	DimensionPR         Dimension = "pr"
+	DimensionStep       Dimension = "step" // splits jobs into their steps, see JobSteps
+)
+
+// tagDimensionPrefix prefixes the name of a cost tag to group by it, e.g. tag:cost_center
+const tagDimensionPrefix = "tag:"
+
+// TagDimension returns the dimension that groups jobs by the value of a cost tag
+func TagDimension(name string) Dimension {
+	return Dimension(tagDimensionPrefix + strings.ToLower(name))
+}
+
+// Tag returns the name of the cost tag a tag dimension groups by
+func (d Dimension) Tag() (string, bool) {
+	name, ok := strings.CutPrefix(string(d), tagDimensionPrefix)
+	return name, ok && name != ""
+}
+
+// Dimensions lists all supported dimensions, next to a tag dimension per cost tag
+var Dimensions = []Dimension{
+	DimensionRepo,
+	DimensionWorkflow,
+	DimensionJob,
+	DimensionRunner,
+	DimensionBranch,
+	DimensionEvent,
+	DimensionActor,
+	DimensionConclusion,
+	DimensionDay,
+	DimensionWeek,
+	DimensionMonth,
+	DimensionPR,
+	DimensionStep,
+}
+
+// ParseDimensions converts dimension names, e.g. from the --group-by flag, into dimensions
+func ParseDimensions(names []string) ([]Dimension, error) {
+	var dims []Dimension
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		dim, err := parseDimension(name)
+		if err != nil {
+			return nil, err
+		}
+		dims = append(dims, dim)
+	}
+	return dims, nil
+}
+
+func parseDimension(name string) (Dimension, error) {
+	if tag, ok := Dimension(name).Tag(); ok {
+		return TagDimension(tag), nil
+	}
+	for _, d := range Dimensions {
+		if string(d) == name {
+			return d, nil
+		}
+	}
+
+	supported := make([]string, len(Dimensions))
+	for i, d := range Dimensions {
+		supported[i] = string(d)
+	}
+	supported = append(supported, tagDimensionPrefix+"<name>")
+	return "", fmt.Errorf("unsupported dimension %q, must be one of: %s", name, strings.Join(supported, ", "))
+}
+
+// Group is the aggregate of all jobs sharing the same dimension values
+type Group struct {
+	Keys            []string // one value per dimension, in order
+	Key             string   // the values joined for display, e.g. "CI / UBUNTU"
+	BillableInUSD   float64
+	BillableMinutes float64
+	JobCount        int // number of jobs, or of steps when grouped by step
+	DurationP50     time.Duration
+	DurationP95     time.Duration
+	Share           float64 // percentage of total cost, 0-100
+}
+
+// Aggregation is the result of grouping jobs by one or more dimensions.
+// Groups are sorted by cost, most expensive first.
+type Aggregation struct {
+	Dimensions []Dimension
+	Groups     []Group
+}
+
+// Title returns a human readable title for the aggregation, e.g. "workflow × runner"
+func (a Aggregation) Title() string {
+	names := make([]string, len(a.Dimensions))
+	for i, d := range a.Dimensions {
+		names[i] = string(d)
+	}
+	return strings.Join(names, " × ")
+}
+
+// Top returns at most n groups
+func (a Aggregation) Top(n int) []Group {
+	if n > 0 && len(a.Groups) > n {
+		return a.Groups[:n]
+	}
+	return a.Groups
+}
+
+// Aggregate groups jobs by the given dimensions and computes cost, billable minutes,
+// job count and duration percentiles for every group. When shouldObfuscate is set,
+// names are obfuscated the same way as in the flattened job reports.
+func Aggregate(jobs []JobDetails, dims []Dimension, shouldObfuscate bool) Aggregation {
+	flattened := FlattenJobs(jobs, shouldObfuscate)
+
+	total := 0.0
+	index := make(map[string]int)
+	var groups []Group
+	var durations [][]time.Duration
+
+	add := func(job JobDetails, fj FlatJobDetails, step string) {
+		keys := make([]string, len(dims))
+		for d, dim := range dims {
+			if dim == DimensionStep {
+				keys[d] = step
+				continue
+			}
+			keys[d] = dimensionValue(dim, job, fj)
+		}
+		// Groups are told apart by their keys, only joined with " / " to be displayed,
+		// so values containing " / " don't merge different groups
+		key := strings.Join(keys, "\x00")
+
+		idx, ok := index[key]
+		if !ok {
+			idx = len(groups)
+			index[key] = idx
+			groups = append(groups, Group{Keys: keys, Key: strings.Join(keys, " / ")})
+			durations = append(durations, nil)
+		}
+
+		groups[idx].BillableInUSD += job.BillableInUSD
+		groups[idx].BillableMinutes += job.RoundedUpJobDuration.Minutes()
+		groups[idx].JobCount++
+		durations[idx] = append(durations[idx], job.JobDuration)
+		total += job.BillableInUSD
+	}
+
+	bySteps := slices.Contains(dims, DimensionStep)
+	for i, job := range jobs {
+		steps := JobSteps(job)
+		if !bySteps || len(steps) == 0 {
+			add(job, flattened[i], "unknown")
+			continue
+		}
+		// Steps are grouped like jobs, each with its share of the job's cost
+		for _, step := range steps {
+			add(stepJob(job, step), flattened[i], derefOr(&step.Name, "unknown"))
+		}
+	}
+
+	for i := range groups {
+		groups[i].DurationP50 = percentile(durations[i], 50)
+		groups[i].DurationP95 = percentile(durations[i], 95)
+		if total > 0 {
+			groups[i].Share = groups[i].BillableInUSD / total * 100
+		}
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].BillableInUSD > groups[j].BillableInUSD
+	})
+
+	return Aggregation{
+		Dimensions: dims,
+		Groups:     groups,
+	}
+}
+
+// dimensionValue returns the value of a dimension for a job
+func dimensionValue(dim Dimension, job JobDetails, fj FlatJobDetails) string {
+	switch dim {
+	case DimensionRepo:
+		if fj.OwnerName != nil && fj.RepoName != nil {
+			return *fj.OwnerName + "/" + *fj.RepoName
+		}
+		return derefOr(fj.RepoName, "unknown")
+	case DimensionWorkflow:
+		return derefOr(fj.WorkflowName, "unknown")
+	case DimensionJob:
+		return derefOr(fj.JobName, "unknown")
+	case DimensionRunner:
+		return derefOr(fj.Runner, "unknown")
+	case DimensionBranch:
+		return derefOr(fj.HeadBranch, "unknown")
+	case DimensionEvent:
+		return derefOr(fj.WorkflowRunEvent, "unknown")
+	case DimensionActor:
+		return derefOr(fj.ActorLogin, "unknown")
+	case DimensionConclusion:
+		return derefOr(fj.JobConclusion, "unknown")
+	case DimensionPR:
+		if fj.PullRequestNumber == nil {
+			return "none"
+		}
+		return fmt.Sprintf("#%d", *fj.PullRequestNumber)
+	case DimensionDay, DimensionWeek, DimensionMonth:
+		t := jobTime(job)
+		if t.IsZero() {
+			return "unknown"
+		}
+		return periodKey(dim, t)
+	}
+	if tag, ok := dim.Tag(); ok {
+		if value, ok := job.Tags[tag]; ok {
+			return value
+		}
+		return "untagged"
+	}
+	return "unknown"
+}
+
+// periodKey formats t as the calendar period it falls in:
+// 2006-01-02 for days, 2006-W01 (ISO week) for weeks and 2006-01 for months
+func periodKey(dim Dimension, t time.Time) string {
+	t = t.UTC()
+	switch dim {
+	case DimensionWeek:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case DimensionMonth:
+		return t.Format("2006-01")
+	default:
+		return t.Format(time.DateOnly)
+	}
+}
+
+// percentile returns the p-th percentile of durations using the nearest-rank method
+func percentile(durations []time.Duration, p float64) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+
+	sorted := make([]time.Duration, len(durations))
+	copy(sorted, durations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,142 @@
+package reports
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// aggregateTestJob creates a job with the given attributes for aggregation tests
+func aggregateTestJob(workflow, runner, conclusion string, created time.Time, duration time.Duration, cost float64) JobDetails {
+	return JobDetails{
+		Repo: &github.Repository{
+			ID:    github.Int64(1),
+			Name:  github.String("testrepo"),
+			Owner: &github.User{Login: github.String("testowner")},
+		},
+		Workflow: &github.Workflow{ID: github.Int64(2), Name: github.String(workflow)},
+		WorkflowRun: &github.WorkflowRun{
+			ID:         github.Int64(3),
+			HeadBranch: github.String("main"),
+			Event:      github.String("push"),
+			CreatedAt:  &github.Timestamp{Time: created},
+			Actor:      &github.User{Login: github.String("testactor")},
+		},
+		Job: &github.WorkflowJob{
+			ID:         github.Int64(4),
+			Name:       github.String("build"),
+			Conclusion: github.String(conclusion),
+		},
+		JobDuration:          duration,
+		RoundedUpJobDuration: duration,
+		BillableInUSD:        cost,
+		Runner:               runner,
+	}
+}
+
+func TestParseDimensions(t *testing.T) {
+	dims, err := ParseDimensions([]string{"workflow", " Runner ", "", "week"})
+	require.NoError(t, err)
+	assert.Equal(t, []Dimension{DimensionWorkflow, DimensionRunner, DimensionWeek}, dims)
+
+	_, err = ParseDimensions([]string{"colour"})
+	assert.ErrorContains(t, err, `unsupported dimension "colour"`)
+}
+
+func TestAggregate(t *testing.T) {
+	day := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+	jobs := []JobDetails{
+		aggregateTestJob("CI", "UBUNTU", "success", day, 10*time.Minute, 0.08),
+		aggregateTestJob("CI", "UBUNTU", "failure", day, 20*time.Minute, 0.16),
+		aggregateTestJob("CI", "WINDOWS", "success", day.AddDate(0, 0, 7), 10*time.Minute, 0.16),
+		aggregateTestJob("Release", "MACOS", "success", day.AddDate(0, 1, 0), 5*time.Minute, 0.60),
+	}
+
+	t.Run("SingleDimension", func(t *testing.T) {
+		agg := Aggregate(jobs, []Dimension{DimensionWorkflow}, false)
+		require.Len(t, agg.Groups, 2)
+
+		// Sorted by cost, most expensive first
+		assert.Equal(t, "Release", agg.Groups[0].Key)
+		assert.Equal(t, "CI", agg.Groups[1].Key)
+		assert.InDelta(t, 0.40, agg.Groups[1].BillableInUSD, 0.0001)
+		assert.Equal(t, 40.0, agg.Groups[1].BillableMinutes)
+		assert.Equal(t, 3, agg.Groups[1].JobCount)
+		assert.InDelta(t, 40.0, agg.Groups[1].Share, 0.0001)
+	})
+
+	t.Run("MultipleDimensions", func(t *testing.T) {
+		agg := Aggregate(jobs, []Dimension{DimensionWorkflow, DimensionRunner}, false)
+		require.Len(t, agg.Groups, 3)
+		assert.Equal(t, "workflow × runner", agg.Title())
+
+		var ciUbuntu Group
+		for _, g := range agg.Groups {
+			if g.Key == "CI / UBUNTU" {
+				ciUbuntu = g
+			}
+		}
+		assert.Equal(t, []string{"CI", "UBUNTU"}, ciUbuntu.Keys)
+		assert.Equal(t, 2, ciUbuntu.JobCount)
+		assert.Equal(t, 10*time.Minute, ciUbuntu.DurationP50)
+		assert.Equal(t, 20*time.Minute, ciUbuntu.DurationP95)
+	})
+
+	t.Run("SeparatorInValues", func(t *testing.T) {
+		// "A / B" × "C" and "A" × "B / C" display the same, but are different groups
+		jobs := []JobDetails{
+			aggregateTestJob("A / B", "C", "success", day, time.Minute, 0.01),
+			aggregateTestJob("A", "B / C", "success", day, time.Minute, 0.02),
+		}
+		agg := Aggregate(jobs, []Dimension{DimensionWorkflow, DimensionRunner}, false)
+		require.Len(t, agg.Groups, 2)
+		assert.Equal(t, []string{"A", "B / C"}, agg.Groups[0].Keys)
+		assert.Equal(t, []string{"A / B", "C"}, agg.Groups[1].Keys)
+		assert.Equal(t, "A / B / C", agg.Groups[1].Key)
+	})
+
+	t.Run("TimeDimensions", func(t *testing.T) {
+		assert.Len(t, Aggregate(jobs, []Dimension{DimensionDay}, false).Groups, 3)
+		assert.Len(t, Aggregate(jobs, []Dimension{DimensionMonth}, false).Groups, 2)
+
+		weeks := Aggregate(jobs, []Dimension{DimensionWeek}, false)
+		keys := map[string]bool{}
+		for _, g := range weeks.Groups {
+			keys[g.Key] = true
+		}
+		assert.True(t, keys["2025-W14"])
+		assert.True(t, keys["2025-W15"])
+	})
+
+	t.Run("OtherDimensions", func(t *testing.T) {
+		assert.Equal(t, "testowner/testrepo", Aggregate(jobs, []Dimension{DimensionRepo}, false).Groups[0].Key)
+		assert.Equal(t, "push", Aggregate(jobs, []Dimension{DimensionEvent}, false).Groups[0].Key)
+		assert.Equal(t, "build", Aggregate(jobs, []Dimension{DimensionJob}, false).Groups[0].Key)
+		assert.Equal(t, "main", Aggregate(jobs, []Dimension{DimensionBranch}, false).Groups[0].Key)
+		assert.Len(t, Aggregate(jobs, []Dimension{DimensionConclusion}, false).Groups, 2)
+	})
+
+	t.Run("Obfuscation", func(t *testing.T) {
+		agg := Aggregate(jobs, []Dimension{DimensionActor}, true)
+		require.Len(t, agg.Groups, 1)
+		assert.Equal(t, "tes******", agg.Groups[0].Key)
+	})
+
+	t.Run("Top", func(t *testing.T) {
+		agg := Aggregate(jobs, []Dimension{DimensionRunner}, false)
+		assert.Len(t, agg.Top(2), 2)
+		assert.Len(t, agg.Top(0), 3)
+	})
+}
+
+func TestPercentile(t *testing.T) {
+	durations := []time.Duration{5, 1, 4, 2, 3, 6, 7, 8, 9, 10}
+	assert.Equal(t, time.Duration(5), percentile(durations, 50))
+	assert.Equal(t, time.Duration(10), percentile(durations, 95))
+	assert.Equal(t, time.Duration(0), percentile(nil, 50))
+	// The input is not reordered
+	assert.Equal(t, time.Duration(5), durations[0])
+}
@@ -0,0 +1,539 @@
+package reports
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	// DefaultAnomalyThreshold is the default modified z-score above which a value is an anomaly
+	DefaultAnomalyThreshold = 3.5
+	// DefaultAnomalyMinSamples is the default number of values needed to build a baseline
+	DefaultAnomalyMinSamples = 5
+	// DefaultAnomalySpendWindow is the default number of previous days in the daily spend baseline
+	DefaultAnomalySpendWindow = 14
+
+	// madScale makes the median absolute deviation comparable to a standard deviation
+	madScale = 0.6745
+	// meanADScale is used instead of madScale when more than half of the values are identical
+	meanADScale = 0.7979
+	// spendAnomalyRuns is the number of runs listed for every anomalous day
+	spendAnomalyRuns = 3
+)
+
+// AnomalyConfig configures anomaly detection
+type AnomalyConfig struct {
+	Threshold   float64 // modified z-score above which a value is an anomaly, defaults to DefaultAnomalyThreshold
+	MinSamples  int     // values needed to build a baseline, defaults to DefaultAnomalyMinSamples
+	SpendWindow int     // previous days in the daily spend baseline, defaults to DefaultAnomalySpendWindow
+}
+
+// DurationAnomaly is a job that ran much longer than the other runs of the same job
+type DurationAnomaly struct {
+	Workflow       string
+	Job            string
+	Runner         string
+	CreatedAt      time.Time
+	Duration       time.Duration
+	MedianDuration time.Duration // the baseline of the workflow's job
+	Score          float64       // modified z-score of the duration
+	BillableInUSD  float64
+	ExcessInUSD    float64 // the part of the cost above the baseline duration
+	RunURL         string
+	JobURL         string
+}
+
+// AnomalyRun is one of the most expensive runs of an anomalous day
+type AnomalyRun struct {
+	Workflow      string
+	RunID         int64
+	BillableInUSD float64
+	URL           string
+}
+
+// SpendAnomaly is a day whose spend is much higher than on the previous days
+type SpendAnomaly struct {
+	Date          time.Time
+	BillableInUSD float64
+	Baseline      float64 // median spend of the previous days
+	Score         float64 // modified z-score of the spend
+	ExcessInUSD   float64 // spend above the baseline
+	Runs          []AnomalyRun
+}
+
+// Anomalies are the outliers found in a set of jobs.
+// Both lists are sorted by the cost attributable to the anomaly, largest first.
+type Anomalies struct {
+	Threshold float64
+	Durations []DurationAnomaly
+	Spend     []SpendAnomaly
+}
+
+// DetectAnomalies builds duration baselines per workflow job and a trailing daily spend
+// baseline using the median and the median absolute deviation (MAD), and returns the jobs
+// and days whose modified z-score exceeds the threshold. When shouldObfuscate is set,
+// names are obfuscated the same way as in the flattened job reports and URLs are omitted.
+func DetectAnomalies(jobs []JobDetails, config AnomalyConfig, shouldObfuscate bool) Anomalies {
+	if config.Threshold <= 0 {
+		config.Threshold = DefaultAnomalyThreshold
+	}
+	if config.MinSamples <= 0 {
+		config.MinSamples = DefaultAnomalyMinSamples
+	}
+	if config.SpendWindow <= 0 {
+		config.SpendWindow = DefaultAnomalySpendWindow
+	}
+
+	flattened := FlattenJobs(jobs, shouldObfuscate)
+	return Anomalies{
+		Threshold: config.Threshold,
+		Durations: durationAnomalies(jobs, flattened, config, shouldObfuscate),
+		Spend:     spendAnomalies(jobs, flattened, config, shouldObfuscate),
+	}
+}
+
+func durationAnomalies(jobs []JobDetails, flattened []FlatJobDetails, config AnomalyConfig, shouldObfuscate bool) []DurationAnomaly {
+	// Baselines are per job of a workflow
+	byJob := make(map[diffKey][]int)
+	for i := range jobs {
+		key := diffKey{
+			workflow: derefOr(flattened[i].WorkflowName, "unknown"),
+			job:      derefOr(flattened[i].JobName, "unknown"),
+		}
+		byJob[key] = append(byJob[key], i)
+	}
+
+	var anomalies []DurationAnomaly
+	for key, indexes := range byJob {
+		if len(indexes) < config.MinSamples {
+			continue
+		}
+
+		durations := make([]float64, len(indexes))
+		for n, i := range indexes {
+			durations[n] = jobs[i].JobDuration.Seconds()
+		}
+		median, score := robustScorer(durations)
+
+		for n, i := range indexes {
+			z := score(durations[n])
+			// Only jobs running longer than usual are a cost problem
+			if z <= config.Threshold {
+				continue
+			}
+
+			job := jobs[i]
+			medianDuration := time.Duration(median * float64(time.Second))
+			anomaly := DurationAnomaly{
+				Workflow:       key.workflow,
+				Job:            key.job,
+				Runner:         job.Runner,
+				CreatedAt:      jobTime(job),
+				Duration:       job.JobDuration,
+				MedianDuration: medianDuration,
+				Score:          z,
+				BillableInUSD:  job.BillableInUSD,
+			}
+			if job.JobDuration > 0 {
+				anomaly.ExcessInUSD = job.BillableInUSD * float64(job.JobDuration-medianDuration) / float64(job.JobDuration)
+			}
+			if !shouldObfuscate {
+				anomaly.RunURL = runURL(job)
+				if job.Job != nil && job.Job.HTMLURL != nil {
+					anomaly.JobURL = *job.Job.HTMLURL
+				}
+			}
+			anomalies = append(anomalies, anomaly)
+		}
+	}
+
+	sort.Slice(anomalies, func(i, j int) bool {
+		if anomalies[i].ExcessInUSD != anomalies[j].ExcessInUSD {
+			return anomalies[i].ExcessInUSD > anomalies[j].ExcessInUSD
+		}
+		return anomalies[i].CreatedAt.Before(anomalies[j].CreatedAt)
+	})
+	return anomalies
+}
+
+func spendAnomalies(jobs []JobDetails, flattened []FlatJobDetails, config AnomalyConfig, shouldObfuscate bool) []SpendAnomaly {
+	type dayRun struct {
+		workflow string
+		url      string
+		cost     float64
+	}
+	spend := make(map[time.Time]float64)
+	runs := make(map[time.Time]map[int64]*dayRun)
+	var first, last time.Time
+	for i, job := range jobs {
+		t := jobTime(job)
+		if t.IsZero() {
+			continue
+		}
+		day := periodStart(DimensionDay, t)
+		if first.IsZero() || day.Before(first) {
+			first = day
+		}
+		if day.After(last) {
+			last = day
+		}
+		spend[day] += job.BillableInUSD
+
+		if job.WorkflowRun == nil || job.WorkflowRun.ID == nil {
+			continue
+		}
+		if runs[day] == nil {
+			runs[day] = make(map[int64]*dayRun)
+		}
+		run, ok := runs[day][*job.WorkflowRun.ID]
+		if !ok {
+			run = &dayRun{workflow: derefOr(flattened[i].WorkflowName, "unknown")}
+			if !shouldObfuscate {
+				run.url = runURL(job)
+			}
+			runs[day][*job.WorkflowRun.ID] = run
+		}
+		run.cost += job.BillableInUSD
+	}
+	if first.IsZero() {
+		return nil
+	}
+
+	// Days without any jobs count as zero spend
+	var days []time.Time
+	var series []float64
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+		series = append(series, spend[day])
+	}
+
+	var anomalies []SpendAnomaly
+	for i := config.MinSamples; i < len(series); i++ {
+		window := series[max(0, i-config.SpendWindow):i]
+		median, score := robustScorer(window)
+		z := score(series[i])
+		// Quiet days, e.g. weekends, are expected and don't cost anything
+		if z <= config.Threshold {
+			continue
+		}
+
+		anomaly := SpendAnomaly{
+			Date:          days[i],
+			BillableInUSD: series[i],
+			Baseline:      median,
+			Score:         z,
+			ExcessInUSD:   series[i] - median,
+		}
+		for id, run := range runs[days[i]] {
+			anomaly.Runs = append(anomaly.Runs, AnomalyRun{
+				Workflow:      run.workflow,
+				RunID:         id,
+				BillableInUSD: run.cost,
+				URL:           run.url,
+			})
+		}
+		sort.Slice(anomaly.Runs, func(a, b int) bool {
+			if anomaly.Runs[a].BillableInUSD != anomaly.Runs[b].BillableInUSD {
+				return anomaly.Runs[a].BillableInUSD > anomaly.Runs[b].BillableInUSD
+			}
+			return anomaly.Runs[a].RunID < anomaly.Runs[b].RunID
+		})
+		if len(anomaly.Runs) > spendAnomalyRuns {
+			anomaly.Runs = anomaly.Runs[:spendAnomalyRuns]
+		}
+		anomalies = append(anomalies, anomaly)
+	}
+
+	sort.Slice(anomalies, func(i, j int) bool {
+		if anomalies[i].ExcessInUSD != anomalies[j].ExcessInUSD {
+			return anomalies[i].ExcessInUSD > anomalies[j].ExcessInUSD
+		}
+		return anomalies[i].Date.Before(anomalies[j].Date)
+	})
+	return anomalies
+}
+
+// robustScorer returns the median of values and a function computing the modified
+// z-score of a value against them. When more than half of the values are identical the
+// MAD is zero, so the mean absolute deviation is used instead. When all values are
+// identical, every value is scored 0.
+func robustScorer(values []float64) (float64, func(float64) float64) {
+	median := medianOf(values)
+
+	deviations := make([]float64, len(values))
+	meanAD := 0.0
+	for i, v := range values {
+		deviations[i] = math.Abs(v - median)
+		meanAD += deviations[i]
+	}
+	meanAD /= float64(len(values))
+	mad := medianOf(deviations)
+
+	return median, func(v float64) float64 {
+		switch {
+		case mad > 0:
+			return madScale * (v - median) / mad
+		case meanAD > 0:
+			return meanADScale * (v - median) / meanAD
+		default:
+			return 0
+		}
+	}
+}
+
+// medianOf returns the median of values without reordering them
+func medianOf(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// runURL returns the link to the workflow run of a job, or to the job itself when the run has none
+func runURL(job JobDetails) string {
+	if job.WorkflowRun != nil && job.WorkflowRun.HTMLURL != nil {
+		return *job.WorkflowRun.HTMLURL
+	}
+	if job.Job != nil && job.Job.HTMLURL != nil {
+		return *job.Job.HTMLURL
+	}
+	return ""
+}
+
+// AnomalyGenerator writes anomalies as a plain text table, Markdown or JSON
+type AnomalyGenerator struct {
+	out    io.Writer
+	format string
+	topN   int
+	logger zerolog.Logger
+}
+
+// NewAnomalyGenerator creates a new anomaly generator that writes to out.
+// topN limits the number of anomalies per list in the table and Markdown formats.
+func NewAnomalyGenerator(out io.Writer, format string, topN int, logger zerolog.Logger) *AnomalyGenerator {
+	if topN <= 0 {
+		topN = DefaultTopN
+	}
+	return &AnomalyGenerator{
+		out:    out,
+		format: format,
+		topN:   topN,
+		logger: logger,
+	}
+}
+
+func (g *AnomalyGenerator) Generate(anomalies *Anomalies) error {
+	g.logger.Debug().Str("format", g.format).Msg("Generating anomalies")
+
+	var err error
+	switch g.format {
+	case FormatTable:
+		_, err = io.WriteString(g.out, g.renderTable(anomalies))
+	case FormatMarkdown:
+		_, err = io.WriteString(g.out, g.renderMarkdown(anomalies))
+	case FormatJSON:
+		enc := json.NewEncoder(g.out)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(exportAnomalies(anomalies))
+	default:
+		return fmt.Errorf("unsupported anomalies format %q, must be one of: %s, %s, %s", g.format, FormatTable, FormatMarkdown, FormatJSON)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s anomalies: %w", g.format, err)
+	}
+	return nil
+}
+
+func (g *AnomalyGenerator) renderTable(anomalies *Anomalies) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Long-running jobs (%d)\n", len(anomalies.Durations))
+	if len(anomalies.Durations) == 0 {
+		b.WriteString("No jobs ran unusually long.\n")
+	}
+	for _, a := range topOf(anomalies.Durations, g.topN) {
+		fmt.Fprintf(&b, "\n%s / %s on %s\n", a.Workflow, a.Job, a.CreatedAt.UTC().Format("2006-01-02 15:04"))
+		fmt.Fprintf(&b, "  ran %s, usually %s (score %.1f) on %s\n", a.Duration.Round(time.Second), a.MedianDuration.Round(time.Second), a.Score, a.Runner)
+		fmt.Fprintf(&b, "  cost %s, of which %s above the usual duration\n", formatUSD(a.BillableInUSD), formatUSD(a.ExcessInUSD))
+		if a.RunURL != "" {
+			fmt.Fprintf(&b, "  %s\n", a.RunURL)
+		}
+	}
+
+	fmt.Fprintf(&b, "\nUnusual daily spend (%d)\n", len(anomalies.Spend))
+	if len(anomalies.Spend) == 0 {
+		b.WriteString("No days with unusual spend.\n")
+	}
+	for _, a := range topOf(anomalies.Spend, g.topN) {
+		fmt.Fprintf(&b, "\n%s: %s, usually %s (%s, score %.1f)\n",
+			a.Date.Format(time.DateOnly), formatUSD(a.BillableInUSD), formatUSD(a.Baseline), formatSignedUSD(a.ExcessInUSD), a.Score)
+		for _, run := range a.Runs {
+			fmt.Fprintf(&b, "  %-10s %s %s\n", formatUSD(run.BillableInUSD), run.Workflow, run.URL)
+		}
+	}
+	return b.String()
+}
+
+func (g *AnomalyGenerator) renderMarkdown(anomalies *Anomalies) string {
+	var b strings.Builder
+
+	b.WriteString("## GitHub Actions anomalies\n\n")
+
+	fmt.Fprintf(&b, "### Long-running jobs (%d)\n\n", len(anomalies.Durations))
+	if len(anomalies.Durations) == 0 {
+		b.WriteString("No jobs ran unusually long.\n\n")
+	} else {
+		b.WriteString("| Job | Started | Duration | Usual | Score | Runner | Cost | Above usual |\n")
+		b.WriteString("| --- | --- | ---: | ---: | ---: | --- | ---: | ---: |\n")
+		for _, a := range topOf(anomalies.Durations, g.topN) {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | %.1f | %s | %s | %s |\n",
+				markdownLink(a.Workflow+" / "+a.Job, a.RunURL),
+				a.CreatedAt.UTC().Format("2006-01-02 15:04"),
+				a.Duration.Round(time.Second),
+				a.MedianDuration.Round(time.Second),
+				a.Score,
+				escapeMarkdownCell(a.Runner),
+				formatUSD(a.BillableInUSD),
+				formatUSD(a.ExcessInUSD))
+		}
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, "### Unusual daily spend (%d)\n\n", len(anomalies.Spend))
+	if len(anomalies.Spend) == 0 {
+		b.WriteString("No days with unusual spend.\n\n")
+	} else {
+		b.WriteString("| Day | Spend | Usual | Difference | Score | Most expensive runs |\n")
+		b.WriteString("| --- | ---: | ---: | ---: | ---: | --- |\n")
+		for _, a := range topOf(anomalies.Spend, g.topN) {
+			runs := make([]string, len(a.Runs))
+			for i, run := range a.Runs {
+				runs[i] = markdownLink(run.Workflow, run.URL) + " " + formatUSD(run.BillableInUSD)
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | %.1f | %s |\n",
+				a.Date.Format(time.DateOnly),
+				formatUSD(a.BillableInUSD),
+				formatUSD(a.Baseline),
+				formatSignedUSD(a.ExcessInUSD),
+				a.Score,
+				strings.Join(runs, ", "))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// topOf returns at most n items
+func topOf[T any](items []T, n int) []T {
+	if len(items) > n {
+		return items[:n]
+	}
+	return items
+}
+
+// markdownLink formats text as a Markdown link, or as plain text when there is no URL
+func markdownLink(text, url string) string {
+	text = escapeMarkdownCell(text)
+	if url == "" {
+		return text
+	}
+	return fmt.Sprintf("[%s](%s)", text, url)
+}
+
+// AnomaliesExport is the document written by the json anomalies format. Durations are in seconds.
+type AnomaliesExport struct {
+	SchemaVersion string                  `json:"schema_version"`
+	Threshold     float64                 `json:"threshold"`
+	Durations     []DurationAnomalyExport `json:"durations"`
+	Spend         []SpendAnomalyExport    `json:"spend"`
+}
+
+// DurationAnomalyExport is a long-running job in a JSON anomalies export
+type DurationAnomalyExport struct {
+	Workflow              string  `json:"workflow"`
+	Job                   string  `json:"job"`
+	Runner                string  `json:"runner,omitempty"`
+	CreatedAt             string  `json:"created_at,omitempty"`
+	DurationSeconds       float64 `json:"duration"`
+	MedianDurationSeconds float64 `json:"median_duration"`
+	Score                 float64 `json:"score"`
+	BillableInUSD         float64 `json:"billable_in_usd"`
+	ExcessInUSD           float64 `json:"excess_in_usd"`
+	RunURL                string  `json:"run_url,omitempty"`
+	JobURL                string  `json:"job_url,omitempty"`
+}
+
+// SpendAnomalyExport is a day with unusual spend in a JSON anomalies export
+type SpendAnomalyExport struct {
+	Date          string             `json:"date"`
+	BillableInUSD float64            `json:"billable_in_usd"`
+	BaselineInUSD float64            `json:"baseline_in_usd"`
+	Score         float64            `json:"score"`
+	ExcessInUSD   float64            `json:"excess_in_usd"`
+	Runs          []AnomalyRunExport `json:"runs"`
+}
+
+// AnomalyRunExport is one of the most expensive runs of a day with unusual spend
+type AnomalyRunExport struct {
+	Workflow      string  `json:"workflow"`
+	RunID         int64   `json:"run_id"`
+	BillableInUSD float64 `json:"billable_in_usd"`
+	URL           string  `json:"url,omitempty"`
+}
+
+func exportAnomalies(anomalies *Anomalies) AnomaliesExport {
+	export := AnomaliesExport{
+		SchemaVersion: ExportSchemaVersion,
+		Threshold:     anomalies.Threshold,
+		Durations:     []DurationAnomalyExport{},
+		Spend:         []SpendAnomalyExport{},
+	}
+	for _, a := range anomalies.Durations {
+		d := DurationAnomalyExport{
+			Workflow:              a.Workflow,
+			Job:                   a.Job,
+			Runner:                a.Runner,
+			DurationSeconds:       a.Duration.Seconds(),
+			MedianDurationSeconds: a.MedianDuration.Seconds(),
+			Score:                 a.Score,
+			BillableInUSD:         a.BillableInUSD,
+			ExcessInUSD:           a.ExcessInUSD,
+			RunURL:                a.RunURL,
+			JobURL:                a.JobURL,
+		}
+		if !a.CreatedAt.IsZero() {
+			d.CreatedAt = a.CreatedAt.UTC().Format(time.RFC3339)
+		}
+		export.Durations = append(export.Durations, d)
+	}
+	for _, a := range anomalies.Spend {
+		s := SpendAnomalyExport{
+			Date:          a.Date.Format(time.DateOnly),
+			BillableInUSD: a.BillableInUSD,
+			BaselineInUSD: a.Baseline,
+			Score:         a.Score,
+			ExcessInUSD:   a.ExcessInUSD,
+			Runs:          []AnomalyRunExport{},
+		}
+		for _, run := range a.Runs {
+			s.Runs = append(s.Runs, AnomalyRunExport(run))
+		}
+		export.Spend = append(export.Spend, s)
+	}
+	return export
+}
@@ -0,0 +1,145 @@
+package reports
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupAnomalyTestData returns two weeks of daily test runs of about 10 minutes,
+// with a hung run of 6 hours on the last day
+func setupAnomalyTestData() []JobDetails {
+	start := time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC)
+	var jobs []JobDetails
+	for day := 0; day < 14; day++ {
+		duration := time.Duration(9+day%3) * time.Minute
+		job := diffTestJob("CI", "test", int64(day+1), start.AddDate(0, 0, day), duration, duration.Minutes()*0.008)
+		job.WorkflowRun.HTMLURL = github.String("https://github.com/testowner/testrepo/actions/runs/" + string(rune('a'+day)))
+		jobs = append(jobs, job)
+	}
+
+	hung := diffTestJob("CI", "test", 100, start.AddDate(0, 0, 14), 6*time.Hour, 360*0.256)
+	hung.Runner = "UBUNTU_64_CORE"
+	hung.WorkflowRun.HTMLURL = github.String("https://github.com/testowner/testrepo/actions/runs/100")
+	hung.Job.HTMLURL = github.String("https://github.com/testowner/testrepo/actions/runs/100/job/1")
+	return append(jobs, hung)
+}
+
+func TestDetectAnomalies(t *testing.T) {
+	anomalies := DetectAnomalies(setupAnomalyTestData(), AnomalyConfig{}, false)
+	assert.Equal(t, DefaultAnomalyThreshold, anomalies.Threshold)
+
+	t.Run("Durations", func(t *testing.T) {
+		require.Len(t, anomalies.Durations, 1)
+		a := anomalies.Durations[0]
+		assert.Equal(t, "CI", a.Workflow)
+		assert.Equal(t, "test", a.Job)
+		assert.Equal(t, "UBUNTU_64_CORE", a.Runner)
+		assert.Equal(t, 6*time.Hour, a.Duration)
+		assert.Equal(t, 10*time.Minute, a.MedianDuration)
+		assert.Greater(t, a.Score, DefaultAnomalyThreshold)
+		assert.InDelta(t, 92.16, a.BillableInUSD, 0.0001)
+		assert.InDelta(t, 92.16*350/360, a.ExcessInUSD, 0.0001)
+		assert.Equal(t, "https://github.com/testowner/testrepo/actions/runs/100", a.RunURL)
+		assert.Equal(t, "https://github.com/testowner/testrepo/actions/runs/100/job/1", a.JobURL)
+	})
+
+	t.Run("Spend", func(t *testing.T) {
+		require.Len(t, anomalies.Spend, 1)
+		a := anomalies.Spend[0]
+		assert.Equal(t, time.Date(2025, 4, 15, 0, 0, 0, 0, time.UTC), a.Date)
+		assert.InDelta(t, 0.08, a.Baseline, 0.0001)
+		assert.InDelta(t, 92.16-0.08, a.ExcessInUSD, 0.0001)
+		require.Len(t, a.Runs, 1)
+		assert.Equal(t, int64(100), a.Runs[0].RunID)
+		assert.Equal(t, "https://github.com/testowner/testrepo/actions/runs/100", a.Runs[0].URL)
+	})
+
+	t.Run("MinSamples", func(t *testing.T) {
+		anomalies := DetectAnomalies(setupAnomalyTestData(), AnomalyConfig{MinSamples: 20}, false)
+		assert.Empty(t, anomalies.Durations)
+		assert.Empty(t, anomalies.Spend)
+	})
+
+	t.Run("Obfuscated", func(t *testing.T) {
+		anomalies := DetectAnomalies(setupAnomalyTestData(), AnomalyConfig{}, true)
+		require.Len(t, anomalies.Durations, 1)
+		assert.Empty(t, anomalies.Durations[0].RunURL)
+		assert.Empty(t, anomalies.Spend[0].Runs[0].URL)
+	})
+
+	t.Run("IdenticalDurations", func(t *testing.T) {
+		var jobs []JobDetails
+		for i := 0; i < 6; i++ {
+			jobs = append(jobs, diffTestJob("CI", "build", int64(i), time.Time{}, time.Minute, 0.008))
+		}
+		assert.Empty(t, DetectAnomalies(jobs, AnomalyConfig{}, false).Durations)
+	})
+}
+
+func TestRobustScorer(t *testing.T) {
+	median, score := robustScorer([]float64{1, 2, 3, 4, 100})
+	assert.Equal(t, 3.0, median)
+	assert.InDelta(t, 0.6745*97, score(100), 0.0001)
+
+	// More than half of the values are identical, so the MAD is zero
+	median, score = robustScorer([]float64{5, 5, 5, 5, 15})
+	assert.Equal(t, 5.0, median)
+	assert.InDelta(t, 0.7979*10/2, score(15), 0.0001)
+
+	assert.Equal(t, 2.5, medianOf([]float64{4, 1, 3, 2}))
+}
+
+func TestAnomalyGenerator(t *testing.T) {
+	logger := zerolog.New(io.Discard)
+	anomalies := DetectAnomalies(setupAnomalyTestData(), AnomalyConfig{}, false)
+
+	t.Run("Table", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewAnomalyGenerator(&buf, FormatTable, 10, logger).Generate(&anomalies))
+
+		out := buf.String()
+		assert.Contains(t, out, "Long-running jobs (1)")
+		assert.Contains(t, out, "ran 6h0m0s, usually 10m0s")
+		assert.Contains(t, out, "cost $92.16, of which $89.60 above the usual duration")
+		assert.Contains(t, out, "https://github.com/testowner/testrepo/actions/runs/100")
+		assert.Contains(t, out, "2025-04-15: $92.16, usually $0.08 (+$92.08")
+	})
+
+	t.Run("Markdown", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewAnomalyGenerator(&buf, FormatMarkdown, 10, logger).Generate(&anomalies))
+
+		md := buf.String()
+		assert.Contains(t, md, "| [CI / test](https://github.com/testowner/testrepo/actions/runs/100) | 2025-04-15 09:00 | 6h0m0s | 10m0s |")
+		assert.Contains(t, md, "| 2025-04-15 | $92.16 | $0.08 | +$92.08 |")
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewAnomalyGenerator(&buf, FormatMarkdown, 10, logger).Generate(&Anomalies{}))
+		assert.Contains(t, buf.String(), "No jobs ran unusually long.")
+		assert.Contains(t, buf.String(), "No days with unusual spend.")
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewAnomalyGenerator(&buf, FormatJSON, 10, logger).Generate(&anomalies))
+
+		var export AnomaliesExport
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &export))
+		require.Len(t, export.Durations, 1)
+		assert.Equal(t, 21600.0, export.Durations[0].DurationSeconds)
+		assert.Equal(t, "2025-04-15T09:00:00Z", export.Durations[0].CreatedAt)
+		require.Len(t, export.Spend, 1)
+		assert.Equal(t, "2025-04-15", export.Spend[0].Date)
+		assert.Equal(t, int64(100), export.Spend[0].Runs[0].RunID)
+	})
+}
@@ -0,0 +1,389 @@
+package reports
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"gopkg.in/yaml.v3"
+)
+
+// BudgetScope is what a budget applies to
+type BudgetScope string
+
+const (
+	BudgetScopeOrg      BudgetScope = "org"      // matched against the repository owner
+	BudgetScopeRepo     BudgetScope = "repo"     // matched against owner/repo
+	BudgetScopeWorkflow BudgetScope = "workflow" // matched against the workflow name
+	BudgetScopeRunner   BudgetScope = "runner"   // matched against the runner type, e.g. UBUNTU
+)
+
+// BudgetState is the outcome of evaluating a budget
+type BudgetState string
+
+const (
+	BudgetOK       BudgetState = "ok"
+	BudgetWarning  BudgetState = "warning"  // spend crossed a threshold below 100%
+	BudgetAtRisk   BudgetState = "at-risk"  // projected spend exceeds the budget
+	BudgetBreached BudgetState = "breached" // spend exceeds the budget
+)
+
+// DefaultBudgetThresholds are the alert thresholds in percent used when a budget has none
+var DefaultBudgetThresholds = []float64{80, 100}
+
+// Budget is a spending limit for an org, repo, workflow or runner type per period
+type Budget struct {
+	Name       string      `yaml:"name"`
+	Scope      BudgetScope `yaml:"scope"`
+	Match      string      `yaml:"match"`      // value the scope is matched against, empty matches everything
+	Period     Dimension   `yaml:"period"`     // day, week or month
+	Amount     float64     `yaml:"amount"`     // limit in US dollars
+	Thresholds []float64   `yaml:"thresholds"` // alert thresholds in percent of the amount
+}
+
+// BudgetFile is the budgets config file:
+//
+//	budgets:
+//	  - name: Monthly repo budget
+//	    scope: repo
+//	    match: my-org/my-repo
+//	    period: month
+//	    amount: 500
+//	    thresholds: [50, 80, 100]
+type BudgetFile struct {
+	Budgets []Budget `yaml:"budgets"`
+}
+
+// LoadBudgets reads and validates a budgets config file
+func LoadBudgets(path string) ([]Budget, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file BudgetFile
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse budgets file %s: %w", path, err)
+	}
+
+	for i := range file.Budgets {
+		if err := file.Budgets[i].validate(); err != nil {
+			return nil, fmt.Errorf("invalid budget #%d in %s: %w", i+1, path, err)
+		}
+	}
+	return file.Budgets, nil
+}
+
+// validate checks the budget and fills in defaults
+func (b *Budget) validate() error {
+	switch b.Scope {
+	case BudgetScopeOrg, BudgetScopeRepo, BudgetScopeWorkflow, BudgetScopeRunner:
+	case "":
+		return errors.New("scope is required, one of: org, repo, workflow, runner")
+	default:
+		return fmt.Errorf("unsupported scope %q, must be one of: org, repo, workflow, runner", b.Scope)
+	}
+
+	switch b.Period {
+	case DimensionDay, DimensionWeek, DimensionMonth:
+	case "":
+		b.Period = DimensionMonth
+	default:
+		return fmt.Errorf("unsupported period %q, must be one of: day, week, month", b.Period)
+	}
+
+	if b.Amount <= 0 {
+		return errors.New("amount must be greater than 0")
+	}
+	if len(b.Thresholds) == 0 {
+		b.Thresholds = DefaultBudgetThresholds
+	}
+	for _, t := range b.Thresholds {
+		if t <= 0 {
+			return fmt.Errorf("threshold %v must be greater than 0", t)
+		}
+	}
+	sort.Float64s(b.Thresholds)
+
+	if b.Name == "" {
+		b.Name = fmt.Sprintf("%s %s per %s", b.Scope, b.Match, b.Period)
+		if b.Match == "" {
+			b.Name = fmt.Sprintf("all %ss per %s", b.Scope, b.Period)
+		}
+	}
+	return nil
+}
+
+// matches reports whether a job falls under the budget's scope
+func (b Budget) matches(job JobDetails, fj FlatJobDetails) bool {
+	if b.Match == "" {
+		return true
+	}
+
+	var value string
+	switch b.Scope {
+	case BudgetScopeOrg:
+		value = derefOr(fj.OwnerName, "")
+	case BudgetScopeRepo:
+		value = derefOr(fj.OwnerName, "") + "/" + derefOr(fj.RepoName, "")
+	case BudgetScopeWorkflow:
+		value = derefOr(fj.WorkflowName, "")
+	case BudgetScopeRunner:
+		value = job.Runner
+	}
+	return strings.EqualFold(value, b.Match)
+}
+
+// BudgetStatus is a budget evaluated against the spend of its current period
+type BudgetStatus struct {
+	Budget
+	PeriodStart  time.Time
+	PeriodEnd    time.Time
+	DataStart    time.Time // first day of the jobs, after PeriodStart when Spent misses the start of the period
+	Spent        float64
+	Projected    float64 // spend at the end of the period at the current run rate
+	Threshold    float64 // highest threshold crossed by the spend, 0 when none
+	State        BudgetState
+	SpentPercent float64
+}
+
+// Breached reports whether the spend already exceeds the budget
+func (s BudgetStatus) Breached() bool {
+	return s.State == BudgetBreached
+}
+
+// Partial reports whether the jobs start after the start of the period, so the spend
+// misses the jobs before them
+func (s BudgetStatus) Partial() bool {
+	return s.DataStart.After(s.PeriodStart)
+}
+
+// Alerting reports whether the budget is anything other than ok
+func (s BudgetStatus) Alerting() bool {
+	return s.State != BudgetOK
+}
+
+// EvaluateBudgets computes the spend of every budget in its period containing now, and
+// projects the spend to the end of that period at the current run rate. When the jobs
+// start after the start of a period, the run rate is the one since the first job.
+func EvaluateBudgets(jobs []JobDetails, budgets []Budget, now time.Time) []BudgetStatus {
+	// Budgets are matched against the real names, never the obfuscated ones
+	flattened := FlattenJobs(jobs, false)
+	first := dataStart(jobs)
+
+	statuses := make([]BudgetStatus, len(budgets))
+	for i, budget := range budgets {
+		start := periodStart(budget.Period, now)
+		end := nextPeriod(budget.Period, start)
+		status := BudgetStatus{
+			Budget:      budget,
+			PeriodStart: start,
+			PeriodEnd:   end,
+			DataStart:   first,
+		}
+
+		for j, job := range jobs {
+			t := jobTime(job)
+			if t.Before(start) || !t.Before(end) || !budget.matches(job, flattened[j]) {
+				continue
+			}
+			status.Spent += job.BillableInUSD
+		}
+
+		status.Projected = status.Spent
+		elapsed := now.Sub(start)
+		if status.Partial() {
+			elapsed = now.Sub(status.DataStart)
+		}
+		if elapsed > 0 {
+			status.Projected = status.Spent * float64(end.Sub(start)) / float64(elapsed)
+		}
+
+		status.SpentPercent = status.Spent / budget.Amount * 100
+		for _, t := range budget.Thresholds {
+			if status.SpentPercent >= t {
+				status.Threshold = t
+			}
+		}
+
+		switch {
+		case status.Spent >= budget.Amount:
+			status.State = BudgetBreached
+		case status.Projected >= budget.Amount:
+			status.State = BudgetAtRisk
+		case status.Threshold > 0:
+			status.State = BudgetWarning
+		default:
+			status.State = BudgetOK
+		}
+		statuses[i] = status
+	}
+	return statuses
+}
+
+// alertingBudgets returns the budgets that are not ok
+func alertingBudgets(statuses []BudgetStatus) []BudgetStatus {
+	var alerting []BudgetStatus
+	for _, s := range statuses {
+		if s.Alerting() {
+			alerting = append(alerting, s)
+		}
+	}
+	return alerting
+}
+
+// formatBudgetPeriod formats the period of a budget status, e.g. "2025-04"
+func formatBudgetPeriod(s BudgetStatus) string {
+	return periodKey(s.Period, s.PeriodStart)
+}
+
+// formatDataStart formats the day the data starts when it misses the start of a period,
+// and is empty otherwise
+func formatDataStart(partial bool, start time.Time) string {
+	if !partial {
+		return ""
+	}
+	return start.Format(time.DateOnly)
+}
+
+// partialBudgetsNote describes the budgets whose spend misses the start of their
+// period, empty when there are none
+func partialBudgetsNote(statuses []BudgetStatus) string {
+	partial := 0
+	var start time.Time
+	for _, s := range statuses {
+		if s.Partial() {
+			partial++
+			start = s.DataStart
+		}
+	}
+	if partial == 0 {
+		return ""
+	}
+	return fmt.Sprintf("The data starts on %s, after the start of %d budget period(s), so their spend misses the jobs before it and is projected from the run rate since then.",
+		start.Format(time.DateOnly), partial)
+}
+
+// writeMarkdownBudgets writes a Markdown table of budget statuses
+func writeMarkdownBudgets(b *strings.Builder, title string, statuses []BudgetStatus) {
+	if len(statuses) == 0 {
+		return
+	}
+
+	fmt.Fprintf(b, "### %s\n\n", title)
+	b.WriteString("| Budget | Period | Spent | Projected | Budget | Used | Status |\n")
+	b.WriteString("| --- | --- | ---: | ---: | ---: | ---: | --- |\n")
+	for _, s := range statuses {
+		state := string(s.State)
+		if s.Alerting() {
+			state = "**" + state + "**"
+		}
+		fmt.Fprintf(b, "| %s | %s | %s | %s | %s | %.1f%% | %s |\n",
+			escapeMarkdownCell(s.Name),
+			formatBudgetPeriod(s),
+			formatUSD(s.Spent),
+			formatUSD(s.Projected),
+			formatUSD(s.Amount),
+			s.SpentPercent,
+			state)
+	}
+	b.WriteString("\n")
+	if note := partialBudgetsNote(statuses); note != "" {
+		fmt.Fprintf(b, "%s\n\n", note)
+	}
+}
+
+// BudgetExport is a budget status in JSON and NDJSON exports
+type BudgetExport struct {
+	Name           string      `json:"name"`
+	Scope          BudgetScope `json:"scope"`
+	Match          string      `json:"match,omitempty"`
+	Period         Dimension   `json:"period"`
+	PeriodStart    string      `json:"period_start"`
+	PeriodEnd      string      `json:"period_end"`
+	DataStart      string      `json:"data_start,omitempty"` // set when the data starts after period_start, so the spend misses the jobs before it
+	AmountInUSD    float64     `json:"amount_in_usd"`
+	SpentInUSD     float64     `json:"spent_in_usd"`
+	ProjectedInUSD float64     `json:"projected_in_usd"`
+	SpentPercent   float64     `json:"spent_percent"`
+	Thresholds     []float64   `json:"thresholds"`
+	Threshold      float64     `json:"threshold,omitempty"` // highest threshold crossed
+	State          BudgetState `json:"state"`
+}
+
+func exportBudgets(statuses []BudgetStatus) []BudgetExport {
+	exported := make([]BudgetExport, len(statuses))
+	for i, s := range statuses {
+		exported[i] = BudgetExport{
+			Name:           s.Name,
+			Scope:          s.Scope,
+			Match:          s.Match,
+			Period:         s.Period,
+			PeriodStart:    s.PeriodStart.Format(time.DateOnly),
+			PeriodEnd:      s.PeriodEnd.Format(time.DateOnly),
+			DataStart:      formatDataStart(s.Partial(), s.DataStart),
+			AmountInUSD:    s.Amount,
+			SpentInUSD:     s.Spent,
+			ProjectedInUSD: s.Projected,
+			SpentPercent:   s.SpentPercent,
+			Thresholds:     s.Thresholds,
+			Threshold:      s.Threshold,
+			State:          s.State,
+		}
+	}
+	return exported
+}
+
+// BudgetGenerator writes budget statuses as a plain text table, Markdown or JSON
+type BudgetGenerator struct {
+	out     io.Writer
+	format  string
+	noColor bool
+	logger  zerolog.Logger
+}
+
+// NewBudgetGenerator creates a new budget status generator that writes to out
+func NewBudgetGenerator(out io.Writer, format string, noColor bool, logger zerolog.Logger) *BudgetGenerator {
+	return &BudgetGenerator{
+		out:     out,
+		format:  format,
+		noColor: noColor,
+		logger:  logger,
+	}
+}
+
+func (g *BudgetGenerator) Generate(statuses []BudgetStatus) error {
+	g.logger.Debug().Str("format", g.format).Msg("Generating budget status")
+
+	var err error
+	switch g.format {
+	case FormatTable:
+		var b strings.Builder
+		terminal := &TerminalGenerator{width: defaultTerminalWidth, colorize: !g.noColor}
+		terminal.writeBudgets(&b, "Budgets", statuses)
+		_, err = io.WriteString(g.out, strings.TrimPrefix(b.String(), "\n"))
+	case FormatMarkdown:
+		var b strings.Builder
+		writeMarkdownBudgets(&b, "Budgets", statuses)
+		_, err = io.WriteString(g.out, b.String())
+	case FormatJSON:
+		enc := json.NewEncoder(g.out)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(struct {
+			SchemaVersion string         `json:"schema_version"`
+			Budgets       []BudgetExport `json:"budgets"`
+		}{ExportSchemaVersion, exportBudgets(statuses)})
+	default:
+		return fmt.Errorf("unsupported budget format %q, must be one of: %s, %s, %s", g.format, FormatTable, FormatMarkdown, FormatJSON)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s budget status: %w", g.format, err)
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package reports
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// budgetTestNow is ten days into April 2025, a third of the month
+var budgetTestNow = time.Date(2025, 4, 11, 0, 0, 0, 0, time.UTC)
+
+// setupBudgetTestData returns $30 of CI on UBUNTU and $5 of Release on MACOS in April,
+// and $100 of CI in March that falls outside the current period
+func setupBudgetTestData() []JobDetails {
+	april := time.Date(2025, 4, 5, 12, 0, 0, 0, time.UTC)
+	return []JobDetails{
+		aggregateTestJob("CI", "UBUNTU", "success", april, time.Hour, 20),
+		aggregateTestJob("CI", "UBUNTU", "success", april.AddDate(0, 0, 1), time.Hour, 10),
+		aggregateTestJob("Release", "MACOS", "success", april, time.Hour, 5),
+		aggregateTestJob("CI", "UBUNTU", "success", april.AddDate(0, -1, 0), time.Hour, 100),
+	}
+}
+
+func writeBudgetsFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "budgets.yml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestLoadBudgets(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		budgets, err := LoadBudgets(writeBudgetsFile(t, `
+budgets:
+  - name: Repo
+    scope: repo
+    match: testowner/testrepo
+    amount: 500
+    thresholds: [100, 50]
+  - scope: runner
+    match: MACOS
+    period: week
+    amount: 10
+`))
+		require.NoError(t, err)
+		require.Len(t, budgets, 2)
+		assert.Equal(t, DimensionMonth, budgets[0].Period)
+		assert.Equal(t, []float64{50, 100}, budgets[0].Thresholds)
+		assert.Equal(t, "runner MACOS per week", budgets[1].Name)
+		assert.Equal(t, DefaultBudgetThresholds, budgets[1].Thresholds)
+	})
+
+	for name, content := range map[string]string{
+		"MissingScope":  "budgets:\n  - amount: 10\n",
+		"UnknownScope":  "budgets:\n  - scope: team\n    amount: 10\n",
+		"UnknownPeriod": "budgets:\n  - scope: org\n    period: year\n    amount: 10\n",
+		"NoAmount":      "budgets:\n  - scope: org\n",
+		"BadThreshold":  "budgets:\n  - scope: org\n    amount: 10\n    thresholds: [0]\n",
+		"InvalidYAML":   "budgets: [",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := LoadBudgets(writeBudgetsFile(t, content))
+			assert.Error(t, err)
+		})
+	}
+
+	t.Run("MissingFile", func(t *testing.T) {
+		_, err := LoadBudgets(filepath.Join(t.TempDir(), "missing.yml"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestEvaluateBudgets(t *testing.T) {
+	budget := func(scope BudgetScope, match string, amount float64) Budget {
+		b := Budget{Scope: scope, Match: match, Amount: amount}
+		require.NoError(t, b.validate())
+		return b
+	}
+
+	statuses := EvaluateBudgets(setupBudgetTestData(), []Budget{
+		budget(BudgetScopeRepo, "TestOwner/TestRepo", 30),
+		budget(BudgetScopeWorkflow, "CI", 80),
+		budget(BudgetScopeRunner, "MACOS", 100),
+		budget(BudgetScopeOrg, "testowner", 40),
+		budget(BudgetScopeOrg, "otherowner", 40),
+	}, budgetTestNow)
+	require.Len(t, statuses, 5)
+
+	repo := statuses[0]
+	assert.Equal(t, time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), repo.PeriodStart)
+	assert.Equal(t, time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC), repo.PeriodEnd)
+	assert.InDelta(t, 35, repo.Spent, 0.0001, "matching is case-insensitive and skips March")
+	assert.InDelta(t, 105, repo.Projected, 0.0001, "a third of the month has passed")
+	assert.Equal(t, BudgetBreached, repo.State)
+	assert.Equal(t, 100.0, repo.Threshold)
+	assert.True(t, repo.Breached())
+	assert.False(t, repo.Partial(), "the data starts in March")
+
+	ci := statuses[1]
+	assert.InDelta(t, 30, ci.Spent, 0.0001)
+	assert.Equal(t, BudgetAtRisk, ci.State)
+	assert.Equal(t, 0.0, ci.Threshold)
+
+	macos := statuses[2]
+	assert.InDelta(t, 5, macos.Spent, 0.0001)
+	assert.Equal(t, BudgetOK, macos.State)
+	assert.False(t, macos.Alerting())
+
+	org := statuses[3]
+	assert.InDelta(t, 87.5, org.SpentPercent, 0.0001)
+	assert.Equal(t, BudgetAtRisk, org.State)
+	assert.Equal(t, 80.0, org.Threshold)
+
+	assert.Zero(t, statuses[4].Spent)
+	assert.Equal(t, BudgetOK, statuses[4].State)
+
+	assert.Len(t, alertingBudgets(statuses), 3)
+}
+
+func TestEvaluateBudgetsWarning(t *testing.T) {
+	b := Budget{Scope: BudgetScopeOrg, Period: DimensionDay, Amount: 10, Thresholds: []float64{50, 100}}
+	require.NoError(t, b.validate())
+
+	// Halfway through the day with $6 spent, the projection is $12
+	now := time.Date(2025, 4, 5, 12, 0, 0, 0, time.UTC)
+	jobs := []JobDetails{aggregateTestJob("CI", "UBUNTU", "success", now.Add(-time.Hour), time.Hour, 6)}
+	statuses := EvaluateBudgets(jobs, []Budget{b}, now)
+	assert.Equal(t, BudgetAtRisk, statuses[0].State)
+
+	// Late in the day the projection stays under the budget
+	statuses = EvaluateBudgets(jobs, []Budget{b}, now.Add(11*time.Hour))
+	assert.Equal(t, BudgetWarning, statuses[0].State)
+	assert.Equal(t, 50.0, statuses[0].Threshold)
+}
+
+func TestEvaluateBudgetsPartial(t *testing.T) {
+	b := Budget{Name: "Repo budget", Scope: BudgetScopeRepo, Amount: 200}
+	require.NoError(t, b.validate())
+
+	// Without the March job, the data starts on April 5, after the start of the month
+	statuses := EvaluateBudgets(setupBudgetTestData()[:3], []Budget{b}, budgetTestNow)
+	require.Len(t, statuses, 1)
+	assert.True(t, statuses[0].Partial())
+	assert.Equal(t, time.Date(2025, 4, 5, 0, 0, 0, 0, time.UTC), statuses[0].DataStart)
+	assert.InDelta(t, 35, statuses[0].Spent, 0.0001)
+	assert.InDelta(t, 175, statuses[0].Projected, 0.0001, "projected from the run rate of the six days with data")
+	assert.Equal(t, BudgetOK, statuses[0].State)
+
+	assert.Contains(t, partialBudgetsNote(statuses), "The data starts on 2025-04-05, after the start of 1 budget period(s)")
+	assert.Equal(t, "2025-04-05", exportBudgets(statuses)[0].DataStart)
+	assert.Empty(t, partialBudgetsNote(EvaluateBudgets(setupBudgetTestData(), []Budget{b}, budgetTestNow)))
+}
+
+func TestBudgetGenerator(t *testing.T) {
+	budgets := []Budget{
+		{Name: "Repo budget", Scope: BudgetScopeRepo, Match: "testowner/testrepo", Period: DimensionMonth, Amount: 30, Thresholds: DefaultBudgetThresholds},
+		{Name: "Mac budget", Scope: BudgetScopeRunner, Match: "MACOS", Period: DimensionMonth, Amount: 100, Thresholds: DefaultBudgetThresholds},
+	}
+	statuses := EvaluateBudgets(setupBudgetTestData(), budgets, budgetTestNow)
+	logger := zerolog.New(io.Discard)
+
+	t.Run("Table", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewBudgetGenerator(&buf, FormatTable, true, logger).Generate(statuses))
+		out := buf.String()
+		assert.Contains(t, out, "Budgets\n")
+		assert.Regexp(t, `Repo budget\s+\$35\.00\s+\$105\.00\s+\$30\.00\s+116\.7% breached`, out)
+		assert.Regexp(t, `Mac budget\s+\$5\.00\s+\$15\.00\s+\$100\.00\s+5\.0% ok`, out)
+	})
+
+	t.Run("Markdown", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewBudgetGenerator(&buf, FormatMarkdown, true, logger).Generate(statuses))
+		out := buf.String()
+		assert.Contains(t, out, "### Budgets")
+		assert.Contains(t, out, "| Repo budget | 2025-04 | $35.00 | $105.00 | $30.00 | 116.7% | **breached** |")
+		assert.Contains(t, out, "| Mac budget | 2025-04 | $5.00 | $15.00 | $100.00 | 5.0% | ok |")
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewBudgetGenerator(&buf, FormatJSON, true, logger).Generate(statuses))
+
+		var export struct {
+			SchemaVersion string         `json:"schema_version"`
+			Budgets       []BudgetExport `json:"budgets"`
+		}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &export))
+		assert.Equal(t, ExportSchemaVersion, export.SchemaVersion)
+		require.Len(t, export.Budgets, 2)
+		assert.Equal(t, "2025-04-01", export.Budgets[0].PeriodStart)
+		assert.Equal(t, "2025-05-01", export.Budgets[0].PeriodEnd)
+		assert.Equal(t, BudgetBreached, export.Budgets[0].State)
+		assert.InDelta(t, 35, export.Budgets[0].SpentInUSD, 0.0001)
+	})
+
+	t.Run("UnsupportedFormat", func(t *testing.T) {
+		assert.Error(t, NewBudgetGenerator(io.Discard, "xml", true, logger).Generate(statuses))
+	})
+}
@@ -0,0 +1,310 @@
+package reports
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// UnallocatedTeam is the chargeback row of spend that no team owns
+const UnallocatedTeam = "unallocated"
+
+// TeamCharge is the spend charged to a team in a month
+type TeamCharge struct {
+	Team            string
+	Jobs            int
+	BillableMinutes float64
+	BillableInUSD   float64
+	Share           float64                      // percentage of the month's spend, 0-100
+	BySource        map[AllocationSource]float64 // cost in US dollars by what allocated it
+}
+
+// ChargebackStatement is the spend of a month split by team. Teams are sorted by cost,
+// most expensive first, and include every team of the teams file, even without spend.
+type ChargebackStatement struct {
+	Month         string // 2006-01, or "unknown" for jobs without a creation time
+	Teams         []TeamCharge
+	Unallocated   TeamCharge
+	TotalInUSD    float64
+	TotalMinutes  float64
+	TotalJobCount int
+}
+
+// BuildChargeback charges every job to the team the resolver assigns it to and returns
+// one statement per month, oldest first
+func BuildChargeback(jobs []JobDetails, resolver *OwnershipResolver) []ChargebackStatement {
+	months := make(map[string]map[string]*TeamCharge)
+	for _, job := range jobs {
+		month := "unknown"
+		if t := jobTime(job); !t.IsZero() {
+			month = periodKey(DimensionMonth, t)
+		}
+		teams, ok := months[month]
+		if !ok {
+			teams = make(map[string]*TeamCharge)
+			months[month] = teams
+		}
+
+		team, source := resolver.Resolve(job)
+		if team == "" {
+			team = UnallocatedTeam
+		}
+		charge, ok := teams[team]
+		if !ok {
+			charge = &TeamCharge{Team: team, BySource: make(map[AllocationSource]float64)}
+			teams[team] = charge
+		}
+		charge.Jobs++
+		charge.BillableMinutes += job.RoundedUpJobDuration.Minutes()
+		charge.BillableInUSD += job.BillableInUSD
+		if source != "" {
+			charge.BySource[source] += job.BillableInUSD
+		}
+	}
+
+	statements := make([]ChargebackStatement, 0, len(months))
+	for month, teams := range months {
+		for _, team := range resolver.teams {
+			if _, ok := teams[team.Name]; !ok {
+				teams[team.Name] = &TeamCharge{Team: team.Name, BySource: make(map[AllocationSource]float64)}
+			}
+		}
+
+		statement := ChargebackStatement{Month: month, Unallocated: TeamCharge{Team: UnallocatedTeam}}
+		for _, charge := range teams {
+			statement.TotalInUSD += charge.BillableInUSD
+			statement.TotalMinutes += charge.BillableMinutes
+			statement.TotalJobCount += charge.Jobs
+		}
+		for _, charge := range teams {
+			if statement.TotalInUSD > 0 {
+				charge.Share = charge.BillableInUSD / statement.TotalInUSD * 100
+			}
+			if charge.Team == UnallocatedTeam {
+				statement.Unallocated = *charge
+				continue
+			}
+			statement.Teams = append(statement.Teams, *charge)
+		}
+		sort.Slice(statement.Teams, func(i, j int) bool {
+			if statement.Teams[i].BillableInUSD != statement.Teams[j].BillableInUSD {
+				return statement.Teams[i].BillableInUSD > statement.Teams[j].BillableInUSD
+			}
+			return statement.Teams[i].Team < statement.Teams[j].Team
+		})
+		statements = append(statements, statement)
+	}
+	sort.Slice(statements, func(i, j int) bool {
+		return statements[i].Month < statements[j].Month
+	})
+	return statements
+}
+
+// ChargebackGenerator writes chargeback statements as a plain text table, CSV, Markdown or JSON
+type ChargebackGenerator struct {
+	out    io.Writer
+	format string
+	logger zerolog.Logger
+}
+
+// NewChargebackGenerator creates a new chargeback generator that writes to out
+func NewChargebackGenerator(out io.Writer, format string, logger zerolog.Logger) *ChargebackGenerator {
+	return &ChargebackGenerator{
+		out:    out,
+		format: format,
+		logger: logger,
+	}
+}
+
+func (g *ChargebackGenerator) Generate(statements []ChargebackStatement) error {
+	g.logger.Debug().Str("format", g.format).Msg("Generating chargeback")
+
+	var err error
+	switch g.format {
+	case FormatTable:
+		_, err = io.WriteString(g.out, g.renderTable(statements))
+	case FormatCSV:
+		err = g.writeCSV(statements)
+	case FormatMarkdown:
+		_, err = io.WriteString(g.out, g.renderMarkdown(statements))
+	case FormatJSON:
+		enc := json.NewEncoder(g.out)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(exportChargeback(statements))
+	default:
+		return fmt.Errorf("unsupported chargeback format %q, must be one of: %s, %s, %s, %s", g.format, FormatTable, FormatCSV, FormatMarkdown, FormatJSON)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s chargeback: %w", g.format, err)
+	}
+	return nil
+}
+
+// rows returns the team rows of a statement with the unallocated row last
+func (s ChargebackStatement) rows() []TeamCharge {
+	return append(append([]TeamCharge{}, s.Teams...), s.Unallocated)
+}
+
+// allocatedBy describes what allocated a team's spend, e.g. "repo 80%, actor 20%"
+func allocatedBy(charge TeamCharge) string {
+	var parts []string
+	for _, source := range AllocationSources {
+		if cost := charge.BySource[source]; cost > 0 && charge.BillableInUSD > 0 {
+			parts = append(parts, fmt.Sprintf("%s %.0f%%", source, cost/charge.BillableInUSD*100))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (g *ChargebackGenerator) renderTable(statements []ChargebackStatement) string {
+	var b strings.Builder
+
+	b.WriteString("Chargeback\n")
+	if len(statements) == 0 {
+		b.WriteString("No jobs found.\n")
+		return b.String()
+	}
+
+	const nameWidth = 28
+	for _, s := range statements {
+		fmt.Fprintf(&b, "\n%s: %s, %.0f minutes, %d jobs\n", s.Month, formatUSD(s.TotalInUSD), s.TotalMinutes, s.TotalJobCount)
+		fmt.Fprintf(&b, "%s %12s %10s %6s %7s  %s\n", padRight("Team", nameWidth), "Cost", "Minutes", "Jobs", "Share", "Allocated by")
+		for _, charge := range s.rows() {
+			fmt.Fprintf(&b, "%s %12s %10.0f %6d %6.1f%%  %s\n",
+				padRight(truncate(charge.Team, nameWidth), nameWidth),
+				formatUSD(charge.BillableInUSD),
+				charge.BillableMinutes,
+				charge.Jobs,
+				charge.Share,
+				allocatedBy(charge))
+		}
+	}
+	return b.String()
+}
+
+func (g *ChargebackGenerator) writeCSV(statements []ChargebackStatement) error {
+	w := csv.NewWriter(g.out)
+	header := []string{"month", "team", "billable_in_usd", "billable_minutes", "job_count", "share_percent"}
+	for _, source := range AllocationSources {
+		header = append(header, fmt.Sprintf("allocated_by_%s_in_usd", source))
+	}
+	if err := w.Write(header); err != nil {
+		return err
+	}
+	for _, s := range statements {
+		for _, charge := range s.rows() {
+			record := []string{
+				s.Month,
+				charge.Team,
+				strconv.FormatFloat(charge.BillableInUSD, 'f', 3, 64),
+				strconv.FormatFloat(charge.BillableMinutes, 'f', 0, 64),
+				strconv.Itoa(charge.Jobs),
+				strconv.FormatFloat(charge.Share, 'f', 2, 64),
+			}
+			for _, source := range AllocationSources {
+				record = append(record, strconv.FormatFloat(charge.BySource[source], 'f', 3, 64))
+			}
+			if err := w.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func (g *ChargebackGenerator) renderMarkdown(statements []ChargebackStatement) string {
+	var b strings.Builder
+
+	b.WriteString("## GitHub Actions chargeback\n\n")
+	if len(statements) == 0 {
+		b.WriteString("No jobs found.\n")
+		return b.String()
+	}
+
+	for _, s := range statements {
+		fmt.Fprintf(&b, "### %s\n\n", s.Month)
+		fmt.Fprintf(&b, "%s across %d jobs and %.0f billable minutes, of which %s (%.1f%%) is unallocated.\n\n",
+			formatUSD(s.TotalInUSD), s.TotalJobCount, s.TotalMinutes, formatUSD(s.Unallocated.BillableInUSD), s.Unallocated.Share)
+		b.WriteString("| Team | Cost | Minutes | Jobs | Share | Allocated by |\n")
+		b.WriteString("| --- | ---: | ---: | ---: | ---: | --- |\n")
+		for _, charge := range s.Teams {
+			fmt.Fprintf(&b, "| %s | %s | %.0f | %d | %.1f%% | %s |\n",
+				escapeMarkdownCell(charge.Team),
+				formatUSD(charge.BillableInUSD),
+				charge.BillableMinutes,
+				charge.Jobs,
+				charge.Share,
+				allocatedBy(charge))
+		}
+		fmt.Fprintf(&b, "| **Unallocated** | **%s** | %.0f | %d | %.1f%% | |\n",
+			formatUSD(s.Unallocated.BillableInUSD),
+			s.Unallocated.BillableMinutes,
+			s.Unallocated.Jobs,
+			s.Unallocated.Share)
+		fmt.Fprintf(&b, "| **Total** | **%s** | %.0f | %d | 100.0%% | |\n\n", formatUSD(s.TotalInUSD), s.TotalMinutes, s.TotalJobCount)
+	}
+	return b.String()
+}
+
+// ChargebackExport is the document written by the json chargeback format
+type ChargebackExport struct {
+	SchemaVersion string                      `json:"schema_version"`
+	Statements    []ChargebackStatementExport `json:"statements"`
+}
+
+// ChargebackStatementExport is a month in a JSON chargeback export
+type ChargebackStatementExport struct {
+	Month           string             `json:"month"`
+	BillableInUSD   float64            `json:"billable_in_usd"`
+	BillableMinutes float64            `json:"billable_minutes"`
+	JobCount        int                `json:"job_count"`
+	Teams           []TeamChargeExport `json:"teams"`
+	Unallocated     TeamChargeExport   `json:"unallocated"`
+}
+
+// TeamChargeExport is a team's spend in a JSON chargeback export
+type TeamChargeExport struct {
+	Team             string                       `json:"team"`
+	BillableInUSD    float64                      `json:"billable_in_usd"`
+	BillableMinutes  float64                      `json:"billable_minutes"`
+	JobCount         int                          `json:"job_count"`
+	SharePercent     float64                      `json:"share_percent"`
+	AllocatedByInUSD map[AllocationSource]float64 `json:"allocated_by_in_usd,omitempty"`
+}
+
+func exportChargeback(statements []ChargebackStatement) ChargebackExport {
+	export := ChargebackExport{SchemaVersion: ExportSchemaVersion, Statements: []ChargebackStatementExport{}}
+	for _, s := range statements {
+		statement := ChargebackStatementExport{
+			Month:           s.Month,
+			BillableInUSD:   s.TotalInUSD,
+			BillableMinutes: s.TotalMinutes,
+			JobCount:        s.TotalJobCount,
+			Teams:           make([]TeamChargeExport, len(s.Teams)),
+			Unallocated:     exportTeamCharge(s.Unallocated),
+		}
+		for i, charge := range s.Teams {
+			statement.Teams[i] = exportTeamCharge(charge)
+		}
+		export.Statements = append(export.Statements, statement)
+	}
+	return export
+}
+
+func exportTeamCharge(charge TeamCharge) TeamChargeExport {
+	return TeamChargeExport{
+		Team:             charge.Team,
+		BillableInUSD:    charge.BillableInUSD,
+		BillableMinutes:  charge.BillableMinutes,
+		JobCount:         charge.Jobs,
+		SharePercent:     charge.Share,
+		AllocatedByInUSD: charge.BySource,
+	}
+}
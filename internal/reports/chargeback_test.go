@@ -0,0 +1,120 @@
+package reports
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupChargebackTestData returns $30 for Platform and $10 for Web in April, of which $5
+// is unallocated, and $8 for Web in March
+func setupChargebackTestData() ([]JobDetails, *OwnershipResolver) {
+	april := time.Date(2025, 4, 5, 12, 0, 0, 0, time.UTC)
+	jobs := []JobDetails{
+		ownershipTestJob(".github/workflows/ci.yml", "infra", "carol", april, 20),
+		ownershipTestJob(".github/workflows/deploy.yml", "web-app", "carol", april, 10),
+		ownershipTestJob(".github/workflows/ci.yml", "web-app", "carol", april, 5),
+		ownershipTestJob(".github/workflows/ci.yml", "other", "carol", april, 5),
+		ownershipTestJob(".github/workflows/ci.yml", "web-app", "carol", april.AddDate(0, -1, 0), 8),
+	}
+	resolver := NewOwnershipResolver(&TeamFile{Teams: []Team{
+		{Name: "Platform", Repos: []string{"infra"}, Workflows: []string{".github/workflows/deploy.yml"}},
+		{Name: "Web", Repos: []string{"web-app"}},
+		{Name: "Mobile", Repos: []string{"mobile"}},
+	}}, nil, nil)
+	return jobs, resolver
+}
+
+func TestBuildChargeback(t *testing.T) {
+	statements := BuildChargeback(setupChargebackTestData())
+	require.Len(t, statements, 2)
+	assert.Equal(t, "2025-03", statements[0].Month)
+
+	april := statements[1]
+	assert.Equal(t, "2025-04", april.Month)
+	assert.InDelta(t, 40, april.TotalInUSD, 0.001)
+	assert.Equal(t, 4, april.TotalJobCount)
+	assert.InDelta(t, 240, april.TotalMinutes, 0.001)
+
+	require.Len(t, april.Teams, 3)
+	assert.Equal(t, "Platform", april.Teams[0].Team)
+	assert.InDelta(t, 30, april.Teams[0].BillableInUSD, 0.001)
+	assert.InDelta(t, 75, april.Teams[0].Share, 0.001)
+	assert.InDelta(t, 20, april.Teams[0].BySource[AllocatedByRepo], 0.001)
+	assert.InDelta(t, 10, april.Teams[0].BySource[AllocatedByWorkflow], 0.001)
+	assert.Equal(t, "Web", april.Teams[1].Team)
+	// Teams without spend are listed too
+	assert.Equal(t, "Mobile", april.Teams[2].Team)
+	assert.Zero(t, april.Teams[2].Jobs)
+
+	assert.Equal(t, UnallocatedTeam, april.Unallocated.Team)
+	assert.InDelta(t, 5, april.Unallocated.BillableInUSD, 0.001)
+	assert.InDelta(t, 12.5, april.Unallocated.Share, 0.001)
+
+	// Nothing unallocated in March, but the row is still there
+	assert.Equal(t, UnallocatedTeam, statements[0].Unallocated.Team)
+	assert.Zero(t, statements[0].Unallocated.BillableInUSD)
+
+	assert.Empty(t, BuildChargeback(nil, NewOwnershipResolver(nil, nil, nil)))
+}
+
+func TestChargebackGenerator(t *testing.T) {
+	statements := BuildChargeback(setupChargebackTestData())
+	generate := func(t *testing.T, format string) string {
+		t.Helper()
+		var buf bytes.Buffer
+		require.NoError(t, NewChargebackGenerator(&buf, format, zerolog.New(io.Discard)).Generate(statements))
+		return buf.String()
+	}
+
+	t.Run("Table", func(t *testing.T) {
+		out := generate(t, FormatTable)
+		assert.Contains(t, out, "2025-04: $40.00, 240 minutes, 4 jobs")
+		assert.Contains(t, out, "workflow 33%, repo 67%")
+		assert.Contains(t, out, UnallocatedTeam)
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		lines := splitLines(strings.TrimSpace(generate(t, FormatCSV)))
+		require.Len(t, lines, 9)
+		assert.Equal(t, "month,team,billable_in_usd,billable_minutes,job_count,share_percent,"+
+			"allocated_by_workflow_in_usd,allocated_by_codeowners_in_usd,allocated_by_repo_in_usd,allocated_by_actor_in_usd", lines[0])
+		assert.Equal(t, "2025-04,Platform,30.000,120,2,75.00,10.000,0.000,20.000,0.000", lines[5])
+		assert.Equal(t, "2025-04,unallocated,5.000,60,1,12.50,0.000,0.000,0.000,0.000", lines[8])
+	})
+
+	t.Run("Markdown", func(t *testing.T) {
+		out := generate(t, FormatMarkdown)
+		assert.Contains(t, out, "### 2025-04")
+		assert.Contains(t, out, "$40.00 across 4 jobs and 240 billable minutes, of which $5.00 (12.5%) is unallocated.")
+		assert.Contains(t, out, "| Platform | $30.00 | 120 | 2 | 75.0% | workflow 33%, repo 67% |")
+		assert.Contains(t, out, "| **Unallocated** | **$5.00** | 60 | 1 | 12.5% | |")
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		var export ChargebackExport
+		require.NoError(t, json.Unmarshal([]byte(generate(t, FormatJSON)), &export))
+		assert.Equal(t, ExportSchemaVersion, export.SchemaVersion)
+		require.Len(t, export.Statements, 2)
+		assert.Equal(t, "Platform", export.Statements[1].Teams[0].Team)
+		assert.InDelta(t, 5, export.Statements[1].Unallocated.BillableInUSD, 0.001)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewChargebackGenerator(&buf, FormatMarkdown, zerolog.New(io.Discard)).Generate(nil))
+		assert.Contains(t, buf.String(), "No jobs found.")
+	})
+
+	t.Run("UnsupportedFormat", func(t *testing.T) {
+		err := NewChargebackGenerator(io.Discard, "html", zerolog.New(io.Discard)).Generate(statements)
+		assert.ErrorContains(t, err, `unsupported chargeback format "html"`)
+	})
+}
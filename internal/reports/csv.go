@@ -6,6 +6,7 @@ import (
 	"os"
 	"reflect"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -15,6 +16,11 @@ import (
 type CSVGenerator struct {
 	jobsPath       string
 	totalsPath     string
+	groupedPath    string // only written when the report data is grouped
+	trendPath      string // only written when the report data has a trend
+	budgetsPath    string // only written when the report data has budgets
+	forecastPath   string // only written when the report data has a forecast
+	stepsPath      string // only written when jobs have steps
 	logger         zerolog.Logger
 	ownerName      string
 	repoName       string
@@ -28,6 +34,11 @@ func NewCSVGenerator(jobsPath, totalsPath string, logger zerolog.Logger) *CSVGen
 	return &CSVGenerator{
 		jobsPath:       jobsPath,
 		totalsPath:     totalsPath,
+		groupedPath:    strings.TrimSuffix(jobsPath, ".csv") + "_grouped.csv",
+		trendPath:      strings.TrimSuffix(jobsPath, ".csv") + "_trend.csv",
+		budgetsPath:    strings.TrimSuffix(jobsPath, ".csv") + "_budgets.csv",
+		forecastPath:   strings.TrimSuffix(jobsPath, ".csv") + "_forecast.csv",
+		stepsPath:      strings.TrimSuffix(jobsPath, ".csv") + "_steps.csv",
 		logger:         logger,
 		timeFormat:     false,
 		dateTimeFormat: "2006-01-02T15:04:05",
@@ -39,10 +50,20 @@ func NewCSVGeneratorWithFormat(basePath string, owner, repo, reportID string, lo
 	timestamp := time.Now().Format("2006-01-02T15:04:05")
 	jobsPath := basePath + "/" + timestamp + "_" + owner + "_" + repo + "_" + reportID + "_report.csv"
 	totalsPath := basePath + "/" + timestamp + "_" + owner + "_" + repo + "_" + reportID + "_totals.csv"
+	groupedPath := basePath + "/" + timestamp + "_" + owner + "_" + repo + "_" + reportID + "_grouped.csv"
+	trendPath := basePath + "/" + timestamp + "_" + owner + "_" + repo + "_" + reportID + "_trend.csv"
+	budgetsPath := basePath + "/" + timestamp + "_" + owner + "_" + repo + "_" + reportID + "_budgets.csv"
+	forecastPath := basePath + "/" + timestamp + "_" + owner + "_" + repo + "_" + reportID + "_forecast.csv"
+	stepsPath := basePath + "/" + timestamp + "_" + owner + "_" + repo + "_" + reportID + "_steps.csv"
 
 	return &CSVGenerator{
 		jobsPath:       jobsPath,
 		totalsPath:     totalsPath,
+		groupedPath:    groupedPath,
+		trendPath:      trendPath,
+		budgetsPath:    budgetsPath,
+		forecastPath:   forecastPath,
+		stepsPath:      stepsPath,
 		logger:         logger,
 		ownerName:      owner,
 		repoName:       repo,
@@ -60,6 +81,36 @@ func (g *CSVGenerator) GetTotalsPath() string {
 	return g.totalsPath
 }
 
+// GetGroupedPath returns the path of the grouped breakdown, which is only
+// written when the report data has dimensions to group by
+func (g *CSVGenerator) GetGroupedPath() string {
+	return g.groupedPath
+}
+
+// GetTrendPath returns the path of the cost trend, which is only
+// written when the report data has a trend
+func (g *CSVGenerator) GetTrendPath() string {
+	return g.trendPath
+}
+
+// GetBudgetsPath returns the path of the budget statuses, which is only
+// written when the report data has budgets
+func (g *CSVGenerator) GetBudgetsPath() string {
+	return g.budgetsPath
+}
+
+// GetForecastPath returns the path of the forecasts per repository and runner type,
+// which is only written when the report data has a forecast
+func (g *CSVGenerator) GetForecastPath() string {
+	return g.forecastPath
+}
+
+// GetStepsPath returns the path of the step-level records, which is only
+// written when jobs have steps
+func (g *CSVGenerator) GetStepsPath() string {
+	return g.stepsPath
+}
+
 func (g *CSVGenerator) Generate(data *ReportData) error {
 	g.logger.Debug().Msg("Generating CSV report")
 
@@ -67,10 +118,38 @@ func (g *CSVGenerator) Generate(data *ReportData) error {
 		return err
 	}
 
-	if err := g.generateTotalsReport(data.Totals); err != nil {
+	if err := g.generateStepsReport(data.Jobs, data.ObfuscateData); err != nil {
 		return err
 	}
 
+	if err := g.generateTotalsReport(data.Totals, data.Forecast); err != nil {
+		return err
+	}
+
+	if data.Forecast != nil {
+		if err := g.generateForecastReport(*data.Forecast); err != nil {
+			return err
+		}
+	}
+
+	if len(data.GroupBy) > 0 {
+		if err := g.generateGroupedReport(Aggregate(data.Jobs, data.GroupBy, data.ObfuscateData)); err != nil {
+			return err
+		}
+	}
+
+	if data.Trend != nil {
+		if err := g.generateTrendReport(*data.Trend); err != nil {
+			return err
+		}
+	}
+
+	if len(data.Budgets) > 0 {
+		if err := g.generateBudgetsReport(data.Budgets); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -86,9 +165,56 @@ func (g *CSVGenerator) generateJobsReport(jobs []JobDetails, shouldObfuscate boo
 	return g.writeCSVFile(g.jobsPath, data)
 }
 
-func (g *CSVGenerator) generateTotalsReport(totals TotalCosts) error {
+func (g *CSVGenerator) generateStepsReport(jobs []JobDetails, shouldObfuscate bool) error {
+	headers := []string{"repo", "workflow_name", "workflow_run_id", "job_id", "job_name", "job_run_attempt", "runner",
+		"step_number", "step_name", "step_status", "step_conclusion", "step_started_at", "step_completed_at",
+		"step_duration_seconds", "share_percent", "billable_minutes", "billable_in_usd"}
+
+	formatTime := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+
+	flattened := FlattenJobs(jobs, shouldObfuscate)
+	data := [][]string{headers}
+	for i, job := range jobs {
+		fj := flattened[i]
+		for _, step := range JobSteps(job) {
+			data = append(data, []string{
+				dimensionValue(DimensionRepo, job, fj),
+				derefOr(fj.WorkflowName, ""),
+				strconv.FormatInt(job.WorkflowRun.GetID(), 10),
+				strconv.FormatInt(job.Job.GetID(), 10),
+				derefOr(fj.JobName, ""),
+				strconv.FormatInt(job.Job.GetRunAttempt(), 10),
+				job.Runner,
+				strconv.FormatInt(step.Number, 10),
+				step.Name,
+				step.Status,
+				step.Conclusion,
+				formatTime(step.StartedAt),
+				formatTime(step.CompletedAt),
+				strconv.FormatFloat(step.Duration.Seconds(), 'f', 0, 64),
+				strconv.FormatFloat(step.Share, 'f', 2, 64),
+				strconv.FormatFloat(step.BillableMinutes, 'f', 3, 64),
+				strconv.FormatFloat(step.BillableInUSD, 'f', 3, 64),
+			})
+		}
+	}
+	if !HasSteps(jobs) {
+		g.logger.Debug().Msg("No job steps in the requested time frame")
+		return nil
+	}
+
+	return g.writeCSVFile(g.stepsPath, data)
+}
+
+func (g *CSVGenerator) generateTotalsReport(totals TotalCosts, forecast *Forecast) error {
 	// Add the requested columns: report_id, owner, repository, report_created_at
-	headers := []string{"report_id", "owner", "repository", "report_created_at", "total_job_duration", "total_rounded_up_job_duration", "total_billable_in_usd"}
+	headers := []string{"report_id", "owner", "repository", "report_created_at", "total_job_duration", "total_rounded_up_job_duration", "total_billable_in_usd",
+		"forecast_model", "forecast_cycle_end", "forecast_actual_in_usd", "forecast_in_usd", "forecast_lower_in_usd", "forecast_upper_in_usd"}
 
 	// Get current timestamp for report_created_at
 	createdAt := time.Now().Format(g.dateTimeFormat)
@@ -122,9 +248,128 @@ func (g *CSVGenerator) generateTotalsReport(totals TotalCosts) error {
 		},
 	}
 
+	// Forecast columns are left empty when there is not enough history for a forecast
+	if forecast != nil {
+		data[1] = append(data[1],
+			string(forecast.Model),
+			forecast.lastDay(),
+			strconv.FormatFloat(forecast.ActualInUSD, 'f', 3, 64),
+			strconv.FormatFloat(forecast.ForecastInUSD, 'f', 3, 64),
+			strconv.FormatFloat(forecast.LowerInUSD, 'f', 3, 64),
+			strconv.FormatFloat(forecast.UpperInUSD, 'f', 3, 64),
+		)
+	} else {
+		data[1] = append(data[1], "", "", "", "", "", "")
+	}
+
 	return g.writeCSVFile(g.totalsPath, data)
 }
 
+func (g *CSVGenerator) generateGroupedReport(agg Aggregation) error {
+	var headers []string
+	for _, dim := range agg.Dimensions {
+		headers = append(headers, string(dim))
+	}
+	headers = append(headers, "billable_in_usd", "billable_minutes", "job_count", "duration_p50_seconds", "duration_p95_seconds", "share_percent")
+
+	data := [][]string{headers}
+	for _, group := range agg.Groups {
+		row := append([]string{}, group.Keys...)
+		row = append(row,
+			strconv.FormatFloat(group.BillableInUSD, 'f', 3, 64),
+			strconv.FormatFloat(group.BillableMinutes, 'f', 0, 64),
+			strconv.Itoa(group.JobCount),
+			strconv.FormatFloat(group.DurationP50.Seconds(), 'f', 0, 64),
+			strconv.FormatFloat(group.DurationP95.Seconds(), 'f', 0, 64),
+			strconv.FormatFloat(group.Share, 'f', 2, 64),
+		)
+		data = append(data, row)
+	}
+
+	return g.writeCSVFile(g.groupedPath, data)
+}
+
+func (g *CSVGenerator) generateTrendReport(trend Trend) error {
+	headers := []string{"period", "period_start", "billable_in_usd", "billable_minutes", "job_count",
+		"previous_in_usd", "change_percent", "trailing_average_in_usd", "change_from_average_percent", "drivers"}
+
+	// Changes are left empty when there is nothing to compare against
+	formatChange := func(change float64, ok bool) string {
+		if !ok {
+			return ""
+		}
+		return strconv.FormatFloat(change, 'f', 2, 64)
+	}
+
+	data := [][]string{headers}
+	for _, bucket := range trend.Buckets {
+		data = append(data, []string{
+			bucket.Key,
+			bucket.Start.Format(time.DateOnly),
+			strconv.FormatFloat(bucket.BillableInUSD, 'f', 3, 64),
+			strconv.FormatFloat(bucket.BillableMinutes, 'f', 0, 64),
+			strconv.Itoa(bucket.JobCount),
+			strconv.FormatFloat(bucket.PreviousInUSD, 'f', 3, 64),
+			formatChange(bucket.Change()),
+			strconv.FormatFloat(bucket.TrailingAverage, 'f', 3, 64),
+			formatChange(bucket.ChangeFromAverage()),
+			formatDrivers(bucket.Drivers),
+		})
+	}
+
+	return g.writeCSVFile(g.trendPath, data)
+}
+
+func (g *CSVGenerator) generateForecastReport(forecast Forecast) error {
+	headers := []string{"scope", "key", "actual_in_usd", "forecast_in_usd", "lower_in_usd", "upper_in_usd"}
+
+	data := [][]string{headers}
+	add := func(scope, key string, p Projection) {
+		data = append(data, []string{
+			scope,
+			key,
+			strconv.FormatFloat(p.ActualInUSD, 'f', 3, 64),
+			strconv.FormatFloat(p.ForecastInUSD, 'f', 3, 64),
+			strconv.FormatFloat(p.LowerInUSD, 'f', 3, 64),
+			strconv.FormatFloat(p.UpperInUSD, 'f', 3, 64),
+		})
+	}
+	add("total", "", forecast.Projection)
+	for _, group := range forecast.Repos {
+		add(string(DimensionRepo), group.Key, group.Projection)
+	}
+	for _, group := range forecast.Runners {
+		add(string(DimensionRunner), group.Key, group.Projection)
+	}
+
+	return g.writeCSVFile(g.forecastPath, data)
+}
+
+func (g *CSVGenerator) generateBudgetsReport(statuses []BudgetStatus) error {
+	headers := []string{"name", "scope", "match", "period", "period_start", "period_end",
+		"amount_in_usd", "spent_in_usd", "projected_in_usd", "spent_percent", "threshold", "state"}
+
+	data := [][]string{headers}
+	for _, s := range statuses {
+		data = append(data, []string{
+			s.Name,
+			string(s.Scope),
+			s.Match,
+			string(s.Period),
+			s.PeriodStart.Format(time.DateOnly),
+			s.PeriodEnd.Format(time.DateOnly),
+			strconv.FormatFloat(s.Amount, 'f', 3, 64),
+			strconv.FormatFloat(s.Spent, 'f', 3, 64),
+			strconv.FormatFloat(s.Projected, 'f', 3, 64),
+			strconv.FormatFloat(s.SpentPercent, 'f', 2, 64),
+			strconv.FormatFloat(s.Threshold, 'f', 0, 64),
+			string(s.State),
+		})
+	}
+
+	return g.writeCSVFile(g.budgetsPath, data)
+}
+
 func (g *CSVGenerator) writeCSVFile(path string, data [][]string) error {
 	file, err := os.Create(path)
 	if err != nil {
@@ -151,17 +396,26 @@ func (g *CSVGenerator) writeCSVFile(path string, data [][]string) error {
 func (g *CSVGenerator) prepareCSVData(flattened []FlatJobDetails) [][]string {
 	var data [][]string
 
-	// Add headers
+	// Add headers, with a column per cost tag in place of the tags
 	t := reflect.TypeOf(flattened[0])
-	headers := make([]string, t.NumField())
-	for i := range headers {
-		headers[i] = t.Field(i).Name
+	var headers []string
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Name != "Tags" {
+			headers = append(headers, t.Field(i).Name)
+		}
+	}
+	tags := TagNames(flattened)
+	for _, tag := range tags {
+		headers = append(headers, tagDimensionPrefix+tag)
 	}
 	data = append(data, headers)
 
 	// Add data rows
 	for _, fj := range flattened {
 		row := g.structToStringSlice(fj)
+		for _, tag := range tags {
+			row = append(row, fj.Tags[tag])
+		}
 		data = append(data, row)
 	}
 
@@ -170,25 +424,29 @@ func (g *CSVGenerator) prepareCSVData(flattened []FlatJobDetails) [][]string {
 
 func (g *CSVGenerator) structToStringSlice(fj FlatJobDetails) []string {
 	v := reflect.ValueOf(fj)
+	t := v.Type()
 	n := v.NumField()
-	values := make([]string, n)
+	values := make([]string, 0, n)
 
 	for i := 0; i < n; i++ {
+		if t.Field(i).Name == "Tags" {
+			continue
+		}
 		val := v.Field(i).Interface()
 
 		// Convert different types to string
 		switch value := val.(type) {
 		case string:
-			values[i] = value
+			values = append(values, value)
 		case float64:
-			values[i] = strconv.FormatFloat(value, 'f', 3, 64)
+			values = append(values, strconv.FormatFloat(value, 'f', 3, 64))
 		case int:
-			values[i] = strconv.Itoa(value)
+			values = append(values, strconv.Itoa(value))
 		case int64:
-			values[i] = strconv.FormatInt(value, 10)
+			values = append(values, strconv.FormatInt(value, 10))
 		default:
 			// For any other type, use fmt.Sprint
-			values[i] = fmt.Sprint(value)
+			values = append(values, fmt.Sprint(value))
 		}
 	}
 
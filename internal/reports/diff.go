@@ -0,0 +1,436 @@
+package reports
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Diff output formats supported by DiffGenerator, next to FormatJSON
+const (
+	FormatTable    = "table"
+	FormatMarkdown = "markdown"
+)
+
+// DiffStatus describes how a workflow or job changed between two data sets
+type DiffStatus string
+
+const (
+	DiffChanged   DiffStatus = "changed"
+	DiffUnchanged DiffStatus = "unchanged"
+	DiffNew       DiffStatus = "new"     // only present in the head data set
+	DiffRemoved   DiffStatus = "removed" // only present in the base data set
+)
+
+// DiffStats is the usage of a workflow, a job or a whole data set
+type DiffStats struct {
+	Runs            int // distinct workflow runs
+	JobCount        int
+	MeanDuration    time.Duration
+	BillableMinutes float64
+	BillableInUSD   float64
+}
+
+// DiffEntry compares a workflow, or a job within a workflow, between two data sets
+type DiffEntry struct {
+	Workflow string
+	Job      string // empty for workflow entries
+	Status   DiffStatus
+	Base     DiffStats
+	Head     DiffStats
+}
+
+// Name returns the display name of the entry, e.g. "CI / build"
+func (e DiffEntry) Name() string {
+	if e.Job == "" {
+		return e.Workflow
+	}
+	return e.Workflow + " / " + e.Job
+}
+
+// CostDelta returns the change in cost from base to head
+func (e DiffEntry) CostDelta() float64 {
+	return e.Head.BillableInUSD - e.Base.BillableInUSD
+}
+
+// Diff compares the usage of two data sets, e.g. before and after a workflow change.
+// Entries are sorted by the absolute change in cost, largest first.
+type Diff struct {
+	BaseLabel string
+	HeadLabel string
+	Base      DiffStats
+	Head      DiffStats
+	Workflows []DiffEntry
+	Jobs      []DiffEntry
+}
+
+// CompareJobs compares two sets of jobs per workflow and per job. When shouldObfuscate
+// is set, names are obfuscated the same way as in the flattened job reports.
+func CompareJobs(baseLabel string, base []JobDetails, headLabel string, head []JobDetails, shouldObfuscate bool) Diff {
+	baseWorkflows, baseJobs, baseTotals := diffStats(base, shouldObfuscate)
+	headWorkflows, headJobs, headTotals := diffStats(head, shouldObfuscate)
+
+	return Diff{
+		BaseLabel: baseLabel,
+		HeadLabel: headLabel,
+		Base:      baseTotals,
+		Head:      headTotals,
+		Workflows: diffEntries(baseWorkflows, headWorkflows),
+		Jobs:      diffEntries(baseJobs, headJobs),
+	}
+}
+
+// JobsBetween returns the jobs created from the start of the from day up to the end of the to day.
+// A zero from or to leaves that side of the window open.
+func JobsBetween(jobs []JobDetails, from, to time.Time) []JobDetails {
+	var filtered []JobDetails
+	for _, job := range jobs {
+		t := jobTime(job)
+		if t.IsZero() {
+			continue
+		}
+		if !from.IsZero() && t.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !t.Before(to.AddDate(0, 0, 1)) {
+			continue
+		}
+		filtered = append(filtered, job)
+	}
+	return filtered
+}
+
+// diffKey identifies a workflow, or a job within a workflow
+type diffKey struct {
+	workflow string
+	job      string
+}
+
+// diffAccumulator collects the usage of a single diff key
+type diffAccumulator struct {
+	runs     map[int64]struct{}
+	jobs     int
+	duration time.Duration
+	minutes  float64
+	cost     float64
+}
+
+func (a *diffAccumulator) add(job JobDetails) {
+	if job.WorkflowRun != nil && job.WorkflowRun.ID != nil {
+		a.runs[*job.WorkflowRun.ID] = struct{}{}
+	}
+	a.jobs++
+	a.duration += job.JobDuration
+	a.minutes += job.RoundedUpJobDuration.Minutes()
+	a.cost += job.BillableInUSD
+}
+
+func (a *diffAccumulator) stats() DiffStats {
+	s := DiffStats{
+		Runs:            len(a.runs),
+		JobCount:        a.jobs,
+		BillableMinutes: a.minutes,
+		BillableInUSD:   a.cost,
+	}
+	if a.jobs > 0 {
+		s.MeanDuration = a.duration / time.Duration(a.jobs)
+	}
+	return s
+}
+
+func newDiffAccumulator() *diffAccumulator {
+	return &diffAccumulator{runs: make(map[int64]struct{})}
+}
+
+// diffStats computes the usage per workflow, per job and in total
+func diffStats(jobs []JobDetails, shouldObfuscate bool) (workflows, jobStats map[diffKey]DiffStats, totals DiffStats) {
+	flattened := FlattenJobs(jobs, shouldObfuscate)
+
+	workflowAcc := make(map[diffKey]*diffAccumulator)
+	jobAcc := make(map[diffKey]*diffAccumulator)
+	totalAcc := newDiffAccumulator()
+
+	for i, job := range jobs {
+		workflow := derefOr(flattened[i].WorkflowName, "unknown")
+		keys := []struct {
+			acc map[diffKey]*diffAccumulator
+			key diffKey
+		}{
+			{workflowAcc, diffKey{workflow: workflow}},
+			{jobAcc, diffKey{workflow: workflow, job: derefOr(flattened[i].JobName, "unknown")}},
+		}
+		for _, k := range keys {
+			acc, ok := k.acc[k.key]
+			if !ok {
+				acc = newDiffAccumulator()
+				k.acc[k.key] = acc
+			}
+			acc.add(job)
+		}
+		totalAcc.add(job)
+	}
+
+	workflows = make(map[diffKey]DiffStats, len(workflowAcc))
+	for k, acc := range workflowAcc {
+		workflows[k] = acc.stats()
+	}
+	jobStats = make(map[diffKey]DiffStats, len(jobAcc))
+	for k, acc := range jobAcc {
+		jobStats[k] = acc.stats()
+	}
+	return workflows, jobStats, totalAcc.stats()
+}
+
+// diffEntries pairs the stats of both data sets and classifies every entry
+func diffEntries(base, head map[diffKey]DiffStats) []DiffEntry {
+	var entries []DiffEntry
+	for k, b := range base {
+		entry := DiffEntry{Workflow: k.workflow, Job: k.job, Base: b, Status: DiffRemoved}
+		if h, ok := head[k]; ok {
+			entry.Head = h
+			entry.Status = DiffChanged
+			if b == h {
+				entry.Status = DiffUnchanged
+			}
+		}
+		entries = append(entries, entry)
+	}
+	for k, h := range head {
+		if _, ok := base[k]; !ok {
+			entries = append(entries, DiffEntry{Workflow: k.workflow, Job: k.job, Head: h, Status: DiffNew})
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if a, b := math.Abs(entries[i].CostDelta()), math.Abs(entries[j].CostDelta()); a != b {
+			return a > b
+		}
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries
+}
+
+// DiffGenerator writes a Diff as a plain text table, Markdown or JSON
+type DiffGenerator struct {
+	out    io.Writer
+	format string
+	topN   int
+	logger zerolog.Logger
+}
+
+// NewDiffGenerator creates a new diff generator that writes to out.
+// topN limits the number of rows per table in the table and Markdown formats.
+func NewDiffGenerator(out io.Writer, format string, topN int, logger zerolog.Logger) *DiffGenerator {
+	if topN <= 0 {
+		topN = DefaultTopN
+	}
+	return &DiffGenerator{
+		out:    out,
+		format: format,
+		topN:   topN,
+		logger: logger,
+	}
+}
+
+func (g *DiffGenerator) Generate(diff *Diff) error {
+	g.logger.Debug().Str("format", g.format).Msg("Generating diff")
+
+	var err error
+	switch g.format {
+	case FormatTable:
+		_, err = io.WriteString(g.out, g.renderTable(diff))
+	case FormatMarkdown:
+		_, err = io.WriteString(g.out, g.renderMarkdown(diff))
+	case FormatJSON:
+		enc := json.NewEncoder(g.out)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(exportDiff(diff))
+	default:
+		return fmt.Errorf("unsupported diff format %q, must be one of: %s, %s, %s", g.format, FormatTable, FormatMarkdown, FormatJSON)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s diff: %w", g.format, err)
+	}
+	return nil
+}
+
+func (g *DiffGenerator) renderTable(diff *Diff) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Comparing %s (base) with %s (head)\n\n", diff.BaseLabel, diff.HeadLabel)
+	fmt.Fprintf(&b, "%-17s %12s %12s %12s\n", "", "Base", "Head", "Change")
+	fmt.Fprintf(&b, "%-17s %12s %12s %12s\n", "Total cost", formatUSD(diff.Base.BillableInUSD), formatUSD(diff.Head.BillableInUSD), formatDelta(diff.Head.BillableInUSD, diff.Base.BillableInUSD))
+	fmt.Fprintf(&b, "%-17s %12.0f %12.0f %12s\n", "Billable minutes", diff.Base.BillableMinutes, diff.Head.BillableMinutes, formatDelta(diff.Head.BillableMinutes, diff.Base.BillableMinutes))
+	fmt.Fprintf(&b, "%-17s %12d %12d %12s\n", "Runs", diff.Base.Runs, diff.Head.Runs, formatDelta(float64(diff.Head.Runs), float64(diff.Base.Runs)))
+	fmt.Fprintf(&b, "%-17s %12d %12d %12s\n", "Jobs", diff.Base.JobCount, diff.Head.JobCount, formatDelta(float64(diff.Head.JobCount), float64(diff.Base.JobCount)))
+
+	g.writeTableSection(&b, "Workflows", diff.Workflows)
+	g.writeTableSection(&b, "Jobs", diff.Jobs)
+	return b.String()
+}
+
+func (g *DiffGenerator) writeTableSection(b *strings.Builder, title string, entries []DiffEntry) {
+	entries = changedEntries(entries, g.topN)
+	if len(entries) == 0 {
+		return
+	}
+
+	const nameWidth = 32
+	fmt.Fprintf(b, "\n%s\n", title)
+	fmt.Fprintf(b, "%s %-9s %11s %13s %13s %10s %10s %10s\n",
+		padRight("Name", nameWidth), "Status", "Runs", "Mean duration", "Minutes", "Base", "Head", "Change")
+	for _, e := range entries {
+		fmt.Fprintf(b, "%s %-9s %11s %13s %13s %10s %10s %10s\n",
+			padRight(truncate(e.Name(), nameWidth), nameWidth),
+			e.Status,
+			fmt.Sprintf("%d→%d", e.Base.Runs, e.Head.Runs),
+			formatDurationDelta(e.Head.MeanDuration-e.Base.MeanDuration),
+			fmt.Sprintf("%+.0f", e.Head.BillableMinutes-e.Base.BillableMinutes),
+			formatUSD(e.Base.BillableInUSD),
+			formatUSD(e.Head.BillableInUSD),
+			formatSignedUSD(e.CostDelta()))
+	}
+}
+
+func (g *DiffGenerator) renderMarkdown(diff *Diff) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "## GitHub Actions cost diff\n\n")
+	fmt.Fprintf(&b, "Comparing `%s` (base) with `%s` (head)\n\n", diff.BaseLabel, diff.HeadLabel)
+	b.WriteString("| | Base | Head | Change |\n")
+	b.WriteString("| --- | ---: | ---: | ---: |\n")
+	fmt.Fprintf(&b, "| Total cost | %s | %s | %s |\n", formatUSD(diff.Base.BillableInUSD), formatUSD(diff.Head.BillableInUSD), formatDelta(diff.Head.BillableInUSD, diff.Base.BillableInUSD))
+	fmt.Fprintf(&b, "| Billable minutes | %.0f | %.0f | %s |\n", diff.Base.BillableMinutes, diff.Head.BillableMinutes, formatDelta(diff.Head.BillableMinutes, diff.Base.BillableMinutes))
+	fmt.Fprintf(&b, "| Runs | %d | %d | %s |\n", diff.Base.Runs, diff.Head.Runs, formatDelta(float64(diff.Head.Runs), float64(diff.Base.Runs)))
+	fmt.Fprintf(&b, "| Jobs | %d | %d | %s |\n\n", diff.Base.JobCount, diff.Head.JobCount, formatDelta(float64(diff.Head.JobCount), float64(diff.Base.JobCount)))
+
+	g.writeMarkdownSection(&b, "Workflows", "Workflow", diff.Workflows)
+	g.writeMarkdownSection(&b, "Jobs", "Job", diff.Jobs)
+	return b.String()
+}
+
+func (g *DiffGenerator) writeMarkdownSection(b *strings.Builder, title, column string, entries []DiffEntry) {
+	entries = changedEntries(entries, g.topN)
+	if len(entries) == 0 {
+		return
+	}
+
+	fmt.Fprintf(b, "### %s\n\n", title)
+	fmt.Fprintf(b, "| %s | Status | Runs | Mean duration | Minutes | Base | Head | Change |\n", column)
+	b.WriteString("| --- | --- | ---: | ---: | ---: | ---: | ---: | ---: |\n")
+	for _, e := range entries {
+		fmt.Fprintf(b, "| %s | %s | %d → %d | %s | %+.0f | %s | %s | %s |\n",
+			escapeMarkdownCell(e.Name()),
+			e.Status,
+			e.Base.Runs, e.Head.Runs,
+			formatDurationDelta(e.Head.MeanDuration-e.Base.MeanDuration),
+			e.Head.BillableMinutes-e.Base.BillableMinutes,
+			formatUSD(e.Base.BillableInUSD),
+			formatUSD(e.Head.BillableInUSD),
+			formatSignedUSD(e.CostDelta()))
+	}
+	b.WriteString("\n")
+}
+
+// changedEntries drops unchanged entries and returns at most n of the rest
+func changedEntries(entries []DiffEntry, n int) []DiffEntry {
+	var changed []DiffEntry
+	for _, e := range entries {
+		if e.Status != DiffUnchanged {
+			changed = append(changed, e)
+		}
+	}
+	if len(changed) > n {
+		changed = changed[:n]
+	}
+	return changed
+}
+
+// formatDurationDelta formats a change in duration, e.g. "+1m30s"
+func formatDurationDelta(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d < 0 {
+		return d.String()
+	}
+	return "+" + d.String()
+}
+
+// DiffExportStats is the usage of a workflow, job or data set in a JSON diff. Durations are in seconds.
+type DiffExportStats struct {
+	Runs                int     `json:"runs"`
+	JobCount            int     `json:"job_count"`
+	MeanDurationSeconds float64 `json:"mean_duration"`
+	BillableMinutes     float64 `json:"billable_minutes"`
+	BillableInUSD       float64 `json:"billable_in_usd"`
+}
+
+// DiffExportEntry is a workflow or job in a JSON diff
+type DiffExportEntry struct {
+	Workflow string          `json:"workflow"`
+	Job      string          `json:"job,omitempty"`
+	Status   DiffStatus      `json:"status"`
+	Base     DiffExportStats `json:"base"`
+	Head     DiffExportStats `json:"head"`
+	Delta    DiffExportStats `json:"delta"`
+}
+
+// DiffExport is the document written by the json diff format. It lists every
+// workflow and job, including unchanged ones.
+type DiffExport struct {
+	SchemaVersion string            `json:"schema_version"`
+	BaseLabel     string            `json:"base_label"`
+	HeadLabel     string            `json:"head_label"`
+	Base          DiffExportStats   `json:"base"`
+	Head          DiffExportStats   `json:"head"`
+	Workflows     []DiffExportEntry `json:"workflows"`
+	Jobs          []DiffExportEntry `json:"jobs"`
+}
+
+func exportDiff(diff *Diff) DiffExport {
+	entries := func(diffEntries []DiffEntry) []DiffExportEntry {
+		exported := make([]DiffExportEntry, len(diffEntries))
+		for i, e := range diffEntries {
+			exported[i] = DiffExportEntry{
+				Workflow: e.Workflow,
+				Job:      e.Job,
+				Status:   e.Status,
+				Base:     exportDiffStats(e.Base),
+				Head:     exportDiffStats(e.Head),
+				Delta: DiffExportStats{
+					Runs:                e.Head.Runs - e.Base.Runs,
+					JobCount:            e.Head.JobCount - e.Base.JobCount,
+					MeanDurationSeconds: (e.Head.MeanDuration - e.Base.MeanDuration).Seconds(),
+					BillableMinutes:     e.Head.BillableMinutes - e.Base.BillableMinutes,
+					BillableInUSD:       e.CostDelta(),
+				},
+			}
+		}
+		return exported
+	}
+
+	return DiffExport{
+		SchemaVersion: ExportSchemaVersion,
+		BaseLabel:     diff.BaseLabel,
+		HeadLabel:     diff.HeadLabel,
+		Base:          exportDiffStats(diff.Base),
+		Head:          exportDiffStats(diff.Head),
+		Workflows:     entries(diff.Workflows),
+		Jobs:          entries(diff.Jobs),
+	}
+}
+
+func exportDiffStats(s DiffStats) DiffExportStats {
+	return DiffExportStats{
+		Runs:                s.Runs,
+		JobCount:            s.JobCount,
+		MeanDurationSeconds: s.MeanDuration.Seconds(),
+		BillableMinutes:     s.BillableMinutes,
+		BillableInUSD:       s.BillableInUSD,
+	}
+}
@@ -0,0 +1,153 @@
+package reports
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// diffTestJob creates a job of the given workflow run for diff tests
+func diffTestJob(workflow, job string, runID int64, created time.Time, duration time.Duration, cost float64) JobDetails {
+	j := aggregateTestJob(workflow, "UBUNTU", "success", created, duration, cost)
+	j.WorkflowRun.ID = github.Int64(runID)
+	j.Job.Name = github.String(job)
+	return j
+}
+
+func setupDiffTestData() (base, head []JobDetails) {
+	day := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+	base = []JobDetails{
+		diffTestJob("CI", "build", 1, day, 10*time.Minute, 0.08),
+		diffTestJob("CI", "test", 1, day, 20*time.Minute, 0.16),
+		diffTestJob("CI", "build", 2, day, 10*time.Minute, 0.08),
+		diffTestJob("CI", "test", 2, day, 20*time.Minute, 0.16),
+		diffTestJob("Lint", "lint", 3, day, time.Minute, 0.008),
+	}
+	head = []JobDetails{
+		diffTestJob("CI", "build", 4, day, 5*time.Minute, 0.04),
+		diffTestJob("CI", "test-sharded", 4, day, 10*time.Minute, 0.08),
+		diffTestJob("CI", "test-sharded", 4, day, 10*time.Minute, 0.08),
+		diffTestJob("Lint", "lint", 5, day, time.Minute, 0.008),
+	}
+	return base, head
+}
+
+func TestCompareJobs(t *testing.T) {
+	base, head := setupDiffTestData()
+	diff := CompareJobs("before", base, "after", head, false)
+
+	assert.Equal(t, 3, diff.Base.Runs)
+	assert.Equal(t, 2, diff.Head.Runs)
+	assert.InDelta(t, 0.488, diff.Base.BillableInUSD, 0.0001)
+	assert.InDelta(t, 0.208, diff.Head.BillableInUSD, 0.0001)
+
+	t.Run("Workflows", func(t *testing.T) {
+		require.Len(t, diff.Workflows, 2)
+		ci := diff.Workflows[0]
+		assert.Equal(t, "CI", ci.Name())
+		assert.Equal(t, DiffChanged, ci.Status)
+		assert.Equal(t, 2, ci.Base.Runs)
+		assert.Equal(t, 1, ci.Head.Runs)
+		assert.Equal(t, 15*time.Minute, ci.Base.MeanDuration)
+		assert.InDelta(t, -0.28, ci.CostDelta(), 0.0001)
+
+		lint := diff.Workflows[1]
+		assert.Equal(t, DiffUnchanged, lint.Status)
+	})
+
+	t.Run("Jobs", func(t *testing.T) {
+		statuses := make(map[string]DiffStatus)
+		for _, e := range diff.Jobs {
+			statuses[e.Name()] = e.Status
+		}
+		assert.Equal(t, map[string]DiffStatus{
+			"CI / build":        DiffChanged,
+			"CI / test":         DiffRemoved,
+			"CI / test-sharded": DiffNew,
+			"Lint / lint":       DiffUnchanged,
+		}, statuses)
+
+		// Sorted by the absolute change in cost
+		assert.Equal(t, "CI / test", diff.Jobs[0].Name())
+		assert.Equal(t, "CI / test-sharded", diff.Jobs[1].Name())
+	})
+}
+
+func TestJobsBetween(t *testing.T) {
+	day := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	jobs := []JobDetails{
+		diffTestJob("CI", "build", 1, day.Add(-time.Second), time.Minute, 0.008),
+		diffTestJob("CI", "build", 2, day, time.Minute, 0.008),
+		diffTestJob("CI", "build", 3, day.Add(47*time.Hour), time.Minute, 0.008),
+		diffTestJob("CI", "build", 4, day.AddDate(0, 0, 2), time.Minute, 0.008),
+	}
+
+	assert.Len(t, JobsBetween(jobs, day, day.AddDate(0, 0, 1)), 2, "the to day is inclusive")
+	assert.Len(t, JobsBetween(jobs, day, time.Time{}), 3)
+	assert.Len(t, JobsBetween(jobs, time.Time{}, day), 2)
+}
+
+func TestDiffGenerator(t *testing.T) {
+	logger := zerolog.New(io.Discard)
+	base, head := setupDiffTestData()
+	diff := CompareJobs("before", base, "after", head, false)
+
+	t.Run("Table", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewDiffGenerator(&buf, FormatTable, 10, logger).Generate(&diff))
+
+		out := buf.String()
+		assert.Contains(t, out, "Comparing before (base) with after (head)")
+		assert.Contains(t, out, "Total cost               $0.49        $0.21       -57.4%")
+		assert.Contains(t, out, "CI / test-sharded                new")
+		assert.Contains(t, out, "-$0.32")
+		assert.NotContains(t, out, "Lint", "unchanged entries are omitted")
+	})
+
+	t.Run("Markdown", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewDiffGenerator(&buf, FormatMarkdown, 10, logger).Generate(&diff))
+
+		md := buf.String()
+		assert.Contains(t, md, "Comparing `before` (base) with `after` (head)")
+		assert.Contains(t, md, "| Total cost | $0.49 | $0.21 | -57.4% |")
+		assert.Contains(t, md, "| CI / test | removed | 2 → 0 | -20m0s | -40 | $0.32 | $0.00 | -$0.32 |")
+		assert.Contains(t, md, "| CI / test-sharded | new | 0 → 1 | +10m0s | +20 | $0.00 | $0.16 | +$0.16 |")
+	})
+
+	t.Run("MarkdownTopN", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewDiffGenerator(&buf, FormatMarkdown, 1, logger).Generate(&diff))
+
+		section := strings.SplitN(buf.String(), "### Jobs", 2)[1]
+		assert.Equal(t, 3, strings.Count(section, "\n|"), "expected header, separator and one row")
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewDiffGenerator(&buf, FormatJSON, 1, logger).Generate(&diff))
+
+		var export DiffExport
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &export))
+		assert.Equal(t, ExportSchemaVersion, export.SchemaVersion)
+		assert.Equal(t, "before", export.BaseLabel)
+		assert.Len(t, export.Jobs, 4, "JSON lists every job, including unchanged ones")
+		assert.Equal(t, "test", export.Jobs[0].Job)
+		assert.Equal(t, DiffRemoved, export.Jobs[0].Status)
+		assert.Equal(t, 1200.0, export.Jobs[0].Base.MeanDurationSeconds)
+		assert.InDelta(t, -0.32, export.Jobs[0].Delta.BillableInUSD, 0.0001)
+	})
+
+	t.Run("UnsupportedFormat", func(t *testing.T) {
+		err := NewDiffGenerator(io.Discard, "xml", 10, logger).Generate(&diff)
+		assert.ErrorContains(t, err, `unsupported diff format "xml"`)
+	})
+}
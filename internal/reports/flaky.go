@@ -0,0 +1,367 @@
+package reports
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// FormatCSV is the csv output format of FlakyGenerator, next to FormatTable, FormatMarkdown and FormatJSON
+const FormatCSV = "csv"
+
+// FlakySort selects how flaky jobs are ranked
+type FlakySort string
+
+const (
+	FlakyByRate FlakySort = "rate" // share of runs that flaked
+	FlakyByCost FlakySort = "cost" // cost of the retries
+)
+
+// ParseFlakySort validates a flaky job ranking, e.g. from the --sort flag
+func ParseFlakySort(name string) (FlakySort, error) {
+	switch sortBy := FlakySort(strings.ToLower(strings.TrimSpace(name))); sortBy {
+	case FlakyByRate, FlakyByCost:
+		return sortBy, nil
+	case "":
+		return FlakyByRate, nil
+	}
+	return "", fmt.Errorf("unsupported flaky sort %q, must be one of: rate, cost", name)
+}
+
+// FlakyRun is a run in which a job failed in one attempt and succeeded in the next
+type FlakyRun struct {
+	RunID          int64
+	URL            string
+	HeadSHA        string
+	CreatedAt      time.Time
+	FailedAttempt  int     // the attempt that failed, the next one succeeded
+	RetryCostInUSD float64 // cost of the attempt that succeeded
+}
+
+// FlakyJob is a job that failed and then succeeded on a re-run of the same commit
+type FlakyJob struct {
+	Workflow       string
+	Job            string
+	Runs           int // runs the job was part of
+	RetryCostInUSD float64
+	FlakyRuns      []FlakyRun // most recent first
+}
+
+// Name returns "workflow / job"
+func (f FlakyJob) Name() string {
+	return f.Workflow + " / " + f.Job
+}
+
+// Rate returns the share of the job's runs that flaked in percent
+func (f FlakyJob) Rate() float64 {
+	if f.Runs == 0 {
+		return 0
+	}
+	return float64(len(f.FlakyRuns)) / float64(f.Runs) * 100
+}
+
+// flakyAttempt is the outcome of a job in a single attempt of a run
+type flakyAttempt struct {
+	conclusion string
+	cost       float64
+}
+
+// DetectFlaky finds jobs that failed in attempt N of a run and succeeded in attempt N+1.
+// All attempts of a run share the run's head SHA, so a pass on re-run means the same
+// commit both failed and passed. Previous attempts are the jobs fetched from
+// RunWithJobs.AttemptJobs, told apart by their run attempt. A run counts as a single
+// flake even if the job flaked in more than one attempt.
+func DetectFlaky(jobs []JobDetails, sortBy FlakySort, shouldObfuscate bool) []FlakyJob {
+	flattened := FlattenJobs(jobs, shouldObfuscate)
+
+	type runJob struct {
+		diffKey
+		runID int64
+	}
+	attempts := make(map[runJob]map[int]*flakyAttempt)
+	runs := make(map[runJob]JobDetails)
+	seenJobs := make(map[int64]bool)
+	for i, job := range jobs {
+		if job.WorkflowRun == nil || job.Job == nil {
+			continue
+		}
+		if id := job.Job.GetID(); id != 0 {
+			if seenJobs[id] {
+				continue
+			}
+			seenJobs[id] = true
+		}
+		key := runJob{
+			diffKey: diffKey{workflow: derefOr(flattened[i].WorkflowName, "unknown"), job: derefOr(flattened[i].JobName, "unknown")},
+			runID:   job.WorkflowRun.GetID(),
+		}
+		attempt := int(job.Job.GetRunAttempt())
+		if attempt == 0 {
+			attempt = job.WorkflowRun.GetRunAttempt()
+		}
+
+		if attempts[key] == nil {
+			attempts[key] = make(map[int]*flakyAttempt)
+			runs[key] = job
+		}
+		a, ok := attempts[key][attempt]
+		if !ok {
+			a = &flakyAttempt{}
+			attempts[key][attempt] = a
+		}
+		// A failure of any job with the same name fails the attempt
+		if c := job.Job.GetConclusion(); a.conclusion == "" || isFailure(c) {
+			a.conclusion = c
+		}
+		a.cost += job.BillableInUSD
+	}
+
+	byJob := make(map[diffKey]*FlakyJob)
+	for key, byAttempt := range attempts {
+		flaky, ok := byJob[key.diffKey]
+		if !ok {
+			flaky = &FlakyJob{Workflow: key.workflow, Job: key.job}
+			byJob[key.diffKey] = flaky
+		}
+		flaky.Runs++
+
+		var run *FlakyRun
+		for n, a := range byAttempt {
+			next, ok := byAttempt[n+1]
+			if !ok || !isFailure(a.conclusion) || next.conclusion != "success" {
+				continue
+			}
+			if run == nil {
+				job := runs[key]
+				run = &FlakyRun{
+					RunID:         key.runID,
+					HeadSHA:       job.WorkflowRun.GetHeadSHA(),
+					CreatedAt:     jobTime(job),
+					FailedAttempt: n,
+				}
+				// URLs name the repository, which is obfuscated
+				if !shouldObfuscate {
+					run.URL = runURL(job)
+				}
+			}
+			run.FailedAttempt = min(run.FailedAttempt, n)
+			run.RetryCostInUSD += next.cost
+		}
+		if run != nil {
+			flaky.FlakyRuns = append(flaky.FlakyRuns, *run)
+			flaky.RetryCostInUSD += run.RetryCostInUSD
+		}
+	}
+
+	var flaky []FlakyJob
+	for _, f := range byJob {
+		if len(f.FlakyRuns) == 0 {
+			continue
+		}
+		sort.Slice(f.FlakyRuns, func(i, j int) bool {
+			if !f.FlakyRuns[i].CreatedAt.Equal(f.FlakyRuns[j].CreatedAt) {
+				return f.FlakyRuns[i].CreatedAt.After(f.FlakyRuns[j].CreatedAt)
+			}
+			return f.FlakyRuns[i].RunID > f.FlakyRuns[j].RunID
+		})
+		flaky = append(flaky, *f)
+	}
+
+	sort.Slice(flaky, func(i, j int) bool {
+		a, b := flaky[i], flaky[j]
+		if sortBy == FlakyByCost && a.RetryCostInUSD != b.RetryCostInUSD {
+			return a.RetryCostInUSD > b.RetryCostInUSD
+		}
+		if a.Rate() != b.Rate() {
+			return a.Rate() > b.Rate()
+		}
+		if a.RetryCostInUSD != b.RetryCostInUSD {
+			return a.RetryCostInUSD > b.RetryCostInUSD
+		}
+		return a.Name() < b.Name()
+	})
+	return flaky
+}
+
+// isFailure reports whether a job conclusion is a failure that a re-run could fix
+func isFailure(conclusion string) bool {
+	return conclusion == "failure" || conclusion == "timed_out"
+}
+
+// FlakyGenerator writes flaky jobs as a plain text table, CSV, Markdown or JSON
+type FlakyGenerator struct {
+	out    io.Writer
+	format string
+	topN   int
+	logger zerolog.Logger
+}
+
+// NewFlakyGenerator creates a new flaky job generator that writes to out.
+// The table and Markdown formats list the topN jobs, CSV and JSON list all of them.
+func NewFlakyGenerator(out io.Writer, format string, topN int, logger zerolog.Logger) *FlakyGenerator {
+	if topN <= 0 {
+		topN = DefaultTopN
+	}
+	return &FlakyGenerator{
+		out:    out,
+		format: format,
+		topN:   topN,
+		logger: logger,
+	}
+}
+
+func (g *FlakyGenerator) Generate(flaky []FlakyJob) error {
+	g.logger.Debug().Str("format", g.format).Msg("Generating flaky jobs")
+
+	var err error
+	switch g.format {
+	case FormatTable:
+		_, err = io.WriteString(g.out, g.renderTable(flaky))
+	case FormatCSV:
+		err = g.writeCSV(flaky)
+	case FormatMarkdown:
+		_, err = io.WriteString(g.out, g.renderMarkdown(flaky))
+	case FormatJSON:
+		enc := json.NewEncoder(g.out)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(exportFlaky(flaky))
+	default:
+		return fmt.Errorf("unsupported flaky format %q, must be one of: %s, %s, %s, %s", g.format, FormatTable, FormatCSV, FormatMarkdown, FormatJSON)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s flaky jobs: %w", g.format, err)
+	}
+	return nil
+}
+
+func (g *FlakyGenerator) renderTable(flaky []FlakyJob) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Flaky jobs (%d)\n", len(flaky))
+	if len(flaky) == 0 {
+		b.WriteString("No jobs failed and then passed on a re-run.\n")
+		return b.String()
+	}
+
+	const nameWidth = 40
+	fmt.Fprintf(&b, "\n%s %7s %6s %6s %12s  %s\n", padRight("Job", nameWidth), "Flakes", "Runs", "Rate", "Retry cost", "Last flake")
+	for _, f := range topOf(flaky, g.topN) {
+		fmt.Fprintf(&b, "%s %7d %6d %5.1f%% %12s  %s\n",
+			padRight(truncate(f.Name(), nameWidth), nameWidth),
+			len(f.FlakyRuns),
+			f.Runs,
+			f.Rate(),
+			formatUSD(f.RetryCostInUSD),
+			f.FlakyRuns[0].URL)
+	}
+	return b.String()
+}
+
+func (g *FlakyGenerator) writeCSV(flaky []FlakyJob) error {
+	w := csv.NewWriter(g.out)
+	if err := w.Write([]string{"workflow", "job", "flaky_runs", "runs", "flake_rate_percent", "retry_cost_in_usd", "last_flaky_run_url", "last_flaky_head_sha"}); err != nil {
+		return err
+	}
+	for _, f := range flaky {
+		if err := w.Write([]string{
+			f.Workflow,
+			f.Job,
+			strconv.Itoa(len(f.FlakyRuns)),
+			strconv.Itoa(f.Runs),
+			strconv.FormatFloat(f.Rate(), 'f', 2, 64),
+			strconv.FormatFloat(f.RetryCostInUSD, 'f', 3, 64),
+			f.FlakyRuns[0].URL,
+			f.FlakyRuns[0].HeadSHA,
+		}); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func (g *FlakyGenerator) renderMarkdown(flaky []FlakyJob) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "## Flaky jobs (%d)\n\n", len(flaky))
+	if len(flaky) == 0 {
+		b.WriteString("No jobs failed and then passed on a re-run.\n")
+		return b.String()
+	}
+
+	b.WriteString("| Job | Flaky runs | Runs | Flake rate | Retry cost | Last flake |\n")
+	b.WriteString("| --- | ---: | ---: | ---: | ---: | --- |\n")
+	for _, f := range topOf(flaky, g.topN) {
+		last := f.FlakyRuns[0]
+		fmt.Fprintf(&b, "| %s | %d | %d | %.1f%% | %s | %s |\n",
+			escapeMarkdownCell(f.Name()),
+			len(f.FlakyRuns),
+			f.Runs,
+			f.Rate(),
+			formatUSD(f.RetryCostInUSD),
+			markdownLink(fmt.Sprintf("attempt %d → %d", last.FailedAttempt, last.FailedAttempt+1), last.URL))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// FlakyExport is the document written by the json flaky format
+type FlakyExport struct {
+	SchemaVersion string           `json:"schema_version"`
+	Jobs          []FlakyJobExport `json:"jobs"`
+}
+
+// FlakyJobExport is a flaky job in a JSON flaky export
+type FlakyJobExport struct {
+	Workflow         string           `json:"workflow"`
+	Job              string           `json:"job"`
+	Runs             int              `json:"runs"`
+	FlakeRatePercent float64          `json:"flake_rate_percent"`
+	RetryCostInUSD   float64          `json:"retry_cost_in_usd"`
+	FlakyRuns        []FlakyRunExport `json:"flaky_runs"`
+}
+
+// FlakyRunExport is a run in which a job flaked in a JSON flaky export
+type FlakyRunExport struct {
+	RunID          int64   `json:"run_id"`
+	URL            string  `json:"url,omitempty"`
+	HeadSHA        string  `json:"head_sha,omitempty"`
+	CreatedAt      string  `json:"created_at,omitempty"`
+	FailedAttempt  int     `json:"failed_attempt"`
+	RetryCostInUSD float64 `json:"retry_cost_in_usd"`
+}
+
+func exportFlaky(flaky []FlakyJob) FlakyExport {
+	export := FlakyExport{SchemaVersion: ExportSchemaVersion, Jobs: []FlakyJobExport{}}
+	for _, f := range flaky {
+		job := FlakyJobExport{
+			Workflow:         f.Workflow,
+			Job:              f.Job,
+			Runs:             f.Runs,
+			FlakeRatePercent: f.Rate(),
+			RetryCostInUSD:   f.RetryCostInUSD,
+		}
+		for _, run := range f.FlakyRuns {
+			r := FlakyRunExport{
+				RunID:          run.RunID,
+				URL:            run.URL,
+				HeadSHA:        run.HeadSHA,
+				FailedAttempt:  run.FailedAttempt,
+				RetryCostInUSD: run.RetryCostInUSD,
+			}
+			if !run.CreatedAt.IsZero() {
+				r.CreatedAt = run.CreatedAt.UTC().Format(time.RFC3339)
+			}
+			job.FlakyRuns = append(job.FlakyRuns, r)
+		}
+		export.Jobs = append(export.Jobs, job)
+	}
+	return export
+}
@@ -0,0 +1,141 @@
+package reports
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupFlakyTestData() []JobDetails {
+	day := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+	jobs := []JobDetails{
+		// Run 1: test failed in attempt 1 and passed in attempt 2
+		wasteTestJob("CI", "test", 1, 11, 1, 2, "failure", day, 2),
+		wasteTestJob("CI", "test", 1, 12, 2, 2, "success", day, 2),
+		// Run 2: test passed on the first attempt
+		wasteTestJob("CI", "test", 2, 21, 1, 1, "success", day.Add(time.Hour), 2),
+		// Run 3: e2e timed out twice before passing in attempt 3, counted as a single flake
+		wasteTestJob("CI", "e2e", 3, 31, 1, 3, "timed_out", day.Add(2*time.Hour), 5),
+		wasteTestJob("CI", "e2e", 3, 32, 2, 3, "failure", day.Add(2*time.Hour), 5),
+		wasteTestJob("CI", "e2e", 3, 33, 3, 3, "success", day.Add(2*time.Hour), 5),
+		wasteTestJob("CI", "e2e", 4, 41, 1, 1, "success", day.Add(3*time.Hour), 5),
+		wasteTestJob("CI", "e2e", 5, 51, 1, 1, "success", day.Add(4*time.Hour), 5),
+		wasteTestJob("CI", "e2e", 6, 61, 1, 1, "success", day.Add(5*time.Hour), 5),
+		// Run 7: lint failed in both attempts, which is not a flake
+		wasteTestJob("Lint", "lint", 7, 71, 1, 2, "failure", day, 1),
+		wasteTestJob("Lint", "lint", 7, 72, 2, 2, "failure", day, 1),
+		// A job listed twice is only counted once
+		wasteTestJob("CI", "test", 1, 12, 2, 2, "success", day, 2),
+	}
+	for i := range jobs {
+		jobs[i].WorkflowRun.HeadSHA = github.String("abc123")
+		jobs[i].WorkflowRun.HTMLURL = github.String("https://github.com/testowner/testrepo/actions/runs/" + strconv.FormatInt(jobs[i].WorkflowRun.GetID(), 10))
+	}
+	return jobs
+}
+
+func TestDetectFlaky(t *testing.T) {
+	flaky := DetectFlaky(setupFlakyTestData(), FlakyByRate, false)
+	require.Len(t, flaky, 2)
+
+	assert.Equal(t, "CI / test", flaky[0].Name())
+	assert.Equal(t, 2, flaky[0].Runs)
+	assert.InDelta(t, 50, flaky[0].Rate(), 0.0001)
+	assert.InDelta(t, 2, flaky[0].RetryCostInUSD, 0.0001)
+	require.Len(t, flaky[0].FlakyRuns, 1)
+	assert.Equal(t, int64(1), flaky[0].FlakyRuns[0].RunID)
+	assert.Equal(t, 1, flaky[0].FlakyRuns[0].FailedAttempt)
+	assert.Equal(t, "abc123", flaky[0].FlakyRuns[0].HeadSHA)
+	assert.Equal(t, "https://github.com/testowner/testrepo/actions/runs/1", flaky[0].FlakyRuns[0].URL)
+
+	assert.Equal(t, "CI / e2e", flaky[1].Name())
+	assert.Equal(t, 4, flaky[1].Runs)
+	assert.InDelta(t, 25, flaky[1].Rate(), 0.0001)
+	assert.InDelta(t, 5, flaky[1].RetryCostInUSD, 0.0001)
+	assert.Equal(t, 2, flaky[1].FlakyRuns[0].FailedAttempt)
+
+	byCost := DetectFlaky(setupFlakyTestData(), FlakyByCost, false)
+	require.Len(t, byCost, 2)
+	assert.Equal(t, "CI / e2e", byCost[0].Name())
+	assert.Equal(t, "CI / test", byCost[1].Name())
+
+	obfuscated := DetectFlaky(setupFlakyTestData(), FlakyByRate, true)
+	require.Len(t, obfuscated, 2)
+	assert.Empty(t, obfuscated[0].FlakyRuns[0].URL)
+}
+
+func TestParseFlakySort(t *testing.T) {
+	sortBy, err := ParseFlakySort("Cost")
+	require.NoError(t, err)
+	assert.Equal(t, FlakyByCost, sortBy)
+
+	sortBy, err = ParseFlakySort("")
+	require.NoError(t, err)
+	assert.Equal(t, FlakyByRate, sortBy)
+
+	_, err = ParseFlakySort("duration")
+	assert.Error(t, err)
+}
+
+func TestFlakyGenerator(t *testing.T) {
+	flaky := DetectFlaky(setupFlakyTestData(), FlakyByRate, false)
+
+	t.Run("Table", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewFlakyGenerator(&buf, FormatTable, 1, zerolog.New(io.Discard)).Generate(flaky))
+		out := buf.String()
+		assert.Contains(t, out, "Flaky jobs (2)")
+		assert.Contains(t, out, "CI / test")
+		assert.Contains(t, out, "50.0%")
+		assert.NotContains(t, out, "CI / e2e")
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewFlakyGenerator(&buf, FormatCSV, 1, zerolog.New(io.Discard)).Generate(flaky))
+		lines := splitLines(strings.TrimSpace(buf.String()))
+		require.Len(t, lines, 3)
+		assert.Equal(t, "workflow,job,flaky_runs,runs,flake_rate_percent,retry_cost_in_usd,last_flaky_run_url,last_flaky_head_sha", lines[0])
+		assert.Contains(t, lines[1], "CI,test,1,2,50.00,2.000,")
+		assert.Contains(t, lines[2], "CI,e2e,1,4,25.00,5.000,")
+	})
+
+	t.Run("Markdown", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewFlakyGenerator(&buf, FormatMarkdown, 0, zerolog.New(io.Discard)).Generate(flaky))
+		out := buf.String()
+		assert.Contains(t, out, "## Flaky jobs (2)")
+		assert.Contains(t, out, "| CI / e2e | 1 | 4 | 25.0% | $5.00 |")
+		assert.Contains(t, out, "attempt 2 → 3")
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewFlakyGenerator(&buf, FormatJSON, 0, zerolog.New(io.Discard)).Generate(flaky))
+		var export FlakyExport
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &export))
+		assert.Equal(t, ExportSchemaVersion, export.SchemaVersion)
+		require.Len(t, export.Jobs, 2)
+		assert.Equal(t, "abc123", export.Jobs[0].FlakyRuns[0].HeadSHA)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewFlakyGenerator(&buf, FormatTable, 0, zerolog.New(io.Discard)).Generate(nil))
+		assert.Contains(t, buf.String(), "No jobs failed and then passed on a re-run.")
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		err := NewFlakyGenerator(io.Discard, "xml", 0, zerolog.New(io.Discard)).Generate(flaky)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,338 @@
+package reports
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ForecastModel is the model used to forecast daily cost
+type ForecastModel string
+
+const (
+	ForecastAuto    ForecastModel = "auto"    // weekday with two weeks of history, linear otherwise
+	ForecastLinear  ForecastModel = "linear"  // linear trend over the daily cost
+	ForecastWeekday ForecastModel = "weekday" // linear trend scaled by a factor per day of the week
+)
+
+const (
+	// DefaultForecastHistory is the default number of days the forecast is fitted on
+	DefaultForecastHistory = 56
+	// DefaultForecastConfidence is the default confidence of the forecast range in percent
+	DefaultForecastConfidence = 90.0
+
+	// minForecastHistory is the number of complete days needed to fit a trend and its error
+	minForecastHistory = 3
+	// minWeekdayHistory is the number of complete days needed to see every weekday twice
+	minWeekdayHistory = 14
+)
+
+// ParseForecastModel validates a forecast model, e.g. from the --forecast-model flag
+func ParseForecastModel(name string) (ForecastModel, error) {
+	switch model := ForecastModel(strings.ToLower(strings.TrimSpace(name))); model {
+	case ForecastAuto, ForecastLinear, ForecastWeekday:
+		return model, nil
+	case "":
+		return ForecastAuto, nil
+	}
+	return "", fmt.Errorf("unsupported forecast model %q, must be one of: auto, linear, weekday", name)
+}
+
+// ForecastConfig configures how a forecast is built
+type ForecastConfig struct {
+	Model      ForecastModel // defaults to auto
+	History    int           // number of complete days the model is fitted on, defaults to DefaultForecastHistory
+	Confidence float64       // confidence of the forecast range in percent, defaults to DefaultForecastConfidence
+}
+
+// Projection is the spend of a billing cycle so far and at its end
+type Projection struct {
+	ActualInUSD   float64 // spend in the billing cycle so far
+	ForecastInUSD float64 // projected spend at the end of the billing cycle
+	LowerInUSD    float64 // lower bound of the confidence range, never below the actual spend
+	UpperInUSD    float64 // upper bound of the confidence range
+}
+
+// ForecastGroup is the projection of a single repository or runner type
+type ForecastGroup struct {
+	Key string
+	Projection
+}
+
+// Forecast projects spend to the end of the current billing cycle, which is the calendar month in UTC.
+// Groups are sorted by forecast cost, most expensive first.
+type Forecast struct {
+	Model       ForecastModel // model used, never auto
+	Confidence  float64
+	CycleStart  time.Time
+	CycleEnd    time.Time // exclusive
+	DataStart   time.Time // first day of the jobs, after CycleStart when ActualInUSD misses the start of the cycle
+	HistoryDays int       // number of complete days the model was fitted on
+	Projection
+	Repos   []ForecastGroup
+	Runners []ForecastGroup
+}
+
+// BuildForecast fits a model on the daily cost of the complete days before now, and projects
+// the spend of the billing cycle containing now to its end. It returns false when there are
+// fewer than three days of history to fit on.
+func BuildForecast(jobs []JobDetails, config ForecastConfig, now time.Time, shouldObfuscate bool) (Forecast, bool) {
+	if config.Model == "" {
+		config.Model = ForecastAuto
+	}
+	if config.History <= 0 {
+		config.History = DefaultForecastHistory
+	}
+	if config.Confidence <= 0 || config.Confidence >= 100 {
+		config.Confidence = DefaultForecastConfidence
+	}
+
+	today := periodStart(DimensionDay, now)
+	historyStart := today.AddDate(0, 0, -config.History)
+
+	// History starts at the first day with data, so days before it don't count as zero spend
+	first := dataStart(jobs)
+	if first.IsZero() {
+		return Forecast{}, false
+	}
+	if first.After(historyStart) {
+		historyStart = first
+	}
+	days := int(today.Sub(historyStart).Hours() / 24)
+	if days < minForecastHistory {
+		return Forecast{}, false
+	}
+
+	model := config.Model
+	if model == ForecastAuto {
+		model = ForecastLinear
+		if days >= minWeekdayHistory {
+			model = ForecastWeekday
+		}
+	}
+
+	forecast := Forecast{
+		Model:       model,
+		Confidence:  config.Confidence,
+		CycleStart:  periodStart(DimensionMonth, now),
+		CycleEnd:    nextPeriod(DimensionMonth, periodStart(DimensionMonth, now)),
+		DataStart:   first,
+		HistoryDays: days,
+	}
+	p := projector{
+		model:        model,
+		historyStart: historyStart,
+		days:         days,
+		now:          now,
+		cycleStart:   forecast.CycleStart,
+		cycleEnd:     forecast.CycleEnd,
+		z:            math.Sqrt2 * math.Erfinv(config.Confidence/100),
+	}
+
+	flattened := FlattenJobs(jobs, shouldObfuscate)
+	repos := make(map[string][]JobDetails)
+	runners := make(map[string][]JobDetails)
+	for i, job := range jobs {
+		repo := dimensionValue(DimensionRepo, job, flattened[i])
+		repos[repo] = append(repos[repo], job)
+		runner := dimensionValue(DimensionRunner, job, flattened[i])
+		runners[runner] = append(runners[runner], job)
+	}
+
+	forecast.Projection = p.project(jobs)
+	forecast.Repos = p.projectGroups(repos)
+	forecast.Runners = p.projectGroups(runners)
+	return forecast, true
+}
+
+// dataStart returns the first day of the jobs, zero when none has a time
+func dataStart(jobs []JobDetails) time.Time {
+	var first time.Time
+	for _, job := range jobs {
+		if t := jobTime(job); !t.IsZero() && (first.IsZero() || t.Before(first)) {
+			first = t
+		}
+	}
+	if first.IsZero() {
+		return first
+	}
+	return periodStart(DimensionDay, first)
+}
+
+// projector projects the spend of a set of jobs using a model fitted on their daily cost
+type projector struct {
+	model        ForecastModel
+	historyStart time.Time
+	days         int
+	now          time.Time
+	cycleStart   time.Time
+	cycleEnd     time.Time
+	z            float64 // standard score of the confidence range
+}
+
+func (p projector) projectGroups(groups map[string][]JobDetails) []ForecastGroup {
+	projected := make([]ForecastGroup, 0, len(groups))
+	for key, jobs := range groups {
+		projected = append(projected, ForecastGroup{Key: key, Projection: p.project(jobs)})
+	}
+	sort.Slice(projected, func(i, j int) bool {
+		if projected[i].ForecastInUSD != projected[j].ForecastInUSD {
+			return projected[i].ForecastInUSD > projected[j].ForecastInUSD
+		}
+		return projected[i].Key < projected[j].Key
+	})
+	return projected
+}
+
+func (p projector) project(jobs []JobDetails) Projection {
+	var projection Projection
+	daily := make([]float64, p.days)
+	for _, job := range jobs {
+		t := jobTime(job)
+		if !t.Before(p.cycleStart) && t.Before(p.cycleEnd) {
+			projection.ActualInUSD += job.BillableInUSD
+		}
+		if i := int(t.Sub(p.historyStart).Hours() / 24); !t.Before(p.historyStart) && i < p.days {
+			daily[i] += job.BillableInUSD
+		}
+	}
+
+	m := fitDailyModel(daily, p.historyStart, p.model == ForecastWeekday)
+
+	// The rest of today is weighted by the part of the day that is left
+	today := periodStart(DimensionDay, p.now)
+	remaining := float64(today.AddDate(0, 0, 1).Sub(p.now)) / float64(24*time.Hour)
+	predicted := m.predict(today, p.historyStart) * remaining
+	for day := today.AddDate(0, 0, 1); day.Before(p.cycleEnd); day = day.AddDate(0, 0, 1) {
+		predicted += m.predict(day, p.historyStart)
+		remaining++
+	}
+
+	// Daily errors are assumed independent, so the error of the sum grows with its square root
+	margin := p.z * m.sigma * math.Sqrt(remaining)
+	projection.ForecastInUSD = projection.ActualInUSD + predicted
+	projection.LowerInUSD = max(projection.ActualInUSD, projection.ForecastInUSD-margin)
+	projection.UpperInUSD = projection.ForecastInUSD + margin
+	return projection
+}
+
+// dailyModel is a linear trend over daily cost, optionally scaled by a factor per weekday
+type dailyModel struct {
+	intercept float64
+	slope     float64
+	factors   [7]float64 // by time.Weekday, all 1 without weekday seasonality
+	sigma     float64    // standard deviation of the daily residuals
+}
+
+// fitDailyModel fits a model on the daily cost starting at start
+func fitDailyModel(daily []float64, start time.Time, weekday bool) dailyModel {
+	var m dailyModel
+	for i := range m.factors {
+		m.factors[i] = 1
+	}
+
+	if weekday {
+		var sums, counts [7]float64
+		total := 0.0
+		for i, cost := range daily {
+			wd := start.AddDate(0, 0, i).Weekday()
+			sums[wd] += cost
+			counts[wd]++
+			total += cost
+		}
+		if mean := total / float64(len(daily)); mean > 0 {
+			for wd := range m.factors {
+				if counts[wd] > 0 {
+					m.factors[wd] = sums[wd] / counts[wd] / mean
+				}
+			}
+		}
+	}
+
+	// Fit the trend on the deseasonalized cost, skipping weekdays that never have any
+	var xs, ys []float64
+	for i, cost := range daily {
+		factor := m.factors[start.AddDate(0, 0, i).Weekday()]
+		if factor == 0 {
+			continue
+		}
+		xs = append(xs, float64(i))
+		ys = append(ys, cost/factor)
+	}
+	m.intercept, m.slope = fitLine(xs, ys)
+
+	if len(daily) > 2 {
+		sum := 0.0
+		for i, cost := range daily {
+			residual := cost - m.predict(start.AddDate(0, 0, i), start)
+			sum += residual * residual
+		}
+		m.sigma = math.Sqrt(sum / float64(len(daily)-2))
+	}
+	return m
+}
+
+// predict returns the expected cost of day, never below zero
+func (m dailyModel) predict(day, start time.Time) float64 {
+	x := day.Sub(start).Hours() / 24
+	return max(0, m.intercept+m.slope*x) * m.factors[day.Weekday()]
+}
+
+// fitLine fits y = intercept + slope*x with least squares
+func fitLine(xs, ys []float64) (intercept, slope float64) {
+	n := float64(len(xs))
+	if n == 0 {
+		return 0, 0
+	}
+	var sumX, sumY float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+	}
+	meanX, meanY := sumX/n, sumY/n
+
+	var sxx, sxy float64
+	for i := range xs {
+		sxx += (xs[i] - meanX) * (xs[i] - meanX)
+		sxy += (xs[i] - meanX) * (ys[i] - meanY)
+	}
+	if sxx == 0 {
+		return meanY, 0
+	}
+	slope = sxy / sxx
+	return meanY - slope*meanX, slope
+}
+
+// formatForecastRange formats the confidence range of a projection, e.g. "$90.00–$120.00"
+func formatForecastRange(p Projection) string {
+	return formatUSD(p.LowerInUSD) + "–" + formatUSD(p.UpperInUSD)
+}
+
+// lastDay returns the last day of the billing cycle, e.g. "2025-04-30"
+func (f Forecast) lastDay() string {
+	return f.CycleEnd.AddDate(0, 0, -1).Format(time.DateOnly)
+}
+
+// Partial reports whether the jobs start after the start of the billing cycle, so the spend
+// so far and the forecast miss the jobs before them
+func (f Forecast) Partial() bool {
+	return f.DataStart.After(f.CycleStart)
+}
+
+// partialNote returns ", missing the spend before <date>" for a partial forecast, or ""
+func (f Forecast) partialNote() string {
+	if !f.Partial() {
+		return ""
+	}
+	return ", missing the spend before " + f.DataStart.Format(time.DateOnly)
+}
+
+// Summary describes the forecast in a sentence, e.g.
+// "$120.00 by 2025-04-30 (90% range $100.00–$140.00, weekday model)", followed by
+// the day the data starts when it misses the start of the billing cycle
+func (f Forecast) Summary() string {
+	return fmt.Sprintf("%s by %s (%.0f%% range %s, %s model)%s",
+		formatUSD(f.ForecastInUSD), f.lastDay(), f.Confidence, formatForecastRange(f.Projection), f.Model, f.partialNote())
+}
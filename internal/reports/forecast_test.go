@@ -0,0 +1,147 @@
+package reports
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupForecastTestData returns $10 a day on UBUNTU and $2 a day on MACOS from 2025-04-01 to 2025-04-20
+func setupForecastTestData() []JobDetails {
+	start := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+	var jobs []JobDetails
+	for day := 0; day < 20; day++ {
+		created := start.AddDate(0, 0, day)
+		jobs = append(jobs,
+			aggregateTestJob("CI", "UBUNTU", "success", created, time.Hour, 10),
+			aggregateTestJob("CI", "MACOS", "success", created, time.Minute, 2),
+		)
+	}
+	return jobs
+}
+
+// setupWeekdayForecastTestData returns $10 every weekday and nothing on weekends
+// for five weeks, from Monday 2025-03-03 to Sunday 2025-04-06
+func setupWeekdayForecastTestData() []JobDetails {
+	start := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+	var jobs []JobDetails
+	for day := 0; day < 35; day++ {
+		created := start.AddDate(0, 0, day)
+		if wd := created.Weekday(); wd == time.Saturday || wd == time.Sunday {
+			continue
+		}
+		jobs = append(jobs, aggregateTestJob("CI", "UBUNTU", "success", created, time.Hour, 10))
+	}
+	return jobs
+}
+
+func TestParseForecastModel(t *testing.T) {
+	for name, want := range map[string]ForecastModel{"": ForecastAuto, "auto": ForecastAuto, " Linear ": ForecastLinear, "weekday": ForecastWeekday} {
+		model, err := ParseForecastModel(name)
+		require.NoError(t, err)
+		assert.Equal(t, want, model)
+	}
+	_, err := ParseForecastModel("arima")
+	assert.Error(t, err)
+}
+
+func TestBuildForecast(t *testing.T) {
+	now := time.Date(2025, 4, 21, 0, 0, 0, 0, time.UTC)
+
+	t.Run("Constant", func(t *testing.T) {
+		forecast, ok := BuildForecast(setupForecastTestData(), ForecastConfig{}, now, false)
+		require.True(t, ok)
+		assert.Equal(t, ForecastWeekday, forecast.Model, "auto picks weekday with two weeks of history")
+		assert.Equal(t, DefaultForecastConfidence, forecast.Confidence)
+		assert.Equal(t, 20, forecast.HistoryDays)
+		assert.Equal(t, time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), forecast.CycleStart)
+		assert.Equal(t, time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC), forecast.CycleEnd)
+
+		// 20 days so far and 10 more days to go
+		assert.InDelta(t, 240, forecast.ActualInUSD, 0.0001)
+		assert.InDelta(t, 360, forecast.ForecastInUSD, 0.0001)
+		assert.InDelta(t, 360, forecast.LowerInUSD, 0.0001, "a perfect fit has no error")
+		assert.InDelta(t, 360, forecast.UpperInUSD, 0.0001)
+		assert.Equal(t, "$360.00 by 2025-04-30 (90% range $360.00–$360.00, weekday model)", forecast.Summary())
+		assert.False(t, forecast.Partial())
+
+		require.Len(t, forecast.Repos, 1)
+		assert.Equal(t, "testowner/testrepo", forecast.Repos[0].Key)
+		require.Len(t, forecast.Runners, 2)
+		assert.Equal(t, "UBUNTU", forecast.Runners[0].Key)
+		assert.InDelta(t, 300, forecast.Runners[0].ForecastInUSD, 0.0001)
+		assert.Equal(t, "MACOS", forecast.Runners[1].Key)
+		assert.InDelta(t, 60, forecast.Runners[1].ForecastInUSD, 0.0001)
+	})
+
+	t.Run("PartialDay", func(t *testing.T) {
+		forecast, ok := BuildForecast(setupForecastTestData(), ForecastConfig{Model: ForecastLinear}, now.Add(12*time.Hour), false)
+		require.True(t, ok)
+		assert.Equal(t, ForecastLinear, forecast.Model)
+		// Half of today and 9 more days to go
+		assert.InDelta(t, 240+12*9.5, forecast.ForecastInUSD, 0.0001)
+	})
+
+	t.Run("Weekday", func(t *testing.T) {
+		jobs := setupWeekdayForecastTestData()
+		now := time.Date(2025, 4, 7, 0, 0, 0, 0, time.UTC)
+
+		weekday, ok := BuildForecast(jobs, ForecastConfig{Model: ForecastWeekday}, now, false)
+		require.True(t, ok)
+		// April 1-4 so far, and 18 weekdays from April 7 to 30
+		assert.InDelta(t, 40, weekday.ActualInUSD, 0.0001)
+		assert.InDelta(t, 220, weekday.ForecastInUSD, 0.0001)
+		assert.InDelta(t, 220, weekday.UpperInUSD, 0.0001, "a perfect fit has no error")
+
+		// Without seasonality, the weekends at the end of every week pull the trend down
+		linear, ok := BuildForecast(jobs, ForecastConfig{Model: ForecastLinear}, now, false)
+		require.True(t, ok)
+		assert.Less(t, linear.ForecastInUSD, weekday.ForecastInUSD)
+		assert.Greater(t, linear.UpperInUSD, linear.ForecastInUSD)
+		assert.Less(t, linear.LowerInUSD, linear.ForecastInUSD)
+		assert.GreaterOrEqual(t, linear.LowerInUSD, linear.ActualInUSD)
+
+		// A wider confidence gives a wider range
+		wide, ok := BuildForecast(jobs, ForecastConfig{Model: ForecastLinear, Confidence: 99}, now, false)
+		require.True(t, ok)
+		assert.Greater(t, wide.UpperInUSD, linear.UpperInUSD)
+	})
+
+	t.Run("Trend", func(t *testing.T) {
+		// Cost grows by $1 a day, so the next days are expected to cost more than the last one
+		start := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+		var jobs []JobDetails
+		for day := 0; day < 10; day++ {
+			jobs = append(jobs, aggregateTestJob("CI", "UBUNTU", "success", start.AddDate(0, 0, day), time.Hour, float64(day+1)))
+		}
+		forecast, ok := BuildForecast(jobs, ForecastConfig{Model: ForecastLinear}, time.Date(2025, 4, 29, 0, 0, 0, 0, time.UTC), false)
+		require.True(t, ok)
+		// Days 11 to 28 had no spend, which pulls the trend down, but it never goes below zero
+		assert.GreaterOrEqual(t, forecast.ForecastInUSD, forecast.ActualInUSD)
+
+		forecast, ok = BuildForecast(jobs, ForecastConfig{Model: ForecastLinear}, time.Date(2025, 4, 11, 0, 0, 0, 0, time.UTC), false)
+		require.True(t, ok)
+		// Days 11 to 30 are expected to cost $11 to $30
+		assert.InDelta(t, 55+410, forecast.ForecastInUSD, 0.0001)
+	})
+
+	t.Run("PartialCycle", func(t *testing.T) {
+		// The data starts on April 8, so the spend of the first week is missing
+		forecast, ok := BuildForecast(setupForecastTestData()[14:], ForecastConfig{Model: ForecastLinear}, now, false)
+		require.True(t, ok)
+		assert.True(t, forecast.Partial())
+		assert.Equal(t, time.Date(2025, 4, 8, 0, 0, 0, 0, time.UTC), forecast.DataStart)
+		assert.InDelta(t, 13*12, forecast.ActualInUSD, 0.0001)
+		assert.Equal(t, "$276.00 by 2025-04-30 (90% range $276.00–$276.00, linear model), missing the spend before 2025-04-08", forecast.Summary())
+	})
+
+	t.Run("NotEnoughHistory", func(t *testing.T) {
+		_, ok := BuildForecast(setupForecastTestData()[:4], ForecastConfig{}, time.Date(2025, 4, 3, 0, 0, 0, 0, time.UTC), false)
+		assert.False(t, ok)
+
+		_, ok = BuildForecast(nil, ForecastConfig{}, now, false)
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,100 @@
+package reports
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ReportHistory is the local history of reports generated on the server, so reports can
+// be found again when the server can't list them
+type ReportHistory struct {
+	path string
+}
+
+// NewReportHistory creates the history kept in the file at path
+func NewReportHistory(path string) *ReportHistory {
+	return &ReportHistory{path: path}
+}
+
+// Reports returns the reports in the history, from the most recently created
+func (h *ReportHistory) Reports() ([]ReportInfo, error) {
+	content, err := os.ReadFile(h.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var infos []ReportInfo
+	if err := json.Unmarshal(content, &infos); err != nil {
+		return nil, fmt.Errorf("failed to read report history %s: %w", h.path, err)
+	}
+	SortReportInfos(infos)
+	return infos, nil
+}
+
+// Find returns the report with this ID in the history
+func (h *ReportHistory) Find(reportID string) (ReportInfo, bool, error) {
+	infos, err := h.Reports()
+	if err != nil {
+		return ReportInfo{}, false, err
+	}
+	for _, info := range infos {
+		if info.ReportID == reportID {
+			return info, true, nil
+		}
+	}
+	return ReportInfo{}, false, nil
+}
+
+// Record adds a report to the history, replacing an earlier record of it
+func (h *ReportHistory) Record(info ReportInfo) error {
+	infos, err := h.Reports()
+	if err != nil {
+		return err
+	}
+	for i := range infos {
+		if infos[i].ReportID == info.ReportID {
+			infos[i] = info
+			return h.save(infos)
+		}
+	}
+	return h.save(append(infos, info))
+}
+
+// Forget removes a report from the history
+func (h *ReportHistory) Forget(reportID string) error {
+	infos, err := h.Reports()
+	if err != nil {
+		return err
+	}
+	kept := infos[:0]
+	for _, info := range infos {
+		if info.ReportID != reportID {
+			kept = append(kept, info)
+		}
+	}
+	if len(kept) == len(infos) {
+		return nil
+	}
+	return h.save(kept)
+}
+
+func (h *ReportHistory) save(infos []ReportInfo) error {
+	SortReportInfos(infos)
+	content, err := json.MarshalIndent(infos, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0700); err != nil {
+		return err
+	}
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, h.path)
+}
@@ -0,0 +1,412 @@
+package reports
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+//go:embed templates/report.html.tmpl
+var htmlTemplates embed.FS
+
+// HTMLGenerator generates a single self-contained HTML report.
+// All styles, scripts and data are inlined so the file can be opened offline
+// and never makes network calls.
+type HTMLGenerator struct {
+	path      string
+	out       io.Writer // when set, the report is written here instead of path
+	logger    zerolog.Logger
+	ownerName string
+	repoName  string
+	reportID  string
+}
+
+// NewHTMLGenerator creates a new HTML report generator writing to path
+func NewHTMLGenerator(path string, logger zerolog.Logger) *HTMLGenerator {
+	return &HTMLGenerator{
+		path:   path,
+		logger: logger,
+	}
+}
+
+// NewHTMLGeneratorWithFormat creates a new HTML report generator with a formatted filename
+func NewHTMLGeneratorWithFormat(basePath string, owner, repo, reportID string, logger zerolog.Logger) *HTMLGenerator {
+	timestamp := time.Now().Format("2006-01-02T15:04:05")
+	path := basePath + "/" + timestamp + "_" + owner + "_" + repo + "_" + reportID + "_report.html"
+
+	return &HTMLGenerator{
+		path:      path,
+		logger:    logger,
+		ownerName: owner,
+		repoName:  repo,
+		reportID:  reportID,
+	}
+}
+
+// NewHTMLGeneratorForWriter creates a new HTML report generator writing to out,
+// e.g. to serve the report over HTTP
+func NewHTMLGeneratorForWriter(out io.Writer, owner, repo, reportID string, logger zerolog.Logger) *HTMLGenerator {
+	return &HTMLGenerator{
+		out:       out,
+		logger:    logger,
+		ownerName: owner,
+		repoName:  repo,
+		reportID:  reportID,
+	}
+}
+
+func (g *HTMLGenerator) GetPath() string {
+	return g.path
+}
+
+// htmlCard is a single summary card at the top of the report
+type htmlCard struct {
+	Label string
+	Value string
+}
+
+// htmlBreakdown is a titled breakdown table
+type htmlBreakdown struct {
+	Title string
+	Rows  []Group
+}
+
+// htmlDay is a single bar in the cost over time chart
+type htmlDay struct {
+	Date          string
+	BillableInUSD float64
+	Height        float64 // bar height in chart units
+	X             float64
+}
+
+// htmlTrend is the cost trend section, only rendered when the report has a trend
+type htmlTrend struct {
+	Title       string
+	AverageName string
+	Rows        []htmlTrendRow
+}
+
+// htmlTrendRow is a single period of the cost trend
+type htmlTrendRow struct {
+	Period          string
+	BillableInUSD   float64
+	BillableMinutes float64
+	JobCount        int
+	VsPrevious      string
+	VsAverage       string
+	Increase        bool // cost went up compared to the previous period
+	Drivers         string
+}
+
+// htmlBudget is a budget that needs attention, shown at the top of the report
+type htmlBudget struct {
+	Name      string
+	Period    string
+	Spent     float64
+	Projected float64
+	Amount    float64
+	Used      float64
+	State     BudgetState
+}
+
+// htmlForecast is the forecast section, only rendered when the report has a forecast
+type htmlForecast struct {
+	Summary string
+	Tables  []htmlForecastTable
+}
+
+// htmlForecastTable is a titled table of forecasts per repository or runner type
+type htmlForecastTable struct {
+	Title string
+	Rows  []ForecastGroup
+}
+
+// htmlJobRow is a single row in the jobs table
+type htmlJobRow struct {
+	CreatedAt       string
+	CreatedAtUnix   int64
+	Workflow        string
+	Job             string
+	Branch          string
+	Actor           string
+	Runner          string
+	Conclusion      string
+	DurationSeconds float64
+	Duration        string
+	BillableInUSD   float64
+	URL             string
+}
+
+// htmlView is the data passed to the HTML template
+type htmlView struct {
+	Title       string
+	GeneratedAt string
+	ReportID    string
+	Cards       []htmlCard
+	Budgets     []htmlBudget
+	BudgetsNote string // set when budget periods start before the data
+	Days        []htmlDay
+	ChartWidth  float64
+	ChartHeight float64
+	BarWidth    float64
+	MaxDayCost  float64
+	Trend       *htmlTrend
+	Forecast    *htmlForecast
+	Breakdowns  []htmlBreakdown
+	Jobs        []htmlJobRow
+	Conclusions []string
+}
+
+func (g *HTMLGenerator) Generate(data *ReportData) error {
+	g.logger.Debug().Msg("Generating HTML report")
+
+	tmpl, err := template.New("report.html.tmpl").Funcs(template.FuncMap{
+		"usd":           formatUSD,
+		"num":           func(f float64) string { return fmt.Sprintf("%.0f", f) },
+		"forecastRange": formatForecastRange,
+		"pct":           func(f float64) string { return fmt.Sprintf("%.1f%%", f) },
+		"subtract":      func(a, b float64) float64 { return a - b },
+		"last":          func(days []htmlDay) int { return len(days) - 1 },
+		"barWidth": func(w float64) float64 {
+			// Leave a small gap between bars when there is room for it
+			if w > 2 {
+				return w - 1
+			}
+			return w
+		},
+		"shareWidth": func(share float64) int { return int(share) },
+		"dur":        func(d time.Duration) string { return d.Round(time.Second).String() },
+	}).ParseFS(htmlTemplates, "templates/report.html.tmpl")
+	if err != nil {
+		return fmt.Errorf("failed to parse HTML template: %w", err)
+	}
+
+	if g.out != nil {
+		if err := tmpl.Execute(g.out, g.buildView(data)); err != nil {
+			return fmt.Errorf("failed to render HTML report: %w", err)
+		}
+		return nil
+	}
+
+	file, err := os.Create(g.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := tmpl.Execute(file, g.buildView(data)); err != nil {
+		return fmt.Errorf("failed to render HTML report: %w", err)
+	}
+
+	g.logger.Debug().Msgf("%s created successfully!", g.path)
+	return nil
+}
+
+func (g *HTMLGenerator) buildView(data *ReportData) htmlView {
+	flattened := FlattenJobs(data.Jobs, data.ObfuscateData)
+
+	title := "GitHub Actions cost report"
+	if g.ownerName != "" && g.repoName != "" {
+		title = fmt.Sprintf("GitHub Actions cost report: %s/%s", g.ownerName, g.repoName)
+	}
+
+	view := htmlView{
+		Title:       title,
+		GeneratedAt: time.Now().Format("2006-01-02 15:04:05 MST"),
+		ReportID:    g.reportID,
+		ChartWidth:  800,
+		ChartHeight: 200,
+	}
+
+	runs := make(map[int64]struct{})
+	conclusions := make(map[string]struct{})
+	// Waste is classified like the waste command does, so both report the same spend
+	wastedCost := 0.0
+	wasteKinds := ClassifyWaste(data.Jobs)
+	for i, job := range data.Jobs {
+		fj := flattened[i]
+		if job.WorkflowRun != nil && job.WorkflowRun.ID != nil {
+			runs[*job.WorkflowRun.ID] = struct{}{}
+		}
+
+		conclusion := derefOr(fj.JobConclusion, "unknown")
+		conclusions[conclusion] = struct{}{}
+		if wasteKinds[i] != "" {
+			wastedCost += job.BillableInUSD
+		}
+
+		created := jobTime(job)
+		row := htmlJobRow{
+			CreatedAtUnix:   created.Unix(),
+			Workflow:        derefOr(fj.WorkflowName, "unknown"),
+			Job:             derefOr(fj.JobName, "unknown"),
+			Branch:          derefOr(fj.HeadBranch, ""),
+			Actor:           derefOr(fj.ActorLogin, ""),
+			Runner:          job.Runner,
+			Conclusion:      conclusion,
+			DurationSeconds: job.JobDuration.Seconds(),
+			Duration:        job.JobDuration.String(),
+			BillableInUSD:   job.BillableInUSD,
+		}
+		if !created.IsZero() {
+			row.CreatedAt = created.Format("2006-01-02 15:04")
+		}
+		if job.Job != nil && job.Job.HTMLURL != nil && !data.ObfuscateData {
+			row.URL = *job.Job.HTMLURL
+		}
+		view.Jobs = append(view.Jobs, row)
+	}
+
+	// Most expensive jobs first
+	sort.SliceStable(view.Jobs, func(i, j int) bool {
+		return view.Jobs[i].BillableInUSD > view.Jobs[j].BillableInUSD
+	})
+
+	for c := range conclusions {
+		view.Conclusions = append(view.Conclusions, c)
+	}
+	sort.Strings(view.Conclusions)
+
+	wastedShare := 0.0
+	if data.Totals.BillableInUSD > 0 {
+		wastedShare = wastedCost / data.Totals.BillableInUSD * 100
+	}
+
+	view.Cards = []htmlCard{
+		{Label: "Total cost", Value: formatUSD(data.Totals.BillableInUSD)},
+		{Label: "Billable minutes", Value: fmt.Sprintf("%.0f", data.Totals.RoundedUpJobDuration.Minutes())},
+		{Label: "Jobs", Value: fmt.Sprintf("%d", len(data.Jobs))},
+		{Label: "Workflow runs", Value: fmt.Sprintf("%d", len(runs))},
+		{Label: "Wasted", Value: fmt.Sprintf("%.1f%% of cost", wastedShare)},
+	}
+
+	if f := data.Forecast; f != nil {
+		view.Cards = append(view.Cards, htmlCard{Label: "Forecast " + f.lastDay(), Value: formatUSD(f.ForecastInUSD)})
+		view.Forecast = &htmlForecast{Summary: fmt.Sprintf("%s so far, %s", formatUSD(f.ActualInUSD), f.Summary())}
+		// A single repository's forecast is the same as the total
+		if len(f.Repos) > 1 {
+			view.Forecast.Tables = append(view.Forecast.Tables, htmlForecastTable{Title: "Repositories", Rows: f.Repos})
+		}
+		view.Forecast.Tables = append(view.Forecast.Tables, htmlForecastTable{Title: "Runner types", Rows: f.Runners})
+	}
+
+	for _, s := range alertingBudgets(data.Budgets) {
+		view.Budgets = append(view.Budgets, htmlBudget{
+			Name:      s.Name,
+			Period:    formatBudgetPeriod(s),
+			Spent:     s.Spent,
+			Projected: s.Projected,
+			Amount:    s.Amount,
+			Used:      s.SpentPercent,
+			State:     s.State,
+		})
+	}
+	view.BudgetsNote = partialBudgetsNote(alertingBudgets(data.Budgets))
+
+	g.buildDays(&view, data.Jobs)
+	if data.Trend != nil && len(data.Trend.Buckets) > 0 {
+		view.Trend = buildHTMLTrend(*data.Trend)
+	}
+
+	breakdown := func(title string, dims ...Dimension) htmlBreakdown {
+		return htmlBreakdown{Title: title, Rows: Aggregate(data.Jobs, dims, data.ObfuscateData).Groups}
+	}
+	if len(data.GroupBy) > 0 {
+		grouped := Aggregate(data.Jobs, data.GroupBy, data.ObfuscateData)
+		view.Breakdowns = append(view.Breakdowns, htmlBreakdown{Title: "By " + grouped.Title(), Rows: grouped.Groups})
+	}
+	view.Breakdowns = append(view.Breakdowns,
+		breakdown("Workflows", DimensionWorkflow),
+		breakdown("Runners", DimensionRunner),
+		breakdown("Branches", DimensionBranch),
+		breakdown("Actors", DimensionActor),
+	)
+
+	return view
+}
+
+// buildDays buckets job cost by calendar day for the cost over time chart
+func (g *HTMLGenerator) buildDays(view *htmlView, jobs []JobDetails) {
+	costs := make(map[string]float64)
+	for _, job := range jobs {
+		t := jobTime(job)
+		if t.IsZero() {
+			continue
+		}
+		costs[t.Format(time.DateOnly)] += job.BillableInUSD
+	}
+	if len(costs) == 0 {
+		return
+	}
+
+	dates := make([]string, 0, len(costs))
+	for d := range costs {
+		dates = append(dates, d)
+	}
+	sort.Strings(dates)
+
+	// Fill in days without any jobs so gaps are visible in the chart
+	first, _ := time.Parse(time.DateOnly, dates[0])
+	last, _ := time.Parse(time.DateOnly, dates[len(dates)-1])
+	var days []htmlDay
+	for d := first; !d.After(last); d = d.AddDate(0, 0, 1) {
+		date := d.Format(time.DateOnly)
+		days = append(days, htmlDay{Date: date, BillableInUSD: costs[date]})
+		if costs[date] > view.MaxDayCost {
+			view.MaxDayCost = costs[date]
+		}
+	}
+
+	view.BarWidth = view.ChartWidth / float64(len(days))
+	for i := range days {
+		days[i].X = float64(i) * view.BarWidth
+		if view.MaxDayCost > 0 {
+			days[i].Height = days[i].BillableInUSD / view.MaxDayCost * view.ChartHeight
+		}
+	}
+	view.Days = days
+}
+
+func buildHTMLTrend(trend Trend) *htmlTrend {
+	t := &htmlTrend{
+		Title:       fmt.Sprintf("Cost trend by %s", trend.Period),
+		AverageName: fmt.Sprintf("vs %d-%s average", trend.Window, trend.Period),
+	}
+	for _, bucket := range trend.Buckets {
+		t.Rows = append(t.Rows, htmlTrendRow{
+			Period:          bucket.Key,
+			BillableInUSD:   bucket.BillableInUSD,
+			BillableMinutes: bucket.BillableMinutes,
+			JobCount:        bucket.JobCount,
+			VsPrevious:      formatDelta(bucket.BillableInUSD, bucket.PreviousInUSD),
+			VsAverage:       formatDelta(bucket.BillableInUSD, bucket.TrailingAverage),
+			Increase:        bucket.PreviousInUSD > 0 && bucket.BillableInUSD > bucket.PreviousInUSD,
+			Drivers:         formatDrivers(bucket.Drivers),
+		})
+	}
+	return t
+}
+
+// jobTime returns the time a job is attributed to: the run creation time, or the job creation time
+func jobTime(job JobDetails) time.Time {
+	if job.WorkflowRun != nil && job.WorkflowRun.CreatedAt != nil {
+		return job.WorkflowRun.CreatedAt.Time
+	}
+	if job.Job != nil && job.Job.CreatedAt != nil {
+		return job.Job.CreatedAt.Time
+	}
+	return time.Time{}
+}
+
+func derefOr(s *string, fallback string) string {
+	if s == nil || *s == "" {
+		return fallback
+	}
+	return *s
+}
@@ -0,0 +1,286 @@
+package reports
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// ExportSchemaVersion is the version of the JSON and NDJSON export schema.
+// It is bumped whenever a field is renamed or removed; new fields may be added
+// without a version change.
+const ExportSchemaVersion = "1"
+
+// Export formats supported by JSONGenerator
+const (
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+)
+
+// Record types emitted in NDJSON exports
+const (
+	RecordTypeJob    = "job"
+	RecordTypeGroup  = "group"
+	RecordTypeTotals = "totals"
+	RecordTypeBudget = "budget"
+)
+
+// ExportTotals is the totals object of a JSON or NDJSON export
+type ExportTotals struct {
+	ReportID                    string  `json:"report_id,omitempty"`
+	Owner                       string  `json:"owner,omitempty"`
+	Repository                  string  `json:"repository,omitempty"`
+	GeneratedAt                 string  `json:"generated_at"`
+	JobCount                    int     `json:"job_count"`
+	JobDurationSeconds          float64 `json:"job_duration"`
+	RoundedUpJobDurationSeconds float64 `json:"rounded_up_job_duration"`
+	BillableMinutes             float64 `json:"billable_minutes"`
+	BillableInUSD               float64 `json:"billable_in_usd"`
+}
+
+// JSONExport is the document written by the json format:
+//
+//	{
+//	  "schema_version": "1",
+//	  "totals": { ...ExportTotals },
+//	  "jobs": [ { ...FlatJobDetails }, ... ],
+//	  "grouped": { "dimensions": [...], "groups": [ { ...ExportGroup }, ... ] },
+//	  "budgets": [ { ...BudgetExport }, ... ]
+//	}
+//
+// grouped is only present when the report is grouped with --group-by,
+// and budgets only when a budgets file is configured.
+type JSONExport struct {
+	SchemaVersion string           `json:"schema_version"`
+	Totals        ExportTotals     `json:"totals"`
+	Jobs          []FlatJobDetails `json:"jobs"`
+	Grouped       *ExportGrouped   `json:"grouped,omitempty"`
+	Budgets       []BudgetExport   `json:"budgets,omitempty"`
+}
+
+// ExportGroup is a single group of a grouped breakdown. Durations are in seconds.
+type ExportGroup struct {
+	Keys               []string `json:"keys"`
+	BillableInUSD      float64  `json:"billable_in_usd"`
+	BillableMinutes    float64  `json:"billable_minutes"`
+	JobCount           int      `json:"job_count"`
+	DurationP50Seconds float64  `json:"duration_p50"`
+	DurationP95Seconds float64  `json:"duration_p95"`
+	SharePercent       float64  `json:"share_percent"`
+}
+
+// ExportGrouped is the grouped breakdown of a JSON export
+type ExportGrouped struct {
+	Dimensions []Dimension   `json:"dimensions"`
+	Groups     []ExportGroup `json:"groups"`
+}
+
+// NDJSONJobRecord is a single job line of the ndjson format.
+// The FlatJobDetails fields are inlined next to the schema version and record type.
+type NDJSONJobRecord struct {
+	SchemaVersion string `json:"schema_version"`
+	RecordType    string `json:"record_type"`
+	FlatJobDetails
+}
+
+// NDJSONGroupRecord is a grouped breakdown line of the ndjson format,
+// emitted after the job lines when the report is grouped with --group-by
+type NDJSONGroupRecord struct {
+	SchemaVersion string      `json:"schema_version"`
+	RecordType    string      `json:"record_type"`
+	Dimensions    []Dimension `json:"dimensions"`
+	ExportGroup
+}
+
+// NDJSONBudgetRecord is a budget status line of the ndjson format,
+// emitted before the totals line when a budgets file is configured
+type NDJSONBudgetRecord struct {
+	SchemaVersion string `json:"schema_version"`
+	RecordType    string `json:"record_type"`
+	BudgetExport
+}
+
+// NDJSONTotalsRecord is the last line of the ndjson format
+type NDJSONTotalsRecord struct {
+	SchemaVersion string `json:"schema_version"`
+	RecordType    string `json:"record_type"`
+	ExportTotals
+}
+
+// JSONGenerator generates machine-readable JSON or NDJSON exports of the processed data
+type JSONGenerator struct {
+	path      string
+	out       io.Writer // when set, the export is written here instead of path
+	format    string
+	logger    zerolog.Logger
+	ownerName string
+	repoName  string
+	reportID  string
+}
+
+// NewJSONGenerator creates a new JSON or NDJSON generator that writes to out, e.g. os.Stdout
+func NewJSONGenerator(out io.Writer, format, owner, repo, reportID string, logger zerolog.Logger) *JSONGenerator {
+	return &JSONGenerator{
+		out:       out,
+		format:    format,
+		logger:    logger,
+		ownerName: owner,
+		repoName:  repo,
+		reportID:  reportID,
+	}
+}
+
+// NewJSONGeneratorWithFormat creates a new JSON or NDJSON generator with a formatted filename
+func NewJSONGeneratorWithFormat(basePath string, format, owner, repo, reportID string, logger zerolog.Logger) *JSONGenerator {
+	timestamp := time.Now().Format("2006-01-02T15:04:05")
+	path := basePath + "/" + timestamp + "_" + owner + "_" + repo + "_" + reportID + "_report." + format
+
+	return &JSONGenerator{
+		path:      path,
+		format:    format,
+		logger:    logger,
+		ownerName: owner,
+		repoName:  repo,
+		reportID:  reportID,
+	}
+}
+
+func (g *JSONGenerator) GetPath() string {
+	return g.path
+}
+
+func (g *JSONGenerator) Generate(data *ReportData) error {
+	g.logger.Debug().Str("format", g.format).Msg("Generating JSON export")
+
+	out := g.out
+	if out == nil {
+		file, err := os.Create(g.path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	flattened := FlattenJobs(data.Jobs, data.ObfuscateData)
+	if flattened == nil {
+		flattened = []FlatJobDetails{}
+	}
+	totals := g.exportTotals(data)
+
+	var grouped *ExportGrouped
+	if len(data.GroupBy) > 0 {
+		grouped = exportGrouped(Aggregate(data.Jobs, data.GroupBy, data.ObfuscateData))
+	}
+
+	budgets := exportBudgets(data.Budgets)
+
+	var err error
+	switch g.format {
+	case FormatJSON:
+		err = g.writeJSON(out, flattened, grouped, budgets, totals)
+	case FormatNDJSON:
+		err = g.writeNDJSON(out, flattened, grouped, budgets, totals)
+	default:
+		return fmt.Errorf("unsupported export format: %s", g.format)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s export: %w", g.format, err)
+	}
+
+	if g.path != "" {
+		g.logger.Debug().Msgf("%s created successfully!", g.path)
+	}
+	return nil
+}
+
+func (g *JSONGenerator) exportTotals(data *ReportData) ExportTotals {
+	return ExportTotals{
+		ReportID:                    g.reportID,
+		Owner:                       g.ownerName,
+		Repository:                  g.repoName,
+		GeneratedAt:                 time.Now().UTC().Format(time.RFC3339),
+		JobCount:                    len(data.Jobs),
+		JobDurationSeconds:          data.Totals.JobDuration.Seconds(),
+		RoundedUpJobDurationSeconds: data.Totals.RoundedUpJobDuration.Seconds(),
+		BillableMinutes:             data.Totals.RoundedUpJobDuration.Minutes(),
+		BillableInUSD:               data.Totals.BillableInUSD,
+	}
+}
+
+func exportGrouped(agg Aggregation) *ExportGrouped {
+	grouped := &ExportGrouped{
+		Dimensions: agg.Dimensions,
+		Groups:     make([]ExportGroup, len(agg.Groups)),
+	}
+	for i, group := range agg.Groups {
+		grouped.Groups[i] = ExportGroup{
+			Keys:               group.Keys,
+			BillableInUSD:      group.BillableInUSD,
+			BillableMinutes:    group.BillableMinutes,
+			JobCount:           group.JobCount,
+			DurationP50Seconds: group.DurationP50.Seconds(),
+			DurationP95Seconds: group.DurationP95.Seconds(),
+			SharePercent:       group.Share,
+		}
+	}
+	return grouped
+}
+
+func (g *JSONGenerator) writeJSON(out io.Writer, jobs []FlatJobDetails, grouped *ExportGrouped, budgets []BudgetExport, totals ExportTotals) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(JSONExport{
+		SchemaVersion: ExportSchemaVersion,
+		Totals:        totals,
+		Jobs:          jobs,
+		Grouped:       grouped,
+		Budgets:       budgets,
+	})
+}
+
+func (g *JSONGenerator) writeNDJSON(out io.Writer, jobs []FlatJobDetails, grouped *ExportGrouped, budgets []BudgetExport, totals ExportTotals) error {
+	enc := json.NewEncoder(out)
+	for _, job := range jobs {
+		if err := enc.Encode(NDJSONJobRecord{
+			SchemaVersion:  ExportSchemaVersion,
+			RecordType:     RecordTypeJob,
+			FlatJobDetails: job,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if grouped != nil {
+		for _, group := range grouped.Groups {
+			if err := enc.Encode(NDJSONGroupRecord{
+				SchemaVersion: ExportSchemaVersion,
+				RecordType:    RecordTypeGroup,
+				Dimensions:    grouped.Dimensions,
+				ExportGroup:   group,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, budget := range budgets {
+		if err := enc.Encode(NDJSONBudgetRecord{
+			SchemaVersion: ExportSchemaVersion,
+			RecordType:    RecordTypeBudget,
+			BudgetExport:  budget,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return enc.Encode(NDJSONTotalsRecord{
+		SchemaVersion: ExportSchemaVersion,
+		RecordType:    RecordTypeTotals,
+		ExportTotals:  totals,
+	})
+}
@@ -0,0 +1,259 @@
+package reports
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// DefaultTopN is the default number of rows shown in "top N" tables
+const DefaultTopN = 10
+
+// MarkdownGenerator generates a Markdown summary suitable for GitHub Actions
+// job summaries, pull request and issue comments
+type MarkdownGenerator struct {
+	path      string
+	out       io.Writer // when set, the report is written here instead of path
+	topN      int
+	logger    zerolog.Logger
+	ownerName string
+	repoName  string
+	reportID  string
+}
+
+// NewMarkdownGenerator creates a new Markdown generator that writes to out,
+// e.g. os.Stdout or the file referenced by $GITHUB_STEP_SUMMARY
+func NewMarkdownGenerator(out io.Writer, topN int, owner, repo, reportID string, logger zerolog.Logger) *MarkdownGenerator {
+	if topN <= 0 {
+		topN = DefaultTopN
+	}
+	return &MarkdownGenerator{
+		out:       out,
+		topN:      topN,
+		logger:    logger,
+		ownerName: owner,
+		repoName:  repo,
+		reportID:  reportID,
+	}
+}
+
+// NewMarkdownGeneratorWithFormat creates a new Markdown generator with a formatted filename
+func NewMarkdownGeneratorWithFormat(basePath string, topN int, owner, repo, reportID string, logger zerolog.Logger) *MarkdownGenerator {
+	timestamp := time.Now().Format("2006-01-02T15:04:05")
+	g := NewMarkdownGenerator(nil, topN, owner, repo, reportID, logger)
+	g.path = basePath + "/" + timestamp + "_" + owner + "_" + repo + "_" + reportID + "_report.md"
+	return g
+}
+
+func (g *MarkdownGenerator) GetPath() string {
+	return g.path
+}
+
+func (g *MarkdownGenerator) Generate(data *ReportData) error {
+	g.logger.Debug().Msg("Generating Markdown report")
+
+	out := g.out
+	if out == nil {
+		file, err := os.Create(g.path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	if _, err := io.WriteString(out, g.render(data)); err != nil {
+		return fmt.Errorf("failed to write Markdown report: %w", err)
+	}
+
+	if g.path != "" {
+		g.logger.Debug().Msgf("%s created successfully!", g.path)
+	}
+	return nil
+}
+
+func (g *MarkdownGenerator) render(data *ReportData) string {
+	var b strings.Builder
+	total := data.Totals.BillableInUSD
+
+	title := "GitHub Actions cost report"
+	if g.ownerName != "" && g.repoName != "" {
+		title = fmt.Sprintf("GitHub Actions cost report: %s/%s", g.ownerName, g.repoName)
+	}
+	fmt.Fprintf(&b, "## %s\n\n", title)
+
+	// Totals
+	b.WriteString("| Total cost | Billable minutes | Jobs | Duration |\n")
+	b.WriteString("| ---: | ---: | ---: | ---: |\n")
+	fmt.Fprintf(&b, "| %s | %.0f | %d | %s |\n\n",
+		formatUSD(total),
+		data.Totals.RoundedUpJobDuration.Minutes(),
+		len(data.Jobs),
+		data.Totals.JobDuration.Round(time.Second))
+
+	// Week over week
+	current, previous, end := weekOverWeek(data.Jobs)
+	if !end.IsZero() {
+		fmt.Fprintf(&b, "**Last 7 days** (to %s): %s", end.Format(time.DateOnly), formatUSD(current))
+		if previous > 0 {
+			fmt.Fprintf(&b, " vs %s the week before (%s)\n\n", formatUSD(previous), formatDelta(current, previous))
+		} else {
+			b.WriteString(" (no data for the week before)\n\n")
+		}
+	}
+
+	if f := data.Forecast; f != nil {
+		fmt.Fprintf(&b, "**Forecast** for the billing cycle: %s so far, %s\n\n", formatUSD(f.ActualInUSD), f.Summary())
+	}
+
+	writeMarkdownBudgets(&b, "Budget alerts", alertingBudgets(data.Budgets))
+
+	if data.Trend != nil {
+		g.writeTrend(&b, *data.Trend)
+	}
+
+	if f := data.Forecast; f != nil {
+		// A single repository's forecast is the same as the total
+		if len(f.Repos) > 1 {
+			g.writeForecast(&b, "Forecast by repository", "Repository", f.Repos)
+		}
+		g.writeForecast(&b, "Forecast by runner type", "Runner", f.Runners)
+	}
+
+	if len(data.GroupBy) > 0 {
+		grouped := Aggregate(data.Jobs, data.GroupBy, data.ObfuscateData)
+		g.writeBreakdown(&b, fmt.Sprintf("Top %d by %s", g.topN, grouped.Title()), grouped.Title(), grouped.Groups)
+	}
+
+	workflows := Aggregate(data.Jobs, []Dimension{DimensionWorkflow}, data.ObfuscateData)
+	g.writeBreakdown(&b, fmt.Sprintf("Top %d workflows", g.topN), "Workflow", workflows.Groups)
+
+	jobs := Aggregate(data.Jobs, []Dimension{DimensionWorkflow, DimensionJob}, data.ObfuscateData)
+	g.writeBreakdown(&b, fmt.Sprintf("Top %d jobs", g.topN), "Job", jobs.Groups)
+
+	runners := Aggregate(data.Jobs, []Dimension{DimensionRunner}, data.ObfuscateData)
+	g.writeBreakdown(&b, "Runner types", "Runner", runners.Groups)
+
+	footer := fmt.Sprintf("Generated by gh-octoscope on %s", time.Now().UTC().Format("2006-01-02 15:04 MST"))
+	if g.reportID != "" {
+		footer += fmt.Sprintf(" · report `%s`", g.reportID)
+	}
+	fmt.Fprintf(&b, "<sub>%s</sub>\n", footer)
+
+	return b.String()
+}
+
+func (g *MarkdownGenerator) writeBreakdown(b *strings.Builder, title, column string, rows []Group) {
+	if len(rows) == 0 {
+		return
+	}
+	if len(rows) > g.topN {
+		rows = rows[:g.topN]
+	}
+
+	fmt.Fprintf(b, "### %s\n\n", title)
+	fmt.Fprintf(b, "| %s | Cost | Minutes | Jobs | p50 | p95 | Share |\n", column)
+	b.WriteString("| --- | ---: | ---: | ---: | ---: | ---: | ---: |\n")
+	for _, row := range rows {
+		fmt.Fprintf(b, "| %s | %s | %.0f | %d | %s | %s | %.1f%% |\n",
+			escapeMarkdownCell(row.Key),
+			formatUSD(row.BillableInUSD),
+			row.BillableMinutes,
+			row.JobCount,
+			row.DurationP50.Round(time.Second),
+			row.DurationP95.Round(time.Second),
+			row.Share)
+	}
+	b.WriteString("\n")
+}
+
+func (g *MarkdownGenerator) writeForecast(b *strings.Builder, title, column string, groups []ForecastGroup) {
+	if len(groups) == 0 {
+		return
+	}
+	if len(groups) > g.topN {
+		groups = groups[:g.topN]
+	}
+
+	fmt.Fprintf(b, "### %s\n\n", title)
+	fmt.Fprintf(b, "| %s | So far | Forecast | Range |\n", column)
+	b.WriteString("| --- | ---: | ---: | ---: |\n")
+	for _, group := range groups {
+		fmt.Fprintf(b, "| %s | %s | %s | %s |\n",
+			escapeMarkdownCell(group.Key),
+			formatUSD(group.ActualInUSD),
+			formatUSD(group.ForecastInUSD),
+			formatForecastRange(group.Projection))
+	}
+	b.WriteString("\n")
+}
+
+func (g *MarkdownGenerator) writeTrend(b *strings.Builder, trend Trend) {
+	if len(trend.Buckets) == 0 {
+		return
+	}
+
+	fmt.Fprintf(b, "### Cost trend by %s\n\n", trend.Period)
+	fmt.Fprintf(b, "| Period | Cost | Minutes | Jobs | vs previous | vs %d-%s average | Drivers |\n", trend.Window, trend.Period)
+	b.WriteString("| --- | ---: | ---: | ---: | ---: | ---: | --- |\n")
+	for _, bucket := range trend.Buckets {
+		fmt.Fprintf(b, "| %s | %s | %.0f | %d | %s | %s | %s |\n",
+			bucket.Key,
+			formatUSD(bucket.BillableInUSD),
+			bucket.BillableMinutes,
+			bucket.JobCount,
+			formatDelta(bucket.BillableInUSD, bucket.PreviousInUSD),
+			formatDelta(bucket.BillableInUSD, bucket.TrailingAverage),
+			escapeMarkdownCell(formatDrivers(bucket.Drivers)))
+	}
+	b.WriteString("\n")
+}
+
+// weekOverWeek returns the cost of the last 7 days of data and of the 7 days before,
+// anchored at the most recent job in the data set
+func weekOverWeek(jobs []JobDetails) (current, previous float64, end time.Time) {
+	for _, job := range jobs {
+		if t := jobTime(job); t.After(end) {
+			end = t
+		}
+	}
+	if end.IsZero() {
+		return 0, 0, end
+	}
+
+	weekStart := end.AddDate(0, 0, -7)
+	previousStart := end.AddDate(0, 0, -14)
+	for _, job := range jobs {
+		t := jobTime(job)
+		switch {
+		case t.After(weekStart):
+			current += job.BillableInUSD
+		case t.After(previousStart):
+			previous += job.BillableInUSD
+		}
+	}
+	return current, previous, end
+}
+
+// formatUSD formats a cost in US dollars
+func formatUSD(f float64) string {
+	return fmt.Sprintf("$%.2f", f)
+}
+
+// formatDelta formats the relative change from previous to current, e.g. "+12.5%"
+func formatDelta(current, previous float64) string {
+	if previous == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%+.1f%%", (current-previous)/previous*100)
+}
+
+// escapeMarkdownCell escapes characters that would break a Markdown table cell
+func escapeMarkdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.ReplaceAll(s, "\n", " ")
+}
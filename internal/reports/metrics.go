@@ -0,0 +1,353 @@
+package reports
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MetricsContentType is the content type of the Prometheus text exposition format
+const MetricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Histogram buckets of job durations and queue times, in seconds
+var (
+	durationBuckets = []float64{30, 60, 120, 300, 600, 1200, 1800, 3600, 7200, 21600}
+	queueBuckets    = []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600}
+)
+
+// metricsLabels are the labels of the job metrics
+type metricsLabels struct {
+	Repo       string
+	Workflow   string
+	Runner     string
+	Conclusion string // status of active jobs, e.g. queued or in_progress
+}
+
+// metricsJob is what the metrics need of a completed job
+type metricsJob struct {
+	labels   metricsLabels
+	minutes  float64
+	cost     float64
+	duration time.Duration
+	queue    time.Duration
+}
+
+// MetricsStore accumulates jobs across incremental fetches for the metrics. Completed
+// jobs are kept by job ID, so jobs fetched again are counted once and counters never
+// decrease. Queued and in progress jobs only count for the latest fetch.
+type MetricsStore struct {
+	shouldObfuscate bool
+	completed       map[int64]metricsJob
+	active          map[metricsLabels]int
+}
+
+// NewMetricsStore creates an empty metrics store. When shouldObfuscate is set, label
+// values are obfuscated the same way as in the flattened job reports.
+func NewMetricsStore(shouldObfuscate bool) *MetricsStore {
+	return &MetricsStore{
+		shouldObfuscate: shouldObfuscate,
+		completed:       make(map[int64]metricsJob),
+		active:          make(map[metricsLabels]int),
+	}
+}
+
+// Add merges the jobs of a fetch into the store. Jobs without an ID are left out.
+func (s *MetricsStore) Add(jobs []JobDetails) {
+	flattened := FlattenJobs(jobs, s.shouldObfuscate)
+	s.active = make(map[metricsLabels]int)
+
+	for i, job := range jobs {
+		if job.Job == nil || job.Job.GetID() == 0 {
+			continue
+		}
+		labels := metricsLabels{
+			Repo:     dimensionValue(DimensionRepo, job, flattened[i]),
+			Workflow: dimensionValue(DimensionWorkflow, job, flattened[i]),
+			Runner:   dimensionValue(DimensionRunner, job, flattened[i]),
+		}
+		if job.Job.GetStatus() != "completed" {
+			labels.Conclusion = job.Job.GetStatus()
+			s.active[labels]++
+			continue
+		}
+		labels.Conclusion = dimensionValue(DimensionConclusion, job, flattened[i])
+		s.completed[job.Job.GetID()] = metricsJob{
+			labels:   labels,
+			minutes:  job.RoundedUpJobDuration.Minutes(),
+			cost:     job.BillableInUSD,
+			duration: job.JobDuration,
+			queue:    job.QueueDuration,
+		}
+	}
+}
+
+// Jobs returns the number of completed jobs in the store
+func (s *MetricsStore) Jobs() int {
+	return len(s.completed)
+}
+
+// metricsState is a metrics store and the counters of its fetches, saved between runs
+// so counters keep growing when the metrics are written by separate processes
+type metricsState struct {
+	Obfuscate   bool              `json:"obfuscate"`
+	Successes   int               `json:"successes"`
+	Failures    int               `json:"failures"`
+	APIRequests int64             `json:"api_requests"`
+	LastSuccess time.Time         `json:"last_success"`
+	Jobs        []metricsStateJob `json:"jobs"`
+}
+
+// metricsStateJob is a completed job of a saved metrics store
+type metricsStateJob struct {
+	ID         int64         `json:"id"`
+	Repo       string        `json:"repo"`
+	Workflow   string        `json:"workflow"`
+	Runner     string        `json:"runner"`
+	Conclusion string        `json:"conclusion"`
+	Minutes    float64       `json:"minutes"`
+	CostInUSD  float64       `json:"cost_usd"`
+	Duration   time.Duration `json:"duration_ns"`
+	Queue      time.Duration `json:"queue_ns"`
+}
+
+// LoadMetricsState loads a metrics store and the health of its fetches saved by
+// SaveMetricsState. A missing file is an empty store that wasn't fetched yet.
+func LoadMetricsState(path string, shouldObfuscate bool) (*MetricsStore, FetchHealth, error) {
+	store := NewMetricsStore(shouldObfuscate)
+	health := FetchHealth{RateLimitRemaining: -1}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, health, nil
+	}
+	if err != nil {
+		return nil, health, err
+	}
+	var state metricsState
+	if err := json.Unmarshal(content, &state); err != nil {
+		return nil, health, fmt.Errorf("failed to read metrics state %s: %w", path, err)
+	}
+	// Obfuscated and plain labels are different series, mixing them would double count jobs
+	if state.Obfuscate != shouldObfuscate {
+		return nil, health, fmt.Errorf("metrics state %s was saved with a different --obfuscate, remove it to change --obfuscate", path)
+	}
+
+	for _, job := range state.Jobs {
+		store.completed[job.ID] = metricsJob{
+			labels:   metricsLabels{Repo: job.Repo, Workflow: job.Workflow, Runner: job.Runner, Conclusion: job.Conclusion},
+			minutes:  job.Minutes,
+			cost:     job.CostInUSD,
+			duration: job.Duration,
+			queue:    job.Queue,
+		}
+	}
+	health.Successes = state.Successes
+	health.Failures = state.Failures
+	health.APIRequests = state.APIRequests
+	health.LastSuccess = state.LastSuccess
+	return store, health, nil
+}
+
+// SaveMetricsState writes the completed jobs of a store and the counters of its fetches
+// to path, replacing it atomically. Active jobs and gauges only describe the latest fetch
+// and aren't saved.
+func SaveMetricsState(path string, store *MetricsStore, health FetchHealth) error {
+	state := metricsState{
+		Obfuscate:   store.shouldObfuscate,
+		Successes:   health.Successes,
+		Failures:    health.Failures,
+		APIRequests: health.APIRequests,
+		LastSuccess: health.LastSuccess,
+		Jobs:        make([]metricsStateJob, 0, len(store.completed)),
+	}
+	for id, job := range store.completed {
+		state.Jobs = append(state.Jobs, metricsStateJob{
+			ID:         id,
+			Repo:       job.labels.Repo,
+			Workflow:   job.labels.Workflow,
+			Runner:     job.labels.Runner,
+			Conclusion: job.labels.Conclusion,
+			Minutes:    job.minutes,
+			CostInUSD:  job.cost,
+			Duration:   job.duration,
+			Queue:      job.queue,
+		})
+	}
+	sort.Slice(state.Jobs, func(i, j int) bool { return state.Jobs[i].ID < state.Jobs[j].ID })
+
+	content, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// FetchHealth is the state of the fetches behind the metrics
+type FetchHealth struct {
+	Successes          int
+	Failures           int
+	APIRequests        int64
+	RateLimitRemaining int // -1 when unknown
+	LastSuccess        time.Time
+	LastDuration       time.Duration
+}
+
+// metricsSeries is the aggregate of the completed jobs sharing the same labels
+type metricsSeries struct {
+	minutes       float64
+	cost          float64
+	jobs          int
+	durationSum   float64
+	durationCount []int // per bucket, cumulative
+	queueSum      float64
+	queueCount    []int // per bucket, cumulative
+}
+
+// WriteMetrics writes the store and fetch health in the Prometheus text exposition format
+func WriteMetrics(w io.Writer, store *MetricsStore, health FetchHealth) error {
+	series := make(map[metricsLabels]*metricsSeries)
+	for _, job := range store.completed {
+		s, ok := series[job.labels]
+		if !ok {
+			s = &metricsSeries{
+				durationCount: make([]int, len(durationBuckets)),
+				queueCount:    make([]int, len(queueBuckets)),
+			}
+			series[job.labels] = s
+		}
+		s.minutes += job.minutes
+		s.cost += job.cost
+		s.jobs++
+		s.durationSum += job.duration.Seconds()
+		observe(s.durationCount, durationBuckets, job.duration.Seconds())
+		s.queueSum += job.queue.Seconds()
+		observe(s.queueCount, queueBuckets, job.queue.Seconds())
+	}
+	keys := make([]metricsLabels, 0, len(series))
+	for labels := range series {
+		keys = append(keys, labels)
+	}
+	sortMetricsLabels(keys)
+
+	var b strings.Builder
+	writeFamily := func(name, kind, help string, value func(*metricsSeries) float64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		for _, labels := range keys {
+			fmt.Fprintf(&b, "%s{%s} %s\n", name, labels.format("conclusion"), formatMetric(value(series[labels])))
+		}
+	}
+	writeHistogram := func(name, help string, buckets []float64, sum func(*metricsSeries) float64, counts func(*metricsSeries) []int) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+		for _, labels := range keys {
+			s := series[labels]
+			l := labels.format("conclusion")
+			for i, le := range buckets {
+				fmt.Fprintf(&b, "%s_bucket{%s,le=\"%s\"} %d\n", name, l, formatMetric(le), counts(s)[i])
+			}
+			fmt.Fprintf(&b, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, l, s.jobs)
+			fmt.Fprintf(&b, "%s_sum{%s} %s\n", name, l, formatMetric(sum(s)))
+			fmt.Fprintf(&b, "%s_count{%s} %d\n", name, l, s.jobs)
+		}
+	}
+
+	writeFamily("octoscope_billable_minutes_total", "counter", "Billable minutes of completed GitHub Actions jobs.",
+		func(s *metricsSeries) float64 { return s.minutes })
+	writeFamily("octoscope_cost_usd_total", "counter", "Cost in US dollars of completed GitHub Actions jobs.",
+		func(s *metricsSeries) float64 { return s.cost })
+	writeFamily("octoscope_jobs_total", "counter", "Completed GitHub Actions jobs.",
+		func(s *metricsSeries) float64 { return float64(s.jobs) })
+	writeHistogram("octoscope_job_duration_seconds", "Duration of completed GitHub Actions jobs.", durationBuckets,
+		func(s *metricsSeries) float64 { return s.durationSum }, func(s *metricsSeries) []int { return s.durationCount })
+	writeHistogram("octoscope_job_queue_seconds", "Time completed GitHub Actions jobs waited for a runner.", queueBuckets,
+		func(s *metricsSeries) float64 { return s.queueSum }, func(s *metricsSeries) []int { return s.queueCount })
+
+	active := make([]metricsLabels, 0, len(store.active))
+	for labels := range store.active {
+		active = append(active, labels)
+	}
+	sortMetricsLabels(active)
+	b.WriteString("# HELP octoscope_jobs_active Queued and in progress GitHub Actions jobs in the latest fetch.\n# TYPE octoscope_jobs_active gauge\n")
+	for _, labels := range active {
+		fmt.Fprintf(&b, "octoscope_jobs_active{%s} %d\n", labels.format("status"), store.active[labels])
+	}
+
+	b.WriteString("# HELP octoscope_tracked_jobs Completed jobs the metrics are computed from.\n# TYPE octoscope_tracked_jobs gauge\n")
+	fmt.Fprintf(&b, "octoscope_tracked_jobs %d\n", store.Jobs())
+	b.WriteString("# HELP octoscope_fetches_total Fetches from the GitHub API by result.\n# TYPE octoscope_fetches_total counter\n")
+	fmt.Fprintf(&b, "octoscope_fetches_total{result=\"success\"} %d\n", health.Successes)
+	fmt.Fprintf(&b, "octoscope_fetches_total{result=\"failure\"} %d\n", health.Failures)
+	b.WriteString("# HELP octoscope_github_api_requests_total Requests made to the GitHub API.\n# TYPE octoscope_github_api_requests_total counter\n")
+	fmt.Fprintf(&b, "octoscope_github_api_requests_total %d\n", health.APIRequests)
+	if health.RateLimitRemaining >= 0 {
+		b.WriteString("# HELP octoscope_github_rate_limit_remaining Requests left in the current GitHub API rate limit window.\n# TYPE octoscope_github_rate_limit_remaining gauge\n")
+		fmt.Fprintf(&b, "octoscope_github_rate_limit_remaining %d\n", health.RateLimitRemaining)
+	}
+	if !health.LastSuccess.IsZero() {
+		b.WriteString("# HELP octoscope_last_success_timestamp_seconds Unix time of the last successful fetch.\n# TYPE octoscope_last_success_timestamp_seconds gauge\n")
+		fmt.Fprintf(&b, "octoscope_last_success_timestamp_seconds %d\n", health.LastSuccess.Unix())
+	}
+	b.WriteString("# HELP octoscope_last_fetch_duration_seconds Duration of the last fetch.\n# TYPE octoscope_last_fetch_duration_seconds gauge\n")
+	fmt.Fprintf(&b, "octoscope_last_fetch_duration_seconds %s\n", formatMetric(health.LastDuration.Seconds()))
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// observe counts a value in every cumulative bucket it falls in
+func observe(counts []int, buckets []float64, value float64) {
+	for i, le := range buckets {
+		if value <= le {
+			counts[i]++
+		}
+	}
+}
+
+// format formats the labels, naming the last label conclusion or status
+func (l metricsLabels) format(last string) string {
+	return fmt.Sprintf(`repo="%s",workflow="%s",runner="%s",%s="%s"`,
+		escapeLabelValue(l.Repo), escapeLabelValue(l.Workflow), escapeLabelValue(l.Runner), last, escapeLabelValue(l.Conclusion))
+}
+
+func sortMetricsLabels(labels []metricsLabels) {
+	sort.Slice(labels, func(i, j int) bool {
+		a, b := labels[i], labels[j]
+		if a.Repo != b.Repo {
+			return a.Repo < b.Repo
+		}
+		if a.Workflow != b.Workflow {
+			return a.Workflow < b.Workflow
+		}
+		if a.Runner != b.Runner {
+			return a.Runner < b.Runner
+		}
+		return a.Conclusion < b.Conclusion
+	})
+}
+
+// escapeLabelValue escapes backslashes, double quotes and line feeds in a label value
+func escapeLabelValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func formatMetric(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,142 @@
+package reports
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// metricsTestJob returns a job with an ID and a status, queued for a minute
+func metricsTestJob(id int64, workflow, status, conclusion string, duration time.Duration, cost float64) JobDetails {
+	job := aggregateTestJob(workflow, "UBUNTU", conclusion, time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC), duration, cost)
+	job.Job.ID = github.Int64(id)
+	job.Job.Status = github.String(status)
+	job.QueueDuration = time.Minute
+	return job
+}
+
+func TestMetricsStore(t *testing.T) {
+	store := NewMetricsStore(false)
+	store.Add([]JobDetails{
+		metricsTestJob(1, "CI", "completed", "success", 90*time.Second, 0.016),
+		metricsTestJob(2, "CI", "completed", "success", 10*time.Minute, 0.08),
+		metricsTestJob(3, "CI", "in_progress", "", 0, 0),
+	})
+	// Fetching a job again doesn't count it twice, and active jobs only count for the latest fetch
+	store.Add([]JobDetails{
+		metricsTestJob(2, "CI", "completed", "success", 10*time.Minute, 0.08),
+		metricsTestJob(3, "CI", "completed", "failure", 2*time.Minute, 0.016),
+		metricsTestJob(4, `Deploy "prod"`, "queued", "", 0, 0),
+		metricsTestJob(0, "CI", "completed", "success", time.Minute, 1),
+	})
+	assert.Equal(t, 3, store.Jobs())
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteMetrics(&buf, store, FetchHealth{
+		Successes:          2,
+		Failures:           1,
+		APIRequests:        42,
+		RateLimitRemaining: 4958,
+		LastSuccess:        time.Unix(1743508800, 0),
+		LastDuration:       1500 * time.Millisecond,
+	}))
+	out := buf.String()
+
+	success := `repo="testowner/testrepo",workflow="CI",runner="UBUNTU",conclusion="success"`
+	for _, line := range []string{
+		"# TYPE octoscope_cost_usd_total counter",
+		`octoscope_cost_usd_total{` + success + `} 0.096`,
+		`octoscope_billable_minutes_total{` + success + `} 11.5`,
+		`octoscope_jobs_total{` + success + `} 2`,
+		`octoscope_jobs_total{repo="testowner/testrepo",workflow="CI",runner="UBUNTU",conclusion="failure"} 1`,
+		"# TYPE octoscope_job_duration_seconds histogram",
+		`octoscope_job_duration_seconds_bucket{` + success + `,le="60"} 0`,
+		`octoscope_job_duration_seconds_bucket{` + success + `,le="120"} 1`,
+		`octoscope_job_duration_seconds_bucket{` + success + `,le="600"} 2`,
+		`octoscope_job_duration_seconds_bucket{` + success + `,le="+Inf"} 2`,
+		`octoscope_job_duration_seconds_sum{` + success + `} 690`,
+		`octoscope_job_duration_seconds_count{` + success + `} 2`,
+		`octoscope_job_queue_seconds_bucket{` + success + `,le="60"} 2`,
+		`octoscope_jobs_active{repo="testowner/testrepo",workflow="Deploy \"prod\"",runner="UBUNTU",status="queued"} 1`,
+		"octoscope_tracked_jobs 3",
+		`octoscope_fetches_total{result="success"} 2`,
+		`octoscope_fetches_total{result="failure"} 1`,
+		"octoscope_github_api_requests_total 42",
+		"octoscope_github_rate_limit_remaining 4958",
+		"octoscope_last_success_timestamp_seconds 1743508800",
+		"octoscope_last_fetch_duration_seconds 1.5",
+	} {
+		assert.Contains(t, out, line+"\n")
+	}
+	assert.NotContains(t, out, `status="in_progress"`)
+}
+
+func TestWriteMetricsEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteMetrics(&buf, NewMetricsStore(false), FetchHealth{RateLimitRemaining: -1}))
+	out := buf.String()
+
+	assert.Contains(t, out, "# TYPE octoscope_jobs_total counter\n")
+	assert.Contains(t, out, "octoscope_tracked_jobs 0\n")
+	// Unknown values are left out rather than reported as zero
+	assert.NotContains(t, out, "octoscope_github_rate_limit_remaining")
+	assert.NotContains(t, out, "octoscope_last_success_timestamp_seconds")
+}
+
+func TestMetricsStoreObfuscate(t *testing.T) {
+	store := NewMetricsStore(true)
+	store.Add([]JobDetails{metricsTestJob(1, "CI", "completed", "success", time.Minute, 0.008)})
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteMetrics(&buf, store, FetchHealth{RateLimitRemaining: -1}))
+	assert.NotContains(t, buf.String(), "testowner")
+}
+
+func TestMetricsState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "octoscope.prom.state.json")
+
+	// A missing state is an empty store that wasn't fetched yet
+	store, health, err := LoadMetricsState(path, false)
+	require.NoError(t, err)
+	assert.Equal(t, 0, store.Jobs())
+	assert.True(t, health.LastSuccess.IsZero())
+	assert.Equal(t, -1, health.RateLimitRemaining)
+
+	store.Add([]JobDetails{
+		metricsTestJob(1, "CI", "completed", "success", 90*time.Second, 0.016),
+		metricsTestJob(2, "CI", "completed", "failure", 10*time.Minute, 0.08),
+		metricsTestJob(3, "CI", "in_progress", "", 0, 0),
+	})
+	health = FetchHealth{Successes: 3, Failures: 1, APIRequests: 42, RateLimitRemaining: 4958, LastSuccess: time.Unix(1743508800, 0).UTC()}
+	require.NoError(t, SaveMetricsState(path, store, health))
+
+	loaded, loadedHealth, err := LoadMetricsState(path, false)
+	require.NoError(t, err)
+	assert.Equal(t, health.Successes, loadedHealth.Successes)
+	assert.Equal(t, health.Failures, loadedHealth.Failures)
+	assert.Equal(t, health.APIRequests, loadedHealth.APIRequests)
+	assert.True(t, health.LastSuccess.Equal(loadedHealth.LastSuccess))
+	assert.Equal(t, -1, loadedHealth.RateLimitRemaining)
+
+	// Jobs of the next run are added to the saved ones, so counters keep growing
+	loaded.Add([]JobDetails{
+		metricsTestJob(2, "CI", "completed", "failure", 10*time.Minute, 0.08),
+		metricsTestJob(3, "CI", "completed", "success", 2*time.Minute, 0.016),
+	})
+	assert.Equal(t, 3, loaded.Jobs())
+	var buf bytes.Buffer
+	require.NoError(t, WriteMetrics(&buf, loaded, loadedHealth))
+	success := `repo="testowner/testrepo",workflow="CI",runner="UBUNTU",conclusion="success"`
+	assert.Contains(t, buf.String(), `octoscope_jobs_total{`+success+`} 2`+"\n")
+	assert.Contains(t, buf.String(), `octoscope_job_duration_seconds_sum{`+success+`} 210`+"\n")
+	assert.Contains(t, buf.String(), `octoscope_fetches_total{result="success"} 3`+"\n")
+	assert.NotContains(t, buf.String(), "octoscope_jobs_active{")
+
+	_, _, err = LoadMetricsState(path, true)
+	assert.ErrorContains(t, err, "different --obfuscate")
+}
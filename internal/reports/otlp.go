@@ -0,0 +1,395 @@
+package reports
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// DefaultTraceServiceName is the service.name of exported traces
+const DefaultTraceServiceName = "github-actions"
+
+// traceScopeName is the instrumentation scope of exported spans
+const traceScopeName = "github.com/noamtamir/gh-octoscope"
+
+// OTLP span kinds and status codes
+const (
+	otlpSpanKindInternal = 1
+	otlpStatusOK         = 1
+	otlpStatusError      = 2
+)
+
+// OTLPTraces is an OTLP TracesData message in the OTLP/JSON encoding, as sent to an
+// OTLP/HTTP endpoint or written by the collector's file exporter
+type OTLPTraces struct {
+	ResourceSpans []OTLPResourceSpans `json:"resourceSpans"`
+}
+
+// OTLPResourceSpans are the spans of a resource, one per repository
+type OTLPResourceSpans struct {
+	Resource   OTLPResource     `json:"resource"`
+	ScopeSpans []OTLPScopeSpans `json:"scopeSpans"`
+}
+
+type OTLPResource struct {
+	Attributes []OTLPKeyValue `json:"attributes"`
+}
+
+type OTLPScopeSpans struct {
+	Scope OTLPScope  `json:"scope"`
+	Spans []OTLPSpan `json:"spans"`
+}
+
+type OTLPScope struct {
+	Name string `json:"name"`
+}
+
+// OTLPSpan is a span. IDs are lower case hex and times are nanoseconds since the
+// Unix epoch as strings, as the OTLP/JSON encoding requires.
+type OTLPSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []OTLPKeyValue  `json:"attributes,omitempty"`
+	Events            []OTLPSpanEvent `json:"events,omitempty"`
+	Status            OTLPStatus      `json:"status"`
+}
+
+type OTLPSpanEvent struct {
+	TimeUnixNano string `json:"timeUnixNano"`
+	Name         string `json:"name"`
+}
+
+type OTLPStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type OTLPKeyValue struct {
+	Key   string       `json:"key"`
+	Value OTLPAnyValue `json:"value"`
+}
+
+// OTLPAnyValue holds one of the values, 64 bit integers are strings in OTLP/JSON
+type OTLPAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+// SpanCount returns the number of spans in the traces
+func (t OTLPTraces) SpanCount() int {
+	count := 0
+	for _, rs := range t.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			count += len(ss.Spans)
+		}
+	}
+	return count
+}
+
+// WriteOTLPJSON writes the traces as a single line of OTLP/JSON, so a file of them can
+// be read by the collector's otlpjsonfile receiver
+func WriteOTLPJSON(w io.Writer, traces OTLPTraces) error {
+	return json.NewEncoder(w).Encode(traces)
+}
+
+// BuildTraces converts jobs into one trace per workflow run, with a span per run, per
+// attempt, per job and per step carrying their cost. Job spans start when the job was
+// created, so they include the time queued for a runner. Jobs that haven't completed
+// and steps without timings are left out.
+//
+// Trace and span IDs are derived from the run, job and step IDs, so exporting the same
+// runs again produces the same traces.
+func BuildTraces(jobs []JobDetails, serviceName string, shouldObfuscate bool) OTLPTraces {
+	if serviceName == "" {
+		serviceName = DefaultTraceServiceName
+	}
+	flattened := FlattenJobs(jobs, shouldObfuscate)
+
+	type traceRun struct {
+		job      JobDetails // first job of the run, for the run's attributes
+		flat     FlatJobDetails
+		attempts map[int64][]int // job indexes by attempt
+	}
+	runs := make(map[int64]*traceRun)
+	repos := make(map[string][]int64)
+	var repoNames []string
+	for i, job := range jobs {
+		if job.WorkflowRun == nil || job.Job == nil || job.Job.CompletedAt == nil || jobStart(job).IsZero() {
+			continue
+		}
+		runID := job.WorkflowRun.GetID()
+		run, ok := runs[runID]
+		if !ok {
+			run = &traceRun{job: job, flat: flattened[i], attempts: make(map[int64][]int)}
+			runs[runID] = run
+			repo := dimensionValue(DimensionRepo, job, flattened[i])
+			if _, ok := repos[repo]; !ok {
+				repoNames = append(repoNames, repo)
+			}
+			repos[repo] = append(repos[repo], runID)
+		}
+		attempt := job.Job.GetRunAttempt()
+		if attempt == 0 {
+			attempt = 1
+		}
+		run.attempts[attempt] = append(run.attempts[attempt], i)
+	}
+	sort.Strings(repoNames)
+
+	traces := OTLPTraces{ResourceSpans: []OTLPResourceSpans{}}
+	for _, repo := range repoNames {
+		var spans []OTLPSpan
+		for _, runID := range repos[repo] {
+			run := runs[runID]
+			traceID := otlpID(16, "run/%d", runID)
+			runSpanID := otlpID(8, "run/%d", runID)
+
+			var runSpans []OTLPSpan
+			var runStart, runEnd time.Time
+			var runCost, runMinutes float64
+			conclusion := run.job.WorkflowRun.GetConclusion()
+
+			attempts := make([]int64, 0, len(run.attempts))
+			for attempt := range run.attempts {
+				attempts = append(attempts, attempt)
+			}
+			sort.Slice(attempts, func(i, j int) bool { return attempts[i] < attempts[j] })
+
+			for _, attempt := range attempts {
+				attemptSpanID := otlpID(8, "run/%d/attempt/%d", runID, attempt)
+				var attemptStart, attemptEnd time.Time
+				var attemptCost, attemptMinutes float64
+				attemptConclusion := "success"
+
+				for _, idx := range run.attempts[attempt] {
+					job := jobs[idx]
+					start, end := jobStart(job), job.Job.CompletedAt.Time
+					attemptStart, attemptEnd = widen(attemptStart, attemptEnd, start, end)
+					attemptCost += job.BillableInUSD
+					attemptMinutes += job.RoundedUpJobDuration.Minutes()
+					if isFailure(job.Job.GetConclusion()) {
+						attemptConclusion = "failure"
+					}
+					runSpans = append(runSpans, jobSpans(job, flattened[idx], traceID, attemptSpanID, shouldObfuscate)...)
+				}
+
+				runSpans = append(runSpans, OTLPSpan{
+					TraceID:           traceID,
+					SpanID:            attemptSpanID,
+					ParentSpanID:      runSpanID,
+					Name:              fmt.Sprintf("attempt %d", attempt),
+					Kind:              otlpSpanKindInternal,
+					StartTimeUnixNano: otlpTime(attemptStart),
+					EndTimeUnixNano:   otlpTime(attemptEnd),
+					Attributes: []OTLPKeyValue{
+						otlpInt("github.run_attempt", attempt),
+						otlpDouble("octoscope.cost_usd", attemptCost),
+						otlpDouble("octoscope.billable_minutes", attemptMinutes),
+					},
+					Status: otlpStatus(attemptConclusion),
+				})
+				runStart, runEnd = widen(runStart, runEnd, attemptStart, attemptEnd)
+				runCost += attemptCost
+				runMinutes += attemptMinutes
+			}
+
+			attributes := []OTLPKeyValue{
+				otlpString("github.workflow", derefOr(run.flat.WorkflowName, "")),
+				otlpInt("github.run_id", runID),
+				otlpInt("github.run_number", int64(run.job.WorkflowRun.GetRunNumber())),
+				otlpString("github.event", run.job.WorkflowRun.GetEvent()),
+				otlpString("github.head_branch", run.job.WorkflowRun.GetHeadBranch()),
+				otlpString("github.head_sha", run.job.WorkflowRun.GetHeadSHA()),
+				otlpString("github.actor", derefOr(run.flat.ActorLogin, "")),
+				otlpString("github.conclusion", conclusion),
+				otlpInt("github.attempts", int64(len(attempts))),
+				otlpDouble("octoscope.cost_usd", runCost),
+				otlpDouble("octoscope.billable_minutes", runMinutes),
+			}
+			// URLs name the repository, which is obfuscated
+			if !shouldObfuscate {
+				attributes = append(attributes, otlpString("url.full", runURL(run.job)))
+			}
+			if run.job.PullRequest != nil {
+				attributes = append(attributes, otlpInt("github.pull_request", int64(run.job.PullRequest.GetNumber())))
+			}
+			spans = append(spans, OTLPSpan{
+				TraceID:           traceID,
+				SpanID:            runSpanID,
+				Name:              derefOr(run.flat.WorkflowName, "workflow run"),
+				Kind:              otlpSpanKindInternal,
+				StartTimeUnixNano: otlpTime(runStart),
+				EndTimeUnixNano:   otlpTime(runEnd),
+				Attributes:        withoutEmpty(attributes),
+				Status:            otlpStatus(conclusion),
+			})
+			spans = append(spans, runSpans...)
+		}
+
+		traces.ResourceSpans = append(traces.ResourceSpans, OTLPResourceSpans{
+			Resource: OTLPResource{Attributes: []OTLPKeyValue{
+				otlpString("service.name", serviceName),
+				otlpString("github.repository", repo),
+			}},
+			ScopeSpans: []OTLPScopeSpans{{Scope: OTLPScope{Name: traceScopeName}, Spans: spans}},
+		})
+	}
+	return traces
+}
+
+// jobSpans returns the span of a job followed by the spans of its steps, without its URL
+// when shouldObfuscate is set
+func jobSpans(job JobDetails, fj FlatJobDetails, traceID, parentSpanID string, shouldObfuscate bool) []OTLPSpan {
+	jobID := job.Job.GetID()
+	jobSpanID := otlpID(8, "job/%d", jobID)
+
+	attributes := []OTLPKeyValue{
+		otlpString("github.job", job.Job.GetName()),
+		otlpInt("github.job_id", jobID),
+		otlpString("github.runner", job.Runner),
+		otlpString("github.runner_name", job.Job.GetRunnerName()),
+		otlpString("github.runner_group", job.Job.GetRunnerGroupName()),
+		otlpString("github.conclusion", job.Job.GetConclusion()),
+		otlpDouble("octoscope.cost_usd", job.BillableInUSD),
+		otlpDouble("octoscope.billable_minutes", job.RoundedUpJobDuration.Minutes()),
+		otlpDouble("octoscope.price_per_minute_usd", job.PricePerMinuteInUSD),
+		otlpDouble("octoscope.queue_seconds", job.QueueDuration.Seconds()),
+		otlpDouble("octoscope.execution_seconds", job.ExecutionDuration.Seconds()),
+	}
+	if !shouldObfuscate {
+		attributes = append(attributes, otlpString("url.full", job.Job.GetHTMLURL()))
+	}
+	tags := make([]string, 0, len(fj.Tags))
+	for name := range fj.Tags {
+		tags = append(tags, name)
+	}
+	sort.Strings(tags)
+	for _, name := range tags {
+		attributes = append(attributes, otlpString("octoscope.tag."+name, fj.Tags[name]))
+	}
+
+	var events []OTLPSpanEvent
+	if job.Job.StartedAt != nil && job.Job.CreatedAt != nil && job.Job.StartedAt.After(job.Job.CreatedAt.Time) {
+		events = append(events, OTLPSpanEvent{TimeUnixNano: otlpTime(job.Job.StartedAt.Time), Name: "started"})
+	}
+
+	spans := []OTLPSpan{{
+		TraceID:           traceID,
+		SpanID:            jobSpanID,
+		ParentSpanID:      parentSpanID,
+		Name:              job.Job.GetName(),
+		Kind:              otlpSpanKindInternal,
+		StartTimeUnixNano: otlpTime(jobStart(job)),
+		EndTimeUnixNano:   otlpTime(job.Job.CompletedAt.Time),
+		Attributes:        withoutEmpty(attributes),
+		Events:            events,
+		Status:            otlpStatus(job.Job.GetConclusion()),
+	}}
+
+	for _, step := range JobSteps(job) {
+		if step.StartedAt.IsZero() || step.CompletedAt.IsZero() {
+			continue
+		}
+		spans = append(spans, OTLPSpan{
+			TraceID:           traceID,
+			SpanID:            otlpID(8, "job/%d/step/%d", jobID, step.Number),
+			ParentSpanID:      jobSpanID,
+			Name:              step.Name,
+			Kind:              otlpSpanKindInternal,
+			StartTimeUnixNano: otlpTime(step.StartedAt),
+			EndTimeUnixNano:   otlpTime(step.CompletedAt),
+			Attributes: withoutEmpty([]OTLPKeyValue{
+				otlpString("github.step", step.Name),
+				otlpInt("github.step_number", step.Number),
+				otlpString("github.conclusion", step.Conclusion),
+				otlpDouble("octoscope.cost_usd", step.BillableInUSD),
+				otlpDouble("octoscope.billable_minutes", step.BillableMinutes),
+				otlpDouble("octoscope.share_percent", step.Share),
+			}),
+			Status: otlpStatus(step.Conclusion),
+		})
+	}
+	return spans
+}
+
+// jobStart returns when a job was created, or started when the creation time is unknown
+func jobStart(job JobDetails) time.Time {
+	if job.Job == nil {
+		return time.Time{}
+	}
+	if job.Job.CreatedAt != nil {
+		return job.Job.CreatedAt.Time
+	}
+	if job.Job.StartedAt != nil {
+		return job.Job.StartedAt.Time
+	}
+	return time.Time{}
+}
+
+// widen extends the start and end to include another start and end
+func widen(start, end, otherStart, otherEnd time.Time) (time.Time, time.Time) {
+	if start.IsZero() || otherStart.Before(start) {
+		start = otherStart
+	}
+	if otherEnd.After(end) {
+		end = otherEnd
+	}
+	return start, end
+}
+
+// otlpID derives a trace ID (16 bytes) or span ID (8 bytes) from a key
+func otlpID(size int, format string, args ...any) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf(format, args...)))
+	return hex.EncodeToString(sum[:size])
+}
+
+func otlpTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// otlpStatus maps a conclusion to a span status, failures are errors
+func otlpStatus(conclusion string) OTLPStatus {
+	switch {
+	case conclusion == "success":
+		return OTLPStatus{Code: otlpStatusOK}
+	case isFailure(conclusion):
+		return OTLPStatus{Code: otlpStatusError, Message: conclusion}
+	}
+	return OTLPStatus{}
+}
+
+func otlpString(key, value string) OTLPKeyValue {
+	return OTLPKeyValue{Key: key, Value: OTLPAnyValue{StringValue: &value}}
+}
+
+func otlpInt(key string, value int64) OTLPKeyValue {
+	s := strconv.FormatInt(value, 10)
+	return OTLPKeyValue{Key: key, Value: OTLPAnyValue{IntValue: &s}}
+}
+
+func otlpDouble(key string, value float64) OTLPKeyValue {
+	return OTLPKeyValue{Key: key, Value: OTLPAnyValue{DoubleValue: &value}}
+}
+
+// withoutEmpty drops string attributes without a value
+func withoutEmpty(attributes []OTLPKeyValue) []OTLPKeyValue {
+	kept := attributes[:0]
+	for _, kv := range attributes {
+		if kv.Value.StringValue != nil && *kv.Value.StringValue == "" {
+			continue
+		}
+		kept = append(kept, kv)
+	}
+	return kept
+}
@@ -0,0 +1,178 @@
+package reports
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// traceTestJob returns a completed job of a run with two timed steps, queued for a minute
+// before its four minutes of steps
+func traceTestJob(runID, jobID, attempt int64, conclusion string, cost float64) JobDetails {
+	job := stepsTestJob("CI", 3*time.Minute, cost)
+	created := time.Date(2025, 4, 1, 11, 59, 0, 0, time.UTC)
+	job.WorkflowRun.ID = github.Int64(runID)
+	job.WorkflowRun.Conclusion = github.String(conclusion)
+	job.Job.ID = github.Int64(jobID)
+	job.Job.Conclusion = github.String(conclusion)
+	job.Job.RunAttempt = github.Int64(attempt)
+	job.Job.CreatedAt = &github.Timestamp{Time: created}
+	job.Job.StartedAt = &github.Timestamp{Time: created.Add(time.Minute)}
+	job.Job.CompletedAt = &github.Timestamp{Time: created.Add(5 * time.Minute)}
+	job.Job.HTMLURL = github.String("https://github.com/testowner/testrepo/actions/runs/" + strconv.FormatInt(runID, 10) + "/job/" + strconv.FormatInt(jobID, 10))
+	job.Tags = map[string]string{"team": "platform"}
+	return job
+}
+
+// spansByName indexes the spans of all resources by name
+func spansByName(traces OTLPTraces) map[string]OTLPSpan {
+	spans := make(map[string]OTLPSpan)
+	for _, rs := range traces.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			for _, span := range ss.Spans {
+				spans[span.Name] = span
+			}
+		}
+	}
+	return spans
+}
+
+// attribute returns the value of an attribute as a string
+func attribute(t *testing.T, attributes []OTLPKeyValue, key string) string {
+	t.Helper()
+	for _, kv := range attributes {
+		if kv.Key != key {
+			continue
+		}
+		switch {
+		case kv.Value.StringValue != nil:
+			return *kv.Value.StringValue
+		case kv.Value.IntValue != nil:
+			return *kv.Value.IntValue
+		case kv.Value.DoubleValue != nil:
+			return strconv.FormatFloat(*kv.Value.DoubleValue, 'f', -1, 64)
+		}
+	}
+	t.Fatalf("attribute %q not found", key)
+	return ""
+}
+
+func TestBuildTraces(t *testing.T) {
+	jobs := []JobDetails{traceTestJob(3, 4, 1, "success", 0.04)}
+	traces := BuildTraces(jobs, "", false)
+
+	require.Len(t, traces.ResourceSpans, 1)
+	resource := traces.ResourceSpans[0].Resource.Attributes
+	assert.Equal(t, DefaultTraceServiceName, attribute(t, resource, "service.name"))
+	assert.Equal(t, "testowner/testrepo", attribute(t, resource, "github.repository"))
+
+	// Run, attempt, job and the two timed steps, the checkout step has no timings
+	require.Equal(t, 5, traces.SpanCount())
+	spans := spansByName(traces)
+	run, attempt, job := spans["CI"], spans["attempt 1"], spans["build"]
+	setup, tests := spans["Set up job"], spans["Run tests"]
+
+	for _, span := range []OTLPSpan{attempt, job, setup, tests} {
+		assert.Equal(t, run.TraceID, span.TraceID)
+	}
+	assert.Len(t, run.TraceID, 32)
+	assert.Len(t, run.SpanID, 16)
+	assert.Empty(t, run.ParentSpanID)
+	assert.Equal(t, run.SpanID, attempt.ParentSpanID)
+	assert.Equal(t, attempt.SpanID, job.ParentSpanID)
+	assert.Equal(t, job.SpanID, setup.ParentSpanID)
+	assert.Equal(t, job.SpanID, tests.ParentSpanID)
+
+	// The job span includes the minute queued, marked by the started event
+	created := time.Date(2025, 4, 1, 11, 59, 0, 0, time.UTC)
+	assert.Equal(t, strconv.FormatInt(created.UnixNano(), 10), job.StartTimeUnixNano)
+	assert.Equal(t, strconv.FormatInt(created.Add(5*time.Minute).UnixNano(), 10), job.EndTimeUnixNano)
+	require.Len(t, job.Events, 1)
+	assert.Equal(t, "started", job.Events[0].Name)
+	assert.Equal(t, strconv.FormatInt(created.Add(time.Minute).UnixNano(), 10), job.Events[0].TimeUnixNano)
+	assert.Equal(t, job.StartTimeUnixNano, run.StartTimeUnixNano)
+	assert.Equal(t, job.EndTimeUnixNano, run.EndTimeUnixNano)
+
+	assert.Equal(t, "0.04", attribute(t, run.Attributes, "octoscope.cost_usd"))
+	assert.Equal(t, "0.04", attribute(t, job.Attributes, "octoscope.cost_usd"))
+	assert.Equal(t, "4", attribute(t, job.Attributes, "github.job_id"))
+	assert.Equal(t, "https://github.com/testowner/testrepo/actions/runs/3/job/4", attribute(t, job.Attributes, "url.full"))
+	assert.Equal(t, "platform", attribute(t, job.Attributes, "octoscope.tag.team"))
+	assert.Equal(t, "0.03", attribute(t, tests.Attributes, "octoscope.cost_usd"))
+	assert.Equal(t, "3", attribute(t, tests.Attributes, "github.step_number"))
+	assert.Equal(t, OTLPStatus{Code: otlpStatusOK}, job.Status)
+
+	// IDs are derived from the run and job IDs, so exporting again produces the same spans
+	assert.Equal(t, traces, BuildTraces(jobs, "", false))
+}
+
+func TestBuildTracesAttempts(t *testing.T) {
+	jobs := []JobDetails{
+		traceTestJob(3, 4, 1, "failure", 0.04),
+		traceTestJob(3, 5, 2, "success", 0.04),
+		traceTestJob(6, 7, 1, "success", 0.04),
+	}
+	jobs[1].Job.Name = github.String("retry")
+	// Still running, left out
+	running := traceTestJob(8, 9, 1, "", 0)
+	running.Job.CompletedAt = nil
+	jobs = append(jobs, running)
+
+	traces := BuildTraces(jobs, "ci", false)
+	require.Len(t, traces.ResourceSpans, 1)
+	assert.Equal(t, "ci", attribute(t, traces.ResourceSpans[0].Resource.Attributes, "service.name"))
+
+	traceIDs := make(map[string]int)
+	var first, second OTLPSpan
+	for _, span := range traces.ResourceSpans[0].ScopeSpans[0].Spans {
+		traceIDs[span.TraceID]++
+		switch span.Name {
+		case "attempt 1":
+			if span.TraceID == otlpID(16, "run/%d", 3) {
+				first = span
+			}
+		case "attempt 2":
+			second = span
+		}
+	}
+	assert.Len(t, traceIDs, 2)
+	assert.Equal(t, OTLPStatus{Code: otlpStatusError, Message: "failure"}, first.Status)
+	assert.Equal(t, OTLPStatus{Code: otlpStatusOK}, second.Status)
+	assert.Equal(t, first.ParentSpanID, second.ParentSpanID)
+	assert.NotEqual(t, first.SpanID, second.SpanID)
+	assert.Equal(t, "5", attribute(t, spansByName(traces)["retry"].Attributes, "github.job_id"))
+}
+
+func TestBuildTracesObfuscate(t *testing.T) {
+	traces := BuildTraces([]JobDetails{traceTestJob(3, 4, 1, "success", 0.04)}, "", true)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteOTLPJSON(&buf, traces))
+	assert.NotContains(t, buf.String(), "testowner")
+	assert.NotContains(t, buf.String(), "testactor")
+	assert.NotContains(t, buf.String(), "url.full")
+}
+
+func TestWriteOTLPJSON(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteOTLPJSON(&buf, BuildTraces([]JobDetails{traceTestJob(3, 4, 1, "success", 0.04)}, "", false)))
+	require.NoError(t, WriteOTLPJSON(&buf, BuildTraces(nil, "", false)))
+
+	lines := splitLines(strings.TrimSuffix(buf.String(), "\n"))
+	require.Len(t, lines, 2)
+
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &decoded))
+	resourceSpans := decoded["resourceSpans"].([]any)
+	span := resourceSpans[0].(map[string]any)["scopeSpans"].([]any)[0].(map[string]any)["spans"].([]any)[0].(map[string]any)
+	// 64 bit integers are strings in OTLP/JSON
+	assert.IsType(t, "", span["startTimeUnixNano"])
+	assert.Equal(t, `{"resourceSpans":[]}`, lines[1])
+}
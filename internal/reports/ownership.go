@@ -0,0 +1,292 @@
+package reports
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AllocationSource is what allocated a job's cost to a team
+type AllocationSource string
+
+const (
+	AllocatedByWorkflow   AllocationSource = "workflow"   // the workflow is listed under a team
+	AllocatedByCodeOwners AllocationSource = "codeowners" // CODEOWNERS owns the workflow file
+	AllocatedByRepo       AllocationSource = "repo"       // the repository is listed under a team
+	AllocatedByActor      AllocationSource = "actor"      // the actor is listed under, or a member of, a team
+)
+
+// AllocationSources lists the allocation sources in order of precedence
+var AllocationSources = []AllocationSource{AllocatedByWorkflow, AllocatedByCodeOwners, AllocatedByRepo, AllocatedByActor}
+
+// Team is a team that CI spend is charged back to. Repos and workflows may contain
+// path.Match wildcards, e.g. my-org/web-* or .github/workflows/deploy-*.yml.
+type Team struct {
+	Name       string   `yaml:"name"`
+	Repos      []string `yaml:"repos"`       // owner/repo or repo
+	Workflows  []string `yaml:"workflows"`   // workflow file paths or workflow names
+	Actors     []string `yaml:"actors"`      // GitHub logins
+	GitHubTeam string   `yaml:"github_team"` // org/team-slug, matched against CODEOWNERS owners and used for membership
+}
+
+// TeamFile is the teams config file:
+//
+//	teams:
+//	  - name: Platform
+//	    repos: [my-org/infra, my-org/tooling-*]
+//	    workflows: [.github/workflows/deploy.yml]
+//	    actors: [alice, bob]
+//	    github_team: my-org/platform
+//	codeowners:
+//	  "@my-org/web-devs": Web
+type TeamFile struct {
+	Teams      []Team            `yaml:"teams"`
+	CodeOwners map[string]string `yaml:"codeowners"` // CODEOWNERS owner to team name
+}
+
+// LoadTeams reads and validates a teams config file
+func LoadTeams(path string) (*TeamFile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file TeamFile
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse teams file %s: %w", path, err)
+	}
+
+	names := make(map[string]bool)
+	for i, team := range file.Teams {
+		if err := team.validate(); err != nil {
+			return nil, fmt.Errorf("invalid team #%d in %s: %w", i+1, path, err)
+		}
+		if names[strings.ToLower(team.Name)] {
+			return nil, fmt.Errorf("invalid team #%d in %s: duplicate team %q", i+1, path, team.Name)
+		}
+		names[strings.ToLower(team.Name)] = true
+	}
+	return &file, nil
+}
+
+// validate checks the team's name and patterns
+func (t Team) validate() error {
+	if t.Name == "" {
+		return errors.New("name is required")
+	}
+	if strings.EqualFold(t.Name, UnallocatedTeam) {
+		return fmt.Errorf("name %q is reserved for unallocated spend", t.Name)
+	}
+	for _, pattern := range append(append([]string{}, t.Repos...), t.Workflows...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	if t.GitHubTeam != "" && !strings.Contains(t.GitHubTeam, "/") {
+		return fmt.Errorf("github_team %q must be org/team-slug", t.GitHubTeam)
+	}
+	return nil
+}
+
+// CodeOwnersRule is a line of a CODEOWNERS file
+type CodeOwnersRule struct {
+	Pattern string
+	Owners  []string
+	re      *regexp.Regexp
+}
+
+// CodeOwners is a parsed CODEOWNERS file. As in GitHub, the last matching rule wins.
+type CodeOwners []CodeOwnersRule
+
+// ParseCodeOwners parses the content of a CODEOWNERS file. Comments, blank lines and
+// invalid patterns are skipped.
+func ParseCodeOwners(content string) CodeOwners {
+	var rules CodeOwners
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		re, err := codeOwnersRegexp(fields[0])
+		if err != nil {
+			continue
+		}
+		rules = append(rules, CodeOwnersRule{Pattern: fields[0], Owners: fields[1:], re: re})
+	}
+	return rules
+}
+
+// Owners returns the owners of a file path, or nil when no rule matches it. A
+// matching rule without owners leaves the file unowned.
+func (c CodeOwners) Owners(filePath string) []string {
+	filePath = strings.TrimPrefix(filePath, "/")
+	for i := len(c) - 1; i >= 0; i-- {
+		if c[i].re.MatchString(filePath) {
+			return c[i].Owners
+		}
+	}
+	return nil
+}
+
+// codeOwnersRegexp translates a gitignore style CODEOWNERS pattern. Patterns with a
+// leading or inner slash are relative to the repository root, others match at any
+// depth, and a pattern matching a directory matches everything below it.
+func codeOwnersRegexp(pattern string) (*regexp.Regexp, error) {
+	trimmed := strings.TrimSuffix(pattern, "/")
+	anchored := strings.Contains(trimmed, "/")
+	trimmed = strings.TrimPrefix(trimmed, "/")
+
+	var b strings.Builder
+	if anchored {
+		b.WriteString("^")
+	} else {
+		b.WriteString("^(?:.*/)?")
+	}
+	for i := 0; i < len(trimmed); i++ {
+		switch {
+		case strings.HasPrefix(trimmed[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(trimmed[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case trimmed[i] == '*':
+			b.WriteString("[^/]*")
+		case trimmed[i] == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(trimmed[i : i+1]))
+		}
+	}
+	b.WriteString("(?:/.*)?$")
+	return regexp.Compile(b.String())
+}
+
+// OwnershipResolver maps jobs to the team their cost is charged to
+type OwnershipResolver struct {
+	teams      []Team
+	codeOwners CodeOwners
+	ownerTeams map[string]string // lower case CODEOWNERS owner to team name
+	actorTeams map[string]string // lower case login to team name
+}
+
+// NewOwnershipResolver creates a resolver from the teams file, a CODEOWNERS file and
+// the members of GitHub teams keyed by org/team-slug. All of them may be empty.
+// Actors listed under a team take precedence over GitHub team membership.
+func NewOwnershipResolver(file *TeamFile, codeOwners CodeOwners, members map[string][]string) *OwnershipResolver {
+	if file == nil {
+		file = &TeamFile{}
+	}
+	r := &OwnershipResolver{
+		teams:      file.Teams,
+		codeOwners: codeOwners,
+		ownerTeams: make(map[string]string),
+		actorTeams: make(map[string]string),
+	}
+
+	for _, team := range file.Teams {
+		if team.GitHubTeam == "" {
+			continue
+		}
+		r.ownerTeams["@"+strings.ToLower(team.GitHubTeam)] = team.Name
+		for _, login := range members[team.GitHubTeam] {
+			if _, ok := r.actorTeams[strings.ToLower(login)]; !ok {
+				r.actorTeams[strings.ToLower(login)] = team.Name
+			}
+		}
+	}
+	for owner, team := range file.CodeOwners {
+		r.ownerTeams[strings.ToLower(owner)] = team
+	}
+	for _, team := range file.Teams {
+		for _, actor := range team.Actors {
+			r.actorTeams[strings.ToLower(strings.TrimPrefix(actor, "@"))] = team.Name
+		}
+	}
+	return r
+}
+
+// Resolve returns the team a job is charged to and what allocated it, in order of
+// precedence: the workflow, the CODEOWNERS of the workflow file, the repository and
+// the actor. It returns an empty team when nothing matches.
+func (r *OwnershipResolver) Resolve(job JobDetails) (string, AllocationSource) {
+	var workflowPath, workflowName, owner, repo, actor string
+	if job.Workflow != nil {
+		workflowPath = job.Workflow.GetPath()
+		workflowName = job.Workflow.GetName()
+	}
+	if job.WorkflowRun != nil {
+		if workflowName == "" {
+			workflowName = job.WorkflowRun.GetName()
+		}
+		actor = job.WorkflowRun.GetActor().GetLogin()
+	}
+	if job.Repo != nil {
+		owner = job.Repo.GetOwner().GetLogin()
+		repo = job.Repo.GetName()
+	}
+
+	for _, team := range r.teams {
+		if matchesAny(team.Workflows, workflowPath) || matchesAny(team.Workflows, workflowName) {
+			return team.Name, AllocatedByWorkflow
+		}
+	}
+	if workflowPath != "" {
+		if team := r.codeOwnersTeam(workflowPath); team != "" {
+			return team, AllocatedByCodeOwners
+		}
+	}
+	for _, team := range r.teams {
+		if (owner != "" && matchesAny(team.Repos, owner+"/"+repo)) || matchesAny(team.Repos, repo) {
+			return team.Name, AllocatedByRepo
+		}
+	}
+	if team, ok := r.actorTeams[strings.ToLower(actor)]; ok && actor != "" {
+		return team, AllocatedByActor
+	}
+	return "", ""
+}
+
+// codeOwnersTeam returns the team of the first owner of a file. Owners that are not
+// mapped to a team are teams of their own, e.g. @my-org/web-devs.
+func (r *OwnershipResolver) codeOwnersTeam(filePath string) string {
+	owners := r.codeOwners.Owners(filePath)
+	if len(owners) == 0 {
+		return ""
+	}
+	owner := owners[0]
+	if team, ok := r.ownerTeams[strings.ToLower(owner)]; ok {
+		return team
+	}
+	if login, ok := strings.CutPrefix(owner, "@"); ok && !strings.Contains(login, "/") {
+		if team, ok := r.actorTeams[strings.ToLower(login)]; ok {
+			return team
+		}
+	}
+	return owner
+}
+
+// matchesAny reports whether value matches one of the patterns, ignoring case
+func matchesAny(patterns []string, value string) bool {
+	if value == "" {
+		return false
+	}
+	value = strings.ToLower(value)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), value); ok {
+			return true
+		}
+	}
+	return false
+}
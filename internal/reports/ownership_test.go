@@ -0,0 +1,134 @@
+package reports
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ownershipTestJob returns a job of a workflow file in a repo of testowner, triggered by actor
+func ownershipTestJob(workflowPath, repo, actor string, created time.Time, cost float64) JobDetails {
+	job := aggregateTestJob(filepath.Base(workflowPath), "UBUNTU", "success", created, time.Hour, cost)
+	job.Workflow.Path = github.String(workflowPath)
+	job.Repo.Name = github.String(repo)
+	job.WorkflowRun.Actor = &github.User{Login: github.String(actor)}
+	return job
+}
+
+func TestLoadTeams(t *testing.T) {
+	write := func(t *testing.T, content string) string {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "teams.yml")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+
+	file, err := LoadTeams(write(t, `
+teams:
+  - name: Platform
+    repos: [testowner/infra]
+    github_team: testowner/platform
+codeowners:
+  "@testowner/web-devs": Web
+`))
+	require.NoError(t, err)
+	require.Len(t, file.Teams, 1)
+	assert.Equal(t, "testowner/platform", file.Teams[0].GitHubTeam)
+	assert.Equal(t, "Web", file.CodeOwners["@testowner/web-devs"])
+
+	for name, content := range map[string]string{
+		"MissingName":   "teams:\n  - repos: [infra]\n",
+		"Reserved":      "teams:\n  - name: Unallocated\n",
+		"Duplicate":     "teams:\n  - name: Web\n  - name: web\n",
+		"BadPattern":    "teams:\n  - name: Web\n    repos: ['[']\n",
+		"BadGitHubTeam": "teams:\n  - name: Web\n    github_team: web\n",
+		"InvalidYAML":   "teams: [",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := LoadTeams(write(t, content))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestCodeOwners(t *testing.T) {
+	owners := ParseCodeOwners(`
+# Default owners
+*                         @testowner/everyone
+/.github/workflows/       @testowner/platform   # CI belongs to platform
+deploy-*.yml              @testowner/release
+docs/**/ci.yml            @alice
+/.github/workflows/lint.yml
+`)
+
+	for path, want := range map[string][]string{
+		"README.md":                          {"@testowner/everyone"},
+		".github/workflows/ci.yml":           {"@testowner/platform"},
+		"/.github/workflows/ci.yml":          {"@testowner/platform"},
+		".github/workflows/deploy-prod.yml":  {"@testowner/release"},
+		"docs/ci.yml":                        {"@alice"},
+		"docs/a/b/ci.yml":                    {"@alice"},
+		".github/workflows/lint.yml":         {},
+		"src/.github/workflows/ci.yml":       {"@testowner/everyone"},
+		".github/workflows/nested/build.yml": {"@testowner/platform"},
+	} {
+		assert.Equal(t, want, owners.Owners(path), path)
+	}
+
+	assert.Nil(t, CodeOwners(nil).Owners(".github/workflows/ci.yml"))
+}
+
+func TestOwnershipResolver(t *testing.T) {
+	day := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+	file := &TeamFile{
+		Teams: []Team{
+			{Name: "Release", Workflows: []string{".github/workflows/deploy-*.yml"}},
+			{Name: "Platform", Repos: []string{"testowner/infra"}, GitHubTeam: "testowner/platform"},
+			{Name: "Web", Repos: []string{"web-*"}, Actors: []string{"@alice"}},
+		},
+		CodeOwners: map[string]string{"@testowner/web-devs": "Web"},
+	}
+	codeOwners := ParseCodeOwners(`
+/.github/workflows/infra.yml  @testowner/platform
+/.github/workflows/site.yml   @testowner/web-devs
+/.github/workflows/docs.yml   @bob
+/.github/workflows/other.yml  @testowner/mobile
+`)
+	resolver := NewOwnershipResolver(file, codeOwners, map[string][]string{
+		"testowner/platform": {"bob", "alice"},
+	})
+
+	for name, tc := range map[string]struct {
+		job    JobDetails
+		team   string
+		source AllocationSource
+	}{
+		"Workflow":           {ownershipTestJob(".github/workflows/deploy-prod.yml", "infra", "carol", day, 1), "Release", AllocatedByWorkflow},
+		"CodeOwnersTeam":     {ownershipTestJob(".github/workflows/infra.yml", "web-app", "carol", day, 1), "Platform", AllocatedByCodeOwners},
+		"CodeOwnersMapping":  {ownershipTestJob(".github/workflows/site.yml", "infra", "carol", day, 1), "Web", AllocatedByCodeOwners},
+		"CodeOwnersUser":     {ownershipTestJob(".github/workflows/docs.yml", "other", "carol", day, 1), "Platform", AllocatedByCodeOwners},
+		"CodeOwnersUnmapped": {ownershipTestJob(".github/workflows/other.yml", "infra", "carol", day, 1), "@testowner/mobile", AllocatedByCodeOwners},
+		"Repo":               {ownershipTestJob(".github/workflows/ci.yml", "infra", "alice", day, 1), "Platform", AllocatedByRepo},
+		"RepoWildcard":       {ownershipTestJob(".github/workflows/ci.yml", "web-app", "bob", day, 1), "Web", AllocatedByRepo},
+		"ListedActor":        {ownershipTestJob(".github/workflows/ci.yml", "other", "Alice", day, 1), "Web", AllocatedByActor},
+		"TeamMember":         {ownershipTestJob(".github/workflows/ci.yml", "other", "bob", day, 1), "Platform", AllocatedByActor},
+		"Unallocated":        {ownershipTestJob(".github/workflows/ci.yml", "other", "carol", day, 1), "", ""},
+	} {
+		t.Run(name, func(t *testing.T) {
+			team, source := resolver.Resolve(tc.job)
+			assert.Equal(t, tc.team, team)
+			assert.Equal(t, tc.source, source)
+		})
+	}
+
+	t.Run("Empty", func(t *testing.T) {
+		team, source := NewOwnershipResolver(nil, nil, nil).Resolve(ownershipTestJob(".github/workflows/ci.yml", "infra", "alice", day, 1))
+		assert.Empty(t, team)
+		assert.Empty(t, source)
+	})
+}
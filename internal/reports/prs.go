@@ -0,0 +1,332 @@
+package reports
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// PRCost is the CI cost of a single pull request
+type PRCost struct {
+	Number        int
+	Title         string
+	URL           string
+	Author        string
+	State         string // open, closed or merged
+	MergedAt      time.Time
+	Runs          int // workflow runs
+	Pushes        int // distinct head commits that ran CI
+	BillableInUSD float64
+}
+
+// PerPush returns the average cost of CI for every push to the pull request
+func (p PRCost) PerPush() float64 {
+	if p.Pushes == 0 {
+		return 0
+	}
+	return p.BillableInUSD / float64(p.Pushes)
+}
+
+// PRAuthorCost is the CI cost of all pull requests by a single author
+type PRAuthorCost struct {
+	Author        string
+	PRs           int
+	Pushes        int
+	BillableInUSD float64
+}
+
+// PRCosts attributes CI cost to pull requests and their authors. Pull requests and
+// authors are sorted by cost, most expensive first.
+type PRCosts struct {
+	PRs               []PRCost
+	Authors           []PRAuthorCost
+	TotalInUSD        float64 // cost of all jobs
+	AttributedInUSD   float64 // cost of the jobs of a pull request
+	MergedPRs         int
+	MedianPerMergedPR float64
+}
+
+// Unattributed returns the cost of jobs that aren't part of a pull request,
+// e.g. of pushes to the default branch and scheduled runs
+func (c PRCosts) Unattributed() float64 {
+	return c.TotalInUSD - c.AttributedInUSD
+}
+
+// AnalyzePRCosts rolls the cost of jobs up to the pull requests their runs belong to.
+// Jobs are attributed using the pull request resolved while fetching, see JobDetails.PullRequest.
+func AnalyzePRCosts(jobs []JobDetails, shouldObfuscate bool) PRCosts {
+	flattened := FlattenJobs(jobs, shouldObfuscate)
+
+	var costs PRCosts
+	byNumber := make(map[int]*PRCost)
+	runs := make(map[int]map[int64]bool)
+	pushes := make(map[int]map[string]bool)
+	for i, job := range jobs {
+		costs.TotalInUSD += job.BillableInUSD
+		pr := job.PullRequest
+		if pr == nil || pr.GetNumber() == 0 {
+			continue
+		}
+		costs.AttributedInUSD += job.BillableInUSD
+
+		number := pr.GetNumber()
+		cost, ok := byNumber[number]
+		if !ok {
+			cost = &PRCost{
+				Number: number,
+				Title:  pr.GetTitle(),
+				Author: derefOr(flattened[i].PullRequestAuthor, "unknown"),
+				State:  pr.GetState(),
+			}
+			// Titles are obfuscated like run display titles, and URLs name the repository
+			if shouldObfuscate {
+				cost.Title = obfuscateString(cost.Title)
+			} else {
+				cost.URL = pr.GetHTMLURL()
+			}
+			if pr.MergedAt != nil {
+				cost.State = "merged"
+				cost.MergedAt = pr.MergedAt.Time
+			}
+			byNumber[number] = cost
+			runs[number] = make(map[int64]bool)
+			pushes[number] = make(map[string]bool)
+		}
+		cost.BillableInUSD += job.BillableInUSD
+		if job.WorkflowRun != nil {
+			runs[number][job.WorkflowRun.GetID()] = true
+			if sha := job.WorkflowRun.GetHeadSHA(); sha != "" {
+				pushes[number][sha] = true
+			}
+		}
+	}
+
+	authors := make(map[string]*PRAuthorCost)
+	var merged []float64
+	for number, cost := range byNumber {
+		cost.Runs = len(runs[number])
+		cost.Pushes = len(pushes[number])
+		costs.PRs = append(costs.PRs, *cost)
+
+		author, ok := authors[cost.Author]
+		if !ok {
+			author = &PRAuthorCost{Author: cost.Author}
+			authors[cost.Author] = author
+		}
+		author.PRs++
+		author.Pushes += cost.Pushes
+		author.BillableInUSD += cost.BillableInUSD
+
+		if cost.State == "merged" {
+			merged = append(merged, cost.BillableInUSD)
+		}
+	}
+	costs.MergedPRs = len(merged)
+	costs.MedianPerMergedPR = medianOf(merged)
+
+	sort.Slice(costs.PRs, func(i, j int) bool {
+		if costs.PRs[i].BillableInUSD != costs.PRs[j].BillableInUSD {
+			return costs.PRs[i].BillableInUSD > costs.PRs[j].BillableInUSD
+		}
+		return costs.PRs[i].Number > costs.PRs[j].Number
+	})
+	for _, author := range authors {
+		costs.Authors = append(costs.Authors, *author)
+	}
+	sort.Slice(costs.Authors, func(i, j int) bool {
+		if costs.Authors[i].BillableInUSD != costs.Authors[j].BillableInUSD {
+			return costs.Authors[i].BillableInUSD > costs.Authors[j].BillableInUSD
+		}
+		return costs.Authors[i].Author < costs.Authors[j].Author
+	})
+	return costs
+}
+
+// PRGenerator writes pull request costs as a plain text table, Markdown or JSON
+type PRGenerator struct {
+	out    io.Writer
+	format string
+	topN   int
+	logger zerolog.Logger
+}
+
+// NewPRGenerator creates a new pull request cost generator that writes to out
+func NewPRGenerator(out io.Writer, format string, topN int, logger zerolog.Logger) *PRGenerator {
+	if topN <= 0 {
+		topN = DefaultTopN
+	}
+	return &PRGenerator{
+		out:    out,
+		format: format,
+		topN:   topN,
+		logger: logger,
+	}
+}
+
+func (g *PRGenerator) Generate(costs *PRCosts) error {
+	g.logger.Debug().Str("format", g.format).Msg("Generating pull request costs")
+
+	var err error
+	switch g.format {
+	case FormatTable:
+		_, err = io.WriteString(g.out, g.renderTable(costs))
+	case FormatMarkdown:
+		_, err = io.WriteString(g.out, g.renderMarkdown(costs))
+	case FormatJSON:
+		enc := json.NewEncoder(g.out)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(exportPRCosts(costs))
+	default:
+		return fmt.Errorf("unsupported pull request format %q, must be one of: %s, %s, %s", g.format, FormatTable, FormatMarkdown, FormatJSON)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s pull request costs: %w", g.format, err)
+	}
+	return nil
+}
+
+func (g *PRGenerator) renderTable(costs *PRCosts) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%-24s %s of %s\n", "Pull requests", formatUSD(costs.AttributedInUSD), formatUSD(costs.TotalInUSD))
+	fmt.Fprintf(&b, "%-24s %s\n", "Not in a pull request", formatUSD(costs.Unattributed()))
+	fmt.Fprintf(&b, "%-24s %s (%d merged)\n", "Median per merged PR", formatUSD(costs.MedianPerMergedPR), costs.MergedPRs)
+
+	fmt.Fprintf(&b, "\nMost expensive pull requests (%d)\n", len(costs.PRs))
+	if len(costs.PRs) == 0 {
+		b.WriteString("No runs were resolved to pull requests.\n")
+		return b.String()
+	}
+	const titleWidth = 36
+	fmt.Fprintf(&b, "%7s %s %-16s %-7s %6s %10s %10s\n", "PR", padRight("Title", titleWidth), "Author", "State", "Pushes", "Per push", "Cost")
+	for _, pr := range topOf(costs.PRs, g.topN) {
+		fmt.Fprintf(&b, "%7s %s %-16s %-7s %6d %10s %10s\n",
+			fmt.Sprintf("#%d", pr.Number),
+			padRight(truncate(pr.Title, titleWidth), titleWidth),
+			truncate(pr.Author, 16),
+			pr.State,
+			pr.Pushes,
+			formatUSD(pr.PerPush()),
+			formatUSD(pr.BillableInUSD))
+	}
+
+	b.WriteString("\nBy author\n")
+	fmt.Fprintf(&b, "%-24s %5s %6s %10s\n", "Author", "PRs", "Pushes", "Cost")
+	for _, author := range topOf(costs.Authors, g.topN) {
+		fmt.Fprintf(&b, "%-24s %5d %6d %10s\n", truncate(author.Author, 24), author.PRs, author.Pushes, formatUSD(author.BillableInUSD))
+	}
+	return b.String()
+}
+
+func (g *PRGenerator) renderMarkdown(costs *PRCosts) string {
+	var b strings.Builder
+
+	b.WriteString("## GitHub Actions cost per pull request\n\n")
+	fmt.Fprintf(&b, "**%s** of **%s** was spent on pull requests, %s elsewhere. The median merged pull request cost **%s** in CI (%d merged).\n\n",
+		formatUSD(costs.AttributedInUSD), formatUSD(costs.TotalInUSD), formatUSD(costs.Unattributed()), formatUSD(costs.MedianPerMergedPR), costs.MergedPRs)
+
+	fmt.Fprintf(&b, "### Most expensive pull requests (%d)\n\n", len(costs.PRs))
+	if len(costs.PRs) == 0 {
+		b.WriteString("No runs were resolved to pull requests.\n")
+		return b.String()
+	}
+	b.WriteString("| Pull request | Author | State | Runs | Pushes | Per push | Cost |\n")
+	b.WriteString("| --- | --- | --- | ---: | ---: | ---: | ---: |\n")
+	for _, pr := range topOf(costs.PRs, g.topN) {
+		fmt.Fprintf(&b, "| %s | %s | %s | %d | %d | %s | %s |\n",
+			markdownLink(fmt.Sprintf("#%d %s", pr.Number, pr.Title), pr.URL),
+			escapeMarkdownCell(pr.Author),
+			pr.State,
+			pr.Runs,
+			pr.Pushes,
+			formatUSD(pr.PerPush()),
+			formatUSD(pr.BillableInUSD))
+	}
+
+	b.WriteString("\n### By author\n\n")
+	b.WriteString("| Author | Pull requests | Pushes | Cost |\n")
+	b.WriteString("| --- | ---: | ---: | ---: |\n")
+	for _, author := range topOf(costs.Authors, g.topN) {
+		fmt.Fprintf(&b, "| %s | %d | %d | %s |\n", escapeMarkdownCell(author.Author), author.PRs, author.Pushes, formatUSD(author.BillableInUSD))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// PRCostsExport is the document written by the json pull request format
+type PRCostsExport struct {
+	SchemaVersion        string               `json:"schema_version"`
+	TotalInUSD           float64              `json:"total_in_usd"`
+	AttributedInUSD      float64              `json:"attributed_in_usd"`
+	UnattributedInUSD    float64              `json:"unattributed_in_usd"`
+	MergedPRs            int                  `json:"merged_pull_requests"`
+	MedianPerMergedInUSD float64              `json:"median_per_merged_pull_request_in_usd"`
+	PullRequests         []PRCostExport       `json:"pull_requests"`
+	Authors              []PRAuthorCostExport `json:"authors"`
+}
+
+// PRCostExport is a pull request in a JSON pull request export
+type PRCostExport struct {
+	Number        int     `json:"number"`
+	Title         string  `json:"title,omitempty"`
+	URL           string  `json:"url,omitempty"`
+	Author        string  `json:"author"`
+	State         string  `json:"state,omitempty"`
+	MergedAt      string  `json:"merged_at,omitempty"`
+	Runs          int     `json:"runs"`
+	Pushes        int     `json:"pushes"`
+	PerPushInUSD  float64 `json:"per_push_in_usd"`
+	BillableInUSD float64 `json:"billable_in_usd"`
+}
+
+// PRAuthorCostExport is an author in a JSON pull request export
+type PRAuthorCostExport struct {
+	Author        string  `json:"author"`
+	PullRequests  int     `json:"pull_requests"`
+	Pushes        int     `json:"pushes"`
+	BillableInUSD float64 `json:"billable_in_usd"`
+}
+
+func exportPRCosts(costs *PRCosts) PRCostsExport {
+	export := PRCostsExport{
+		SchemaVersion:        ExportSchemaVersion,
+		TotalInUSD:           costs.TotalInUSD,
+		AttributedInUSD:      costs.AttributedInUSD,
+		UnattributedInUSD:    costs.Unattributed(),
+		MergedPRs:            costs.MergedPRs,
+		MedianPerMergedInUSD: costs.MedianPerMergedPR,
+		PullRequests:         []PRCostExport{},
+		Authors:              []PRAuthorCostExport{},
+	}
+	for _, pr := range costs.PRs {
+		e := PRCostExport{
+			Number:        pr.Number,
+			Title:         pr.Title,
+			URL:           pr.URL,
+			Author:        pr.Author,
+			State:         pr.State,
+			Runs:          pr.Runs,
+			Pushes:        pr.Pushes,
+			PerPushInUSD:  pr.PerPush(),
+			BillableInUSD: pr.BillableInUSD,
+		}
+		if !pr.MergedAt.IsZero() {
+			e.MergedAt = pr.MergedAt.UTC().Format(time.RFC3339)
+		}
+		export.PullRequests = append(export.PullRequests, e)
+	}
+	for _, author := range costs.Authors {
+		export.Authors = append(export.Authors, PRAuthorCostExport{
+			Author:        author.Author,
+			PullRequests:  author.PRs,
+			Pushes:        author.Pushes,
+			BillableInUSD: author.BillableInUSD,
+		})
+	}
+	return export
+}
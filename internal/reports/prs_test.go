@@ -0,0 +1,137 @@
+package reports
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func prsTestPullRequest(number int, author string, merged bool) *github.PullRequest {
+	pr := &github.PullRequest{
+		Number:  github.Int(number),
+		Title:   github.String("Change | things"),
+		State:   github.String("open"),
+		HTMLURL: github.String(fmt.Sprintf("https://github.com/testowner/testrepo/pull/%d", number)),
+		User:    &github.User{Login: github.String(author)},
+	}
+	if merged {
+		pr.State = github.String("closed")
+		pr.MergedAt = &github.Timestamp{Time: time.Date(2025, 4, 2, 0, 0, 0, 0, time.UTC)}
+	}
+	return pr
+}
+
+// prsTestJob returns a job of run runID on commit sha that belongs to pr
+func prsTestJob(pr *github.PullRequest, runID int64, sha string, cost float64) JobDetails {
+	job := diffTestJob("CI", "test", runID, time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC), 10*time.Minute, cost)
+	job.WorkflowRun.HeadSHA = github.String(sha)
+	job.PullRequest = pr
+	return job
+}
+
+func setupPRsTestData() []JobDetails {
+	first := prsTestPullRequest(1, "alice", true)
+	second := prsTestPullRequest(2, "bob", false)
+	third := prsTestPullRequest(3, "alice", true)
+	return []JobDetails{
+		// Three pushes to the first pull request, the second one ran two workflows
+		prsTestJob(first, 10, "a1", 2),
+		prsTestJob(first, 11, "a2", 2),
+		prsTestJob(first, 12, "a2", 1),
+		prsTestJob(first, 13, "a3", 1),
+		prsTestJob(second, 20, "b1", 3),
+		prsTestJob(third, 30, "c1", 1),
+		// A push to the default branch isn't part of a pull request
+		prsTestJob(nil, 40, "main", 5),
+	}
+}
+
+func TestAnalyzePRCosts(t *testing.T) {
+	costs := AnalyzePRCosts(setupPRsTestData(), false)
+
+	assert.InDelta(t, 15, costs.TotalInUSD, 0.0001)
+	assert.InDelta(t, 10, costs.AttributedInUSD, 0.0001)
+	assert.InDelta(t, 5, costs.Unattributed(), 0.0001)
+	assert.Equal(t, 2, costs.MergedPRs)
+	assert.InDelta(t, 3.5, costs.MedianPerMergedPR, 0.0001)
+
+	require.Len(t, costs.PRs, 3)
+	assert.Equal(t, 1, costs.PRs[0].Number)
+	assert.Equal(t, "merged", costs.PRs[0].State)
+	assert.Equal(t, 4, costs.PRs[0].Runs)
+	assert.Equal(t, 3, costs.PRs[0].Pushes)
+	assert.InDelta(t, 2, costs.PRs[0].PerPush(), 0.0001)
+	assert.Equal(t, 2, costs.PRs[1].Number)
+	assert.Equal(t, "open", costs.PRs[1].State)
+
+	require.Len(t, costs.Authors, 2)
+	assert.Equal(t, "alice", costs.Authors[0].Author)
+	assert.Equal(t, 2, costs.Authors[0].PRs)
+	assert.Equal(t, 4, costs.Authors[0].Pushes)
+	assert.InDelta(t, 7, costs.Authors[0].BillableInUSD, 0.0001)
+
+	assert.Equal(t, "Change | things", costs.PRs[0].Title)
+	assert.Equal(t, "https://github.com/testowner/testrepo/pull/1", costs.PRs[0].URL)
+
+	obfuscated := AnalyzePRCosts(setupPRsTestData(), true)
+	assert.Equal(t, "ali**", obfuscated.Authors[0].Author)
+	assert.Equal(t, obfuscateString("Change | things"), obfuscated.PRs[0].Title)
+	assert.NotEqual(t, "Change | things", obfuscated.PRs[0].Title)
+	assert.Empty(t, obfuscated.PRs[0].URL)
+}
+
+func TestAggregateByPR(t *testing.T) {
+	agg := Aggregate(setupPRsTestData(), []Dimension{DimensionPR}, false)
+	require.Len(t, agg.Groups, 4)
+	assert.Equal(t, "#1", agg.Groups[0].Key)
+	assert.Equal(t, "none", agg.Groups[1].Key)
+}
+
+func TestPRGenerator(t *testing.T) {
+	costs := AnalyzePRCosts(setupPRsTestData(), false)
+
+	t.Run("Table", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewPRGenerator(&buf, FormatTable, 1, zerolog.New(io.Discard)).Generate(&costs))
+		out := buf.String()
+		assert.Contains(t, out, "Median per merged PR     $3.50 (2 merged)")
+		assert.Contains(t, out, "Most expensive pull requests (3)")
+		assert.Contains(t, out, "#1")
+		assert.NotContains(t, out, "#2", "pull requests are limited to the top N")
+	})
+
+	t.Run("Markdown", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewPRGenerator(&buf, FormatMarkdown, 0, zerolog.New(io.Discard)).Generate(&costs))
+		out := buf.String()
+		assert.Contains(t, out, "**$10.00** of **$15.00** was spent on pull requests")
+		assert.Contains(t, out, `| [#1 Change \| things](https://github.com/testowner/testrepo/pull/1) | alice | merged | 4 | 3 | $2.00 | $6.00 |`)
+		assert.Contains(t, out, "| alice | 2 | 4 | $7.00 |")
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewPRGenerator(&buf, FormatJSON, 0, zerolog.New(io.Discard)).Generate(&costs))
+		var export PRCostsExport
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &export))
+		assert.Equal(t, ExportSchemaVersion, export.SchemaVersion)
+		require.Len(t, export.PullRequests, 3)
+		assert.Equal(t, "2025-04-02T00:00:00Z", export.PullRequests[0].MergedAt)
+		assert.InDelta(t, 5, export.UnattributedInUSD, 0.0001)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		var buf bytes.Buffer
+		empty := AnalyzePRCosts(nil, false)
+		require.NoError(t, NewPRGenerator(&buf, FormatTable, 0, zerolog.New(io.Discard)).Generate(&empty))
+		assert.Contains(t, buf.String(), "No runs were resolved to pull requests.")
+	})
+}
@@ -0,0 +1,290 @@
+package reports
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// QueueStats are the queue latency percentiles of a set of jobs, the time from
+// job creation until a runner picked it up
+type QueueStats struct {
+	Key          string
+	Jobs         int
+	QueuedTotal  time.Duration
+	P50          time.Duration
+	P90          time.Duration
+	P95          time.Duration
+	P99          time.Duration
+	Max          time.Duration
+	ExecutionP50 time.Duration // median time from start to completion, for comparison
+}
+
+// QueueLatency is the queue latency of all jobs, per runner type, per runner group and
+// per hour of the day in UTC. Runner types and groups are sorted by p95, slowest first,
+// and hours by hour of the day.
+type QueueLatency struct {
+	QueueStats
+	Runners      []QueueStats
+	RunnerGroups []QueueStats
+	Hours        []QueueStats
+}
+
+// AnalyzeQueue computes queue latency percentiles of the jobs that ran on a runner.
+// Skipped jobs and jobs that never got a runner are left out.
+func AnalyzeQueue(jobs []JobDetails, shouldObfuscate bool) QueueLatency {
+	flattened := FlattenJobs(jobs, shouldObfuscate)
+
+	var all queueAccumulator
+	runners := make(map[string]*queueAccumulator)
+	groups := make(map[string]*queueAccumulator)
+	hours := make(map[string]*queueAccumulator)
+	add := func(groups map[string]*queueAccumulator, key string, job JobDetails) {
+		acc, ok := groups[key]
+		if !ok {
+			acc = &queueAccumulator{}
+			groups[key] = acc
+		}
+		acc.add(job)
+	}
+
+	for i, job := range jobs {
+		if job.Job == nil || job.Job.StartedAt == nil || job.Job.CreatedAt == nil ||
+			job.Job.RunnerID == nil || job.Job.GetConclusion() == "skipped" {
+			continue
+		}
+		all.add(job)
+		add(runners, dimensionValue(DimensionRunner, job, flattened[i]), job)
+		add(groups, derefOr(flattened[i].JobRunnerGroupName, "unknown"), job)
+		add(hours, fmt.Sprintf("%02d:00", job.Job.CreatedAt.UTC().Hour()), job)
+	}
+
+	latency := QueueLatency{
+		QueueStats:   all.stats(""),
+		Runners:      queueStatsOf(runners),
+		RunnerGroups: queueStatsOf(groups),
+		Hours:        queueStatsOf(hours),
+	}
+	sort.Slice(latency.Hours, func(i, j int) bool {
+		return latency.Hours[i].Key < latency.Hours[j].Key
+	})
+	return latency
+}
+
+type queueAccumulator struct {
+	queued     []time.Duration
+	executions []time.Duration
+}
+
+func (a *queueAccumulator) add(job JobDetails) {
+	a.queued = append(a.queued, job.QueueDuration)
+	a.executions = append(a.executions, job.ExecutionDuration)
+}
+
+func (a *queueAccumulator) stats(key string) QueueStats {
+	s := QueueStats{
+		Key:          key,
+		Jobs:         len(a.queued),
+		P50:          percentile(a.queued, 50),
+		P90:          percentile(a.queued, 90),
+		P95:          percentile(a.queued, 95),
+		P99:          percentile(a.queued, 99),
+		Max:          percentile(a.queued, 100),
+		ExecutionP50: percentile(a.executions, 50),
+	}
+	for _, d := range a.queued {
+		s.QueuedTotal += d
+	}
+	return s
+}
+
+// queueStatsOf returns the stats of every group, slowest p95 first
+func queueStatsOf(groups map[string]*queueAccumulator) []QueueStats {
+	stats := make([]QueueStats, 0, len(groups))
+	for key, acc := range groups {
+		stats = append(stats, acc.stats(key))
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].P95 != stats[j].P95 {
+			return stats[i].P95 > stats[j].P95
+		}
+		return stats[i].Key < stats[j].Key
+	})
+	return stats
+}
+
+// QueueGenerator writes queue latency as a plain text table, Markdown or JSON
+type QueueGenerator struct {
+	out    io.Writer
+	format string
+	topN   int
+	logger zerolog.Logger
+}
+
+// NewQueueGenerator creates a new queue latency generator that writes to out.
+// Runner types and groups are limited to the topN slowest, hours of the day are all listed.
+func NewQueueGenerator(out io.Writer, format string, topN int, logger zerolog.Logger) *QueueGenerator {
+	if topN <= 0 {
+		topN = DefaultTopN
+	}
+	return &QueueGenerator{
+		out:    out,
+		format: format,
+		topN:   topN,
+		logger: logger,
+	}
+}
+
+func (g *QueueGenerator) Generate(latency *QueueLatency) error {
+	g.logger.Debug().Str("format", g.format).Msg("Generating queue latency")
+
+	var err error
+	switch g.format {
+	case FormatTable:
+		_, err = io.WriteString(g.out, g.renderTable(latency))
+	case FormatMarkdown:
+		_, err = io.WriteString(g.out, g.renderMarkdown(latency))
+	case FormatJSON:
+		enc := json.NewEncoder(g.out)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(exportQueue(latency))
+	default:
+		return fmt.Errorf("unsupported queue format %q, must be one of: %s, %s, %s", g.format, FormatTable, FormatMarkdown, FormatJSON)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s queue latency: %w", g.format, err)
+	}
+	return nil
+}
+
+// formatQueueDuration rounds durations to the second, e.g. "1m5s"
+func formatQueueDuration(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
+
+func (g *QueueGenerator) renderTable(latency *QueueLatency) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Queue latency (%d jobs)\n", latency.Jobs)
+	if latency.Jobs == 0 {
+		b.WriteString("No jobs with start times.\n")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "p50 %s, p95 %s, max %s, %s queued in total\n",
+		formatQueueDuration(latency.P50), formatQueueDuration(latency.P95), formatQueueDuration(latency.Max), formatQueueDuration(latency.QueuedTotal))
+
+	g.writeTableSection(&b, "By runner type", topOf(latency.Runners, g.topN))
+	g.writeTableSection(&b, "By runner group", topOf(latency.RunnerGroups, g.topN))
+	g.writeTableSection(&b, "By hour of day (UTC)", latency.Hours)
+	return b.String()
+}
+
+func (g *QueueGenerator) writeTableSection(b *strings.Builder, title string, stats []QueueStats) {
+	const nameWidth = 24
+	fmt.Fprintf(b, "\n%s\n", title)
+	fmt.Fprintf(b, "%s %6s %9s %9s %9s %9s %9s %9s\n", padRight("", nameWidth), "Jobs", "p50", "p90", "p95", "p99", "Max", "Exec p50")
+	for _, s := range stats {
+		fmt.Fprintf(b, "%s %6d %9s %9s %9s %9s %9s %9s\n",
+			padRight(truncate(s.Key, nameWidth), nameWidth),
+			s.Jobs,
+			formatQueueDuration(s.P50),
+			formatQueueDuration(s.P90),
+			formatQueueDuration(s.P95),
+			formatQueueDuration(s.P99),
+			formatQueueDuration(s.Max),
+			formatQueueDuration(s.ExecutionP50))
+	}
+}
+
+func (g *QueueGenerator) renderMarkdown(latency *QueueLatency) string {
+	var b strings.Builder
+
+	b.WriteString("## GitHub Actions queue latency\n\n")
+	if latency.Jobs == 0 {
+		b.WriteString("No jobs with start times.\n")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "%d jobs waited %s for a runner at the median and %s at p95, %s in total.\n\n",
+		latency.Jobs, formatQueueDuration(latency.P50), formatQueueDuration(latency.P95), formatQueueDuration(latency.QueuedTotal))
+
+	g.writeMarkdownSection(&b, "By runner type", "Runner", topOf(latency.Runners, g.topN))
+	g.writeMarkdownSection(&b, "By runner group", "Runner group", topOf(latency.RunnerGroups, g.topN))
+	g.writeMarkdownSection(&b, "By hour of day (UTC)", "Hour", latency.Hours)
+	return b.String()
+}
+
+func (g *QueueGenerator) writeMarkdownSection(b *strings.Builder, title, column string, stats []QueueStats) {
+	fmt.Fprintf(b, "### %s\n\n", title)
+	fmt.Fprintf(b, "| %s | Jobs | p50 | p90 | p95 | p99 | Max | Execution p50 |\n", column)
+	b.WriteString("| --- | ---: | ---: | ---: | ---: | ---: | ---: | ---: |\n")
+	for _, s := range stats {
+		fmt.Fprintf(b, "| %s | %d | %s | %s | %s | %s | %s | %s |\n",
+			escapeMarkdownCell(s.Key),
+			s.Jobs,
+			formatQueueDuration(s.P50),
+			formatQueueDuration(s.P90),
+			formatQueueDuration(s.P95),
+			formatQueueDuration(s.P99),
+			formatQueueDuration(s.Max),
+			formatQueueDuration(s.ExecutionP50))
+	}
+	b.WriteString("\n")
+}
+
+// QueueExport is the document written by the json queue format. Durations are in seconds.
+type QueueExport struct {
+	SchemaVersion string             `json:"schema_version"`
+	Total         QueueStatsExport   `json:"total"`
+	Runners       []QueueStatsExport `json:"runners"`
+	RunnerGroups  []QueueStatsExport `json:"runner_groups"`
+	Hours         []QueueStatsExport `json:"hours"`
+}
+
+// QueueStatsExport is the queue latency of a group in a JSON queue export
+type QueueStatsExport struct {
+	Key          string  `json:"key,omitempty"`
+	Jobs         int     `json:"jobs"`
+	QueuedTotal  float64 `json:"queued_total"`
+	P50          float64 `json:"p50"`
+	P90          float64 `json:"p90"`
+	P95          float64 `json:"p95"`
+	P99          float64 `json:"p99"`
+	Max          float64 `json:"max"`
+	ExecutionP50 float64 `json:"execution_p50"`
+}
+
+func exportQueue(latency *QueueLatency) QueueExport {
+	export := func(stats []QueueStats) []QueueStatsExport {
+		exported := make([]QueueStatsExport, len(stats))
+		for i, s := range stats {
+			exported[i] = exportQueueStats(s)
+		}
+		return exported
+	}
+	return QueueExport{
+		SchemaVersion: ExportSchemaVersion,
+		Total:         exportQueueStats(latency.QueueStats),
+		Runners:       export(latency.Runners),
+		RunnerGroups:  export(latency.RunnerGroups),
+		Hours:         export(latency.Hours),
+	}
+}
+
+func exportQueueStats(s QueueStats) QueueStatsExport {
+	return QueueStatsExport{
+		Key:          s.Key,
+		Jobs:         s.Jobs,
+		QueuedTotal:  s.QueuedTotal.Seconds(),
+		P50:          s.P50.Seconds(),
+		P90:          s.P90.Seconds(),
+		P95:          s.P95.Seconds(),
+		P99:          s.P99.Seconds(),
+		Max:          s.Max.Seconds(),
+		ExecutionP50: s.ExecutionP50.Seconds(),
+	}
+}
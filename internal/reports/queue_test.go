@@ -0,0 +1,111 @@
+package reports
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// queueTestJob returns a job created at created that waited queued for a runner and then ran for five minutes
+func queueTestJob(runner, group string, created time.Time, queued time.Duration) JobDetails {
+	job := aggregateTestJob("CI", runner, "success", created, queued+5*time.Minute, 1)
+	job.Job.CreatedAt = &github.Timestamp{Time: created}
+	job.Job.StartedAt = &github.Timestamp{Time: created.Add(queued)}
+	job.Job.CompletedAt = &github.Timestamp{Time: created.Add(queued + 5*time.Minute)}
+	job.Job.RunnerID = github.Int64(1)
+	job.Job.RunnerGroupName = github.String(group)
+	job.QueueDuration = queued
+	job.ExecutionDuration = 5 * time.Minute
+	return job
+}
+
+func setupQueueTestData() []JobDetails {
+	morning := time.Date(2025, 4, 1, 9, 15, 0, 0, time.UTC)
+	evening := time.Date(2025, 4, 1, 18, 40, 0, 0, time.UTC)
+	skipped := queueTestJob("UBUNTU", "GitHub Actions", morning, 0)
+	skipped.Job.Conclusion = github.String("skipped")
+	return []JobDetails{
+		queueTestJob("UBUNTU", "GitHub Actions", morning, 5*time.Second),
+		queueTestJob("UBUNTU", "GitHub Actions", morning, 15*time.Second),
+		queueTestJob("UBUNTU", "GitHub Actions", evening, 10*time.Second),
+		queueTestJob("LINUX_16_CORE", "larger-runners", morning, 4*time.Minute),
+		queueTestJob("LINUX_16_CORE", "larger-runners", evening, 10*time.Minute),
+		skipped,
+	}
+}
+
+func TestAnalyzeQueue(t *testing.T) {
+	latency := AnalyzeQueue(setupQueueTestData(), false)
+
+	assert.Equal(t, 5, latency.Jobs)
+	assert.Equal(t, 15*time.Second, latency.P50)
+	assert.Equal(t, 10*time.Minute, latency.Max)
+	assert.Equal(t, 14*time.Minute+30*time.Second, latency.QueuedTotal)
+	assert.Equal(t, 5*time.Minute, latency.ExecutionP50)
+
+	require.Len(t, latency.Runners, 2)
+	assert.Equal(t, "LINUX_16_CORE", latency.Runners[0].Key, "the slowest runner type comes first")
+	assert.Equal(t, 10*time.Minute, latency.Runners[0].P95)
+	assert.Equal(t, "UBUNTU", latency.Runners[1].Key)
+	assert.Equal(t, 10*time.Second, latency.Runners[1].P50)
+
+	require.Len(t, latency.RunnerGroups, 2)
+	assert.Equal(t, "larger-runners", latency.RunnerGroups[0].Key)
+
+	require.Len(t, latency.Hours, 2)
+	assert.Equal(t, "09:00", latency.Hours[0].Key)
+	assert.Equal(t, 3, latency.Hours[0].Jobs)
+	assert.Equal(t, "18:00", latency.Hours[1].Key)
+}
+
+func TestQueueGenerator(t *testing.T) {
+	latency := AnalyzeQueue(setupQueueTestData(), false)
+
+	t.Run("Table", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewQueueGenerator(&buf, FormatTable, 1, zerolog.New(io.Discard)).Generate(&latency))
+		out := buf.String()
+		assert.Contains(t, out, "Queue latency (5 jobs)")
+		assert.Contains(t, out, "By runner type")
+		assert.Contains(t, out, "LINUX_16_CORE")
+		assert.NotContains(t, out, "UBUNTU", "runner types are limited to the top N")
+		assert.Contains(t, out, "18:00")
+	})
+
+	t.Run("Markdown", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewQueueGenerator(&buf, FormatMarkdown, 0, zerolog.New(io.Discard)).Generate(&latency))
+		out := buf.String()
+		assert.Contains(t, out, "## GitHub Actions queue latency")
+		assert.Contains(t, out, "| larger-runners | 2 | 4m0s | 10m0s | 10m0s | 10m0s | 10m0s | 5m0s |")
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewQueueGenerator(&buf, FormatJSON, 0, zerolog.New(io.Discard)).Generate(&latency))
+		var export QueueExport
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &export))
+		assert.Equal(t, ExportSchemaVersion, export.SchemaVersion)
+		assert.Equal(t, 5, export.Total.Jobs)
+		assert.InDelta(t, 600, export.Total.Max, 0.0001)
+		assert.Len(t, export.Hours, 2)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		var buf bytes.Buffer
+		empty := AnalyzeQueue(nil, false)
+		require.NoError(t, NewQueueGenerator(&buf, FormatTable, 0, zerolog.New(io.Discard)).Generate(&empty))
+		assert.Contains(t, buf.String(), "No jobs with start times.")
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		assert.Error(t, NewQueueGenerator(io.Discard, "csv", 0, zerolog.New(io.Discard)).Generate(&latency))
+	})
+}
@@ -0,0 +1,221 @@
+package reports
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Access settings of reports on the server
+const (
+	AccessLink    = "link"    // anyone with the report URL can view it
+	AccessPrivate = "private" // only its creator can view it
+)
+
+// ReportAccesses are the supported access settings of reports
+var ReportAccesses = []string{AccessLink, AccessPrivate}
+
+// ReportInfo describes a report generated on the server. It is what the server lists,
+// and what the local report history records.
+type ReportInfo struct {
+	ReportID    string     `json:"report_id"`
+	Repo        string     `json:"repo,omitempty"` // owner/name, empty when the report spans several repositories
+	From        time.Time  `json:"from"`           // creation time of the earliest job
+	To          time.Time  `json:"to"`             // creation time of the latest job
+	JobCount    int        `json:"job_count"`
+	CreatedAt   time.Time  `json:"created_at"`
+	RefreshedAt *time.Time `json:"refreshed_at,omitempty"` // when its data was last replaced or appended to
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`   // nil when the report doesn't expire
+	Access      string     `json:"access,omitempty"`
+	Pending     bool       `json:"pending,omitempty"` // the upload wasn't committed yet
+	URL         string     `json:"url,omitempty"`
+	// ViewToken opens a private report in a browser for a limited time, as the token
+	// query parameter of its URL
+	ViewToken string `json:"view_token,omitempty"`
+}
+
+// NewReportInfo describes a report of jobs, created at createdAt
+func NewReportInfo(reportID string, jobs []JobDetails, createdAt time.Time) ReportInfo {
+	info := ReportInfo{ReportID: reportID, JobCount: len(jobs), CreatedAt: createdAt, Access: AccessLink}
+	for i, job := range jobs {
+		repo := job.Repo.GetOwner().GetLogin() + "/" + job.Repo.GetName()
+		if i == 0 {
+			info.Repo = repo
+		} else if repo != info.Repo {
+			info.Repo = ""
+		}
+
+		created := job.Job.GetCreatedAt().Time
+		if created.IsZero() {
+			continue
+		}
+		if info.From.IsZero() || created.Before(info.From) {
+			info.From = created
+		}
+		if created.After(info.To) {
+			info.To = created
+		}
+	}
+	return info
+}
+
+// Expired returns whether the report expired at now
+func (r ReportInfo) Expired(now time.Time) bool {
+	return r.ExpiresAt != nil && !now.Before(*r.ExpiresAt)
+}
+
+// SortReportInfos sorts reports from the most recently created
+func SortReportInfos(infos []ReportInfo) {
+	sort.SliceStable(infos, func(i, j int) bool {
+		return infos[i].CreatedAt.After(infos[j].CreatedAt)
+	})
+}
+
+// ReportInfoGenerator writes reports generated on the server as a plain text table or JSON
+type ReportInfoGenerator struct {
+	out    io.Writer
+	format string
+	now    time.Time
+	logger zerolog.Logger
+}
+
+// NewReportInfoGenerator creates a new report info generator that writes to out,
+// showing expiry relative to now
+func NewReportInfoGenerator(out io.Writer, format string, now time.Time, logger zerolog.Logger) *ReportInfoGenerator {
+	return &ReportInfoGenerator{
+		out:    out,
+		format: format,
+		now:    now,
+		logger: logger,
+	}
+}
+
+// Generate writes a list of reports
+func (g *ReportInfoGenerator) Generate(infos []ReportInfo) error {
+	g.logger.Debug().Str("format", g.format).Int("report_count", len(infos)).Msg("Generating report list")
+
+	var err error
+	switch g.format {
+	case FormatTable:
+		_, err = io.WriteString(g.out, g.renderTable(infos))
+	case FormatJSON:
+		if infos == nil {
+			infos = []ReportInfo{}
+		}
+		err = g.writeJSON(ReportListExport{SchemaVersion: ExportSchemaVersion, Reports: infos})
+	default:
+		return fmt.Errorf("unsupported report list format %q, must be one of: %s, %s", g.format, FormatTable, FormatJSON)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s report list: %w", g.format, err)
+	}
+	return nil
+}
+
+// GenerateDetails writes the details of a report
+func (g *ReportInfoGenerator) GenerateDetails(info ReportInfo) error {
+	var err error
+	switch g.format {
+	case FormatTable:
+		_, err = io.WriteString(g.out, g.renderDetails(info))
+	case FormatJSON:
+		err = g.writeJSON(info)
+	default:
+		return fmt.Errorf("unsupported report format %q, must be one of: %s, %s", g.format, FormatTable, FormatJSON)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s report: %w", g.format, err)
+	}
+	return nil
+}
+
+func (g *ReportInfoGenerator) writeJSON(v any) error {
+	enc := json.NewEncoder(g.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func (g *ReportInfoGenerator) renderTable(infos []ReportInfo) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Reports (%d)\n", len(infos))
+	if len(infos) == 0 {
+		b.WriteString("No reports were generated on the server.\n")
+		return b.String()
+	}
+
+	const idWidth, repoWidth = 36, 30
+	fmt.Fprintf(&b, "\n%s  %s  %-23s %6s  %-16s  %-16s  %s\n", padRight("Report", idWidth), padRight("Repo", repoWidth), "Window", "Jobs", "Created", "Expires", "URL")
+	for _, info := range infos {
+		fmt.Fprintf(&b, "%s  %s  %-23s %6d  %-16s  %-16s  %s\n",
+			padRight(truncate(info.ReportID, idWidth), idWidth),
+			padRight(truncate(g.repo(info), repoWidth), repoWidth),
+			formatWindow(info),
+			info.JobCount,
+			info.CreatedAt.UTC().Format("2006-01-02 15:04"),
+			g.expiry(info, "2006-01-02 15:04"),
+			g.url(info))
+	}
+	return b.String()
+}
+
+func (g *ReportInfoGenerator) renderDetails(info ReportInfo) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Report   %s\n", info.ReportID)
+	fmt.Fprintf(&b, "Repo     %s\n", g.repo(info))
+	fmt.Fprintf(&b, "Window   %s\n", formatWindow(info))
+	fmt.Fprintf(&b, "Jobs     %d\n", info.JobCount)
+	fmt.Fprintf(&b, "Created  %s\n", info.CreatedAt.UTC().Format("2006-01-02 15:04 MST"))
+	if info.RefreshedAt != nil {
+		fmt.Fprintf(&b, "Updated  %s\n", info.RefreshedAt.UTC().Format("2006-01-02 15:04 MST"))
+	}
+	fmt.Fprintf(&b, "Expires  %s\n", g.expiry(info, "2006-01-02 15:04 MST"))
+	if info.Access != "" {
+		fmt.Fprintf(&b, "Access   %s\n", info.Access)
+	}
+	fmt.Fprintf(&b, "URL      %s\n", g.url(info))
+	return b.String()
+}
+
+func (g *ReportInfoGenerator) repo(info ReportInfo) string {
+	if info.Repo == "" {
+		return "(several)"
+	}
+	return info.Repo
+}
+
+func (g *ReportInfoGenerator) expiry(info ReportInfo, layout string) string {
+	switch {
+	case info.ExpiresAt == nil:
+		return "never"
+	case info.Expired(g.now):
+		return "expired"
+	}
+	return info.ExpiresAt.UTC().Format(layout)
+}
+
+func (g *ReportInfoGenerator) url(info ReportInfo) string {
+	if info.Pending {
+		return "(upload incomplete)"
+	}
+	return info.URL
+}
+
+// formatWindow formats the days a report's jobs were created on
+func formatWindow(info ReportInfo) string {
+	if info.From.IsZero() {
+		return "-"
+	}
+	return info.From.UTC().Format("2006-01-02") + ".." + info.To.UTC().Format("2006-01-02")
+}
+
+// ReportListExport is the document written by the json report list format
+type ReportListExport struct {
+	SchemaVersion string       `json:"schema_version"`
+	Reports       []ReportInfo `json:"reports"`
+}
@@ -0,0 +1,128 @@
+package reports
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// reportInfoTestJob returns a job of testowner/testrepo created at created
+func reportInfoTestJob(created time.Time) JobDetails {
+	job := aggregateTestJob("CI", "UBUNTU", "success", created, time.Minute, 0.008)
+	job.Job.CreatedAt = &github.Timestamp{Time: created}
+	return job
+}
+
+func TestNewReportInfo(t *testing.T) {
+	first := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+	jobs := []JobDetails{reportInfoTestJob(first.Add(48 * time.Hour)), reportInfoTestJob(first)}
+	createdAt := time.Date(2025, 4, 5, 9, 0, 0, 0, time.UTC)
+
+	info := NewReportInfo("report-1", jobs, createdAt)
+	assert.Equal(t, "testowner/testrepo", info.Repo)
+	assert.Equal(t, first, info.From)
+	assert.Equal(t, first.Add(48*time.Hour), info.To)
+	assert.Equal(t, 2, info.JobCount)
+	assert.Equal(t, AccessLink, info.Access)
+	assert.False(t, info.Expired(createdAt))
+
+	// Reports spanning several repositories have no repository
+	other := reportInfoTestJob(first)
+	other.Repo.Name = github.String("other")
+	assert.Empty(t, NewReportInfo("report-2", append(jobs, other), createdAt).Repo)
+
+	expiresAt := createdAt.Add(time.Hour)
+	info.ExpiresAt = &expiresAt
+	assert.False(t, info.Expired(createdAt))
+	assert.True(t, info.Expired(expiresAt))
+}
+
+func TestReportHistory(t *testing.T) {
+	history := NewReportHistory(filepath.Join(t.TempDir(), "gh-octoscope", "history.json"))
+
+	// A history that was never written is empty
+	infos, err := history.Reports()
+	require.NoError(t, err)
+	assert.Empty(t, infos)
+
+	created := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, history.Record(ReportInfo{ReportID: "report-1", CreatedAt: created, URL: "https://app/report/report-1"}))
+	require.NoError(t, history.Record(ReportInfo{ReportID: "report-2", CreatedAt: created.Add(time.Hour)}))
+	// Recording a report again replaces it
+	require.NoError(t, history.Record(ReportInfo{ReportID: "report-1", CreatedAt: created, Access: AccessPrivate}))
+
+	infos, err = history.Reports()
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+	assert.Equal(t, "report-2", infos[0].ReportID)
+	assert.Equal(t, AccessPrivate, infos[1].Access)
+
+	info, found, err := history.Find("report-1")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, created, info.CreatedAt)
+
+	require.NoError(t, history.Forget("report-1"))
+	_, found, err = history.Find("report-1")
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestReportInfoGenerator(t *testing.T) {
+	now := time.Date(2025, 4, 10, 12, 0, 0, 0, time.UTC)
+	expired, later := now.Add(-time.Hour), now.Add(72*time.Hour)
+	infos := []ReportInfo{
+		{ReportID: "report-1", Repo: "testowner/testrepo", From: now.AddDate(0, 0, -9), To: now.AddDate(0, 0, -2), JobCount: 12, CreatedAt: now.AddDate(0, 0, -1), ExpiresAt: &later, Access: AccessLink, URL: "https://app/report/report-1"},
+		{ReportID: "report-2", JobCount: 3, CreatedAt: now.AddDate(0, 0, -30), ExpiresAt: &expired, Access: AccessPrivate, URL: "https://app/report/report-2"},
+		{ReportID: "report-3", Repo: "testowner/testrepo", CreatedAt: now, Pending: true},
+	}
+
+	t.Run("Table", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewReportInfoGenerator(&buf, FormatTable, now, zerolog.New(io.Discard)).Generate(infos))
+		out := buf.String()
+		assert.Contains(t, out, "Reports (3)")
+		assert.Contains(t, out, "2025-04-01..2025-04-08")
+		assert.Contains(t, out, "2025-04-13 12:00")
+		assert.Contains(t, out, "(several)")
+		assert.Contains(t, out, "expired")
+		assert.Contains(t, out, "(upload incomplete)")
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewReportInfoGenerator(&buf, FormatTable, now, zerolog.New(io.Discard)).Generate(nil))
+		assert.Contains(t, buf.String(), "No reports were generated on the server.")
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewReportInfoGenerator(&buf, FormatJSON, now, zerolog.New(io.Discard)).Generate(infos))
+		var export ReportListExport
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &export))
+		assert.Equal(t, ExportSchemaVersion, export.SchemaVersion)
+		require.Len(t, export.Reports, 3)
+		assert.Equal(t, later, *export.Reports[0].ExpiresAt)
+	})
+
+	t.Run("Details", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewReportInfoGenerator(&buf, FormatTable, now, zerolog.New(io.Discard)).GenerateDetails(infos[1]))
+		out := buf.String()
+		assert.Contains(t, out, "Access   private")
+		assert.Contains(t, out, "Expires  expired")
+		assert.Contains(t, out, "URL      https://app/report/report-2")
+	})
+
+	t.Run("UnsupportedFormat", func(t *testing.T) {
+		assert.ErrorContains(t, NewReportInfoGenerator(io.Discard, FormatCSV, now, zerolog.New(io.Discard)).Generate(infos), "unsupported report list format")
+	})
+}
@@ -13,9 +13,13 @@ type Generator interface {
 }
 
 type ReportData struct {
-	Jobs          []JobDetails `json:"jobs"`
-	Totals        TotalCosts   `json:"totals"`
-	ObfuscateData bool         `json:"-"`
+	Jobs          []JobDetails   `json:"jobs"`
+	Totals        TotalCosts     `json:"totals"`
+	ObfuscateData bool           `json:"-"`
+	GroupBy       []Dimension    `json:"-"` // Optional dimensions for an additional grouped breakdown
+	Trend         *Trend         `json:"-"` // Optional cost trend, rendered as an extra section when set
+	Budgets       []BudgetStatus `json:"-"` // Optional budget statuses; alerting budgets are shown in every report
+	Forecast      *Forecast      `json:"-"` // Optional spend forecast to the end of the billing cycle
 }
 
 type JobDetails struct {
@@ -25,9 +29,13 @@ type JobDetails struct {
 	Job                  *github.WorkflowJob `json:"job,omitempty"`
 	JobDuration          time.Duration       `json:"job_duration"`
 	RoundedUpJobDuration time.Duration       `json:"rounded_up_job_duration"`
+	QueueDuration        time.Duration       `json:"queue_duration"`     // from creation to start, waiting for a runner
+	ExecutionDuration    time.Duration       `json:"execution_duration"` // from start to completion
 	PricePerMinuteInUSD  float64             `json:"price_per_minute_in_usd"`
 	BillableInUSD        float64             `json:"billable_in_usd"`
 	Runner               string              `json:"runner,omitempty"`
+	PullRequest          *github.PullRequest `json:"pull_request,omitempty"` // pull request the run belongs to, if any
+	Tags                 map[string]string   `json:"tags,omitempty"`         // cost tags from repository custom properties and the workflow file
 }
 
 type TotalCosts struct {
@@ -37,46 +45,51 @@ type TotalCosts struct {
 }
 
 type FlatJobDetails struct {
-	OwnerName                         *string  `json:"owner_name,omitempty"`
-	RepoID                            *int64   `json:"repo_id,omitempty"`
-	RepoName                          *string  `json:"repo_name,omitempty"`
-	WorkflowID                        *int64   `json:"workflow_id,omitempty"`
-	WorkflowName                      *string  `json:"workflow_name,omitempty"`
-	WorkflowRunID                     *int64   `json:"workflow_run_id,omitempty"`
-	WorkflowRunName                   *string  `json:"workflow_run_name,omitempty"`
-	HeadBranch                        *string  `json:"head_branch,omitempty"`
-	HeadSHA                           *string  `json:"head_sha,omitempty"`
-	WorkflowRunRunNumber              *int     `json:"workflow_run_run_number,omitempty"`
-	WorkflowRunRunAttempt             *int     `json:"workflow_run_run_attempt,omitempty"`
-	WorkflowRunEvent                  *string  `json:"workflow_run_event,omitempty"`
-	WorkflowRunDisplayTitle           *string  `json:"workflow_run_display_title,omitempty"`
-	WorkflowRunStatus                 *string  `json:"workflow_run_status,omitempty"`
-	WorkflowRunConclusion             *string  `json:"workflow_run_conclusion,omitempty"`
-	WorkflowRunCreatedAt              *string  `json:"workflow_run_created_at,omitempty"`
-	WorkflowRunUpdatedAt              *string  `json:"workflow_run_updated_at,omitempty"`
-	WorkflowRunRunStartedAt           *string  `json:"workflow_run_run_started_at,omitempty"`
-	ActorLogin                        *string  `json:"actor_login,omitempty"`
-	JobID                             *int64   `json:"job_id,omitempty"`
-	JobName                           *string  `json:"job_name,omitempty"`
-	JobStatus                         *string  `json:"job_status,omitempty"`
-	JobConclusion                     *string  `json:"job_conclusion,omitempty"`
-	JobCreatedAt                      *string  `json:"job_created_at,omitempty"`
-	JobStartedAt                      *string  `json:"job_started_at,omitempty"`
-	JobCompletedAt                    *string  `json:"job_completed_at,omitempty"`
-	JobSteps                          *string  `json:"job_steps,omitempty"`
-	JobLabels                         *string  `json:"job_labels,omitempty"`
-	JobRunnerID                       *int64   `json:"job_runner_id,omitempty"`
-	JobRunnerName                     *string  `json:"job_runner_name,omitempty"`
-	JobRunnerGroupID                  *int64   `json:"job_runner_group_id,omitempty"`
-	JobRunnerGroupName                *string  `json:"job_runner_group_name,omitempty"`
-	JobRunAttempt                     *int64   `json:"job_run_attempt,omitempty"`
-	JobDurationSeconds                *float64 `json:"job_duration,omitempty"`
-	JobDurationHumanReadable          *string  `json:"job_duration_human_readable,omitempty"`
-	RoundedUpJobDurationSeconds       *float64 `json:"rounded_up_job_duration,omitempty"`
-	RoundedUpJobDurationHumanReadable *string  `json:"rounded_up_job_duration_human_readable,omitempty"`
-	PricePerMinuteInUSD               *float64 `json:"price_per_minute_in_usd,omitempty"`
-	BillableInUSD                     *float64 `json:"billable_in_usd,omitempty"`
-	Runner                            *string  `json:"runner,omitempty"`
+	OwnerName                         *string           `json:"owner_name,omitempty"`
+	RepoID                            *int64            `json:"repo_id,omitempty"`
+	RepoName                          *string           `json:"repo_name,omitempty"`
+	WorkflowID                        *int64            `json:"workflow_id,omitempty"`
+	WorkflowName                      *string           `json:"workflow_name,omitempty"`
+	WorkflowRunID                     *int64            `json:"workflow_run_id,omitempty"`
+	WorkflowRunName                   *string           `json:"workflow_run_name,omitempty"`
+	HeadBranch                        *string           `json:"head_branch,omitempty"`
+	HeadSHA                           *string           `json:"head_sha,omitempty"`
+	WorkflowRunRunNumber              *int              `json:"workflow_run_run_number,omitempty"`
+	WorkflowRunRunAttempt             *int              `json:"workflow_run_run_attempt,omitempty"`
+	WorkflowRunEvent                  *string           `json:"workflow_run_event,omitempty"`
+	WorkflowRunDisplayTitle           *string           `json:"workflow_run_display_title,omitempty"`
+	WorkflowRunStatus                 *string           `json:"workflow_run_status,omitempty"`
+	WorkflowRunConclusion             *string           `json:"workflow_run_conclusion,omitempty"`
+	WorkflowRunCreatedAt              *string           `json:"workflow_run_created_at,omitempty"`
+	WorkflowRunUpdatedAt              *string           `json:"workflow_run_updated_at,omitempty"`
+	WorkflowRunRunStartedAt           *string           `json:"workflow_run_run_started_at,omitempty"`
+	ActorLogin                        *string           `json:"actor_login,omitempty"`
+	JobID                             *int64            `json:"job_id,omitempty"`
+	JobName                           *string           `json:"job_name,omitempty"`
+	JobStatus                         *string           `json:"job_status,omitempty"`
+	JobConclusion                     *string           `json:"job_conclusion,omitempty"`
+	JobCreatedAt                      *string           `json:"job_created_at,omitempty"`
+	JobStartedAt                      *string           `json:"job_started_at,omitempty"`
+	JobCompletedAt                    *string           `json:"job_completed_at,omitempty"`
+	JobSteps                          *string           `json:"job_steps,omitempty"`
+	JobLabels                         *string           `json:"job_labels,omitempty"`
+	JobRunnerID                       *int64            `json:"job_runner_id,omitempty"`
+	JobRunnerName                     *string           `json:"job_runner_name,omitempty"`
+	JobRunnerGroupID                  *int64            `json:"job_runner_group_id,omitempty"`
+	JobRunnerGroupName                *string           `json:"job_runner_group_name,omitempty"`
+	JobRunAttempt                     *int64            `json:"job_run_attempt,omitempty"`
+	JobDurationSeconds                *float64          `json:"job_duration,omitempty"`
+	JobDurationHumanReadable          *string           `json:"job_duration_human_readable,omitempty"`
+	RoundedUpJobDurationSeconds       *float64          `json:"rounded_up_job_duration,omitempty"`
+	RoundedUpJobDurationHumanReadable *string           `json:"rounded_up_job_duration_human_readable,omitempty"`
+	PricePerMinuteInUSD               *float64          `json:"price_per_minute_in_usd,omitempty"`
+	BillableInUSD                     *float64          `json:"billable_in_usd,omitempty"`
+	Runner                            *string           `json:"runner,omitempty"`
+	QueueDurationSeconds              *float64          `json:"queue_duration,omitempty"`
+	ExecutionDurationSeconds          *float64          `json:"execution_duration,omitempty"`
+	PullRequestNumber                 *int              `json:"pull_request_number,omitempty"`
+	PullRequestAuthor                 *string           `json:"pull_request_author,omitempty"`
+	Tags                              map[string]string `json:"tags,omitempty"`
 }
 
 func FlattenJobs(jobs []JobDetails, shouldObfuscate bool) []FlatJobDetails {
@@ -147,7 +160,13 @@ func FlattenJob(job JobDetails, shouldObfuscate bool) FlatJobDetails {
 		workflowRunDisplayTitle = *job.WorkflowRun.DisplayTitle
 	}
 
+	pullRequestAuthor := ""
+	if job.PullRequest != nil {
+		pullRequestAuthor = job.PullRequest.GetUser().GetLogin()
+	}
+
 	if shouldObfuscate {
+		pullRequestAuthor = obfuscateString(pullRequestAuthor)
 		ownerName = obfuscateString(ownerName)
 		repoName = obfuscateString(repoName)
 		actorLogin = obfuscateString(actorLogin)
@@ -202,7 +221,109 @@ func FlattenJob(job JobDetails, shouldObfuscate bool) FlatJobDetails {
 		PricePerMinuteInUSD:               float64Ptr(job.PricePerMinuteInUSD),
 		BillableInUSD:                     float64Ptr(job.BillableInUSD),
 		Runner:                            strPtr(job.Runner),
+		QueueDurationSeconds:              float64Ptr(job.QueueDuration.Seconds()),
+		ExecutionDurationSeconds:          float64Ptr(job.ExecutionDuration.Seconds()),
+		PullRequestNumber:                 intPtr(pullRequestNumber(job)),
+		PullRequestAuthor:                 strPtr(pullRequestAuthor),
+		Tags:                              job.Tags,
+	}
+}
+
+// flatTimeLayout is the layout FlattenJob formats timestamps with, that of time.Time.String
+const flatTimeLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+// UnflattenJob reverses FlattenJob, e.g. for jobs uploaded to the Octoscope server.
+// Fields a flat job doesn't carry, like URLs, are left empty.
+func UnflattenJob(fj FlatJobDetails) JobDetails {
+	timestamp := func(s *string) *github.Timestamp {
+		if s == nil {
+			return nil
+		}
+		// Drop the monotonic clock reading of times taken from time.Now
+		value, _, _ := strings.Cut(*s, " m=")
+		t, err := time.Parse(flatTimeLayout, value)
+		if err != nil {
+			return nil
+		}
+		return &github.Timestamp{Time: t}
+	}
+	seconds := func(f *float64) time.Duration {
+		if f == nil {
+			return 0
+		}
+		return time.Duration(*f * float64(time.Second))
+	}
+	login := func(s *string) *github.User {
+		if s == nil {
+			return nil
+		}
+		return &github.User{Login: s}
+	}
+
+	job := JobDetails{
+		Repo:     &github.Repository{ID: fj.RepoID, Name: fj.RepoName, Owner: login(fj.OwnerName)},
+		Workflow: &github.Workflow{ID: fj.WorkflowID, Name: fj.WorkflowName},
+		WorkflowRun: &github.WorkflowRun{
+			ID:           fj.WorkflowRunID,
+			Name:         fj.WorkflowRunName,
+			HeadBranch:   fj.HeadBranch,
+			HeadSHA:      fj.HeadSHA,
+			RunNumber:    fj.WorkflowRunRunNumber,
+			RunAttempt:   fj.WorkflowRunRunAttempt,
+			Event:        fj.WorkflowRunEvent,
+			DisplayTitle: fj.WorkflowRunDisplayTitle,
+			Status:       fj.WorkflowRunStatus,
+			Conclusion:   fj.WorkflowRunConclusion,
+			CreatedAt:    timestamp(fj.WorkflowRunCreatedAt),
+			UpdatedAt:    timestamp(fj.WorkflowRunUpdatedAt),
+			RunStartedAt: timestamp(fj.WorkflowRunRunStartedAt),
+			Actor:        login(fj.ActorLogin),
+		},
+		Job: &github.WorkflowJob{
+			ID:              fj.JobID,
+			Name:            fj.JobName,
+			Status:          fj.JobStatus,
+			Conclusion:      fj.JobConclusion,
+			CreatedAt:       timestamp(fj.JobCreatedAt),
+			StartedAt:       timestamp(fj.JobStartedAt),
+			CompletedAt:     timestamp(fj.JobCompletedAt),
+			RunnerID:        fj.JobRunnerID,
+			RunnerName:      fj.JobRunnerName,
+			RunnerGroupID:   fj.JobRunnerGroupID,
+			RunnerGroupName: fj.JobRunnerGroupName,
+			RunAttempt:      fj.JobRunAttempt,
+		},
+		JobDuration:          seconds(fj.JobDurationSeconds),
+		RoundedUpJobDuration: seconds(fj.RoundedUpJobDurationSeconds),
+		QueueDuration:        seconds(fj.QueueDurationSeconds),
+		ExecutionDuration:    seconds(fj.ExecutionDurationSeconds),
+		Runner:               derefOr(fj.Runner, ""),
+		Tags:                 fj.Tags,
+	}
+	if fj.PricePerMinuteInUSD != nil {
+		job.PricePerMinuteInUSD = *fj.PricePerMinuteInUSD
+	}
+	if fj.BillableInUSD != nil {
+		job.BillableInUSD = *fj.BillableInUSD
+	}
+	if fj.JobSteps != nil {
+		_ = json.Unmarshal([]byte(*fj.JobSteps), &job.Job.Steps)
+	}
+	if fj.JobLabels != nil {
+		job.Job.Labels = strings.Split(*fj.JobLabels, "; ")
+	}
+	if fj.PullRequestNumber != nil {
+		job.PullRequest = &github.PullRequest{Number: fj.PullRequestNumber, User: login(fj.PullRequestAuthor)}
+	}
+	return job
+}
+
+// pullRequestNumber returns the number of the job's pull request, nil when it has none
+func pullRequestNumber(job JobDetails) *int {
+	if job.PullRequest == nil {
+		return nil
 	}
+	return job.PullRequest.Number
 }
 
 func obfuscateString(input string) string {
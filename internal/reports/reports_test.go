@@ -1,13 +1,19 @@
 package reports
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/go-github/v62/github"
 	"github.com/rs/zerolog"
@@ -92,6 +98,46 @@ func setupTestData() *ReportData {
 	}
 }
 
+// setupTrendTestData adds a cheaper job from two weeks earlier to the test data,
+// and builds a weekly trend from it
+func setupTrendTestData() *ReportData {
+	data := setupTestData()
+	previous := data.Jobs[0]
+	run := *previous.WorkflowRun
+	run.CreatedAt = &github.Timestamp{Time: run.CreatedAt.AddDate(0, 0, -14)}
+	previous.WorkflowRun = &run
+	previous.BillableInUSD = 0.1
+	data.Jobs = append(data.Jobs, previous)
+	data.Totals.BillableInUSD = 0.3
+
+	trend := BuildTrend(data.Jobs, TrendConfig{Period: DimensionWeek}, false)
+	data.Trend = &trend
+	return data
+}
+
+// setupBudgetReportData adds a breached and an ok budget to the test data
+func setupBudgetReportData() *ReportData {
+	data := setupTestData()
+	budgets := []Budget{
+		{Name: "Tight budget", Scope: BudgetScopeRepo, Match: "testowner/testrepo", Period: DimensionMonth, Amount: 0.1, Thresholds: DefaultBudgetThresholds},
+		{Name: "Loose budget", Scope: BudgetScopeOrg, Period: DimensionMonth, Amount: 1000, Thresholds: DefaultBudgetThresholds},
+	}
+	// Evaluate right after the job so it always falls in the current period
+	data.Budgets = EvaluateBudgets(data.Jobs, budgets, jobTime(data.Jobs[0]).Add(time.Minute))
+	return data
+}
+
+// setupForecastReportData returns the forecast test jobs with a forecast built on 2025-04-21
+func setupForecastReportData() *ReportData {
+	jobs := setupForecastTestData()
+	forecast, _ := BuildForecast(jobs, ForecastConfig{}, time.Date(2025, 4, 21, 0, 0, 0, 0, time.UTC), false)
+	return &ReportData{
+		Jobs:     jobs,
+		Totals:   TotalCosts{BillableInUSD: 240},
+		Forecast: &forecast,
+	}
+}
+
 func TestCSVGenerator(t *testing.T) {
 	t.Run("BasicGenerator", func(t *testing.T) {
 		// Create a temporary directory for test outputs
@@ -219,6 +265,519 @@ func TestCSVGenerator(t *testing.T) {
 		assert.True(t, foundReport, "Report file with formatted name not found")
 		assert.True(t, foundTotals, "Totals file with formatted name not found")
 	})
+
+	t.Run("GroupedGenerator", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		reportPath := filepath.Join(tmpDir, "report.csv")
+		totalsPath := filepath.Join(tmpDir, "totals.csv")
+
+		data := setupTestData()
+		data.GroupBy = []Dimension{DimensionWorkflow, DimensionRunner}
+
+		generator := NewCSVGenerator(reportPath, totalsPath, zerolog.New(io.Discard))
+		require.NoError(t, generator.Generate(data))
+		assert.Equal(t, filepath.Join(tmpDir, "report_grouped.csv"), generator.GetGroupedPath())
+
+		content, err := os.ReadFile(generator.GetGroupedPath())
+		require.NoError(t, err)
+		lines := splitLines(strings.TrimSpace(string(content)))
+		require.Len(t, lines, 2)
+		assert.Equal(t, "workflow,runner,billable_in_usd,billable_minutes,job_count,duration_p50_seconds,duration_p95_seconds,share_percent", lines[0])
+		assert.Equal(t, "Test Workflow,UBUNTU,0.200,25,1,1500,1500,100.00", lines[1])
+	})
+
+	t.Run("TrendGenerator", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		reportPath := filepath.Join(tmpDir, "report.csv")
+		totalsPath := filepath.Join(tmpDir, "totals.csv")
+
+		generator := NewCSVGenerator(reportPath, totalsPath, zerolog.New(io.Discard))
+		require.NoError(t, generator.Generate(setupTrendTestData()))
+		assert.Equal(t, filepath.Join(tmpDir, "report_trend.csv"), generator.GetTrendPath())
+
+		content, err := os.ReadFile(generator.GetTrendPath())
+		require.NoError(t, err)
+		lines := splitLines(strings.TrimSpace(string(content)))
+		require.Len(t, lines, 4, "expected a header and three weeks, including an empty one")
+		assert.Equal(t, "period,period_start,billable_in_usd,billable_minutes,job_count,previous_in_usd,change_percent,trailing_average_in_usd,change_from_average_percent,drivers", lines[0])
+		assert.True(t, strings.HasSuffix(lines[1], ",0.100,25,1,0.000,,0.000,,"), lines[1])
+		assert.True(t, strings.HasSuffix(lines[3], ",0.200,25,1,0.000,,0.050,300.00,Test Workflow +$0.20"), lines[3])
+	})
+
+	t.Run("NoTrend", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		generator := NewCSVGenerator(filepath.Join(tmpDir, "report.csv"), filepath.Join(tmpDir, "totals.csv"), zerolog.New(io.Discard))
+		require.NoError(t, generator.Generate(setupTestData()))
+		assert.NoFileExists(t, generator.GetTrendPath())
+		assert.NoFileExists(t, generator.GetBudgetsPath())
+		assert.NoFileExists(t, generator.GetForecastPath())
+
+		totals, err := os.ReadFile(generator.GetTotalsPath())
+		require.NoError(t, err)
+		assert.True(t, strings.HasSuffix(strings.TrimSpace(string(totals)), ",0.200,,,,,,"), "forecast columns are empty without a forecast")
+	})
+
+	t.Run("ForecastGenerator", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		totalsPath := filepath.Join(tmpDir, "totals.csv")
+		generator := NewCSVGenerator(filepath.Join(tmpDir, "report.csv"), totalsPath, zerolog.New(io.Discard))
+		require.NoError(t, generator.Generate(setupForecastReportData()))
+		assert.Equal(t, filepath.Join(tmpDir, "report_forecast.csv"), generator.GetForecastPath())
+
+		totals, err := os.ReadFile(totalsPath)
+		require.NoError(t, err)
+		lines := splitLines(strings.TrimSpace(string(totals)))
+		require.Len(t, lines, 2)
+		assert.True(t, strings.HasSuffix(lines[0], ",forecast_model,forecast_cycle_end,forecast_actual_in_usd,forecast_in_usd,forecast_lower_in_usd,forecast_upper_in_usd"), lines[0])
+		assert.True(t, strings.HasSuffix(lines[1], ",weekday,2025-04-30,240.000,360.000,360.000,360.000"), lines[1])
+
+		content, err := os.ReadFile(generator.GetForecastPath())
+		require.NoError(t, err)
+		lines = splitLines(strings.TrimSpace(string(content)))
+		require.Len(t, lines, 5, "expected a header, the total, one repository and two runners")
+		assert.Equal(t, "scope,key,actual_in_usd,forecast_in_usd,lower_in_usd,upper_in_usd", lines[0])
+		assert.Equal(t, "total,,240.000,360.000,360.000,360.000", lines[1])
+		assert.Equal(t, "repo,testowner/testrepo,240.000,360.000,360.000,360.000", lines[2])
+		assert.Equal(t, "runner,UBUNTU,200.000,300.000,300.000,300.000", lines[3])
+		assert.Equal(t, "runner,MACOS,40.000,60.000,60.000,60.000", lines[4])
+	})
+
+	t.Run("BudgetsGenerator", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		generator := NewCSVGenerator(filepath.Join(tmpDir, "report.csv"), filepath.Join(tmpDir, "totals.csv"), zerolog.New(io.Discard))
+		require.NoError(t, generator.Generate(setupBudgetReportData()))
+		assert.Equal(t, filepath.Join(tmpDir, "report_budgets.csv"), generator.GetBudgetsPath())
+
+		content, err := os.ReadFile(generator.GetBudgetsPath())
+		require.NoError(t, err)
+		lines := splitLines(strings.TrimSpace(string(content)))
+		require.Len(t, lines, 3, "expected a header and every budget, including ok ones")
+		assert.Equal(t, "name,scope,match,period,period_start,period_end,amount_in_usd,spent_in_usd,projected_in_usd,spent_percent,threshold,state", lines[0])
+		assert.True(t, strings.HasPrefix(lines[1], "Tight budget,repo,testowner/testrepo,month,"), lines[1])
+		assert.Contains(t, lines[1], ",0.100,0.200,", lines[1])
+		assert.True(t, strings.HasSuffix(lines[1], ",200.00,100,breached"), lines[1])
+		assert.True(t, strings.HasSuffix(lines[2], ",0.02,0,ok"), lines[2])
+	})
+}
+
+func TestHTMLGenerator(t *testing.T) {
+	t.Run("SelfContained", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		reportPath := filepath.Join(tmpDir, "report.html")
+
+		generator := NewHTMLGenerator(reportPath, zerolog.New(io.Discard))
+		require.NoError(t, generator.Generate(setupTestData()))
+		assert.Equal(t, reportPath, generator.GetPath())
+
+		content, err := os.ReadFile(reportPath)
+		require.NoError(t, err)
+		html := string(content)
+
+		// Summary, chart, breakdowns and job table are all rendered
+		assert.Contains(t, html, "Total cost")
+		assert.Contains(t, html, "$0.20")
+		assert.Contains(t, html, "Cost over time")
+		assert.Contains(t, html, "<svg")
+		assert.Contains(t, html, "Workflows")
+		assert.Contains(t, html, "Runners")
+		assert.Contains(t, html, "Branches")
+		assert.Contains(t, html, "Actors")
+		assert.Contains(t, html, `<table id="jobs">`)
+		assert.Contains(t, html, "Test Workflow")
+		assert.Contains(t, html, "testactor")
+
+		// No external assets are referenced
+		assert.NotContains(t, html, "<link")
+		assert.NotContains(t, html, "src=\"http")
+		assert.NotContains(t, html, "href=\"http")
+	})
+
+	t.Run("Obfuscated", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		reportPath := filepath.Join(tmpDir, "report.html")
+
+		data := setupTestData()
+		data.ObfuscateData = true
+
+		generator := NewHTMLGenerator(reportPath, zerolog.New(io.Discard))
+		require.NoError(t, generator.Generate(data))
+
+		content, err := os.ReadFile(reportPath)
+		require.NoError(t, err)
+		assert.NotContains(t, string(content), "testactor")
+		assert.Contains(t, string(content), "tes******")
+	})
+
+	t.Run("Trend", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		reportPath := filepath.Join(tmpDir, "report.html")
+
+		generator := NewHTMLGenerator(reportPath, zerolog.New(io.Discard))
+		require.NoError(t, generator.Generate(setupTrendTestData()))
+
+		content, err := os.ReadFile(reportPath)
+		require.NoError(t, err)
+		assert.Contains(t, string(content), "Cost trend by week")
+		assert.Contains(t, string(content), "vs 4-week average")
+		assert.Contains(t, string(content), "Test Workflow &#43;$0.20")
+	})
+
+	t.Run("Forecast", func(t *testing.T) {
+		reportPath := filepath.Join(t.TempDir(), "report.html")
+		require.NoError(t, NewHTMLGenerator(reportPath, zerolog.New(io.Discard)).Generate(setupForecastReportData()))
+
+		content, err := os.ReadFile(reportPath)
+		require.NoError(t, err)
+		assert.Contains(t, string(content), "Forecast 2025-04-30")
+		assert.Contains(t, string(content), "$240.00 so far, $360.00 by 2025-04-30 (90% range $360.00–$360.00, weekday model)")
+		assert.Contains(t, string(content), "<h3>Runner types</h3>")
+		assert.NotContains(t, string(content), "<h3>Repositories</h3>", "a single repository is not broken down")
+		assert.Contains(t, string(content), `<td>UBUNTU</td><td class="num">$200.00</td><td class="num">$300.00</td>`)
+	})
+
+	t.Run("Budgets", func(t *testing.T) {
+		reportPath := filepath.Join(t.TempDir(), "report.html")
+		require.NoError(t, NewHTMLGenerator(reportPath, zerolog.New(io.Discard)).Generate(setupBudgetReportData()))
+
+		content, err := os.ReadFile(reportPath)
+		require.NoError(t, err)
+		assert.Contains(t, string(content), "Budget alerts")
+		assert.Contains(t, string(content), "<td>Tight budget</td>")
+		assert.Contains(t, string(content), `<td class="budget-breached">breached</td>`)
+		assert.NotContains(t, string(content), "Loose budget", "only alerting budgets are shown")
+	})
+
+	t.Run("FormattedGenerator", func(t *testing.T) {
+		tmpDir := t.TempDir()
+
+		generator := NewHTMLGeneratorWithFormat(tmpDir, "testowner", "testrepo", "test-report-id", zerolog.New(io.Discard))
+		require.NoError(t, generator.Generate(setupTestData()))
+
+		assert.Contains(t, generator.GetPath(), "_testowner_testrepo_test-report-id_report.html")
+		content, err := os.ReadFile(generator.GetPath())
+		require.NoError(t, err)
+		assert.Contains(t, string(content), "testowner/testrepo")
+	})
+}
+
+func TestJSONGenerator(t *testing.T) {
+	logger := zerolog.New(io.Discard)
+
+	t.Run("JSON", func(t *testing.T) {
+		var buf bytes.Buffer
+		generator := NewJSONGenerator(&buf, FormatJSON, "testowner", "testrepo", "test-report-id", logger)
+		require.NoError(t, generator.Generate(setupTestData()))
+
+		var export JSONExport
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &export))
+		assert.Equal(t, ExportSchemaVersion, export.SchemaVersion)
+		assert.Equal(t, "test-report-id", export.Totals.ReportID)
+		assert.Equal(t, 1, export.Totals.JobCount)
+		assert.Equal(t, 25.0, export.Totals.BillableMinutes)
+		assert.Equal(t, 0.2, export.Totals.BillableInUSD)
+		require.Len(t, export.Jobs, 1)
+		assert.Equal(t, "Test Job", derefStr(export.Jobs[0].JobName))
+	})
+
+	t.Run("NDJSON", func(t *testing.T) {
+		var buf bytes.Buffer
+		generator := NewJSONGenerator(&buf, FormatNDJSON, "testowner", "testrepo", "test-report-id", logger)
+		require.NoError(t, generator.Generate(setupTestData()))
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		require.Len(t, lines, 2)
+
+		var job NDJSONJobRecord
+		require.NoError(t, json.Unmarshal([]byte(lines[0]), &job))
+		assert.Equal(t, RecordTypeJob, job.RecordType)
+		assert.Equal(t, ExportSchemaVersion, job.SchemaVersion)
+		assert.Equal(t, "Test Workflow", derefStr(job.WorkflowName))
+
+		var totals NDJSONTotalsRecord
+		require.NoError(t, json.Unmarshal([]byte(lines[1]), &totals))
+		assert.Equal(t, RecordTypeTotals, totals.RecordType)
+		assert.Equal(t, 0.2, totals.BillableInUSD)
+	})
+
+	t.Run("FormattedGenerator", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		generator := NewJSONGeneratorWithFormat(tmpDir, FormatNDJSON, "testowner", "testrepo", "test-report-id", logger)
+		require.NoError(t, generator.Generate(setupTestData()))
+
+		assert.Contains(t, generator.GetPath(), "_testowner_testrepo_test-report-id_report.ndjson")
+		assert.FileExists(t, generator.GetPath())
+	})
+
+	t.Run("Grouped", func(t *testing.T) {
+		data := setupTestData()
+		data.GroupBy = []Dimension{DimensionWorkflow, DimensionRunner}
+
+		var buf bytes.Buffer
+		generator := NewJSONGenerator(&buf, FormatJSON, "testowner", "testrepo", "", logger)
+		require.NoError(t, generator.Generate(data))
+
+		var export JSONExport
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &export))
+		require.NotNil(t, export.Grouped)
+		assert.Equal(t, data.GroupBy, export.Grouped.Dimensions)
+		require.Len(t, export.Grouped.Groups, 1)
+		assert.Equal(t, []string{"Test Workflow", "UBUNTU"}, export.Grouped.Groups[0].Keys)
+		assert.Equal(t, 1500.0, export.Grouped.Groups[0].DurationP50Seconds)
+
+		buf.Reset()
+		generator = NewJSONGenerator(&buf, FormatNDJSON, "testowner", "testrepo", "", logger)
+		require.NoError(t, generator.Generate(data))
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		require.Len(t, lines, 3)
+		assert.Contains(t, lines[1], `"record_type":"group"`)
+	})
+
+	t.Run("Budgets", func(t *testing.T) {
+		data := setupBudgetReportData()
+
+		var buf bytes.Buffer
+		require.NoError(t, NewJSONGenerator(&buf, FormatJSON, "", "", "", logger).Generate(data))
+		var export JSONExport
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &export))
+		require.Len(t, export.Budgets, 2)
+		assert.Equal(t, "Tight budget", export.Budgets[0].Name)
+		assert.Equal(t, BudgetBreached, export.Budgets[0].State)
+		assert.Equal(t, BudgetOK, export.Budgets[1].State)
+
+		buf.Reset()
+		require.NoError(t, NewJSONGenerator(&buf, FormatNDJSON, "", "", "", logger).Generate(data))
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		require.Len(t, lines, 4)
+		var budget NDJSONBudgetRecord
+		require.NoError(t, json.Unmarshal([]byte(lines[1]), &budget))
+		assert.Equal(t, RecordTypeBudget, budget.RecordType)
+		assert.Equal(t, "Tight budget", budget.Name)
+		assert.Contains(t, lines[3], `"record_type":"totals"`)
+	})
+
+	t.Run("UnsupportedFormat", func(t *testing.T) {
+		generator := NewJSONGenerator(io.Discard, "xml", "", "", "", logger)
+		assert.Error(t, generator.Generate(setupTestData()))
+	})
+}
+
+func TestMarkdownGenerator(t *testing.T) {
+	logger := zerolog.New(io.Discard)
+
+	t.Run("Summary", func(t *testing.T) {
+		var buf bytes.Buffer
+		generator := NewMarkdownGenerator(&buf, 5, "testowner", "testrepo", "test-report-id", logger)
+		require.NoError(t, generator.Generate(setupTestData()))
+
+		md := buf.String()
+		assert.Contains(t, md, "## GitHub Actions cost report: testowner/testrepo")
+		assert.Contains(t, md, "| $0.20 | 25 | 1 |")
+		assert.Contains(t, md, "### Top 5 workflows")
+		assert.Contains(t, md, "| Test Workflow | $0.20 | 25 | 1 | 25m0s | 25m0s | 100.0% |")
+		assert.Contains(t, md, "### Top 5 jobs")
+		assert.Contains(t, md, "| Test Workflow / Test Job |")
+		assert.Contains(t, md, "### Runner types")
+		assert.Contains(t, md, "| UBUNTU |")
+		assert.Contains(t, md, "no data for the week before")
+	})
+
+	t.Run("WeekOverWeek", func(t *testing.T) {
+		data := setupTestData()
+		previous := data.Jobs[0]
+		run := *previous.WorkflowRun
+		run.CreatedAt = &github.Timestamp{Time: run.CreatedAt.AddDate(0, 0, -8)}
+		previous.WorkflowRun = &run
+		previous.BillableInUSD = 0.1
+		data.Jobs = append(data.Jobs, previous)
+
+		var buf bytes.Buffer
+		generator := NewMarkdownGenerator(&buf, 5, "testowner", "testrepo", "", logger)
+		require.NoError(t, generator.Generate(data))
+		assert.Contains(t, buf.String(), "$0.20 vs $0.10 the week before (+100.0%)")
+	})
+
+	t.Run("TopNLimit", func(t *testing.T) {
+		data := setupTestData()
+		for i := 0; i < 3; i++ {
+			job := data.Jobs[0]
+			job.Workflow = &github.Workflow{ID: github.Int64(int64(i)), Name: github.String("Workflow " + string(rune('A'+i)))}
+			data.Jobs = append(data.Jobs, job)
+		}
+
+		var buf bytes.Buffer
+		generator := NewMarkdownGenerator(&buf, 2, "", "", "", logger)
+		require.NoError(t, generator.Generate(data))
+
+		section := strings.SplitN(buf.String(), "### Top 2 workflows", 2)[1]
+		section = strings.SplitN(section, "###", 2)[0]
+		assert.Equal(t, 4, strings.Count(section, "\n|"), "expected header, separator and two rows")
+	})
+
+	t.Run("Trend", func(t *testing.T) {
+		var buf bytes.Buffer
+		generator := NewMarkdownGenerator(&buf, 5, "", "", "", logger)
+		require.NoError(t, generator.Generate(setupTrendTestData()))
+
+		md := buf.String()
+		assert.Contains(t, md, "### Cost trend by week")
+		assert.Contains(t, md, "| Period | Cost | Minutes | Jobs | vs previous | vs 4-week average | Drivers |")
+		assert.Contains(t, md, "| $0.00 | 0 | 0 | -100.0% | -100.0% | Test Workflow -$0.10 |")
+		assert.Contains(t, md, "| $0.20 | 25 | 1 | n/a | +300.0% | Test Workflow +$0.20 |")
+	})
+
+	t.Run("Forecast", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewMarkdownGenerator(&buf, 5, "", "", "", logger).Generate(setupForecastReportData()))
+
+		md := buf.String()
+		assert.Contains(t, md, "**Forecast** for the billing cycle: $240.00 so far, $360.00 by 2025-04-30 (90% range $360.00–$360.00, weekday model)")
+		assert.NotContains(t, md, "### Forecast by repository")
+		assert.Contains(t, md, "### Forecast by runner type")
+		assert.Contains(t, md, "| UBUNTU | $200.00 | $300.00 | $300.00–$300.00 |")
+		assert.Contains(t, md, "| MACOS | $40.00 | $60.00 | $60.00–$60.00 |")
+	})
+
+	t.Run("Budgets", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewMarkdownGenerator(&buf, 5, "", "", "", logger).Generate(setupBudgetReportData()))
+
+		md := buf.String()
+		assert.Contains(t, md, "### Budget alerts")
+		assert.Regexp(t, `\| Tight budget \| \d{4}-\d{2} \| \$0\.20 \| \$[\d.]+ \| \$0\.10 \| 200\.0% \| \*\*breached\*\* \|`, md)
+		assert.NotContains(t, md, "Loose budget")
+	})
+
+	t.Run("EscapesPipes", func(t *testing.T) {
+		assert.Equal(t, "a\\|b", escapeMarkdownCell("a|b"))
+	})
+}
+
+func TestTerminalGenerator(t *testing.T) {
+	logger := zerolog.New(io.Discard)
+
+	t.Run("Summary", func(t *testing.T) {
+		var buf bytes.Buffer
+		generator := NewTerminalGenerator(&buf, TerminalConfig{
+			Width:     80,
+			NoColor:   true,
+			OwnerName: "testowner",
+			RepoName:  "testrepo",
+		}, logger)
+		require.NoError(t, generator.Generate(setupTestData()))
+
+		out := buf.String()
+		assert.Contains(t, out, "GitHub Actions usage summary: testowner/testrepo")
+		assert.Contains(t, out, "$0.20")
+		assert.Contains(t, out, "Billable minutes  25")
+		assert.Contains(t, out, "Jobs              1 (0 failed, 0 cancelled)")
+		assert.Contains(t, out, "Wasted            $0.00 (0.0% of cost)")
+		assert.Contains(t, out, "Top workflows")
+		assert.Contains(t, out, "Top runners")
+		assert.Contains(t, out, "Top branches")
+		assert.Contains(t, out, "Top actors")
+		assert.NotContains(t, out, "\x1b[", "no ANSI escape codes expected with NoColor")
+	})
+
+	t.Run("WidthAware", func(t *testing.T) {
+		data := setupTestData()
+		data.Jobs[0].Workflow = &github.Workflow{ID: github.Int64(1), Name: github.String(strings.Repeat("very long workflow name ", 5))}
+
+		for _, width := range []int{50, 60, 120} {
+			var buf bytes.Buffer
+			generator := NewTerminalGenerator(&buf, TerminalConfig{Width: width, NoColor: true}, logger)
+			require.NoError(t, generator.Generate(data))
+
+			for _, line := range strings.Split(buf.String(), "\n") {
+				assert.LessOrEqual(t, utf8.RuneCountInString(line), width, "line exceeds width %d: %q", width, line)
+			}
+		}
+	})
+
+	t.Run("Trend", func(t *testing.T) {
+		for _, width := range []int{50, 120} {
+			var buf bytes.Buffer
+			generator := NewTerminalGenerator(&buf, TerminalConfig{Width: width, NoColor: true}, logger)
+			require.NoError(t, generator.Generate(setupTrendTestData()))
+
+			out := buf.String()
+			assert.Contains(t, out, "Trend by week")
+			assert.Contains(t, out, "Cost     ▄▁█")
+			assert.Contains(t, out, "Minutes  █▁█")
+			for _, line := range strings.Split(out, "\n") {
+				assert.LessOrEqual(t, utf8.RuneCountInString(line), width, "line exceeds width %d: %q", width, line)
+			}
+		}
+	})
+
+	t.Run("Budgets", func(t *testing.T) {
+		for _, width := range []int{50, 120} {
+			var buf bytes.Buffer
+			generator := NewTerminalGenerator(&buf, TerminalConfig{Width: width, NoColor: true}, logger)
+			require.NoError(t, generator.Generate(setupBudgetReportData()))
+
+			out := buf.String()
+			assert.Contains(t, out, "Budget alerts")
+			assert.Regexp(t, `Tight[^\n]+\$0\.20[^\n]+\$0\.10\s+200\.0% breached`, out)
+			assert.NotContains(t, out, "Loose budget")
+			for _, line := range strings.Split(out, "\n") {
+				assert.LessOrEqual(t, utf8.RuneCountInString(line), width, "line exceeds width %d: %q", width, line)
+			}
+		}
+	})
+
+	t.Run("Forecast", func(t *testing.T) {
+		for _, width := range []int{50, 120} {
+			var buf bytes.Buffer
+			generator := NewTerminalGenerator(&buf, TerminalConfig{Width: width, NoColor: true}, logger)
+			require.NoError(t, generator.Generate(setupForecastReportData()))
+
+			out := buf.String()
+			assert.Contains(t, out, "Forecast          $360.00 by 2025-04-30")
+			assert.Contains(t, out, "Forecast range    $360.00–$360.00 (90%, weekday)")
+			assert.Contains(t, out, "Forecast by runner")
+			assert.Regexp(t, `UBUNTU\s+\$200\.00\s+\$300\.00`, out)
+			assert.NotContains(t, out, "Forecast by repository")
+			for _, line := range strings.Split(out, "\n") {
+				assert.LessOrEqual(t, utf8.RuneCountInString(line), width, "line exceeds width %d: %q", width, line)
+			}
+		}
+	})
+
+	t.Run("FailedShare", func(t *testing.T) {
+		data := setupTestData()
+		failed := data.Jobs[0]
+		job := *failed.Job
+		job.ID = github.Int64(job.GetID() + 1)
+		job.Conclusion = github.String("failure")
+		failed.Job = &job
+		data.Jobs = append(data.Jobs, failed)
+		data.Totals.BillableInUSD = 0.4
+
+		var buf bytes.Buffer
+		generator := NewTerminalGenerator(&buf, TerminalConfig{NoColor: true}, logger)
+		require.NoError(t, generator.Generate(data))
+		assert.Contains(t, buf.String(), "2 (1 failed, 0 cancelled)")
+		assert.Contains(t, buf.String(), "$0.20 (50.0% of cost)")
+	})
+
+	t.Run("TimedOutIsWasted", func(t *testing.T) {
+		// Jobs that timed out count as failed, as in the waste command
+		data := setupTestData()
+		timedOut := data.Jobs[0]
+		job := *timedOut.Job
+		job.ID = github.Int64(job.GetID() + 1)
+		job.Conclusion = github.String("timed_out")
+		timedOut.Job = &job
+		data.Jobs = append(data.Jobs, timedOut)
+		data.Totals.BillableInUSD = 0.4
+
+		var buf bytes.Buffer
+		generator := NewTerminalGenerator(&buf, TerminalConfig{NoColor: true}, logger)
+		require.NoError(t, generator.Generate(data))
+		waste := AnalyzeWaste(data.Jobs, false)
+		assert.Contains(t, buf.String(), "2 (1 failed, 0 cancelled)")
+		assert.Contains(t, buf.String(), fmt.Sprintf("%s (50.0%% of cost)", formatUSD(waste.WastedInUSD)))
+	})
 }
 
 // Mock implementation of octoscopeClient for testing
@@ -229,6 +788,9 @@ type mockOctoscopeClient struct {
 	batchCreateObfuscation bool
 	batchCreateError       error
 
+	commitManifests []*UploadManifest
+	commitError     error
+
 	deleteReportCalled bool
 	deleteReportID     string
 	deleteReportError  error
@@ -242,6 +804,11 @@ func (m *mockOctoscopeClient) BatchCreate(ctx context.Context, jobs []JobDetails
 	return m.batchCreateError
 }
 
+func (m *mockOctoscopeClient) CommitReport(ctx context.Context, manifest *UploadManifest) error {
+	m.commitManifests = append(m.commitManifests, manifest)
+	return m.commitError
+}
+
 func (m *mockOctoscopeClient) SyncJobs(ctx context.Context, jobs []JobDetails, shouldObfuscate bool) error {
 	// For tests that don't use sync, just return nil
 	return nil
@@ -289,6 +856,213 @@ func TestServerGenerator(t *testing.T) {
 	assert.False(t, mockClient.batchCreateObfuscation)
 }
 
+func TestServerGeneratorMerge(t *testing.T) {
+	mockClient := &mockOctoscopeClient{}
+	config := ServerConfig{ReportID: "weekly", ManifestDir: t.TempDir(), Merge: true}
+	require.NoError(t, NewServerGenerator(mockClient, config, zerolog.New(io.Discard)).Generate(setupTestData()))
+
+	// The commit asks the server to merge the jobs, also when the upload is resumed
+	require.Len(t, mockClient.commitManifests, 1)
+	assert.True(t, mockClient.commitManifests[0].Merge)
+	manifest, err := LoadUploadManifest(config.ManifestDir, "weekly")
+	require.NoError(t, err)
+	assert.True(t, manifest.Merge)
+}
+
+// failingOctoscopeClient fails uploading the batch starting with job failAt
+type failingOctoscopeClient struct {
+	mockOctoscopeClient
+	failAt  int64
+	mu      sync.Mutex
+	batches [][]JobDetails
+}
+
+func (m *failingOctoscopeClient) BatchCreate(ctx context.Context, jobs []JobDetails, reportID string, shouldObfuscate bool) error {
+	if jobs[0].Job.GetID() == m.failAt {
+		return errors.New("unavailable")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.batches = append(m.batches, jobs)
+	return nil
+}
+
+// useTestBatches makes uploads quick to retry, in batches of 25 jobs
+func useTestBatches(t *testing.T) {
+	delay, maxJobs := retryDelay, batchMaxJobs
+	t.Cleanup(func() { retryDelay, batchMaxJobs = delay, maxJobs })
+	retryDelay, batchMaxJobs = time.Millisecond, 25
+}
+
+// uploadTestJobs returns count jobs with IDs from 1
+func uploadTestJobs(count int) []JobDetails {
+	var jobs []JobDetails
+	for id := int64(1); id <= int64(count); id++ {
+		jobs = append(jobs, metricsTestJob(id, "CI", "completed", "success", time.Minute, 0.008))
+	}
+	return jobs
+}
+
+func TestServerGeneratorResume(t *testing.T) {
+	useTestBatches(t)
+	logger := zerolog.New(io.Discard)
+
+	data := &ReportData{Jobs: uploadTestJobs(100), ObfuscateData: true}
+	config := ServerConfig{ReportID: "report-1", ManifestDir: t.TempDir(), Upload: UploadOptions{Concurrency: 1}}
+
+	// The upload is interrupted by the second batch
+	client := &failingOctoscopeClient{failAt: 26}
+	err := NewServerGenerator(client, config, logger).Generate(data)
+	assert.ErrorContains(t, err, "failed to upload batch after 3 retries")
+	assert.Empty(t, client.commitManifests)
+	manifest, err := LoadUploadManifest(config.ManifestDir, "report-1")
+	require.NoError(t, err)
+	assert.Equal(t, 3, manifest.Pending())
+	assert.True(t, manifest.Obfuscate)
+	assert.False(t, manifest.Committed)
+
+	// Resuming sends only the missing batches, and then commits the whole report
+	config.Upload = UploadOptions{}
+	client = &failingOctoscopeClient{}
+	require.NoError(t, NewServerGenerator(client, config, logger).Resume(data.Jobs))
+	var firstIDs []int64
+	for _, batch := range client.batches {
+		firstIDs = append(firstIDs, batch[0].Job.GetID())
+	}
+	assert.ElementsMatch(t, []int64{26, 51, 76}, firstIDs)
+	require.Len(t, client.commitManifests, 1)
+	assert.Equal(t, NewUploadManifest("report-1", splitBatches(data.Jobs), true).Hashes(), client.commitManifests[0].Hashes())
+	manifest, err = LoadUploadManifest(config.ManifestDir, "report-1")
+	require.NoError(t, err)
+	assert.True(t, manifest.Committed)
+
+	err = NewServerGenerator(client, config, logger).Resume(data.Jobs)
+	assert.ErrorContains(t, err, "report report-1 was already uploaded completely")
+
+	t.Run("MissingBatches", func(t *testing.T) {
+		client := &failingOctoscopeClient{}
+		client.commitError = &MissingBatchesError{Hashes: []string{manifest.Batches[0].Hash}}
+		err := NewServerGenerator(client, config, logger).Generate(data)
+		var missing *MissingBatchesError
+		assert.ErrorAs(t, err, &missing)
+
+		// The batches the server is missing are sent again on resume
+		manifest, err := LoadUploadManifest(config.ManifestDir, "report-1")
+		require.NoError(t, err)
+		assert.Equal(t, 1, manifest.Pending())
+		assert.False(t, manifest.Batches[0].Uploaded)
+	})
+
+	t.Run("CommitUnsupported", func(t *testing.T) {
+		client := &failingOctoscopeClient{}
+		client.commitError = ErrCommitUnsupported
+		require.NoError(t, NewServerGenerator(client, config, logger).Generate(data))
+	})
+
+	t.Run("UnknownReport", func(t *testing.T) {
+		err := NewServerGenerator(client, ServerConfig{ReportID: "report-2", ManifestDir: config.ManifestDir}, logger).Resume(data.Jobs)
+		assert.ErrorContains(t, err, "no upload of report report-2 was started from this directory")
+	})
+}
+
+// throttledError asks to retry after a delay, like a response with status 429
+type throttledError struct{}
+
+func (throttledError) Error() string             { return "status=429" }
+func (throttledError) RetryAfter() time.Duration { return 20 * time.Millisecond }
+
+func TestUploadInBatches(t *testing.T) {
+	useTestBatches(t)
+	logger := zerolog.New(io.Discard)
+	jobs := uploadTestJobs(51)
+
+	var mu sync.Mutex
+	var sizes []int
+	var progress []string
+	calls, active, maxActive, failed := 0, 0, 0, false
+	err := UploadInBatches(context.Background(), jobs, func(ctx context.Context, batch []JobDetails) error {
+		mu.Lock()
+		calls++
+		active++
+		maxActive = max(maxActive, active)
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		active--
+
+		// The second batch fails once and succeeds on the retry
+		if batch[0].Job.GetID() == 26 && !failed {
+			failed = true
+			return errors.New("unavailable")
+		}
+		sizes = append(sizes, len(batch))
+		return nil
+	}, UploadOptions{Concurrency: 2, Progress: func(done, total int) {
+		progress = append(progress, fmt.Sprintf("%d/%d", done, total))
+	}}, logger)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int{25, 25, 1}, sizes)
+	assert.Equal(t, 4, calls)
+	assert.Equal(t, 2, maxActive)
+	assert.Equal(t, []string{"1/3", "2/3", "3/3"}, progress)
+
+	calls = 0
+	err = UploadInBatches(context.Background(), jobs[:1], func(ctx context.Context, batch []JobDetails) error {
+		calls++
+		return errors.New("unavailable")
+	}, UploadOptions{}, logger)
+	assert.ErrorContains(t, err, "failed to upload batch after 3 retries: unavailable")
+	assert.Equal(t, maxRetries, calls)
+
+	t.Run("RetryAfter", func(t *testing.T) {
+		// Throttled attempts wait as long as the server asked, and don't count as failures
+		calls := 0
+		start := time.Now()
+		err := UploadInBatches(context.Background(), jobs[:1], func(ctx context.Context, batch []JobDetails) error {
+			calls++
+			if calls <= maxRetries {
+				return fmt.Errorf("server returned error: %w", throttledError{})
+			}
+			return nil
+		}, UploadOptions{}, logger)
+		require.NoError(t, err)
+		assert.Equal(t, maxRetries+1, calls)
+		assert.GreaterOrEqual(t, time.Since(start), 3*throttledError{}.RetryAfter())
+	})
+
+	t.Run("BatchBytes", func(t *testing.T) {
+		defer func(maxBytes int) { batchMaxBytes = maxBytes }(batchMaxBytes)
+		content, _ := json.Marshal(FlattenJob(jobs[0], false))
+		batchMaxBytes = len(content)*2 + 1
+		batches := splitBatches(jobs[:5])
+		assert.Equal(t, []int{2, 2, 1}, []int{len(batches[0]), len(batches[1]), len(batches[2])})
+	})
+}
+
+func TestUnflattenJob(t *testing.T) {
+	job := setupTestData().Jobs[0]
+	job.Job.Steps = []*github.TaskStep{{Number: github.Int64(1), Name: github.String("Set up job")}}
+	job.PullRequest = &github.PullRequest{Number: github.Int(7), User: &github.User{Login: github.String("author")}}
+	job.QueueDuration = 90 * time.Second
+	job.Tags = map[string]string{"team": "platform"}
+
+	// Times taken from time.Now are flattened with their monotonic clock reading
+	unflattened := UnflattenJob(FlattenJob(job, false))
+	assert.True(t, job.Job.CreatedAt.Time.Equal(unflattened.Job.CreatedAt.Time))
+
+	for _, ts := range []*github.Timestamp{job.WorkflowRun.CreatedAt, job.WorkflowRun.UpdatedAt, job.WorkflowRun.RunStartedAt, job.Job.CreatedAt, job.Job.StartedAt, job.Job.CompletedAt} {
+		ts.Time = ts.Time.Round(0)
+	}
+	flat := FlattenJob(job, false)
+	unflattened = UnflattenJob(flat)
+	assert.Equal(t, flat, FlattenJob(unflattened, false))
+	assert.Equal(t, 90*time.Second, unflattened.QueueDuration)
+	assert.Equal(t, 0.2, unflattened.BillableInUSD)
+	assert.Equal(t, "Set up job", unflattened.Job.Steps[0].GetName())
+	assert.Equal(t, []string{"ubuntu-latest"}, unflattened.Job.Labels)
+}
+
 func TestFlattenJobsAndObfuscation(t *testing.T) {
 	// Create test data
 	testData := setupTestData()
@@ -2,21 +2,17 @@ package reports
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"time"
 
 	"github.com/google/uuid"
 	"github.com/rs/zerolog"
 )
 
-const (
-	batchSize  = 25 // Size of batches for server upload
-	maxRetries = 3  // Maximum number of retries for failed batch uploads
-)
-
 // Convert timestamps and avoid circular import by defining the interface we need
 type octoscopeClient interface {
 	BatchCreate(ctx context.Context, jobs []JobDetails, reportID string, shouldObfuscate bool) error
+	CommitReport(ctx context.Context, manifest *UploadManifest) error
 }
 
 // ServerGenerator generates reports on our servers
@@ -29,10 +25,13 @@ type ServerGenerator struct {
 }
 
 type ServerConfig struct {
-	AppURL    string
-	OwnerName string
-	RepoName  string
-	ReportID  string // Optional custom report ID
+	AppURL      string
+	OwnerName   string
+	RepoName    string
+	ReportID    string // Optional custom report ID
+	ManifestDir string // Optional directory upload manifests are kept in, so interrupted uploads can be resumed
+	Merge       bool   // Merge the jobs into an existing report with the same ID, instead of replacing its jobs
+	Upload      UploadOptions
 }
 
 func NewServerGenerator(client octoscopeClient, config ServerConfig, logger zerolog.Logger) *ServerGenerator {
@@ -59,45 +58,112 @@ func (g *ServerGenerator) Generate(data *ReportData) error {
 		reportID = uuid.New().String()
 	}
 
-	// Split jobs into batches
-	for i := 0; i < len(data.Jobs); i += batchSize {
-		end := i + batchSize
-		if end > len(data.Jobs) {
-			end = len(data.Jobs)
-		}
+	batches := splitBatches(data.Jobs)
+	manifest := NewUploadManifest(reportID, batches, data.ObfuscateData)
+	manifest.Merge = g.config.Merge
+	if err := g.upload(context.Background(), manifest, batches); err != nil {
+		return err
+	}
 
-		batch := data.Jobs[i:end]
+	g.logger.Debug().
+		Str("report_url", g.reportURL).
+		Msg("Report generated successfully. View your report at: " + g.reportURL)
 
-		// Retry logic for each batch
-		var err error
-		for retry := 0; retry < maxRetries; retry++ {
-			err = g.client.BatchCreate(context.Background(), batch, reportID, data.ObfuscateData)
-			if err == nil {
-				break
-			}
-			g.logger.Warn().
-				Int("retry", retry+1).
-				Int("batch", i/batchSize+1).
-				Err(err).
-				Msg("Failed to upload batch, retrying...")
-
-			if retry < maxRetries-1 {
-				time.Sleep(time.Second * time.Duration(retry+1))
+	return nil
+}
+
+// Resume continues an interrupted upload of the report, sending only the batches the
+// server didn't acknowledge. Their jobs are looked up by ID in jobs, the local data.
+func (g *ServerGenerator) Resume(jobs []JobDetails) error {
+	manifest, err := LoadUploadManifest(g.config.ManifestDir, g.reportID)
+	if err != nil {
+		return err
+	}
+	if manifest.Committed {
+		return fmt.Errorf("report %s was already uploaded completely", g.reportID)
+	}
+
+	byID := make(map[int64]JobDetails, len(jobs))
+	for _, job := range jobs {
+		byID[job.Job.GetID()] = job
+	}
+	batches := make([][]JobDetails, len(manifest.Batches))
+	for i, batch := range manifest.Batches {
+		if batch.Uploaded {
+			continue
+		}
+		for _, id := range batch.JobIDs {
+			job, ok := byID[id]
+			if !ok {
+				return fmt.Errorf("job %d of batch %d is no longer in the local data", id, i+1)
 			}
+			batches[i] = append(batches[i], job)
 		}
-		if err != nil {
-			return fmt.Errorf("failed to upload batch after %d retries: %w", maxRetries, err)
+	}
+
+	g.logger.Debug().
+		Str("report_id", g.reportID).
+		Int("pending_batches", manifest.Pending()).
+		Int("total_batches", len(manifest.Batches)).
+		Msg("Resuming upload")
+	return g.upload(context.Background(), manifest, batches)
+}
+
+// upload sends the batches of the manifest that weren't acknowledged yet, recording every
+// acknowledged batch in the manifest, and then commits the report so the server publishes it
+func (g *ServerGenerator) upload(ctx context.Context, manifest *UploadManifest, batches [][]JobDetails) error {
+	if err := g.saveManifest(manifest); err != nil {
+		return err
+	}
+
+	var pending []int
+	for i, batch := range batches {
+		if manifest.Batches[i].Uploaded {
+			continue
 		}
+		// Local data may have been refetched since the upload started
+		manifest.Batches[i].Hash = BatchHash(FlattenJobs(batch, manifest.Obfuscate))
+		pending = append(pending, i)
+	}
 
-		g.logger.Debug().
-			Int("batch", i/batchSize+1).
-			Int("total_batches", (len(data.Jobs)+batchSize-1)/batchSize).
-			Msg("Batch uploaded successfully")
+	err := uploadBatches(ctx, pending, func(ctx context.Context, i int) error {
+		return g.client.BatchCreate(ctx, batches[i], manifest.ReportID, manifest.Obfuscate)
+	}, func(i int) error {
+		manifest.Batches[i].Uploaded = true
+		return g.saveManifest(manifest)
+	}, g.config.Upload, g.logger)
+	if err != nil {
+		return err
 	}
 
-	g.logger.Debug().
-		Str("report_url", g.reportURL).
-		Msg("Report generated successfully. View your report at: " + g.reportURL)
+	if len(manifest.Batches) > 0 {
+		err := g.client.CommitReport(ctx, manifest)
+		var missing *MissingBatchesError
+		switch {
+		case errors.As(err, &missing):
+			// Send them again on resume
+			manifest.markMissing(missing.Hashes)
+			if saveErr := g.saveManifest(manifest); saveErr != nil {
+				return saveErr
+			}
+			return err
+		case errors.Is(err, ErrCommitUnsupported):
+			g.logger.Debug().Msg("Server published the batches as they were uploaded")
+		case err != nil:
+			return fmt.Errorf("failed to commit report: %w", err)
+		}
+	}
 
+	manifest.Committed = true
+	return g.saveManifest(manifest)
+}
+
+func (g *ServerGenerator) saveManifest(manifest *UploadManifest) error {
+	if g.config.ManifestDir == "" {
+		return nil
+	}
+	if err := manifest.Save(g.config.ManifestDir); err != nil {
+		return fmt.Errorf("failed to save upload manifest: %w", err)
+	}
 	return nil
 }
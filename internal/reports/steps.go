@@ -0,0 +1,85 @@
+package reports
+
+import (
+	"time"
+)
+
+// StepDetails is a step of a job with its share of the job's cost
+type StepDetails struct {
+	Number      int64
+	Name        string
+	Status      string
+	Conclusion  string
+	StartedAt   time.Time
+	CompletedAt time.Time
+	Duration    time.Duration
+	// Share of the job's cost in percent, by the step's part of the job's step durations
+	Share           float64
+	BillableMinutes float64
+	BillableInUSD   float64
+}
+
+// HasSteps reports whether any of the jobs has steps, so the step-level records are written
+func HasSteps(jobs []JobDetails) bool {
+	for _, job := range jobs {
+		if job.Job != nil && len(job.Job.Steps) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// JobSteps returns the steps of a job with the job's cost attributed to them proportionally
+// to their duration. Steps without timings, e.g. skipped ones, get no cost, unless no step
+// has timings, in which case the cost is split evenly.
+func JobSteps(job JobDetails) []StepDetails {
+	if job.Job == nil || len(job.Job.Steps) == 0 {
+		return nil
+	}
+
+	steps := make([]StepDetails, 0, len(job.Job.Steps))
+	var total time.Duration
+	for _, s := range job.Job.Steps {
+		if s == nil {
+			continue
+		}
+		step := StepDetails{
+			Number:     s.GetNumber(),
+			Name:       s.GetName(),
+			Status:     s.GetStatus(),
+			Conclusion: s.GetConclusion(),
+		}
+		if s.StartedAt != nil {
+			step.StartedAt = s.StartedAt.Time
+		}
+		if s.CompletedAt != nil {
+			step.CompletedAt = s.CompletedAt.Time
+		}
+		if !step.StartedAt.IsZero() && step.CompletedAt.After(step.StartedAt) {
+			step.Duration = step.CompletedAt.Sub(step.StartedAt)
+		}
+		total += step.Duration
+		steps = append(steps, step)
+	}
+
+	for i := range steps {
+		switch {
+		case total > 0:
+			steps[i].Share = float64(steps[i].Duration) / float64(total) * 100
+		case len(steps) > 0:
+			steps[i].Share = 100 / float64(len(steps))
+		}
+		steps[i].BillableMinutes = job.RoundedUpJobDuration.Minutes() * steps[i].Share / 100
+		steps[i].BillableInUSD = job.BillableInUSD * steps[i].Share / 100
+	}
+	return steps
+}
+
+// stepJob returns a job that stands for a single step in aggregations, carrying the step's
+// duration and share of the cost, so that steps can be grouped like jobs
+func stepJob(job JobDetails, step StepDetails) JobDetails {
+	job.JobDuration = step.Duration
+	job.RoundedUpJobDuration = time.Duration(step.BillableMinutes * float64(time.Minute))
+	job.BillableInUSD = step.BillableInUSD
+	return job
+}
@@ -0,0 +1,125 @@
+package reports
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stepsTestJob returns a job with a one minute setup step, a checkout step without timings
+// and a test step of the given length, costing cost in total
+func stepsTestJob(workflow string, test time.Duration, cost float64) JobDetails {
+	start := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+	job := aggregateTestJob(workflow, "UBUNTU", "success", start, time.Minute+test, cost)
+	job.Job.Steps = []*github.TaskStep{
+		{
+			Number:      github.Int64(1),
+			Name:        github.String("Set up job"),
+			Status:      github.String("completed"),
+			Conclusion:  github.String("success"),
+			StartedAt:   &github.Timestamp{Time: start},
+			CompletedAt: &github.Timestamp{Time: start.Add(time.Minute)},
+		},
+		{
+			Number:     github.Int64(2),
+			Name:       github.String("Run actions/checkout@v4"),
+			Status:     github.String("completed"),
+			Conclusion: github.String("skipped"),
+		},
+		{
+			Number:      github.Int64(3),
+			Name:        github.String("Run tests"),
+			Status:      github.String("completed"),
+			Conclusion:  github.String("success"),
+			StartedAt:   &github.Timestamp{Time: start.Add(time.Minute)},
+			CompletedAt: &github.Timestamp{Time: start.Add(time.Minute + test)},
+		},
+	}
+	return job
+}
+
+func TestJobSteps(t *testing.T) {
+	steps := JobSteps(stepsTestJob("CI", 3*time.Minute, 4))
+	require.Len(t, steps, 3)
+
+	assert.Equal(t, "Set up job", steps[0].Name)
+	assert.Equal(t, time.Minute, steps[0].Duration)
+	assert.InDelta(t, 25, steps[0].Share, 0.0001)
+	assert.InDelta(t, 1, steps[0].BillableInUSD, 0.0001)
+	assert.InDelta(t, 1, steps[0].BillableMinutes, 0.0001)
+
+	assert.Equal(t, "skipped", steps[1].Conclusion)
+	assert.Zero(t, steps[1].Duration)
+	assert.Zero(t, steps[1].BillableInUSD)
+
+	assert.Equal(t, int64(3), steps[2].Number)
+	assert.InDelta(t, 3, steps[2].BillableInUSD, 0.0001)
+
+	t.Run("WithoutTimings", func(t *testing.T) {
+		job := aggregateTestJob("CI", "UBUNTU", "success", time.Now(), time.Minute, 2)
+		job.Job.Steps = []*github.TaskStep{{Name: github.String("a")}, {Name: github.String("b")}}
+		steps := JobSteps(job)
+		require.Len(t, steps, 2)
+		assert.InDelta(t, 1, steps[0].BillableInUSD, 0.0001)
+		assert.InDelta(t, 1, steps[1].BillableInUSD, 0.0001)
+	})
+
+	t.Run("WithoutSteps", func(t *testing.T) {
+		assert.Empty(t, JobSteps(aggregateTestJob("CI", "UBUNTU", "success", time.Now(), time.Minute, 2)))
+	})
+}
+
+func TestAggregateBySteps(t *testing.T) {
+	jobs := []JobDetails{
+		stepsTestJob("CI", 3*time.Minute, 4),
+		stepsTestJob("Nightly", 9*time.Minute, 10),
+		// Jobs without steps are kept whole, so the total stays the same
+		aggregateTestJob("Deploy", "UBUNTU", "success", time.Now(), time.Minute, 1),
+	}
+
+	agg := Aggregate(jobs, []Dimension{DimensionStep}, false)
+	require.Len(t, agg.Groups, 4)
+	assert.Equal(t, "Run tests", agg.Groups[0].Key)
+	assert.InDelta(t, 12, agg.Groups[0].BillableInUSD, 0.0001)
+	assert.Equal(t, 2, agg.Groups[0].JobCount)
+	assert.Equal(t, 9*time.Minute, agg.Groups[0].DurationP95)
+	assert.Equal(t, "Set up job", agg.Groups[1].Key)
+	assert.InDelta(t, 2, agg.Groups[1].BillableInUSD, 0.0001)
+	assert.Equal(t, "unknown", agg.Groups[2].Key)
+
+	total := 0.0
+	for _, g := range agg.Groups {
+		total += g.BillableInUSD
+	}
+	assert.InDelta(t, 15, total, 0.0001)
+
+	byWorkflow := Aggregate(jobs, []Dimension{DimensionWorkflow, DimensionStep}, false)
+	assert.Equal(t, "Nightly / Run tests", byWorkflow.Groups[0].Key)
+	assert.InDelta(t, 9, byWorkflow.Groups[0].BillableInUSD, 0.0001)
+}
+
+func TestCSVGeneratorSteps(t *testing.T) {
+	tmpDir := t.TempDir()
+	generator := NewCSVGenerator(filepath.Join(tmpDir, "report.csv"), filepath.Join(tmpDir, "totals.csv"), zerolog.New(io.Discard))
+	require.NoError(t, generator.Generate(&ReportData{Jobs: []JobDetails{stepsTestJob("CI", 3*time.Minute, 4)}}))
+
+	content, err := os.ReadFile(generator.GetStepsPath())
+	require.NoError(t, err)
+	lines := splitLines(strings.TrimSpace(string(content)))
+	require.Len(t, lines, 4)
+	assert.Equal(t, "repo,workflow_name,workflow_run_id,job_id,job_name,job_run_attempt,runner,step_number,step_name,step_status,step_conclusion,step_started_at,step_completed_at,step_duration_seconds,share_percent,billable_minutes,billable_in_usd", lines[0])
+	assert.Equal(t, "testowner/testrepo,CI,3,4,build,0,UBUNTU,3,Run tests,completed,success,2025-04-01T12:01:00Z,2025-04-01T12:04:00Z,180,75.00,3.000,3.000", lines[3])
+
+	// Jobs without steps don't write a steps file
+	generator = NewCSVGenerator(filepath.Join(tmpDir, "nosteps.csv"), filepath.Join(tmpDir, "nosteps_totals.csv"), zerolog.New(io.Discard))
+	require.NoError(t, generator.Generate(setupTestData()))
+	assert.NoFileExists(t, generator.GetStepsPath())
+}
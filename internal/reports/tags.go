@@ -0,0 +1,105 @@
+package reports
+
+import (
+	"bufio"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v62/github"
+	"gopkg.in/yaml.v3"
+)
+
+// workflowTagEnvPrefix marks top level env keys of a workflow file that are cost tags,
+// e.g. OCTOSCOPE_TAG_COST_CENTER: payments tags its jobs with cost_center=payments
+const workflowTagEnvPrefix = "OCTOSCOPE_TAG_"
+
+// workflowTagComment matches cost tag comments in a workflow file,
+// e.g. "# octoscope: cost-center=payments, team=web"
+var workflowTagComment = regexp.MustCompile(`^\s*#\s*octoscope:\s*(.*)$`)
+
+// RepoTags returns the custom property values of a repository as cost tags.
+// Properties without a value are left out.
+func RepoTags(values []*github.CustomPropertyValue) map[string]string {
+	tags := make(map[string]string)
+	for _, v := range values {
+		if v == nil || v.Value == nil || *v.Value == "" {
+			continue
+		}
+		setTag(tags, v.PropertyName, *v.Value)
+	}
+	return tags
+}
+
+// ParseWorkflowTags returns the cost tags of a workflow file, from octoscope comments
+// and from top level env keys starting with OCTOSCOPE_TAG_. Comments override env keys.
+// A file that isn't valid YAML only has comment tags.
+func ParseWorkflowTags(content string) map[string]string {
+	tags := make(map[string]string)
+
+	var workflow struct {
+		Env map[string]string `yaml:"env"`
+	}
+	if err := yaml.Unmarshal([]byte(content), &workflow); err == nil {
+		for key, value := range workflow.Env {
+			if name, ok := strings.CutPrefix(key, workflowTagEnvPrefix); ok {
+				setTag(tags, name, value)
+			}
+		}
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		match := workflowTagComment.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue
+		}
+		for _, pair := range strings.FieldsFunc(match[1], func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+			if name, value, ok := strings.Cut(pair, "="); ok {
+				setTag(tags, name, value)
+			}
+		}
+	}
+	return tags
+}
+
+// MergeTags merges tag sets, later sets overriding earlier ones. It returns nil when
+// there are no tags.
+func MergeTags(sets ...map[string]string) map[string]string {
+	var merged map[string]string
+	for _, set := range sets {
+		for name, value := range set {
+			if merged == nil {
+				merged = make(map[string]string)
+			}
+			merged[name] = value
+		}
+	}
+	return merged
+}
+
+// TagNames returns the names of all tags of the jobs, sorted
+func TagNames(jobs []FlatJobDetails) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, job := range jobs {
+		for name := range job.Tags {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// setTag sets a tag with its name normalized to lower case, skipping empty names and values
+func setTag(tags map[string]string, name, value string) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	value = strings.TrimSpace(value)
+	if name == "" || value == "" {
+		return
+	}
+	tags[name] = value
+}
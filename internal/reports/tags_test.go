@@ -0,0 +1,110 @@
+package reports
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepoTags(t *testing.T) {
+	tags := RepoTags([]*github.CustomPropertyValue{
+		{PropertyName: "Cost-Center", Value: github.String("payments")},
+		{PropertyName: "team", Value: github.String(" web ")},
+		{PropertyName: "unset"},
+		{PropertyName: "empty", Value: github.String("")},
+		nil,
+	})
+	assert.Equal(t, map[string]string{"cost-center": "payments", "team": "web"}, tags)
+}
+
+func TestParseWorkflowTags(t *testing.T) {
+	t.Run("EnvAndComments", func(t *testing.T) {
+		tags := ParseWorkflowTags(`name: Deploy
+# octoscope: cost-center=payments, owner=web
+on: push
+env:
+  OCTOSCOPE_TAG_COST_CENTER: checkout
+  OCTOSCOPE_TAG_Environment: production
+  NODE_VERSION: 20
+jobs:
+  deploy:
+    runs-on: ubuntu-latest
+    steps:
+      - run: 'echo "# octoscope: step=ignored-because-not-a-comment"'
+        # octoscope: tier=1 broken
+`)
+		assert.Equal(t, map[string]string{
+			"cost-center": "payments", // comments override env keys
+			"cost_center": "checkout",
+			"environment": "production",
+			"owner":       "web",
+			"tier":        "1",
+		}, tags)
+	})
+
+	t.Run("InvalidYAML", func(t *testing.T) {
+		tags := ParseWorkflowTags("# octoscope: team=web\nenv: [\n")
+		assert.Equal(t, map[string]string{"team": "web"}, tags)
+	})
+
+	t.Run("NoTags", func(t *testing.T) {
+		assert.Empty(t, ParseWorkflowTags("on: push\n"))
+	})
+}
+
+func TestMergeTags(t *testing.T) {
+	assert.Nil(t, MergeTags(nil, map[string]string{}))
+	assert.Equal(t, map[string]string{"team": "web", "env": "prod"},
+		MergeTags(map[string]string{"team": "platform", "env": "prod"}, nil, map[string]string{"team": "web"}))
+}
+
+func TestTagDimension(t *testing.T) {
+	dims, err := ParseDimensions([]string{"tag:Cost_Center", "workflow"})
+	require.NoError(t, err)
+	assert.Equal(t, []Dimension{TagDimension("cost_center"), DimensionWorkflow}, dims)
+
+	_, err = ParseDimensions([]string{"tag:"})
+	assert.ErrorContains(t, err, "tag:<name>")
+
+	day := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+	payments := aggregateTestJob("CI", "UBUNTU", "success", day, time.Hour, 3)
+	payments.Tags = map[string]string{"cost_center": "payments"}
+	checkout := aggregateTestJob("CI", "UBUNTU", "success", day, time.Hour, 2)
+	checkout.Tags = map[string]string{"cost_center": "checkout"}
+	untagged := aggregateTestJob("CI", "UBUNTU", "success", day, time.Hour, 1)
+
+	agg := Aggregate([]JobDetails{payments, checkout, untagged}, dims[:1], false)
+	require.Len(t, agg.Groups, 3)
+	assert.Equal(t, "payments", agg.Groups[0].Key)
+	assert.Equal(t, "checkout", agg.Groups[1].Key)
+	assert.Equal(t, "untagged", agg.Groups[2].Key)
+}
+
+func TestCSVTagColumns(t *testing.T) {
+	day := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+	tagged := aggregateTestJob("CI", "UBUNTU", "success", day, time.Hour, 3)
+	tagged.Tags = map[string]string{"team": "web", "cost_center": "payments"}
+	untagged := aggregateTestJob("CI", "UBUNTU", "success", day, time.Hour, 1)
+
+	tmpDir := t.TempDir()
+	reportPath := filepath.Join(tmpDir, "report.csv")
+	generator := NewCSVGenerator(reportPath, filepath.Join(tmpDir, "totals.csv"), zerolog.Nop())
+	require.NoError(t, generator.Generate(&ReportData{
+		Jobs:   []JobDetails{tagged, untagged},
+		Totals: TotalCosts{},
+	}))
+
+	content, err := os.ReadFile(reportPath)
+	require.NoError(t, err)
+	lines := splitLines(string(content))
+	require.GreaterOrEqual(t, len(lines), 3)
+	assert.Regexp(t, `,PullRequestAuthor,tag:cost_center,tag:team$`, lines[0])
+	assert.Regexp(t, `,payments,web$`, lines[1])
+	assert.Regexp(t, `,,$`, lines[2])
+}
@@ -0,0 +1,396 @@
+package reports
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+	"github.com/rs/zerolog"
+)
+
+const (
+	defaultTerminalWidth = 80
+	minTerminalWidth     = 50
+)
+
+// TerminalGenerator renders a summary of the report as tables in the terminal
+type TerminalGenerator struct {
+	out       io.Writer
+	width     int
+	colorize  bool
+	topN      int
+	logger    zerolog.Logger
+	ownerName string
+	repoName  string
+}
+
+// TerminalConfig configures the terminal summary
+type TerminalConfig struct {
+	Width     int  // Terminal width in columns, defaults to 80
+	NoColor   bool // Disable colored output
+	TopN      int  // Number of rows per table, defaults to DefaultTopN
+	OwnerName string
+	RepoName  string
+}
+
+// NewTerminalGenerator creates a new terminal summary generator writing to out
+func NewTerminalGenerator(out io.Writer, config TerminalConfig, logger zerolog.Logger) *TerminalGenerator {
+	width := config.Width
+	if width <= 0 {
+		width = defaultTerminalWidth
+	}
+	if width < minTerminalWidth {
+		width = minTerminalWidth
+	}
+
+	topN := config.TopN
+	if topN <= 0 {
+		topN = DefaultTopN
+	}
+
+	return &TerminalGenerator{
+		out:       out,
+		width:     width,
+		colorize:  !config.NoColor,
+		topN:      topN,
+		logger:    logger,
+		ownerName: config.OwnerName,
+		repoName:  config.RepoName,
+	}
+}
+
+func (g *TerminalGenerator) Generate(data *ReportData) error {
+	g.logger.Debug().Msg("Generating terminal summary")
+
+	if _, err := io.WriteString(g.out, g.render(data)); err != nil {
+		return fmt.Errorf("failed to write terminal summary: %w", err)
+	}
+	return nil
+}
+
+// paint returns a function that colors text, unless color is disabled
+func (g *TerminalGenerator) paint(attrs ...color.Attribute) func(a ...interface{}) string {
+	c := color.New(attrs...)
+	if !g.colorize {
+		c.DisableColor()
+	}
+	return c.SprintFunc()
+}
+
+func (g *TerminalGenerator) render(data *ReportData) string {
+	var b strings.Builder
+	bold := g.paint(color.Bold)
+	faint := g.paint(color.Faint)
+	red := g.paint(color.FgRed)
+
+	total := data.Totals.BillableInUSD
+
+	title := "GitHub Actions usage summary"
+	if g.ownerName != "" && g.repoName != "" {
+		title = fmt.Sprintf("GitHub Actions usage summary: %s/%s", g.ownerName, g.repoName)
+	}
+	fmt.Fprintf(&b, "\n%s\n%s\n", bold(title), faint(strings.Repeat("─", min(g.width, utf8.RuneCountInString(title)))))
+
+	// Waste is classified like the waste command does, so both report the same spend
+	failed, cancelled := 0, 0
+	wastedCost := 0.0
+	for i, kind := range ClassifyWaste(data.Jobs) {
+		switch kind {
+		case "":
+			continue
+		case WasteFailed:
+			failed++
+		case WasteCancelled, WasteConcurrency:
+			cancelled++
+		}
+		wastedCost += data.Jobs[i].BillableInUSD
+	}
+	wastedShare := 0.0
+	if total > 0 {
+		wastedShare = wastedCost / total * 100
+	}
+
+	writeField := func(label, value string) {
+		fmt.Fprintf(&b, "%-17s %s\n", label, value)
+	}
+	writeField("Total cost", bold(formatUSD(total)))
+	writeField("Billable minutes", fmt.Sprintf("%.0f", data.Totals.RoundedUpJobDuration.Minutes()))
+	writeField("Job duration", data.Totals.JobDuration.Round(time.Second).String())
+	writeField("Jobs", fmt.Sprintf("%d (%d failed, %d cancelled)", len(data.Jobs), failed, cancelled))
+	wasted := fmt.Sprintf("%s (%.1f%% of cost)", formatUSD(wastedCost), wastedShare)
+	if wastedCost > 0 {
+		wasted = red(wasted)
+	}
+	writeField("Wasted", wasted)
+	if f := data.Forecast; f != nil {
+		writeField("Forecast", fmt.Sprintf("%s by %s", bold(formatUSD(f.ForecastInUSD)), f.lastDay()))
+		writeField("Forecast range", fmt.Sprintf("%s (%.0f%%, %s)", formatForecastRange(f.Projection), f.Confidence, f.Model))
+		if f.Partial() {
+			writeField("Forecast misses", "spend before "+f.DataStart.Format(time.DateOnly))
+		}
+	}
+
+	g.writeBudgets(&b, "Budget alerts", alertingBudgets(data.Budgets))
+
+	if data.Trend != nil {
+		g.writeTrend(&b, *data.Trend)
+	}
+
+	if f := data.Forecast; f != nil {
+		// A single repository's forecast is the same as the total
+		if len(f.Repos) > 1 {
+			g.writeForecast(&b, "Forecast by repository", f.Repos)
+		}
+		g.writeForecast(&b, "Forecast by runner", f.Runners)
+	}
+
+	if len(data.GroupBy) > 0 {
+		grouped := Aggregate(data.Jobs, data.GroupBy, data.ObfuscateData)
+		g.writeTable(&b, "Top by "+grouped.Title(), grouped.Groups)
+	}
+	g.writeTable(&b, "Top workflows", Aggregate(data.Jobs, []Dimension{DimensionWorkflow}, data.ObfuscateData).Groups)
+	g.writeTable(&b, "Top runners", Aggregate(data.Jobs, []Dimension{DimensionRunner}, data.ObfuscateData).Groups)
+	g.writeTable(&b, "Top branches", Aggregate(data.Jobs, []Dimension{DimensionBranch}, data.ObfuscateData).Groups)
+	g.writeTable(&b, "Top actors", Aggregate(data.Jobs, []Dimension{DimensionActor}, data.ObfuscateData).Groups)
+
+	b.WriteString("\n")
+	return b.String()
+}
+
+// writeTable writes a breakdown as a table with a bar chart column that fills the terminal width
+func (g *TerminalGenerator) writeTable(b *strings.Builder, title string, rows []Group) {
+	if len(rows) == 0 {
+		return
+	}
+	if len(rows) > g.topN {
+		rows = rows[:g.topN]
+	}
+
+	bold := g.paint(color.Bold)
+	cyan := g.paint(color.FgCyan)
+
+	const (
+		costWidth  = 10
+		shareWidth = 7
+		gaps       = 3
+	)
+
+	// The name column takes what it needs, up to half of the available space;
+	// the bar chart fills the rest
+	available := g.width - costWidth - shareWidth - gaps
+	nameWidth := 0
+	for _, row := range rows {
+		nameWidth = max(nameWidth, utf8.RuneCountInString(row.Key))
+	}
+	nameWidth = max(min(nameWidth, available/2), 4)
+	barWidth := available - nameWidth - 1
+
+	fmt.Fprintf(b, "\n%s\n", bold(title))
+	for _, row := range rows {
+		name := truncate(row.Key, nameWidth)
+		bar := ""
+		if barWidth > 0 {
+			bar = strings.Repeat("█", int(row.Share/100*float64(barWidth)+0.5))
+		}
+		fmt.Fprintf(b, "%s %s %*s %*s\n",
+			padRight(name, nameWidth),
+			cyan(padRight(bar, barWidth)),
+			costWidth, formatUSD(row.BillableInUSD),
+			shareWidth, fmt.Sprintf("%.1f%%", row.Share))
+	}
+}
+
+// writeBudgets writes a table of budget statuses, highlighting the ones that need attention
+func (g *TerminalGenerator) writeBudgets(b *strings.Builder, title string, statuses []BudgetStatus) {
+	if len(statuses) == 0 {
+		return
+	}
+
+	bold := g.paint(color.Bold)
+	colors := map[BudgetState]func(a ...interface{}) string{
+		BudgetOK:       g.paint(color.FgGreen),
+		BudgetWarning:  g.paint(color.FgYellow),
+		BudgetAtRisk:   g.paint(color.FgYellow, color.Bold),
+		BudgetBreached: g.paint(color.FgRed, color.Bold),
+	}
+
+	const (
+		amountWidth = 9
+		usedWidth   = 6
+		stateWidth  = 8
+	)
+	// The projection is dropped on narrow terminals to leave room for the name
+	showProjected := g.width >= 64
+	fixed := 2*amountWidth + usedWidth + stateWidth + 4
+	if showProjected {
+		fixed += amountWidth + 1
+	}
+	nameWidth := max(g.width-fixed, 4)
+
+	row := func(name, spent, projected, amount, used, state string) {
+		fmt.Fprintf(b, "%s %*s ", padRight(truncate(name, nameWidth), nameWidth), amountWidth, spent)
+		if showProjected {
+			fmt.Fprintf(b, "%*s ", amountWidth, projected)
+		}
+		fmt.Fprintf(b, "%*s %*s %s\n", amountWidth, amount, usedWidth, used, state)
+	}
+
+	fmt.Fprintf(b, "\n%s\n", bold(title))
+	row("Budget", "Spent", "Projected", "Budget", "Used", "Status")
+	for _, s := range statuses {
+		paint := colors[s.State]
+		if paint == nil {
+			paint = fmt.Sprint
+		}
+		row(s.Name,
+			formatUSD(s.Spent),
+			formatUSD(s.Projected),
+			formatUSD(s.Amount),
+			fmt.Sprintf("%.1f%%", s.SpentPercent),
+			paint(s.State))
+	}
+	for _, line := range wrapWords(partialBudgetsNote(statuses), g.width) {
+		fmt.Fprintf(b, "%s\n", line)
+	}
+}
+
+// writeForecast writes the top forecasts of repositories or runner types
+func (g *TerminalGenerator) writeForecast(b *strings.Builder, title string, groups []ForecastGroup) {
+	if len(groups) == 0 {
+		return
+	}
+	if len(groups) > g.topN {
+		groups = groups[:g.topN]
+	}
+
+	const amountWidth = 9
+	// The range is dropped on narrow terminals to leave room for the name
+	rangeWidth := 0
+	for _, group := range groups {
+		rangeWidth = max(rangeWidth, utf8.RuneCountInString(formatForecastRange(group.Projection)))
+	}
+	fixed := 2 * (amountWidth + 1)
+	showRange := g.width-fixed-rangeWidth-1 >= 16
+	if showRange {
+		fixed += rangeWidth + 1
+	}
+	nameWidth := max(g.width-fixed, 4)
+
+	fmt.Fprintf(b, "\n%s\n", g.paint(color.Bold)(title))
+	row := func(name, actual, forecast, rng string) {
+		line := fmt.Sprintf("%s %*s %*s", padRight(truncate(name, nameWidth), nameWidth), amountWidth, actual, amountWidth, forecast)
+		if showRange {
+			line += " " + rng
+		}
+		b.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+	row("", "So far", "Forecast", "Range")
+	for _, group := range groups {
+		row(group.Key, formatUSD(group.ActualInUSD), formatUSD(group.ForecastInUSD), formatForecastRange(group.Projection))
+	}
+}
+
+// writeTrend writes sparkline charts of cost and minutes per period, followed by
+// a table comparing the most recent periods with the previous period and the trailing average
+func (g *TerminalGenerator) writeTrend(b *strings.Builder, trend Trend) {
+	if len(trend.Buckets) == 0 {
+		return
+	}
+
+	bold := g.paint(color.Bold)
+	faint := g.paint(color.Faint)
+	cyan := g.paint(color.FgCyan)
+	red := g.paint(color.FgRed)
+	green := g.paint(color.FgGreen)
+
+	// The sparklines show as many of the most recent periods as fit next to the labels
+	const labelWidth = 8
+	buckets := trend.Last(g.width - labelWidth - 1)
+	costs := make([]float64, len(buckets))
+	minutes := make([]float64, len(buckets))
+	for i, bucket := range buckets {
+		costs[i] = bucket.BillableInUSD
+		minutes[i] = bucket.BillableMinutes
+	}
+
+	fmt.Fprintf(b, "\n%s\n", bold(fmt.Sprintf("Trend by %s", trend.Period)))
+	fmt.Fprintf(b, "%-*s %s\n", labelWidth, "Cost", cyan(sparkline(costs)))
+	fmt.Fprintf(b, "%-*s %s\n", labelWidth, "Minutes", cyan(sparkline(minutes)))
+	fmt.Fprintf(b, "%-*s %s\n", labelWidth, "", faint(fmt.Sprintf("%s – %s", buckets[0].Key, buckets[len(buckets)-1].Key)))
+
+	const (
+		periodWidth = 10
+		costWidth   = 10
+		deltaWidth  = 9
+	)
+	driversWidth := g.width - periodWidth - costWidth - 2*deltaWidth - 4
+
+	fmt.Fprintf(b, "\n%-*s %*s %*s %*s %s\n",
+		periodWidth, "Period",
+		costWidth, "Cost",
+		deltaWidth, "vs prev",
+		deltaWidth, fmt.Sprintf("vs avg%d", trend.Window),
+		"Drivers")
+	for _, bucket := range trend.Last(g.topN) {
+		vsPrevious := fmt.Sprintf("%*s", deltaWidth, formatDelta(bucket.BillableInUSD, bucket.PreviousInUSD))
+		if change, ok := bucket.Change(); ok && change > 0 {
+			vsPrevious = red(vsPrevious)
+		} else if ok && change < 0 {
+			vsPrevious = green(vsPrevious)
+		}
+
+		drivers := ""
+		if driversWidth > 0 {
+			drivers = truncate(formatDrivers(bucket.Drivers), driversWidth)
+		}
+		line := fmt.Sprintf("%-*s %*s %s %*s %s",
+			periodWidth, bucket.Key,
+			costWidth, formatUSD(bucket.BillableInUSD),
+			vsPrevious,
+			deltaWidth, formatDelta(bucket.BillableInUSD, bucket.TrailingAverage),
+			drivers)
+		b.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+}
+
+// truncate shortens s to width runes, marking the cut with an ellipsis
+func truncate(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	if width <= 1 {
+		return string([]rune(s)[:width])
+	}
+	return string([]rune(s)[:width-1]) + "…"
+}
+
+// wrapWords splits s into lines of up to width runes, breaking between words
+func wrapWords(s string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		if line != "" && utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// padRight pads s with spaces to width runes
+func padRight(s string, width int) string {
+	if n := utf8.RuneCountInString(s); n < width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	return s
+}
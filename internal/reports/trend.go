@@ -0,0 +1,301 @@
+package reports
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TrendBasis selects the timestamp jobs are bucketed by in a trend report
+type TrendBasis string
+
+const (
+	TrendByRun TrendBasis = "run" // workflow run creation time
+	TrendByJob TrendBasis = "job" // job creation time
+)
+
+const (
+	// DefaultTrendWindow is the default number of previous buckets in the trailing average
+	DefaultTrendWindow = 4
+	// DefaultTrendDrivers is the default number of workflows listed as drivers of a change
+	DefaultTrendDrivers = 3
+)
+
+// TrendConfig configures how a trend is built
+type TrendConfig struct {
+	Period  Dimension  // day, week or month
+	Basis   TrendBasis // which creation time to bucket jobs by, defaults to run
+	Window  int        // number of previous buckets in the trailing average, defaults to DefaultTrendWindow
+	Drivers int        // number of drivers per bucket, defaults to DefaultTrendDrivers
+}
+
+// ParseTrendPeriod validates a trend bucket size, e.g. from the --period flag
+func ParseTrendPeriod(name string) (Dimension, error) {
+	switch dim := Dimension(strings.ToLower(strings.TrimSpace(name))); dim {
+	case DimensionDay, DimensionWeek, DimensionMonth:
+		return dim, nil
+	}
+	return "", fmt.Errorf("unsupported trend period %q, must be one of: day, week, month", name)
+}
+
+// ParseTrendBasis validates a trend basis, e.g. from the --by flag
+func ParseTrendBasis(name string) (TrendBasis, error) {
+	switch basis := TrendBasis(strings.ToLower(strings.TrimSpace(name))); basis {
+	case TrendByRun, TrendByJob:
+		return basis, nil
+	case "":
+		return TrendByRun, nil
+	}
+	return "", fmt.Errorf("unsupported trend basis %q, must be one of: run, job", name)
+}
+
+// TrendDriver is a workflow whose change in cost contributed to a bucket's change
+type TrendDriver struct {
+	Workflow      string
+	BillableInUSD float64
+	PreviousInUSD float64
+	Delta         float64
+}
+
+// TrendBucket is a single period of a trend
+type TrendBucket struct {
+	Key             string // e.g. 2025-04-01, 2025-W14 or 2025-04
+	Start           time.Time
+	BillableInUSD   float64
+	BillableMinutes float64
+	JobCount        int
+	PreviousInUSD   float64 // cost of the previous bucket, 0 for the first one
+	TrailingAverage float64 // average cost of up to Window previous buckets, 0 for the first one
+	Drivers         []TrendDriver
+}
+
+// Change returns the relative change in cost from the previous bucket in percent,
+// and false when there is no previous cost to compare against
+func (b TrendBucket) Change() (float64, bool) {
+	return relativeChange(b.BillableInUSD, b.PreviousInUSD)
+}
+
+// ChangeFromAverage returns the relative change in cost from the trailing average in percent,
+// and false when there is no trailing average to compare against
+func (b TrendBucket) ChangeFromAverage() (float64, bool) {
+	return relativeChange(b.BillableInUSD, b.TrailingAverage)
+}
+
+// Trend is the cost of a report over time, bucketed by day, week or month.
+// Buckets are in chronological order, and periods without jobs are included with zero cost.
+type Trend struct {
+	Period  Dimension
+	Basis   TrendBasis
+	Window  int
+	Buckets []TrendBucket
+}
+
+// BuildTrend buckets jobs by period and compares every bucket with the previous one
+// and with the trailing average. When shouldObfuscate is set, workflow names of the
+// drivers are obfuscated the same way as in the flattened job reports.
+func BuildTrend(jobs []JobDetails, config TrendConfig, shouldObfuscate bool) Trend {
+	if config.Basis == "" {
+		config.Basis = TrendByRun
+	}
+	if config.Window <= 0 {
+		config.Window = DefaultTrendWindow
+	}
+	if config.Drivers <= 0 {
+		config.Drivers = DefaultTrendDrivers
+	}
+
+	trend := Trend{
+		Period: config.Period,
+		Basis:  config.Basis,
+		Window: config.Window,
+	}
+
+	flattened := FlattenJobs(jobs, shouldObfuscate)
+
+	buckets := make(map[time.Time]*TrendBucket)
+	workflowCosts := make(map[time.Time]map[string]float64)
+	var first, last time.Time
+	for i, job := range jobs {
+		t := trendTime(job, config.Basis)
+		if t.IsZero() {
+			continue
+		}
+		start := periodStart(config.Period, t)
+		if first.IsZero() || start.Before(first) {
+			first = start
+		}
+		if start.After(last) {
+			last = start
+		}
+
+		bucket, ok := buckets[start]
+		if !ok {
+			bucket = &TrendBucket{Start: start}
+			buckets[start] = bucket
+			workflowCosts[start] = make(map[string]float64)
+		}
+		bucket.BillableInUSD += job.BillableInUSD
+		bucket.BillableMinutes += job.RoundedUpJobDuration.Minutes()
+		bucket.JobCount++
+		workflowCosts[start][derefOr(flattened[i].WorkflowName, "unknown")] += job.BillableInUSD
+	}
+	if first.IsZero() {
+		return trend
+	}
+
+	// Fill in periods without any jobs so gaps are visible
+	var previousCosts map[string]float64
+	for start := first; !start.After(last); start = nextPeriod(config.Period, start) {
+		bucket := TrendBucket{Start: start}
+		if b, ok := buckets[start]; ok {
+			bucket = *b
+		}
+		bucket.Key = periodKey(config.Period, start)
+
+		if n := len(trend.Buckets); n > 0 {
+			bucket.PreviousInUSD = trend.Buckets[n-1].BillableInUSD
+
+			window := trend.Buckets[max(0, n-config.Window):]
+			sum := 0.0
+			for _, b := range window {
+				sum += b.BillableInUSD
+			}
+			bucket.TrailingAverage = sum / float64(len(window))
+
+			bucket.Drivers = trendDrivers(workflowCosts[start], previousCosts, config.Drivers)
+		}
+
+		trend.Buckets = append(trend.Buckets, bucket)
+		previousCosts = workflowCosts[start]
+	}
+
+	return trend
+}
+
+// Last returns at most the n most recent buckets
+func (t Trend) Last(n int) []TrendBucket {
+	if n > 0 && len(t.Buckets) > n {
+		return t.Buckets[len(t.Buckets)-n:]
+	}
+	return t.Buckets
+}
+
+// trendDrivers returns the workflows with the largest absolute change in cost between two buckets
+func trendDrivers(current, previous map[string]float64, n int) []TrendDriver {
+	var drivers []TrendDriver
+	add := func(workflow string) {
+		delta := current[workflow] - previous[workflow]
+		if delta == 0 {
+			return
+		}
+		drivers = append(drivers, TrendDriver{
+			Workflow:      workflow,
+			BillableInUSD: current[workflow],
+			PreviousInUSD: previous[workflow],
+			Delta:         delta,
+		})
+	}
+	for workflow := range current {
+		add(workflow)
+	}
+	for workflow := range previous {
+		if _, ok := current[workflow]; !ok {
+			add(workflow)
+		}
+	}
+
+	sort.Slice(drivers, func(i, j int) bool {
+		if a, b := math.Abs(drivers[i].Delta), math.Abs(drivers[j].Delta); a != b {
+			return a > b
+		}
+		return drivers[i].Workflow < drivers[j].Workflow
+	})
+	if len(drivers) > n {
+		drivers = drivers[:n]
+	}
+	return drivers
+}
+
+// trendTime returns the time a job is bucketed by for the given basis
+func trendTime(job JobDetails, basis TrendBasis) time.Time {
+	if basis == TrendByJob && job.Job != nil && job.Job.CreatedAt != nil {
+		return job.Job.CreatedAt.Time
+	}
+	return jobTime(job)
+}
+
+// periodStart returns the start of the day, ISO week or month t falls in, in UTC
+func periodStart(dim Dimension, t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch dim {
+	case DimensionWeek:
+		// ISO weeks start on Monday
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case DimensionMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+// nextPeriod returns the start of the period following the one starting at start
+func nextPeriod(dim Dimension, start time.Time) time.Time {
+	switch dim {
+	case DimensionWeek:
+		return start.AddDate(0, 0, 7)
+	case DimensionMonth:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// relativeChange returns the change from previous to current in percent
+func relativeChange(current, previous float64) (float64, bool) {
+	if previous == 0 {
+		return 0, false
+	}
+	return (current - previous) / previous * 100, true
+}
+
+// sparkline renders values as a line of block characters scaled to the largest value
+func sparkline(values []float64) string {
+	const blocks = "▁▂▃▄▅▆▇█"
+	levels := []rune(blocks)
+
+	maxValue := 0.0
+	for _, v := range values {
+		maxValue = max(maxValue, v)
+	}
+
+	var b strings.Builder
+	for _, v := range values {
+		level := 0
+		if maxValue > 0 {
+			level = int(v / maxValue * float64(len(levels)-1))
+		}
+		b.WriteRune(levels[level])
+	}
+	return b.String()
+}
+
+// formatDrivers formats the drivers of a bucket, e.g. "CI +$1.20, Release -$0.40"
+func formatDrivers(drivers []TrendDriver) string {
+	parts := make([]string, len(drivers))
+	for i, d := range drivers {
+		parts[i] = d.Workflow + " " + formatSignedUSD(d.Delta)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatSignedUSD formats a change in cost in US dollars, e.g. "+$1.20"
+func formatSignedUSD(f float64) string {
+	if f < 0 {
+		return "-" + formatUSD(-f)
+	}
+	return "+" + formatUSD(f)
+}
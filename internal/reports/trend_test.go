@@ -0,0 +1,110 @@
+package reports
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTrendOptions(t *testing.T) {
+	period, err := ParseTrendPeriod("Month")
+	require.NoError(t, err)
+	assert.Equal(t, DimensionMonth, period)
+
+	_, err = ParseTrendPeriod("workflow")
+	assert.ErrorContains(t, err, "unsupported trend period")
+
+	basis, err := ParseTrendBasis("")
+	require.NoError(t, err)
+	assert.Equal(t, TrendByRun, basis)
+
+	_, err = ParseTrendBasis("step")
+	assert.ErrorContains(t, err, "unsupported trend basis")
+}
+
+func TestBuildTrend(t *testing.T) {
+	monday := time.Date(2025, 3, 31, 10, 0, 0, 0, time.UTC) // ISO week 14
+	jobs := []JobDetails{
+		aggregateTestJob("CI", "UBUNTU", "success", monday, 10*time.Minute, 1.00),
+		aggregateTestJob("CI", "UBUNTU", "success", monday.AddDate(0, 0, 7), 10*time.Minute, 1.00),
+		aggregateTestJob("Release", "MACOS", "success", monday.AddDate(0, 0, 9), 10*time.Minute, 2.00),
+		// Week 17 is preceded by an empty week 16
+		aggregateTestJob("CI", "UBUNTU", "success", monday.AddDate(0, 0, 21), 20*time.Minute, 3.00),
+	}
+
+	t.Run("Weekly", func(t *testing.T) {
+		trend := BuildTrend(jobs, TrendConfig{Period: DimensionWeek, Window: 2}, false)
+		require.Len(t, trend.Buckets, 4)
+
+		keys := make([]string, len(trend.Buckets))
+		for i, b := range trend.Buckets {
+			keys[i] = b.Key
+		}
+		assert.Equal(t, []string{"2025-W14", "2025-W15", "2025-W16", "2025-W17"}, keys)
+		assert.Equal(t, monday.Truncate(24*time.Hour), trend.Buckets[0].Start)
+
+		first := trend.Buckets[0]
+		_, ok := first.Change()
+		assert.False(t, ok, "the first bucket has nothing to compare against")
+		assert.Empty(t, first.Drivers)
+
+		second := trend.Buckets[1]
+		assert.InDelta(t, 3.00, second.BillableInUSD, 0.0001)
+		assert.Equal(t, 2, second.JobCount)
+		change, ok := second.Change()
+		require.True(t, ok)
+		assert.InDelta(t, 200.0, change, 0.0001)
+		require.Len(t, second.Drivers, 1)
+		assert.Equal(t, "Release", second.Drivers[0].Workflow)
+		assert.InDelta(t, 2.00, second.Drivers[0].Delta, 0.0001)
+
+		empty := trend.Buckets[2]
+		assert.Zero(t, empty.JobCount)
+		assert.InDelta(t, 2.00, empty.TrailingAverage, 0.0001)
+		require.Len(t, empty.Drivers, 2)
+		assert.Equal(t, "Release", empty.Drivers[0].Workflow)
+		assert.InDelta(t, -2.00, empty.Drivers[0].Delta, 0.0001)
+
+		// The trailing average only covers the last two weeks
+		last := trend.Buckets[3]
+		assert.InDelta(t, 1.50, last.TrailingAverage, 0.0001)
+		assert.InDelta(t, 20.0, last.BillableMinutes, 0.0001)
+		_, ok = last.Change()
+		assert.False(t, ok, "the previous week had no cost")
+		vsAverage, ok := last.ChangeFromAverage()
+		require.True(t, ok)
+		assert.InDelta(t, 100.0, vsAverage, 0.0001)
+	})
+
+	t.Run("Monthly", func(t *testing.T) {
+		trend := BuildTrend(jobs, TrendConfig{Period: DimensionMonth}, false)
+		require.Len(t, trend.Buckets, 2)
+		assert.Equal(t, "2025-03", trend.Buckets[0].Key)
+		assert.Equal(t, "2025-04", trend.Buckets[1].Key)
+		assert.Equal(t, DefaultTrendWindow, trend.Window)
+	})
+
+	t.Run("ByJobCreationTime", func(t *testing.T) {
+		job := aggregateTestJob("CI", "UBUNTU", "success", monday, time.Minute, 1.00)
+		job.Job.CreatedAt = &github.Timestamp{Time: monday.AddDate(0, 0, 1)}
+
+		byRun := BuildTrend([]JobDetails{job}, TrendConfig{Period: DimensionDay}, false)
+		byJob := BuildTrend([]JobDetails{job}, TrendConfig{Period: DimensionDay, Basis: TrendByJob}, false)
+		assert.Equal(t, "2025-03-31", byRun.Buckets[0].Key)
+		assert.Equal(t, "2025-04-01", byJob.Buckets[0].Key)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		trend := BuildTrend(nil, TrendConfig{Period: DimensionDay}, false)
+		assert.Empty(t, trend.Buckets)
+		assert.Empty(t, trend.Last(3))
+	})
+}
+
+func TestSparkline(t *testing.T) {
+	assert.Equal(t, "▁▄█", sparkline([]float64{0, 5, 10}))
+	assert.Equal(t, "▁▁", sparkline([]float64{0, 0}))
+}
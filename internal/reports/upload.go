@@ -0,0 +1,215 @@
+package reports
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	maxRetries          = 3  // Maximum number of retries for failed batch uploads
+	maxThrottledRetries = 10 // Maximum number of retries of a batch the server asked to wait with
+)
+
+// DefaultUploadConcurrency is the number of batches uploaded at once when not configured
+const DefaultUploadConcurrency = 4
+
+var (
+	// retryDelay is the delay before the first retry of a failed batch, growing with every retry
+	retryDelay = time.Second
+	// batchMaxBytes and batchMaxJobs bound the batches of an upload, by the JSON size of their
+	// flattened jobs and by their number of jobs
+	batchMaxBytes = 1 << 20
+	batchMaxJobs  = 1000
+)
+
+// UploadOptions tune how batches are uploaded
+type UploadOptions struct {
+	Concurrency int                   // batches uploaded at once, DefaultUploadConcurrency when not set
+	Progress    func(done, total int) // optional, called every time a batch was uploaded
+}
+
+// retryAfterError is implemented by errors of servers asking to wait before retrying,
+// like responses with status 429 and a Retry-After header
+type retryAfterError interface {
+	RetryAfter() time.Duration
+}
+
+// UploadInBatches uploads jobs in batches, retrying a failed batch with a growing delay.
+// It is shared by the report upload and every sync sink.
+func UploadInBatches(ctx context.Context, jobs []JobDetails, upload func(ctx context.Context, batch []JobDetails) error, opts UploadOptions, logger zerolog.Logger) error {
+	batches := splitBatches(jobs)
+	indexes := make([]int, len(batches))
+	for i := range batches {
+		indexes[i] = i
+	}
+	return uploadBatches(ctx, indexes, func(ctx context.Context, i int) error {
+		return upload(ctx, batches[i])
+	}, nil, opts, logger)
+}
+
+// splitBatches splits jobs into upload batches of at most batchMaxBytes of flattened jobs,
+// so batches of jobs with many steps don't grow too large to upload
+func splitBatches(jobs []JobDetails) [][]JobDetails {
+	var batches [][]JobDetails
+	start, size := 0, 0
+	for i, job := range jobs {
+		// Obfuscating keeps the length of values, so the size is the same either way
+		content, _ := json.Marshal(FlattenJob(job, false))
+		if i > start && (size+len(content) > batchMaxBytes || i-start == batchMaxJobs) {
+			batches = append(batches, jobs[start:i])
+			start, size = i, 0
+		}
+		size += len(content)
+	}
+	if start < len(jobs) {
+		batches = append(batches, jobs[start:])
+	}
+	return batches
+}
+
+// uploadBatches uploads the batches at indexes, up to opts.Concurrency at once, retrying
+// every failed batch. completed is called after every uploaded batch, one at a time, and
+// the first batch that still fails after its retries stops the upload.
+func uploadBatches(ctx context.Context, indexes []int, upload func(ctx context.Context, i int) error, completed func(i int) error, opts UploadOptions, logger zerolog.Logger) error {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultUploadConcurrency
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		done     int
+		pause    uploadPause
+	)
+	slots := make(chan struct{}, concurrency)
+	for _, i := range indexes {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			err := uploadWithRetries(ctx, i+1, &pause, func(ctx context.Context) error {
+				return upload(ctx, i)
+			}, logger)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil && completed != nil {
+				err = completed(i)
+			}
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				return
+			}
+
+			done++
+			logger.Debug().
+				Int("batch", i+1).
+				Int("done", done).
+				Int("total_batches", len(indexes)).
+				Msg("Batch uploaded successfully")
+			if opts.Progress != nil {
+				opts.Progress(done, len(indexes))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// uploadPause holds back every upload of a run while the server asked to wait
+type uploadPause struct {
+	mu    sync.Mutex
+	until time.Time
+}
+
+func (p *uploadPause) extend(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if until := time.Now().Add(d); until.After(p.until) {
+		p.until = until
+	}
+}
+
+func (p *uploadPause) wait(ctx context.Context) error {
+	p.mu.Lock()
+	d := time.Until(p.until)
+	p.mu.Unlock()
+	if d <= 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// uploadWithRetries calls upload until it succeeds, retrying with a growing delay. When the
+// server asks to wait, e.g. with status 429, all uploads sharing the pause wait that long.
+func uploadWithRetries(ctx context.Context, batchNumber int, pause *uploadPause, upload func(ctx context.Context) error, logger zerolog.Logger) error {
+	var err error
+	throttled := 0
+	for retry := 0; retry < maxRetries; {
+		if waitErr := pause.wait(ctx); waitErr != nil {
+			return waitErr
+		}
+		err = upload(ctx)
+		if err == nil {
+			return nil
+		}
+
+		var throttle retryAfterError
+		if errors.As(err, &throttle) && throttle.RetryAfter() > 0 && throttled < maxThrottledRetries {
+			throttled++
+			pause.extend(throttle.RetryAfter())
+			logger.Warn().
+				Int("batch", batchNumber).
+				Dur("retry_after", throttle.RetryAfter()).
+				Msg("Server is throttling uploads, waiting before retrying...")
+			continue
+		}
+
+		retry++
+		logger.Warn().
+			Int("retry", retry).
+			Int("batch", batchNumber).
+			Err(err).
+			Msg("Failed to upload batch, retrying...")
+
+		if retry < maxRetries {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(retryDelay * time.Duration(retry)):
+			}
+		}
+	}
+	return fmt.Errorf("failed to upload batch after %d retries: %w", maxRetries, err)
+}
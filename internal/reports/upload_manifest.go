@@ -0,0 +1,164 @@
+package reports
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// reportIDPattern matches valid report IDs, which are used in file names
+var reportIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,128}$`)
+
+// ValidReportID returns whether a report ID is valid: up to 128 letters, digits, - and _
+func ValidReportID(reportID string) bool {
+	return reportIDPattern.MatchString(reportID)
+}
+
+// ErrCommitUnsupported is returned by servers that publish report batches as soon as
+// they are uploaded, and don't take a final commit
+var ErrCommitUnsupported = errors.New("server doesn't support committing uploads")
+
+// MissingBatchesError is returned when committing a report the server is missing batches of
+type MissingBatchesError struct {
+	Hashes []string
+}
+
+func (e *MissingBatchesError) Error() string {
+	return fmt.Sprintf("server is missing %d batches of the report", len(e.Hashes))
+}
+
+// UploadManifest records the batches of a report upload and which of them the server
+// acknowledged, so an interrupted upload can be resumed by sending only the missing ones
+type UploadManifest struct {
+	ReportID  string        `json:"report_id"`
+	Obfuscate bool          `json:"obfuscate"`
+	Batches   []UploadBatch `json:"batches"`
+	Merge     bool          `json:"merge,omitempty"` // merge the jobs into the existing report instead of replacing its jobs
+	Committed bool          `json:"committed"`       // the server published the complete report
+}
+
+// UploadBatch is a batch of an upload, identified by the hash of its content
+type UploadBatch struct {
+	Hash     string  `json:"hash"` // sent as the idempotency key of the batch
+	JobIDs   []int64 `json:"job_ids"`
+	Uploaded bool    `json:"uploaded"`
+}
+
+// BatchHash returns the content hash of a batch of flattened jobs, used as its idempotency
+// key. Flattening is deterministic, so a batch sent again has the same hash.
+func BatchHash(jobs []FlatJobDetails) string {
+	content, _ := json.Marshal(jobs)
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// NewUploadManifest creates the manifest of a new upload of these batches
+func NewUploadManifest(reportID string, batches [][]JobDetails, obfuscate bool) *UploadManifest {
+	manifest := &UploadManifest{ReportID: reportID, Obfuscate: obfuscate}
+	for _, batch := range batches {
+		ids := make([]int64, len(batch))
+		for j, job := range batch {
+			ids[j] = job.Job.GetID()
+		}
+		manifest.Batches = append(manifest.Batches, UploadBatch{
+			Hash:   BatchHash(FlattenJobs(batch, obfuscate)),
+			JobIDs: ids,
+		})
+	}
+	return manifest
+}
+
+// Hashes returns the hashes of all batches, in upload order
+func (m *UploadManifest) Hashes() []string {
+	hashes := make([]string, len(m.Batches))
+	for i, batch := range m.Batches {
+		hashes[i] = batch.Hash
+	}
+	return hashes
+}
+
+// JobCount returns the number of jobs in the upload
+func (m *UploadManifest) JobCount() int {
+	count := 0
+	for _, batch := range m.Batches {
+		count += len(batch.JobIDs)
+	}
+	return count
+}
+
+// Pending returns the number of batches the server hasn't acknowledged yet
+func (m *UploadManifest) Pending() int {
+	count := 0
+	for _, batch := range m.Batches {
+		if !batch.Uploaded {
+			count++
+		}
+	}
+	return count
+}
+
+// markMissing marks the batches with these hashes as not uploaded
+func (m *UploadManifest) markMissing(hashes []string) {
+	for i := range m.Batches {
+		for _, hash := range hashes {
+			if m.Batches[i].Hash == hash {
+				m.Batches[i].Uploaded = false
+			}
+		}
+	}
+}
+
+func uploadManifestPath(dir, reportID string) string {
+	return filepath.Join(dir, reportID+".json")
+}
+
+// LoadUploadManifest loads the manifest of a report upload from dir
+func LoadUploadManifest(dir, reportID string) (*UploadManifest, error) {
+	if strings.ContainsAny(reportID, `/\`) {
+		return nil, fmt.Errorf("invalid report ID %q", reportID)
+	}
+	content, err := os.ReadFile(uploadManifestPath(dir, reportID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no upload of report %s was started from this directory", reportID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var manifest UploadManifest
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to read upload manifest of report %s: %w", reportID, err)
+	}
+	return &manifest, nil
+}
+
+// Save writes the manifest to dir, replacing it atomically so an interruption
+// never leaves a truncated manifest behind
+func (m *UploadManifest) Save(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".manifest-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), uploadManifestPath(dir, m.ReportID))
+}
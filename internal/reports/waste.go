@@ -0,0 +1,389 @@
+package reports
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// WasteKind is the reason the spend of a job was wasted
+type WasteKind string
+
+const (
+	WasteFailed      WasteKind = "failed"      // the job failed or timed out
+	WasteCancelled   WasteKind = "cancelled"   // the job was cancelled
+	WasteConcurrency WasteKind = "concurrency" // the job was cancelled by a newer run of the same workflow and branch
+	WasteSuperseded  WasteKind = "superseded"  // the job succeeded in an attempt that was re-run
+)
+
+// WasteKinds lists every kind of waste in the order they are reported
+var WasteKinds = []WasteKind{WasteFailed, WasteCancelled, WasteConcurrency, WasteSuperseded}
+
+// WasteGroup is the wasted spend of a workflow, or of a job of a workflow
+type WasteGroup struct {
+	Workflow     string
+	Job          string // empty for workflows
+	TotalInUSD   float64
+	WastedInUSD  float64
+	WastedJobs   int
+	ByKind       map[WasteKind]float64
+	ShareOfTotal float64 // wasted spend of the group in percent of the total spend of the report
+}
+
+// Name returns "workflow" or "workflow / job"
+func (w WasteGroup) Name() string {
+	if w.Job == "" {
+		return w.Workflow
+	}
+	return w.Workflow + " / " + w.Job
+}
+
+// Share returns the wasted spend in percent of the group's own spend
+func (w WasteGroup) Share() float64 {
+	if w.TotalInUSD == 0 {
+		return 0
+	}
+	return w.WastedInUSD / w.TotalInUSD * 100
+}
+
+// Waste is the spend of a report that did not produce a useful result.
+// Workflows and jobs are sorted by wasted spend, most wasteful first, and
+// only the ones with any waste are listed.
+type Waste struct {
+	WasteGroup // totals of the whole report
+	Workflows  []WasteGroup
+	Jobs       []WasteGroup
+}
+
+// ClassifyWaste returns the kind of waste of every job, or an empty kind for productive jobs.
+//
+// Failed and cancelled jobs are waste whatever their attempt. A cancelled job counts as cancelled
+// by concurrency when a newer run of the same workflow on the same branch was created before it
+// completed, which is what a concurrency group with cancel-in-progress does. Other jobs of an
+// attempt that was re-run are superseded. A job listed in more than one attempt is only counted once.
+func ClassifyWaste(jobs []JobDetails) []WasteKind {
+	type runKey struct {
+		repo     int64
+		workflow int64
+		branch   string
+	}
+	// Creation times of the runs of every workflow and branch, to detect concurrency cancellations
+	runTimes := make(map[runKey][]time.Time)
+	seenRuns := make(map[int64]bool)
+	keyOf := func(job JobDetails) runKey {
+		var key runKey
+		if job.Repo != nil {
+			key.repo = job.Repo.GetID()
+		}
+		if job.Workflow != nil {
+			key.workflow = job.Workflow.GetID()
+		}
+		key.branch = job.WorkflowRun.GetHeadBranch()
+		return key
+	}
+	for _, job := range jobs {
+		if job.WorkflowRun == nil || job.WorkflowRun.CreatedAt == nil || seenRuns[job.WorkflowRun.GetID()] {
+			continue
+		}
+		seenRuns[job.WorkflowRun.GetID()] = true
+		key := keyOf(job)
+		runTimes[key] = append(runTimes[key], job.WorkflowRun.CreatedAt.Time)
+	}
+
+	kinds := make([]WasteKind, len(jobs))
+	seenJobs := make(map[int64]bool)
+	for i, job := range jobs {
+		if job.Job == nil {
+			continue
+		}
+		if id := job.Job.GetID(); id != 0 {
+			if seenJobs[id] {
+				continue
+			}
+			seenJobs[id] = true
+		}
+
+		switch job.Job.GetConclusion() {
+		case "failure", "timed_out":
+			kinds[i] = WasteFailed
+		case "cancelled":
+			kinds[i] = WasteCancelled
+			if cancelledByConcurrency(job, runTimes[keyOf(job)]) {
+				kinds[i] = WasteConcurrency
+			}
+		default:
+			if job.WorkflowRun != nil && job.Job.GetRunAttempt() > 0 && job.Job.GetRunAttempt() < int64(job.WorkflowRun.GetRunAttempt()) {
+				kinds[i] = WasteSuperseded
+			}
+		}
+	}
+	return kinds
+}
+
+// cancelledByConcurrency reports whether a newer run was created after the job's run and before the job completed
+func cancelledByConcurrency(job JobDetails, runTimes []time.Time) bool {
+	if job.WorkflowRun == nil || job.WorkflowRun.CreatedAt == nil || job.Job.CompletedAt == nil {
+		return false
+	}
+	created := job.WorkflowRun.CreatedAt.Time
+	completed := job.Job.CompletedAt.Time
+	for _, t := range runTimes {
+		if t.After(created) && !t.After(completed) {
+			return true
+		}
+	}
+	return false
+}
+
+// AnalyzeWaste attributes the cost of failed, cancelled and superseded jobs to their workflows and jobs
+func AnalyzeWaste(jobs []JobDetails, shouldObfuscate bool) Waste {
+	flattened := FlattenJobs(jobs, shouldObfuscate)
+	kinds := ClassifyWaste(jobs)
+
+	waste := Waste{WasteGroup: WasteGroup{ByKind: make(map[WasteKind]float64)}}
+	// Workflows are keyed without a job name
+	groups := make(map[diffKey]*WasteGroup)
+	group := func(key diffKey) *WasteGroup {
+		g, ok := groups[key]
+		if !ok {
+			g = &WasteGroup{Workflow: key.workflow, Job: key.job, ByKind: make(map[WasteKind]float64)}
+			groups[key] = g
+		}
+		return g
+	}
+
+	for i, job := range jobs {
+		workflow := derefOr(flattened[i].WorkflowName, "unknown")
+		jobName := derefOr(flattened[i].JobName, "unknown")
+		for _, g := range []*WasteGroup{&waste.WasteGroup, group(diffKey{workflow: workflow}), group(diffKey{workflow, jobName})} {
+			g.TotalInUSD += job.BillableInUSD
+			if kinds[i] != "" {
+				g.WastedInUSD += job.BillableInUSD
+				g.WastedJobs++
+				g.ByKind[kinds[i]] += job.BillableInUSD
+			}
+		}
+	}
+
+	if waste.TotalInUSD > 0 {
+		waste.ShareOfTotal = waste.WastedInUSD / waste.TotalInUSD * 100
+	}
+	for _, g := range groups {
+		if g.WastedJobs == 0 {
+			continue
+		}
+		if waste.TotalInUSD > 0 {
+			g.ShareOfTotal = g.WastedInUSD / waste.TotalInUSD * 100
+		}
+		if g.Job == "" {
+			waste.Workflows = append(waste.Workflows, *g)
+		} else {
+			waste.Jobs = append(waste.Jobs, *g)
+		}
+	}
+	sortWasteGroups(waste.Workflows)
+	sortWasteGroups(waste.Jobs)
+	return waste
+}
+
+// sortWasteGroups sorts groups by wasted spend, most wasteful first
+func sortWasteGroups(groups []WasteGroup) {
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].WastedInUSD != groups[j].WastedInUSD {
+			return groups[i].WastedInUSD > groups[j].WastedInUSD
+		}
+		return groups[i].Name() < groups[j].Name()
+	})
+}
+
+// WasteGenerator writes a waste analysis as a plain text table, Markdown or JSON
+type WasteGenerator struct {
+	out    io.Writer
+	format string
+	topN   int
+	logger zerolog.Logger
+}
+
+// NewWasteGenerator creates a new waste analysis generator that writes to out
+func NewWasteGenerator(out io.Writer, format string, topN int, logger zerolog.Logger) *WasteGenerator {
+	if topN <= 0 {
+		topN = DefaultTopN
+	}
+	return &WasteGenerator{
+		out:    out,
+		format: format,
+		topN:   topN,
+		logger: logger,
+	}
+}
+
+func (g *WasteGenerator) Generate(waste *Waste) error {
+	g.logger.Debug().Str("format", g.format).Msg("Generating waste analysis")
+
+	var err error
+	switch g.format {
+	case FormatTable:
+		_, err = io.WriteString(g.out, g.renderTable(waste))
+	case FormatMarkdown:
+		_, err = io.WriteString(g.out, g.renderMarkdown(waste))
+	case FormatJSON:
+		enc := json.NewEncoder(g.out)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(exportWaste(waste))
+	default:
+		return fmt.Errorf("unsupported waste format %q, must be one of: %s, %s, %s", g.format, FormatTable, FormatMarkdown, FormatJSON)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s waste analysis: %w", g.format, err)
+	}
+	return nil
+}
+
+func (g *WasteGenerator) renderTable(waste *Waste) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%-17s %s of %s (%.1f%%)\n", "Wasted", formatUSD(waste.WastedInUSD), formatUSD(waste.TotalInUSD), waste.ShareOfTotal)
+	for _, kind := range WasteKinds {
+		fmt.Fprintf(&b, "%-17s %s\n", wasteKindLabel(kind), formatUSD(waste.ByKind[kind]))
+	}
+
+	g.writeTableSection(&b, "Workflows", waste.Workflows)
+	g.writeTableSection(&b, "Jobs", waste.Jobs)
+	return b.String()
+}
+
+func (g *WasteGenerator) writeTableSection(b *strings.Builder, title string, groups []WasteGroup) {
+	if len(groups) == 0 {
+		return
+	}
+
+	const nameWidth = 32
+	fmt.Fprintf(b, "\n%s\n", title)
+	fmt.Fprintf(b, "%s %10s %10s %10s %11s %10s %7s %7s\n",
+		padRight("Name", nameWidth), "Wasted", "Failed", "Cancelled", "Concurrency", "Superseded", "Own %", "Total %")
+	for _, w := range topOf(groups, g.topN) {
+		fmt.Fprintf(b, "%s %10s %10s %10s %11s %10s %6.1f%% %6.1f%%\n",
+			padRight(truncate(w.Name(), nameWidth), nameWidth),
+			formatUSD(w.WastedInUSD),
+			formatUSD(w.ByKind[WasteFailed]),
+			formatUSD(w.ByKind[WasteCancelled]),
+			formatUSD(w.ByKind[WasteConcurrency]),
+			formatUSD(w.ByKind[WasteSuperseded]),
+			w.Share(),
+			w.ShareOfTotal)
+	}
+}
+
+func (g *WasteGenerator) renderMarkdown(waste *Waste) string {
+	var b strings.Builder
+
+	b.WriteString("## GitHub Actions waste\n\n")
+	fmt.Fprintf(&b, "**%s** of %s (%.1f%%) was spent on jobs that failed, were cancelled or were re-run.\n\n",
+		formatUSD(waste.WastedInUSD), formatUSD(waste.TotalInUSD), waste.ShareOfTotal)
+	b.WriteString("| Failed | Cancelled | Cancelled by concurrency | Superseded attempts |\n")
+	b.WriteString("| ---: | ---: | ---: | ---: |\n")
+	fmt.Fprintf(&b, "| %s | %s | %s | %s |\n\n",
+		formatUSD(waste.ByKind[WasteFailed]),
+		formatUSD(waste.ByKind[WasteCancelled]),
+		formatUSD(waste.ByKind[WasteConcurrency]),
+		formatUSD(waste.ByKind[WasteSuperseded]))
+
+	g.writeMarkdownSection(&b, "Workflows", "Workflow", waste.Workflows)
+	g.writeMarkdownSection(&b, "Jobs", "Job", waste.Jobs)
+	return b.String()
+}
+
+func (g *WasteGenerator) writeMarkdownSection(b *strings.Builder, title, column string, groups []WasteGroup) {
+	if len(groups) == 0 {
+		return
+	}
+
+	fmt.Fprintf(b, "### %s\n\n", title)
+	fmt.Fprintf(b, "| %s | Wasted | Failed | Cancelled | Concurrency | Superseded | %% of its cost | %% of total |\n", column)
+	b.WriteString("| --- | ---: | ---: | ---: | ---: | ---: | ---: | ---: |\n")
+	for _, w := range topOf(groups, g.topN) {
+		fmt.Fprintf(b, "| %s | %s | %s | %s | %s | %s | %.1f%% | %.1f%% |\n",
+			escapeMarkdownCell(w.Name()),
+			formatUSD(w.WastedInUSD),
+			formatUSD(w.ByKind[WasteFailed]),
+			formatUSD(w.ByKind[WasteCancelled]),
+			formatUSD(w.ByKind[WasteConcurrency]),
+			formatUSD(w.ByKind[WasteSuperseded]),
+			w.Share(),
+			w.ShareOfTotal)
+	}
+	b.WriteString("\n")
+}
+
+// wasteKindLabel returns a human readable label of a kind of waste
+func wasteKindLabel(kind WasteKind) string {
+	switch kind {
+	case WasteFailed:
+		return "Failed"
+	case WasteCancelled:
+		return "Cancelled"
+	case WasteConcurrency:
+		return "Concurrency"
+	case WasteSuperseded:
+		return "Superseded"
+	}
+	return string(kind)
+}
+
+// WasteExport is the document written by the json waste format
+type WasteExport struct {
+	SchemaVersion string `json:"schema_version"`
+	WasteGroupExport
+	Workflows []WasteGroupExport `json:"workflows"`
+	Jobs      []WasteGroupExport `json:"jobs"`
+}
+
+// WasteGroupExport is the wasted spend of a workflow or job in a JSON waste export
+type WasteGroupExport struct {
+	Workflow     string                `json:"workflow,omitempty"`
+	Job          string                `json:"job,omitempty"`
+	TotalInUSD   float64               `json:"total_in_usd"`
+	WastedInUSD  float64               `json:"wasted_in_usd"`
+	WastedJobs   int                   `json:"wasted_jobs"`
+	ByKindInUSD  map[WasteKind]float64 `json:"by_kind_in_usd"`
+	SharePercent float64               `json:"share_percent"`
+	ShareOfTotal float64               `json:"share_of_total_percent"`
+}
+
+func exportWasteGroup(w WasteGroup) WasteGroupExport {
+	byKind := make(map[WasteKind]float64, len(WasteKinds))
+	for _, kind := range WasteKinds {
+		byKind[kind] = w.ByKind[kind]
+	}
+	return WasteGroupExport{
+		Workflow:     w.Workflow,
+		Job:          w.Job,
+		TotalInUSD:   w.TotalInUSD,
+		WastedInUSD:  w.WastedInUSD,
+		WastedJobs:   w.WastedJobs,
+		ByKindInUSD:  byKind,
+		SharePercent: w.Share(),
+		ShareOfTotal: w.ShareOfTotal,
+	}
+}
+
+func exportWaste(waste *Waste) WasteExport {
+	export := WasteExport{
+		SchemaVersion:    ExportSchemaVersion,
+		WasteGroupExport: exportWasteGroup(waste.WasteGroup),
+		Workflows:        []WasteGroupExport{},
+		Jobs:             []WasteGroupExport{},
+	}
+	for _, w := range waste.Workflows {
+		export.Workflows = append(export.Workflows, exportWasteGroup(w))
+	}
+	for _, w := range waste.Jobs {
+		export.Jobs = append(export.Jobs, exportWasteGroup(w))
+	}
+	return export
+}
@@ -0,0 +1,130 @@
+package reports
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wasteTestJob returns a job of a run created at created on main, that ran for ten minutes
+func wasteTestJob(workflow, job string, runID, jobID int64, attempt, runAttempt int, conclusion string, created time.Time, cost float64) JobDetails {
+	j := diffTestJob(workflow, job, runID, created, 10*time.Minute, cost)
+	j.WorkflowRun.RunAttempt = github.Int(runAttempt)
+	j.Job.ID = github.Int64(jobID)
+	j.Job.RunAttempt = github.Int64(int64(attempt))
+	j.Job.Conclusion = github.String(conclusion)
+	j.Job.CompletedAt = &github.Timestamp{Time: created.Add(10 * time.Minute)}
+	return j
+}
+
+func setupWasteTestData() []JobDetails {
+	day := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+	return []JobDetails{
+		// Run 1 failed its tests and was re-run: the failed attempt and its build are waste
+		wasteTestJob("CI", "build", 1, 11, 1, 2, "success", day, 1),
+		wasteTestJob("CI", "test", 1, 12, 1, 2, "failure", day, 2),
+		wasteTestJob("CI", "build", 1, 13, 2, 2, "success", day, 1),
+		wasteTestJob("CI", "test", 1, 14, 2, 2, "success", day, 2),
+		// Run 2 was cancelled by run 3 on the same branch, which started 5 minutes later
+		wasteTestJob("CI", "test", 2, 21, 1, 1, "cancelled", day.Add(time.Hour), 0.5),
+		wasteTestJob("CI", "test", 3, 31, 1, 1, "success", day.Add(time.Hour+5*time.Minute), 2),
+		// Run 4 was cancelled by hand
+		wasteTestJob("Deploy", "deploy", 4, 41, 1, 1, "cancelled", day.Add(3*time.Hour), 1.5),
+		// A job listed in two attempts is only counted once
+		wasteTestJob("Lint", "lint", 5, 51, 1, 1, "timed_out", day, 1),
+		wasteTestJob("Lint", "lint", 5, 51, 1, 1, "timed_out", day, 1),
+	}
+}
+
+func TestClassifyWaste(t *testing.T) {
+	kinds := ClassifyWaste(setupWasteTestData())
+	assert.Equal(t, []WasteKind{
+		WasteSuperseded, WasteFailed, "", "",
+		WasteConcurrency, "",
+		WasteCancelled,
+		WasteFailed, "",
+	}, kinds)
+}
+
+func TestAnalyzeWaste(t *testing.T) {
+	waste := AnalyzeWaste(setupWasteTestData(), false)
+
+	assert.InDelta(t, 12, waste.TotalInUSD, 0.0001)
+	assert.InDelta(t, 6, waste.WastedInUSD, 0.0001)
+	assert.Equal(t, 5, waste.WastedJobs)
+	assert.InDelta(t, 50, waste.ShareOfTotal, 0.0001)
+	assert.InDelta(t, 3, waste.ByKind[WasteFailed], 0.0001)
+	assert.InDelta(t, 1.5, waste.ByKind[WasteCancelled], 0.0001)
+	assert.InDelta(t, 0.5, waste.ByKind[WasteConcurrency], 0.0001)
+	assert.InDelta(t, 1, waste.ByKind[WasteSuperseded], 0.0001)
+
+	require.Len(t, waste.Workflows, 3)
+	assert.Equal(t, "CI", waste.Workflows[0].Name())
+	assert.InDelta(t, 3.5, waste.Workflows[0].WastedInUSD, 0.0001)
+	assert.InDelta(t, 3.5/8.5*100, waste.Workflows[0].Share(), 0.0001)
+	assert.InDelta(t, 3.5/12*100, waste.Workflows[0].ShareOfTotal, 0.0001)
+	assert.Equal(t, "Deploy", waste.Workflows[1].Name())
+	assert.Equal(t, "Lint", waste.Workflows[2].Name())
+	assert.InDelta(t, 50, waste.Workflows[2].Share(), 0.0001)
+
+	require.Len(t, waste.Jobs, 4)
+	assert.Equal(t, "CI / test", waste.Jobs[0].Name())
+	assert.InDelta(t, 2.5, waste.Jobs[0].WastedInUSD, 0.0001)
+	assert.Equal(t, "CI / build", waste.Jobs[2].Name())
+	assert.InDelta(t, 1, waste.Jobs[2].ByKind[WasteSuperseded], 0.0001)
+}
+
+func TestWasteGenerator(t *testing.T) {
+	waste := AnalyzeWaste(setupWasteTestData(), false)
+	logger := zerolog.New(io.Discard)
+
+	t.Run("Table", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewWasteGenerator(&buf, FormatTable, 10, logger).Generate(&waste))
+		out := buf.String()
+		assert.Contains(t, out, "Wasted            $6.00 of $12.00 (50.0%)")
+		assert.Contains(t, out, "Concurrency       $0.50")
+		assert.Regexp(t, `CI\s+\$3\.50\s+\$2\.00\s+\$0\.00\s+\$0\.50\s+\$1\.00\s+41\.2%\s+29\.2%`, out)
+		assert.Contains(t, out, "CI / test")
+	})
+
+	t.Run("TopN", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewWasteGenerator(&buf, FormatTable, 1, logger).Generate(&waste))
+		assert.NotContains(t, buf.String(), "Deploy")
+	})
+
+	t.Run("Markdown", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewWasteGenerator(&buf, FormatMarkdown, 10, logger).Generate(&waste))
+		md := buf.String()
+		assert.Contains(t, md, "**$6.00** of $12.00 (50.0%)")
+		assert.Contains(t, md, "| $3.00 | $1.50 | $0.50 | $1.00 |")
+		assert.Contains(t, md, "| CI / test | $2.50 | $2.00 | $0.00 | $0.50 | $0.00 | 38.5% | 20.8% |")
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, NewWasteGenerator(&buf, FormatJSON, 10, logger).Generate(&waste))
+
+		var export WasteExport
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &export))
+		assert.Equal(t, ExportSchemaVersion, export.SchemaVersion)
+		assert.InDelta(t, 6, export.WastedInUSD, 0.0001)
+		assert.InDelta(t, 50, export.SharePercent, 0.0001)
+		assert.Len(t, export.Workflows, 3)
+		assert.Len(t, export.Jobs, 4)
+		assert.InDelta(t, 0.5, export.ByKindInUSD[WasteConcurrency], 0.0001)
+	})
+
+	t.Run("UnsupportedFormat", func(t *testing.T) {
+		assert.Error(t, NewWasteGenerator(io.Discard, "xml", 10, logger).Generate(&waste))
+	})
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnauthorized is returned for a missing or rejected token
+var ErrUnauthorized = errors.New("unauthorized")
+
+// Authenticator validates the bearer token of a request and returns who it belongs to.
+// Reports are owned by whoever created them, so only they can change or delete them.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (string, error)
+}
+
+type noAuth struct{}
+
+// NoAuth accepts every request, for servers only reachable by trusted clients
+func NoAuth() Authenticator {
+	return noAuth{}
+}
+
+func (noAuth) Authenticate(ctx context.Context, token string) (string, error) {
+	return "", nil
+}
+
+type tokenAuth struct {
+	tokens map[string]string // principal by token
+}
+
+// LoadTokens reads an authenticator accepting a fixed set of tokens from a file with
+// one "<name> <token>" pair per line. Blank lines and lines starting with # are ignored.
+func LoadTokens(path string) (Authenticator, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tokens file: %w", err)
+	}
+	defer file.Close()
+
+	auth := tokenAuth{tokens: make(map[string]string)}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid tokens file line %d, must be <name> <token>", line)
+		}
+		auth.tokens[fields[1]] = fields[0]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read tokens file: %w", err)
+	}
+	if len(auth.tokens) == 0 {
+		return nil, errors.New("tokens file has no tokens")
+	}
+	return auth, nil
+}
+
+func (a tokenAuth) Authenticate(ctx context.Context, token string) (string, error) {
+	name, ok := a.tokens[token]
+	if !ok || token == "" {
+		return "", ErrUnauthorized
+	}
+	return name, nil
+}
+
+// githubIdentity is a validated GitHub token
+type githubIdentity struct {
+	login   string
+	expires time.Time
+}
+
+type githubAuth struct {
+	httpClient *http.Client
+	apiURL     string
+	orgs       []string
+	ttl        time.Duration
+
+	mu    sync.Mutex
+	cache map[[32]byte]githubIdentity // by token hash
+}
+
+type GitHubAuthConfig struct {
+	APIURL     string        // e.g. https://api.github.com, or https://<host>/api/v3 for GitHub Enterprise Server
+	Orgs       []string      // when set, only members of one of these organizations are accepted
+	CacheTTL   time.Duration // how long a validated token is trusted before checking it again
+	HTTPClient *http.Client  // optional
+}
+
+// NewGitHubAuth creates an authenticator accepting the GitHub tokens the CLI sends,
+// identifying clients by their GitHub login
+func NewGitHubAuth(cfg GitHubAuthConfig) Authenticator {
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &githubAuth{
+		httpClient: httpClient,
+		apiURL:     strings.TrimSuffix(cfg.APIURL, "/"),
+		orgs:       cfg.Orgs,
+		ttl:        cfg.CacheTTL,
+		cache:      make(map[[32]byte]githubIdentity),
+	}
+}
+
+func (a *githubAuth) Authenticate(ctx context.Context, token string) (string, error) {
+	if token == "" {
+		return "", ErrUnauthorized
+	}
+	key := sha256.Sum256([]byte(token))
+	a.mu.Lock()
+	identity, ok := a.cache[key]
+	a.mu.Unlock()
+	if ok && time.Now().Before(identity.expires) {
+		return identity.login, nil
+	}
+
+	var user struct {
+		Login string `json:"login"`
+	}
+	status, err := a.get(ctx, token, "/user", &user)
+	if err != nil {
+		return "", err
+	}
+	if status != http.StatusOK || user.Login == "" {
+		return "", fmt.Errorf("%w: GitHub rejected the token", ErrUnauthorized)
+	}
+
+	if len(a.orgs) > 0 {
+		member := false
+		for _, org := range a.orgs {
+			// 204 when the user is a member, visible to the user's own token even when private
+			status, err := a.get(ctx, token, "/orgs/"+url.PathEscape(org)+"/members/"+url.PathEscape(user.Login), nil)
+			if err != nil {
+				return "", err
+			}
+			if status == http.StatusNoContent {
+				member = true
+				break
+			}
+		}
+		if !member {
+			return "", fmt.Errorf("%w: %s is not a member of %s", ErrUnauthorized, user.Login, strings.Join(a.orgs, ", "))
+		}
+	}
+
+	a.mu.Lock()
+	a.cache[key] = githubIdentity{login: user.Login, expires: time.Now().Add(a.ttl)}
+	a.mu.Unlock()
+	return user.Login, nil
+}
+
+// get calls the GitHub API with the token, decoding a successful response into v when set
+func (a *githubAuth) get(ctx context.Context, token, path string, v any) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.apiURL+path, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to validate token with GitHub: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK && v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			return 0, fmt.Errorf("failed to validate token with GitHub: %w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// viewTokenTTL is how long a signed link to a private report can be opened
+const viewTokenTTL = 24 * time.Hour
+
+// loadViewKey reads the key signing links to private reports from dir, creating it the
+// first time, so links stay valid when the server restarts
+func loadViewKey(dir string) ([]byte, error) {
+	path := filepath.Join(dir, "view.key")
+	key, err := os.ReadFile(path)
+	if err == nil && len(key) == sha256.Size {
+		return key, nil
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read view key: %w", err)
+	}
+	key = make([]byte, sha256.Size)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, key, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write view key: %w", err)
+	}
+	return key, nil
+}
+
+// signView returns a token opening a report in a browser until expires, formatted as
+// "<expiry unix time>.<signature>"
+func signView(key []byte, reportID string, expires time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	return exp + "." + viewSignature(key, reportID, exp)
+}
+
+// validView returns whether a token signed by signView opens a report at now
+func validView(key []byte, reportID, token string, now time.Time) bool {
+	exp, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || !now.Before(time.Unix(unix, 0)) {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(viewSignature(key, reportID, exp)))
+}
+
+func viewSignature(key []byte, reportID, exp string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(reportID + "\n" + exp))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
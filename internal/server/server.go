@@ -0,0 +1,357 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/noamtamir/gh-octoscope/internal/reports"
+	"github.com/rs/zerolog"
+)
+
+// maxBodyBytes is the largest request body accepted, far more than a batch of jobs
+const maxBodyBytes = 64 << 20
+
+// Server is a self-hosted Octoscope API server. It implements the endpoints the report
+// and sync commands upload to, and serves uploaded reports at /report/<report id>:
+//
+//	POST   /report-jobs              {"report_id": "...", "jobs": [...]}
+//	POST   /report-jobs/commit       {"report_id": "...", "batches": ["<idempotency key>", ...], "merge": false}
+//	GET    /report-jobs?report_id=   {"report_id": "...", "jobs": [...]}
+//	DELETE /report-jobs?report_id=
+//	POST   /jobs                     {"jobs": [...]}
+//	GET    /reports                  {"reports": [...]}, the reports created by the token
+//	GET    /reports/{id}             the description of a report created by the token
+//	PATCH  /reports/{id}             {"expires_at": "...", "access": "link|private"}
+//	GET    /report/{id}              the HTML report
+//	GET    /healthz
+//
+// API requests are authenticated with their bearer token, while reports are shared by
+// their unguessable ID like on the hosted server. Reports shared as private are only
+// shown to their creator: to API requests authenticated as them, and to browsers opening
+// the signed link the server describes the report with, valid for a day. Expired reports
+// aren't shown. Jobs uploaded by sync are kept for other tools to read from the data
+// directory, the API doesn't serve them back.
+//
+// Batches sent with an Idempotency-Key header are applied once, however often they're
+// retried. A report whose first batch had a key is pending, and only published when the
+// client commits it with the keys of all its batches, so a failed upload is never shown
+// as a partial report. Committing a report with missing batches responds 409 with them
+// in {"missing": [...]}. Uploading a published report again keeps it at the same URL:
+// its jobs are replaced by the new upload once committed, or with "merge" the new jobs
+// are added to it, replacing the copies of jobs it already has.
+type Server struct {
+	store  *Store
+	auth   Authenticator
+	logger zerolog.Logger
+	mux    *http.ServeMux
+}
+
+type Config struct {
+	Store  *Store
+	Auth   Authenticator
+	Logger zerolog.Logger
+}
+
+// New creates a new server
+func New(cfg Config) *Server {
+	s := &Server{
+		store:  cfg.Store,
+		auth:   cfg.Auth,
+		logger: cfg.Logger,
+		mux:    http.NewServeMux(),
+	}
+	s.mux.HandleFunc("POST /report-jobs", s.authenticated(s.addReportJobs))
+	s.mux.HandleFunc("POST /report-jobs/commit", s.authenticated(s.commitReport))
+	s.mux.HandleFunc("GET /report-jobs", s.authenticated(s.getReportJobs))
+	s.mux.HandleFunc("DELETE /report-jobs", s.authenticated(s.deleteReport))
+	s.mux.HandleFunc("POST /jobs", s.authenticated(s.addJobs))
+	s.mux.HandleFunc("GET /reports", s.authenticated(s.listReports))
+	s.mux.HandleFunc("GET /reports/{id}", s.authenticated(s.getReport))
+	s.mux.HandleFunc("PATCH /reports/{id}", s.authenticated(s.updateReport))
+	s.mux.HandleFunc("GET /report/{id}", s.viewReport)
+	s.mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// commitPayload is the body of report commits
+type commitPayload struct {
+	ReportID string   `json:"report_id"`
+	Batches  []string `json:"batches"`
+	Merge    bool     `json:"merge,omitempty"`
+}
+
+// jobsPayload is the body of job uploads, and of the jobs of a report
+type jobsPayload struct {
+	ReportID string                   `json:"report_id,omitempty"`
+	Jobs     []reports.FlatJobDetails `json:"jobs"`
+}
+
+// authenticated wraps a handler with token authentication, passing it the principal
+func (s *Server) authenticated(handler func(w http.ResponseWriter, r *http.Request, principal string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		principal, err := s.auth.Authenticate(r.Context(), strings.TrimSpace(token))
+		if err != nil {
+			status := http.StatusUnauthorized
+			if !errors.Is(err, ErrUnauthorized) {
+				// GitHub couldn't be reached, the token may well be valid
+				status = http.StatusBadGateway
+			}
+			s.logger.Debug().Err(err).Str("path", r.URL.Path).Msg("Rejected request")
+			writeError(w, status, err)
+			return
+		}
+		handler(w, r, principal)
+	}
+}
+
+func (s *Server) addReportJobs(w http.ResponseWriter, r *http.Request, principal string) {
+	var payload jobsPayload
+	if !decodePayload(w, r, &payload) {
+		return
+	}
+	if payload.ReportID == "" {
+		writeError(w, http.StatusBadRequest, errors.New("missing report_id"))
+		return
+	}
+	added, err := s.store.AddReportJobs(payload.ReportID, principal, idempotencyKey(r), payload.Jobs)
+	if err != nil {
+		s.writeStoreError(w, err)
+		return
+	}
+	if !added {
+		s.logger.Debug().Str("report_id", payload.ReportID).Msg("Ignored batch received before")
+		writeJSON(w, http.StatusOK, map[string]int{"job_count": len(payload.Jobs)})
+		return
+	}
+	s.logger.Info().Str("report_id", payload.ReportID).Str("principal", principal).Int("job_count", len(payload.Jobs)).Msg("Added report jobs")
+	writeJSON(w, http.StatusCreated, map[string]int{"job_count": len(payload.Jobs)})
+}
+
+func (s *Server) commitReport(w http.ResponseWriter, r *http.Request, principal string) {
+	var payload commitPayload
+	if !decodePayload(w, r, &payload) {
+		return
+	}
+	missing, err := s.store.CommitReport(payload.ReportID, principal, payload.Batches, payload.Merge)
+	if err != nil {
+		s.writeStoreError(w, err)
+		return
+	}
+	if len(missing) > 0 {
+		writeJSON(w, http.StatusConflict, map[string]any{"error": "report is missing batches", "missing": missing})
+		return
+	}
+	s.logger.Info().Str("report_id", payload.ReportID).Str("principal", principal).Int("batch_count", len(payload.Batches)).Msg("Committed report")
+	writeJSON(w, http.StatusOK, map[string]int{"batch_count": len(payload.Batches)})
+}
+
+func (s *Server) getReportJobs(w http.ResponseWriter, r *http.Request, principal string) {
+	reportID := r.URL.Query().Get("report_id")
+	_, jobs, err := s.store.ReadableReport(reportID, principal, "")
+	if err != nil {
+		s.writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, jobsPayload{ReportID: reportID, Jobs: jobs})
+}
+
+func (s *Server) deleteReport(w http.ResponseWriter, r *http.Request, principal string) {
+	reportID := r.URL.Query().Get("report_id")
+	if err := s.store.DeleteReport(reportID, principal); err != nil {
+		s.writeStoreError(w, err)
+		return
+	}
+	s.logger.Info().Str("report_id", reportID).Str("principal", principal).Msg("Deleted report")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) addJobs(w http.ResponseWriter, r *http.Request, principal string) {
+	var payload jobsPayload
+	if !decodePayload(w, r, &payload) {
+		return
+	}
+	added, err := s.store.AddJobs(idempotencyKey(r), payload.Jobs)
+	if err != nil {
+		s.writeStoreError(w, err)
+		return
+	}
+	if !added {
+		s.logger.Debug().Msg("Ignored synced batch received before")
+		writeJSON(w, http.StatusOK, map[string]int{"job_count": len(payload.Jobs)})
+		return
+	}
+	s.logger.Info().Str("principal", principal).Int("job_count", len(payload.Jobs)).Msg("Added synced jobs")
+	writeJSON(w, http.StatusCreated, map[string]int{"job_count": len(payload.Jobs)})
+}
+
+func (s *Server) listReports(w http.ResponseWriter, r *http.Request, principal string) {
+	metas, err := s.store.Reports(principal)
+	if err != nil {
+		s.writeStoreError(w, err)
+		return
+	}
+	infos := make([]reports.ReportInfo, len(metas))
+	for i, meta := range metas {
+		infos[i] = s.store.Info(meta)
+	}
+	writeJSON(w, http.StatusOK, map[string][]reports.ReportInfo{"reports": infos})
+}
+
+func (s *Server) getReport(w http.ResponseWriter, r *http.Request, principal string) {
+	meta, err := s.store.Meta(r.PathValue("id"))
+	if err == nil && meta.CreatedBy != principal {
+		err = ErrForbidden
+	}
+	if err != nil {
+		s.writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.store.Info(meta))
+}
+
+func (s *Server) updateReport(w http.ResponseWriter, r *http.Request, principal string) {
+	var update ReportUpdate
+	if !decodePayload(w, r, &update) {
+		return
+	}
+	reportID := r.PathValue("id")
+	meta, err := s.store.UpdateReport(reportID, principal, update)
+	if err != nil {
+		s.writeStoreError(w, err)
+		return
+	}
+	s.logger.Info().Str("report_id", reportID).Str("principal", principal).Msg("Updated report")
+	writeJSON(w, http.StatusOK, s.store.Info(meta))
+}
+
+func (s *Server) viewReport(w http.ResponseWriter, r *http.Request) {
+	reportID := r.PathValue("id")
+	_, flat, err := s.store.ReadableReport(reportID, s.principal(r), r.URL.Query().Get("token"))
+	switch {
+	case errors.Is(err, ErrReportPending):
+		http.Error(w, "Report is still being uploaded", http.StatusNotFound)
+		return
+	case errors.Is(err, ErrReportNotFound):
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return
+	case errors.Is(err, ErrReportExpired):
+		http.Error(w, "Report expired", http.StatusGone)
+		return
+	case err != nil:
+		s.logger.Error().Err(err).Str("report_id", reportID).Msg("Failed to read report")
+		http.Error(w, "Failed to read report", http.StatusInternalServerError)
+		return
+	}
+
+	data := &reports.ReportData{}
+	owner, repo := "", ""
+	for i, fj := range flat {
+		job := reports.UnflattenJob(fj)
+		data.Jobs = append(data.Jobs, job)
+		data.Totals.JobDuration += job.JobDuration
+		data.Totals.RoundedUpJobDuration += job.RoundedUpJobDuration
+		data.Totals.BillableInUSD += job.BillableInUSD
+
+		// Title the report after its repository, unless it spans several
+		jobOwner, jobRepo := job.Repo.GetOwner().GetLogin(), job.Repo.GetName()
+		if i == 0 {
+			owner, repo = jobOwner, jobRepo
+		} else if jobOwner != owner || jobRepo != repo {
+			owner, repo = "", ""
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := reports.NewHTMLGeneratorForWriter(&buf, owner, repo, reportID, s.logger).Generate(data); err != nil {
+		s.logger.Error().Err(err).Str("report_id", reportID).Msg("Failed to render report")
+		http.Error(w, "Failed to render report", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(buf.Bytes())
+}
+
+// principal returns who a request to a public endpoint is authenticated as, empty when
+// it has no valid bearer token, like requests of browsers
+func (s *Server) principal(r *http.Request) string {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return ""
+	}
+	principal, err := s.auth.Authenticate(r.Context(), strings.TrimSpace(token))
+	if err != nil {
+		return ""
+	}
+	return principal
+}
+
+// idempotencyKey returns the idempotency key of a request, empty when it has none
+func idempotencyKey(r *http.Request) string {
+	return strings.TrimSpace(r.Header.Get("Idempotency-Key"))
+}
+
+// decodePayload decodes a JSON request body, plain or gzipped, writing an error response
+// when it can't
+func decodePayload(w http.ResponseWriter, r *http.Request, payload any) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	var body io.Reader = r.Body
+	switch encoding := r.Header.Get("Content-Encoding"); encoding {
+	case "", "identity":
+	case "gzip":
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+			return false
+		}
+		defer gz.Close()
+		// Bound the decompressed body as well
+		body = io.LimitReader(gz, maxBodyBytes)
+	default:
+		writeError(w, http.StatusUnsupportedMediaType, fmt.Errorf("unsupported content encoding %q", encoding))
+		return false
+	}
+	if err := json.NewDecoder(body).Decode(payload); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return false
+	}
+	return true
+}
+
+func (s *Server) writeStoreError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrReportNotFound):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, ErrForbidden):
+		writeError(w, http.StatusForbidden, err)
+	case errors.Is(err, ErrReportExpired):
+		writeError(w, http.StatusGone, err)
+	case errors.Is(err, ErrInvalidReportID), errors.Is(err, ErrInvalidAccess):
+		writeError(w, http.StatusBadRequest, err)
+	default:
+		s.logger.Error().Err(err).Msg("Store operation failed")
+		writeError(w, http.StatusInternalServerError, errors.New("internal error"))
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,413 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/noamtamir/gh-octoscope/internal/api"
+	"github.com/noamtamir/gh-octoscope/internal/reports"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serverTestJob returns a completed job of the testowner/testrepo CI workflow
+func serverTestJob(id int64, cost float64) reports.JobDetails {
+	created := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+	return reports.JobDetails{
+		Repo: &github.Repository{
+			ID:    github.Int64(1),
+			Name:  github.String("testrepo"),
+			Owner: &github.User{Login: github.String("testowner")},
+		},
+		Workflow: &github.Workflow{ID: github.Int64(2), Name: github.String("CI")},
+		WorkflowRun: &github.WorkflowRun{
+			ID:         github.Int64(3),
+			HeadBranch: github.String("main"),
+			CreatedAt:  &github.Timestamp{Time: created},
+			Actor:      &github.User{Login: github.String("testactor")},
+		},
+		Job: &github.WorkflowJob{
+			ID:         github.Int64(id),
+			Name:       github.String("build"),
+			Conclusion: github.String("success"),
+			CreatedAt:  &github.Timestamp{Time: created},
+		},
+		JobDuration:          90 * time.Second,
+		RoundedUpJobDuration: 2 * time.Minute,
+		BillableInUSD:        cost,
+		Runner:               "UBUNTU",
+	}
+}
+
+// newTestServer starts a server with a fresh store
+func newTestServer(t *testing.T, auth Authenticator) (*httptest.Server, *Store) {
+	t.Helper()
+	store, err := OpenStore(t.TempDir(), 0)
+	require.NoError(t, err)
+	server := httptest.NewServer(New(Config{Store: store, Auth: auth, Logger: zerolog.New(io.Discard)}))
+	t.Cleanup(server.Close)
+	return server, store
+}
+
+func newTestClient(url, token string) api.OctoscopeClient {
+	return api.NewOctoscopeClient(api.OctoscopeConfig{BaseUrl: url, GitHubToken: token, Logger: zerolog.New(io.Discard)})
+}
+
+func get(t *testing.T, url string) (int, string) {
+	t.Helper()
+	return getAs(t, url, "")
+}
+
+// getAs gets url with a bearer token, or like a browser when token is empty
+func getAs(t *testing.T, url, token string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest("GET", url, nil)
+	require.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestServerWithOctoscopeClient(t *testing.T) {
+	server, store := newTestServer(t, NoAuth())
+	client := newTestClient(server.URL, "")
+	ctx := context.Background()
+
+	first := []reports.JobDetails{serverTestJob(4, 0.016), serverTestJob(5, 0.024)}
+	second := []reports.JobDetails{serverTestJob(5, 0.032)}
+	require.NoError(t, client.BatchCreate(ctx, first, "report-1", false))
+	// A batch sent again is only applied once
+	require.NoError(t, client.BatchCreate(ctx, first, "report-1", false))
+	// A job uploaded again replaces its earlier copy
+	require.NoError(t, client.BatchCreate(ctx, second, "report-1", false))
+
+	// The report isn't published until it's committed with all its batches
+	status, body := get(t, server.URL+"/report/report-1")
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Contains(t, body, "still being uploaded")
+	status, _ = get(t, server.URL+"/report-jobs?report_id=report-1")
+	assert.Equal(t, http.StatusNotFound, status)
+	manifest := reports.NewUploadManifest("report-1", [][]reports.JobDetails{first, second, {serverTestJob(6, 0.008)}}, false)
+	var missing *reports.MissingBatchesError
+	require.ErrorAs(t, client.CommitReport(ctx, manifest), &missing)
+	assert.Equal(t, []string{manifest.Batches[2].Hash}, missing.Hashes)
+	manifest.Batches = manifest.Batches[:2]
+	require.NoError(t, client.CommitReport(ctx, manifest))
+
+	meta, jobs, err := store.Report("report-1")
+	require.NoError(t, err)
+	assert.Equal(t, "report-1", meta.ReportID)
+	assert.Len(t, meta.Batches, 2)
+	require.Len(t, jobs, 2)
+	assert.Equal(t, 0.032, *jobs[1].BillableInUSD)
+
+	status, body = get(t, server.URL+"/report/report-1")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "GitHub Actions cost report: testowner/testrepo")
+	assert.Contains(t, body, "$0.05")
+
+	require.NoError(t, client.SyncJobs(ctx, []reports.JobDetails{serverTestJob(6, 0.008)}, false))
+	require.NoError(t, client.SyncJobs(ctx, []reports.JobDetails{serverTestJob(6, 0.008)}, false))
+	synced, err := store.Jobs()
+	require.NoError(t, err)
+	assert.Len(t, synced, 1)
+	keys, err := os.ReadFile(filepath.Join(store.dir, "jobs.keys"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(keys), "\n"))
+	// Synced jobs aren't served back
+	status, _ = get(t, server.URL+"/jobs")
+	assert.Equal(t, http.StatusMethodNotAllowed, status)
+
+	require.NoError(t, client.DeleteReport(ctx, "report-1"))
+	status, _ = get(t, server.URL+"/report/report-1")
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.ErrorContains(t, client.DeleteReport(ctx, "report-1"), "status=404")
+
+	assert.ErrorContains(t, client.BatchCreate(ctx, []reports.JobDetails{serverTestJob(4, 0.016)}, "../escape", false), "status=400")
+}
+
+func TestServerTokenAuth(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens")
+	require.NoError(t, os.WriteFile(path, []byte("# CI uploaders\nalice alice-token\n\nbob bob-token\n"), 0600))
+	auth, err := LoadTokens(path)
+	require.NoError(t, err)
+
+	server, store := newTestServer(t, auth)
+	ctx := context.Background()
+	alice, bob := newTestClient(server.URL, "alice-token"), newTestClient(server.URL, "bob-token")
+
+	assert.ErrorContains(t, newTestClient(server.URL, "").SyncJobs(ctx, []reports.JobDetails{serverTestJob(4, 1)}, false), "status=401")
+	assert.ErrorContains(t, newTestClient(server.URL, "wrong").SyncJobs(ctx, []reports.JobDetails{serverTestJob(4, 1)}, false), "status=401")
+
+	require.NoError(t, alice.BatchCreate(ctx, []reports.JobDetails{serverTestJob(4, 1)}, "report-1", false))
+	meta, _, err := store.Report("report-1")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", meta.CreatedBy)
+
+	// Only the creator of a report can change or delete it, anyone with its ID can view it
+	manifest := reports.NewUploadManifest("report-1", [][]reports.JobDetails{{serverTestJob(4, 1)}}, false)
+	assert.ErrorContains(t, bob.CommitReport(ctx, manifest), "status=403")
+	require.NoError(t, alice.CommitReport(ctx, manifest))
+	assert.ErrorContains(t, bob.BatchCreate(ctx, []reports.JobDetails{serverTestJob(5, 1)}, "report-1", false), "status=403")
+	assert.ErrorContains(t, bob.DeleteReport(ctx, "report-1"), "status=403")
+	status, _ := get(t, server.URL+"/report/report-1")
+	assert.Equal(t, http.StatusOK, status)
+	require.NoError(t, alice.DeleteReport(ctx, "report-1"))
+
+	t.Run("InvalidFile", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte("alice\n"), 0600))
+		_, err := LoadTokens(path)
+		assert.ErrorContains(t, err, "invalid tokens file line 1")
+	})
+}
+
+func TestServerGitHubAuth(t *testing.T) {
+	userCalls := 0
+	gitHubAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		switch {
+		case r.URL.Path == "/user":
+			userCalls++
+			switch token {
+			case "alice-token":
+				_, _ = w.Write([]byte(`{"login": "alice"}`))
+			case "bob-token":
+				_, _ = w.Write([]byte(`{"login": "bob"}`))
+			default:
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		case r.URL.Path == "/orgs/acme/members/alice":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer gitHubAPI.Close()
+
+	server, store := newTestServer(t, NewGitHubAuth(GitHubAuthConfig{APIURL: gitHubAPI.URL, Orgs: []string{"acme"}, CacheTTL: time.Minute}))
+	ctx := context.Background()
+
+	alice := newTestClient(server.URL, "alice-token")
+	require.NoError(t, alice.BatchCreate(ctx, []reports.JobDetails{serverTestJob(4, 1)}, "report-1", false))
+	require.NoError(t, alice.BatchCreate(ctx, []reports.JobDetails{serverTestJob(5, 1)}, "report-1", false))
+	// The token is validated once and then cached
+	assert.Equal(t, 1, userCalls)
+	meta, _, err := store.Report("report-1")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", meta.CreatedBy)
+
+	assert.ErrorContains(t, newTestClient(server.URL, "bob-token").SyncJobs(ctx, []reports.JobDetails{serverTestJob(4, 1)}, false), "bob is not a member of acme")
+	assert.ErrorContains(t, newTestClient(server.URL, "stolen").SyncJobs(ctx, []reports.JobDetails{serverTestJob(4, 1)}, false), "status=401")
+}
+
+func TestServerReportLifecycle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens")
+	require.NoError(t, os.WriteFile(path, []byte("alice alice-token\nbob bob-token\n"), 0600))
+	auth, err := LoadTokens(path)
+	require.NoError(t, err)
+	store, err := OpenStore(t.TempDir(), 24*time.Hour)
+	require.NoError(t, err)
+	server := httptest.NewServer(New(Config{Store: store, Auth: auth, Logger: zerolog.New(io.Discard)}))
+	defer server.Close()
+
+	ctx := context.Background()
+	alice, bob := newTestClient(server.URL, "alice-token"), newTestClient(server.URL, "bob-token")
+	jobs := []reports.JobDetails{serverTestJob(4, 1), serverTestJob(5, 1)}
+	require.NoError(t, alice.BatchCreate(ctx, jobs, "report-1", false))
+	require.NoError(t, alice.CommitReport(ctx, reports.NewUploadManifest("report-1", [][]reports.JobDetails{jobs}, false)))
+
+	// Reports are listed and described to their creator only
+	infos, err := alice.ListReports(ctx)
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	info := infos[0]
+	assert.Equal(t, "report-1", info.ReportID)
+	assert.Equal(t, "testowner/testrepo", info.Repo)
+	assert.Equal(t, 2, info.JobCount)
+	assert.Equal(t, reports.AccessLink, info.Access)
+	require.NotNil(t, info.ExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), *info.ExpiresAt, time.Minute)
+	infos, err = bob.ListReports(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, infos)
+	_, err = bob.GetReport(ctx, "report-1")
+	assert.ErrorContains(t, err, "status=403")
+	_, err = alice.GetReport(ctx, "missing")
+	assert.ErrorContains(t, err, "status=404")
+
+	// Private reports are only shown to their creator
+	_, err = bob.ShareReport(ctx, "report-1", reports.AccessPrivate)
+	assert.ErrorContains(t, err, "status=403")
+	_, err = alice.ShareReport(ctx, "report-1", "public")
+	assert.ErrorContains(t, err, "status=400")
+	shared, err := alice.ShareReport(ctx, "report-1", reports.AccessPrivate)
+	require.NoError(t, err)
+	assert.Equal(t, reports.AccessPrivate, shared.Access)
+	require.NotEmpty(t, shared.ViewToken)
+	status, _ := get(t, server.URL+"/report/report-1")
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = getAs(t, server.URL+"/report/report-1", "alice-token")
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = getAs(t, server.URL+"/report-jobs?report_id=report-1", "bob-token")
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = getAs(t, server.URL+"/report-jobs?report_id=report-1", "alice-token")
+	assert.Equal(t, http.StatusOK, status)
+
+	// Browsers open private reports with the signed link of their creator
+	status, _ = get(t, server.URL+"/report/report-1?token="+url.QueryEscape(shared.ViewToken))
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = get(t, server.URL+"/report/report-1?token="+url.QueryEscape(shared.ViewToken+"x"))
+	assert.Equal(t, http.StatusNotFound, status)
+	expired := signView(store.viewKey, "report-1", time.Now().Add(-time.Second))
+	status, _ = get(t, server.URL+"/report/report-1?token="+url.QueryEscape(expired))
+	assert.Equal(t, http.StatusNotFound, status)
+	// Links of one report don't open another
+	assert.False(t, validView(store.viewKey, "report-2", shared.ViewToken, time.Now()))
+
+	// Expired reports aren't shown until they're extended
+	_, err = alice.ShareReport(ctx, "report-1", reports.AccessLink)
+	require.NoError(t, err)
+	extended, err := alice.ExtendReport(ctx, "report-1", time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	assert.True(t, extended.Expired(time.Now()))
+	status, _ = get(t, server.URL+"/report/report-1")
+	assert.Equal(t, http.StatusGone, status)
+	status, _ = getAs(t, server.URL+"/report-jobs?report_id=report-1", "bob-token")
+	assert.Equal(t, http.StatusGone, status)
+	expiresAt := time.Now().Add(48 * time.Hour).UTC().Truncate(time.Second)
+	extended, err = alice.ExtendReport(ctx, "report-1", expiresAt)
+	require.NoError(t, err)
+	assert.True(t, expiresAt.Equal(*extended.ExpiresAt))
+	status, _ = get(t, server.URL+"/report/report-1")
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestServerRefreshReport(t *testing.T) {
+	server, store := newTestServer(t, NoAuth())
+	client := newTestClient(server.URL, "")
+	ctx := context.Background()
+
+	upload := func(jobs []reports.JobDetails, merge bool) *reports.UploadManifest {
+		t.Helper()
+		require.NoError(t, client.BatchCreate(ctx, jobs, "weekly", false))
+		manifest := reports.NewUploadManifest("weekly", [][]reports.JobDetails{jobs}, false)
+		manifest.Merge = merge
+		return manifest
+	}
+	jobIDs := func() []int64 {
+		t.Helper()
+		_, jobs, err := store.Report("weekly")
+		require.NoError(t, err)
+		var ids []int64
+		for _, job := range jobs {
+			ids = append(ids, *job.JobID)
+		}
+		return ids
+	}
+
+	require.NoError(t, client.CommitReport(ctx, upload([]reports.JobDetails{serverTestJob(4, 1), serverTestJob(5, 1)}, false)))
+
+	// A new upload to the report is staged, the report keeps showing its data until it's committed
+	manifest := upload([]reports.JobDetails{serverTestJob(5, 2), serverTestJob(6, 1)}, true)
+	assert.Equal(t, []int64{4, 5}, jobIDs())
+	status, body := get(t, server.URL+"/report/weekly")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "$2.00")
+
+	// Merging adds the new jobs and replaces the ones the report has
+	require.NoError(t, client.CommitReport(ctx, manifest))
+	assert.Equal(t, []int64{4, 5, 6}, jobIDs())
+	_, body = get(t, server.URL+"/report/weekly")
+	assert.Contains(t, body, "$4.00")
+	info, err := client.GetReport(ctx, "weekly")
+	require.NoError(t, err)
+	assert.Equal(t, 3, info.JobCount)
+	require.NotNil(t, info.RefreshedAt)
+	// Committing again changes nothing
+	require.NoError(t, client.CommitReport(ctx, manifest))
+	assert.Equal(t, []int64{4, 5, 6}, jobIDs())
+
+	// Without merging, the report is replaced by the new upload, even with the same jobs
+	require.NoError(t, client.CommitReport(ctx, upload([]reports.JobDetails{serverTestJob(6, 1)}, false)))
+	assert.Equal(t, []int64{6}, jobIDs())
+
+	// A staged upload missing batches isn't applied
+	upload([]reports.JobDetails{serverTestJob(7, 1)}, true)
+	manifest = reports.NewUploadManifest("weekly", [][]reports.JobDetails{{serverTestJob(7, 1)}, {serverTestJob(8, 1)}}, false)
+	var missing *reports.MissingBatchesError
+	require.ErrorAs(t, client.CommitReport(ctx, manifest), &missing)
+	assert.Equal(t, []int64{6}, jobIDs())
+
+	require.NoError(t, client.DeleteReport(ctx, "weekly"))
+	_, err = os.Stat(store.stagedDir("weekly"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestStoreDropsAbortedUploads(t *testing.T) {
+	store, err := OpenStore(t.TempDir(), 0)
+	require.NoError(t, err)
+	flat := func(ids ...int64) []reports.FlatJobDetails {
+		var jobs []reports.FlatJobDetails
+		for _, id := range ids {
+			jobs = append(jobs, reports.FlattenJob(serverTestJob(id, 1), false))
+		}
+		return jobs
+	}
+	upload := func(key string, ids ...int64) {
+		t.Helper()
+		added, err := store.AddReportJobs("weekly", "", key, flat(ids...))
+		require.NoError(t, err)
+		assert.True(t, added)
+	}
+	jobIDs := func() []int64 {
+		t.Helper()
+		_, jobs, err := store.Report("weekly")
+		require.NoError(t, err)
+		var ids []int64
+		for _, job := range jobs {
+			ids = append(ids, *job.JobID)
+		}
+		return ids
+	}
+
+	upload("first", 1, 2)
+	missing, err := store.CommitReport("weekly", "", []string{"first"}, false)
+	require.NoError(t, err)
+	assert.Empty(t, missing)
+
+	// An upload aborted after its first batch is never committed
+	upload("aborted", 3)
+	// The next upload replaces the report with its own jobs only
+	upload("second-a", 4)
+	upload("second-b", 5)
+	missing, err = store.CommitReport("weekly", "", []string{"second-a", "second-b"}, false)
+	require.NoError(t, err)
+	assert.Empty(t, missing)
+	assert.Equal(t, []int64{4, 5}, jobIDs())
+
+	// Batches of the aborted upload don't come back with a later refresh either
+	upload("third", 6)
+	missing, err = store.CommitReport("weekly", "", []string{"third"}, true)
+	require.NoError(t, err)
+	assert.Empty(t, missing)
+	assert.Equal(t, []int64{4, 5, 6}, jobIDs())
+	meta, err := store.Meta("weekly")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"third"}, meta.Batches)
+	assert.Empty(t, meta.Staged)
+}
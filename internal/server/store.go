@@ -0,0 +1,505 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/noamtamir/gh-octoscope/internal/reports"
+)
+
+var (
+	// ErrReportNotFound is returned for a report that was never uploaded or was deleted
+	ErrReportNotFound = errors.New("report not found")
+	// ErrForbidden is returned when a report is changed by someone else than its creator
+	ErrForbidden = errors.New("report belongs to someone else")
+	// ErrReportExpired is returned when reading a report past its expiry
+	ErrReportExpired = errors.New("report expired")
+	// ErrReportPending is returned when reading a report whose upload wasn't committed yet
+	ErrReportPending = fmt.Errorf("%w, it is still being uploaded", ErrReportNotFound)
+	// ErrInvalidReportID is returned for report IDs that aren't letters, digits, - and _
+	ErrInvalidReportID = errors.New("invalid report ID")
+	// ErrInvalidAccess is returned for access settings other than reports.ReportAccesses
+	ErrInvalidAccess = errors.New("invalid access")
+)
+
+// ReportMeta describes an uploaded report. Pending reports were started by a client
+// committing its uploads, and aren't published until the client commits them.
+type ReportMeta struct {
+	reports.ReportInfo
+	CreatedBy string    `json:"created_by,omitempty"` // empty when the server doesn't authenticate
+	UpdatedAt time.Time `json:"updated_at"`
+	Batches   []string  `json:"batches,omitempty"` // idempotency keys of the received batches
+	// Staged are the idempotency keys of the batches of new uploads to a published report,
+	// kept apart from its jobs until an upload is committed. Only the batches of the
+	// committed upload are applied, batches of aborted uploads are dropped.
+	Staged []string `json:"staged,omitempty"`
+}
+
+// ReportUpdate changes the settings of a report, leaving nil fields unchanged
+type ReportUpdate struct {
+	ExpiresAt *time.Time `json:"expires_at"`
+	Access    *string    `json:"access"`
+}
+
+// Store keeps the jobs uploaded to the server in a local data directory:
+//
+//	<dir>/reports/<report id>.ndjson     jobs of a report, one flat job per line
+//	<dir>/reports/<report id>.meta.json  who created the report and when, its jobs and settings
+//	<dir>/reports/<report id>.staged/    batches of new uploads to a published report
+//	<dir>/jobs.ndjson                    jobs uploaded by sync
+//	<dir>/jobs.keys                      idempotency keys of the batches uploaded by sync
+//	<dir>/view.key                       key signing links to private reports
+//
+// Batches are appended, and a job uploaded again replaces its earlier copy when read.
+// A batch sent again with the same idempotency key isn't appended again.
+type Store struct {
+	dir       string
+	reportTTL time.Duration
+	viewKey   []byte
+	mu        sync.Mutex
+}
+
+// OpenStore opens the store in dir, creating the directory when it doesn't exist. New
+// reports expire after reportTTL, or never when it's zero.
+func OpenStore(dir string, reportTTL time.Duration) (*Store, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "reports"), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+	viewKey, err := loadViewKey(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir, reportTTL: reportTTL, viewKey: viewKey}, nil
+}
+
+// AddReportJobs appends jobs to a report, creating it on the first batch. Batches with an
+// idempotency key sent to a published report are staged until they're committed, so the
+// report keeps showing its earlier data while it's uploaded again. It returns false when a
+// batch with the same idempotency key was already received.
+func (s *Store) AddReportJobs(reportID, principal, key string, jobs []reports.FlatJobDetails) (bool, error) {
+	if !reports.ValidReportID(reportID) {
+		return false, fmt.Errorf("%w %q", ErrInvalidReportID, reportID)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	meta, err := s.readMeta(reportID)
+	switch {
+	case errors.Is(err, ErrReportNotFound):
+		meta = ReportMeta{CreatedBy: principal}
+		meta.ReportID, meta.CreatedAt, meta.Access, meta.Pending = reportID, now, reports.AccessLink, key != ""
+		if s.reportTTL > 0 {
+			expiresAt := now.Add(s.reportTTL)
+			meta.ExpiresAt = &expiresAt
+		}
+	case err != nil:
+		return false, err
+	case meta.CreatedBy != principal:
+		return false, ErrForbidden
+	case key != "" && !meta.Pending:
+		if slices.Contains(meta.Staged, key) {
+			return false, nil
+		}
+		if err := os.MkdirAll(s.stagedDir(reportID), 0700); err != nil {
+			return false, err
+		}
+		if err := appendJobs(s.stagedPath(reportID, key), jobs); err != nil {
+			return false, err
+		}
+		meta.Staged = append(meta.Staged, key)
+		meta.UpdatedAt = now
+		return true, s.writeMeta(meta)
+	case key != "" && slices.Contains(meta.Batches, key):
+		return false, nil
+	}
+	meta.UpdatedAt = now
+	if key != "" {
+		meta.Batches = append(meta.Batches, key)
+	}
+
+	if err := appendJobs(s.reportPath(reportID), jobs); err != nil {
+		return false, err
+	}
+	// Pending reports are described once they're committed, so uploads don't reread them
+	if !meta.Pending {
+		if err := s.describe(&meta); err != nil {
+			return false, err
+		}
+	}
+	return true, s.writeMeta(meta)
+}
+
+// CommitReport publishes a pending report once every batch of the client's manifest was
+// received. It returns the keys of the missing batches otherwise. Committing a new upload
+// to a published report replaces its jobs with the staged ones, or when merging, appends
+// them to its jobs, replacing the jobs it already has.
+func (s *Store) CommitReport(reportID, principal string, batches []string, merge bool) ([]string, error) {
+	if !reports.ValidReportID(reportID) {
+		return nil, ErrReportNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	meta, err := s.readMeta(reportID)
+	if err != nil {
+		return nil, err
+	}
+	if meta.CreatedBy != principal {
+		return nil, ErrForbidden
+	}
+	// A published report without staged batches was committed before
+	staged := !meta.Pending && len(meta.Staged) > 0
+	received := meta.Batches
+	if staged {
+		received = meta.Staged
+	}
+	var missing []string
+	for _, key := range batches {
+		if !slices.Contains(received, key) {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		return missing, nil
+	}
+
+	now := time.Now().UTC()
+	if staged {
+		if err := s.applyStaged(reportID, batches, merge); err != nil {
+			return nil, err
+		}
+		meta.Batches, meta.Staged = batches, nil
+		meta.RefreshedAt = &now
+	}
+	meta.Pending = false
+	meta.UpdatedAt = now
+	if err := s.describe(&meta); err != nil {
+		return nil, err
+	}
+	return nil, s.writeMeta(meta)
+}
+
+// Meta returns the description of a report
+func (s *Store) Meta(reportID string) (ReportMeta, error) {
+	if !reports.ValidReportID(reportID) {
+		return ReportMeta{}, ErrReportNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.readMeta(reportID)
+}
+
+// Reports returns the reports created by principal, from the most recently created
+func (s *Store) Reports(principal string) ([]ReportMeta, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	paths, err := filepath.Glob(filepath.Join(s.dir, "reports", "*.meta.json"))
+	if err != nil {
+		return nil, err
+	}
+	var metas []ReportMeta
+	for _, path := range paths {
+		meta, err := s.readMeta(strings.TrimSuffix(filepath.Base(path), ".meta.json"))
+		if err != nil {
+			return nil, err
+		}
+		if meta.CreatedBy == principal {
+			metas = append(metas, meta)
+		}
+	}
+	sort.SliceStable(metas, func(i, j int) bool {
+		return metas[i].CreatedAt.After(metas[j].CreatedAt)
+	})
+	return metas, nil
+}
+
+// UpdateReport changes the expiry or access of a report, only its creator may change them
+func (s *Store) UpdateReport(reportID, principal string, update ReportUpdate) (ReportMeta, error) {
+	if update.Access != nil && !slices.Contains(reports.ReportAccesses, *update.Access) {
+		return ReportMeta{}, fmt.Errorf("%w %q, must be one of: %s", ErrInvalidAccess, *update.Access, strings.Join(reports.ReportAccesses, ", "))
+	}
+	if !reports.ValidReportID(reportID) {
+		return ReportMeta{}, ErrReportNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	meta, err := s.readMeta(reportID)
+	if err != nil {
+		return ReportMeta{}, err
+	}
+	if meta.CreatedBy != principal {
+		return ReportMeta{}, ErrForbidden
+	}
+	if update.ExpiresAt != nil {
+		expiresAt := update.ExpiresAt.UTC()
+		meta.ExpiresAt = &expiresAt
+	}
+	if update.Access != nil {
+		meta.Access = *update.Access
+	}
+	meta.UpdatedAt = time.Now().UTC()
+	return meta, s.writeMeta(meta)
+}
+
+// ReadableReport returns a report and its jobs when principal may read them: the report
+// was committed, hasn't expired, and is shared by link or was created by principal. A
+// valid view token, signed by Info, opens a private report as well.
+func (s *Store) ReadableReport(reportID, principal, viewToken string) (ReportMeta, []reports.FlatJobDetails, error) {
+	meta, jobs, err := s.Report(reportID)
+	switch {
+	case err != nil:
+		return ReportMeta{}, nil, err
+	case meta.Pending:
+		return ReportMeta{}, nil, ErrReportPending
+	case meta.Access == reports.AccessPrivate && meta.CreatedBy != principal && !validView(s.viewKey, reportID, viewToken, time.Now()):
+		// Private reports aren't revealed to anyone else
+		return ReportMeta{}, nil, ErrReportNotFound
+	case meta.Expired(time.Now()):
+		return ReportMeta{}, nil, ErrReportExpired
+	}
+	return meta, jobs, nil
+}
+
+// Info describes a report to its creator. Private reports come with a view token,
+// opening them in a browser for viewTokenTTL.
+func (s *Store) Info(meta ReportMeta) reports.ReportInfo {
+	info := meta.ReportInfo
+	if info.Access == reports.AccessPrivate {
+		info.ViewToken = signView(s.viewKey, meta.ReportID, time.Now().Add(viewTokenTTL))
+	}
+	return info
+}
+
+// Report returns a report and its jobs
+func (s *Store) Report(reportID string) (ReportMeta, []reports.FlatJobDetails, error) {
+	if !reports.ValidReportID(reportID) {
+		return ReportMeta{}, nil, ErrReportNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	meta, err := s.readMeta(reportID)
+	if err != nil {
+		return ReportMeta{}, nil, err
+	}
+	jobs, err := readJobs(s.reportPath(reportID))
+	return meta, jobs, err
+}
+
+// DeleteReport deletes a report, only its creator may delete it
+func (s *Store) DeleteReport(reportID, principal string) error {
+	if !reports.ValidReportID(reportID) {
+		return ErrReportNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	meta, err := s.readMeta(reportID)
+	if err != nil {
+		return err
+	}
+	if meta.CreatedBy != principal {
+		return ErrForbidden
+	}
+	if err := os.Remove(s.reportPath(reportID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.RemoveAll(s.stagedDir(reportID)); err != nil {
+		return err
+	}
+	return os.Remove(s.metaPath(reportID))
+}
+
+// AddJobs appends jobs uploaded by sync. It returns false when a batch with the same
+// idempotency key was already received.
+func (s *Store) AddJobs(key string, jobs []reports.FlatJobDetails) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keysPath := filepath.Join(s.dir, "jobs.keys")
+	if key != "" {
+		content, err := os.ReadFile(keysPath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return false, err
+		}
+		if slices.Contains(strings.Split(string(content), "\n"), key) {
+			return false, nil
+		}
+	}
+	if err := appendJobs(filepath.Join(s.dir, "jobs.ndjson"), jobs); err != nil {
+		return false, err
+	}
+	if key == "" {
+		return true, nil
+	}
+	return true, appendFile(keysPath, []byte(key+"\n"))
+}
+
+// Jobs returns the jobs uploaded by sync
+func (s *Store) Jobs() ([]reports.FlatJobDetails, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return readJobs(filepath.Join(s.dir, "jobs.ndjson"))
+}
+
+func (s *Store) reportPath(reportID string) string {
+	return filepath.Join(s.dir, "reports", reportID+".ndjson")
+}
+
+func (s *Store) metaPath(reportID string) string {
+	return filepath.Join(s.dir, "reports", reportID+".meta.json")
+}
+
+func (s *Store) stagedDir(reportID string) string {
+	return filepath.Join(s.dir, "reports", reportID+".staged")
+}
+
+// stagedPath is the file of a staged batch, named after the hash of its idempotency key
+// since clients choose the keys
+func (s *Store) stagedPath(reportID, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.stagedDir(reportID), hex.EncodeToString(sum[:])+".ndjson")
+}
+
+// applyStaged replaces the jobs of a report with the staged batches of the committed
+// upload, or merges them into its jobs, and drops every staged batch
+func (s *Store) applyStaged(reportID string, batches []string, merge bool) error {
+	var content []byte
+	for _, key := range batches {
+		batch, err := os.ReadFile(s.stagedPath(reportID, key))
+		if err != nil {
+			return err
+		}
+		content = append(content, batch...)
+	}
+
+	if merge {
+		if err := appendFile(s.reportPath(reportID), content); err != nil {
+			return err
+		}
+	} else {
+		tmp := s.reportPath(reportID) + ".tmp"
+		if err := os.WriteFile(tmp, content, 0600); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, s.reportPath(reportID)); err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(s.stagedDir(reportID))
+}
+
+// describe sets the repository, window and job count of a report from its jobs
+func (s *Store) describe(meta *ReportMeta) error {
+	flat, err := readJobs(s.reportPath(meta.ReportID))
+	if err != nil {
+		return err
+	}
+	jobs := make([]reports.JobDetails, len(flat))
+	for i, fj := range flat {
+		jobs[i] = reports.UnflattenJob(fj)
+	}
+	info := reports.NewReportInfo(meta.ReportID, jobs, meta.CreatedAt)
+	meta.Repo, meta.From, meta.To, meta.JobCount = info.Repo, info.From, info.To, info.JobCount
+	return nil
+}
+
+func (s *Store) writeMeta(meta ReportMeta) error {
+	content, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.metaPath(meta.ReportID), content, 0600)
+}
+
+func (s *Store) readMeta(reportID string) (ReportMeta, error) {
+	var meta ReportMeta
+	content, err := os.ReadFile(s.metaPath(reportID))
+	if errors.Is(err, os.ErrNotExist) {
+		return meta, ErrReportNotFound
+	}
+	if err != nil {
+		return meta, err
+	}
+	if err := json.Unmarshal(content, &meta); err != nil {
+		return meta, fmt.Errorf("failed to read report %s: %w", reportID, err)
+	}
+	if meta.Access == "" {
+		meta.Access = reports.AccessLink
+	}
+	return meta, nil
+}
+
+// appendJobs appends jobs to an ndjson file in a single write
+func appendJobs(path string, jobs []reports.FlatJobDetails) error {
+	var content []byte
+	for _, job := range jobs {
+		line, err := json.Marshal(job)
+		if err != nil {
+			return err
+		}
+		content = append(append(content, line...), '\n')
+	}
+	return appendFile(path, content)
+}
+
+// appendFile appends content to a file in a single write, creating it when it doesn't exist
+func appendFile(path string, content []byte) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// readJobs reads the jobs of an ndjson file, in the order they were first uploaded,
+// keeping the last copy of every job
+func readJobs(path string) ([]reports.FlatJobDetails, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var jobs []reports.FlatJobDetails
+	index := make(map[int64]int)
+	decoder := json.NewDecoder(file)
+	for {
+		var job reports.FlatJobDetails
+		if err := decoder.Decode(&job); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if job.JobID != nil {
+			if i, ok := index[*job.JobID]; ok {
+				jobs[i] = job
+				continue
+			}
+			index[*job.JobID] = len(jobs)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
@@ -54,6 +54,31 @@ func (m *mockGitHubClient) ListWorkflowJobsAttempt(ctx context.Context, runID, a
 	return args.Get(0).(*github.Jobs), args.Error(1)
 }
 
+func (m *mockGitHubClient) ListPullRequestsWithCommit(ctx context.Context, sha string) ([]*github.PullRequest, error) {
+	args := m.Called(ctx, sha)
+	return args.Get(0).([]*github.PullRequest), args.Error(1)
+}
+
+func (m *mockGitHubClient) GetPullRequest(ctx context.Context, number int) (*github.PullRequest, error) {
+	args := m.Called(ctx, number)
+	return args.Get(0).(*github.PullRequest), args.Error(1)
+}
+
+func (m *mockGitHubClient) GetFileContent(ctx context.Context, path string) (string, error) {
+	args := m.Called(ctx, path)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockGitHubClient) ListTeamMembers(ctx context.Context, org, slug string) ([]*github.User, error) {
+	args := m.Called(ctx, org, slug)
+	return args.Get(0).([]*github.User), args.Error(1)
+}
+
+func (m *mockGitHubClient) GetCustomPropertyValues(ctx context.Context) ([]*github.CustomPropertyValue, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]*github.CustomPropertyValue), args.Error(1)
+}
+
 // GetWorkflowRunUsage has been removed since we're now using job labels
 
 // Mock Octoscope API client
@@ -71,11 +96,36 @@ func (m *mockOctoscopeClient) SyncJobs(ctx context.Context, jobs []reports.JobDe
 	return args.Error(0)
 }
 
+func (m *mockOctoscopeClient) CommitReport(ctx context.Context, manifest *reports.UploadManifest) error {
+	args := m.Called(ctx, manifest)
+	return args.Error(0)
+}
+
 func (m *mockOctoscopeClient) DeleteReport(ctx context.Context, reportID string) error {
 	args := m.Called(ctx, reportID)
 	return args.Error(0)
 }
 
+func (m *mockOctoscopeClient) ListReports(ctx context.Context) ([]reports.ReportInfo, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]reports.ReportInfo), args.Error(1)
+}
+
+func (m *mockOctoscopeClient) GetReport(ctx context.Context, reportID string) (*reports.ReportInfo, error) {
+	args := m.Called(ctx, reportID)
+	return args.Get(0).(*reports.ReportInfo), args.Error(1)
+}
+
+func (m *mockOctoscopeClient) ExtendReport(ctx context.Context, reportID string, expiresAt time.Time) (*reports.ReportInfo, error) {
+	args := m.Called(ctx, reportID, expiresAt)
+	return args.Get(0).(*reports.ReportInfo), args.Error(1)
+}
+
+func (m *mockOctoscopeClient) ShareReport(ctx context.Context, reportID, access string) (*reports.ReportInfo, error) {
+	args := m.Called(ctx, reportID, access)
+	return args.Get(0).(*reports.ReportInfo), args.Error(1)
+}
+
 func TestCobraCommands(t *testing.T) {
 	// Save original args
 	oldArgs := os.Args